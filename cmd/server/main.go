@@ -1,25 +1,41 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/psewda/typing"
 	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/breaker"
 	"github.com/psewda/typing/pkg/controllers"
 	ctrlv1 "github.com/psewda/typing/pkg/controllers/v1"
+	"github.com/psewda/typing/pkg/errs"
 	"github.com/psewda/typing/pkg/ioc"
 	"github.com/psewda/typing/pkg/log"
+	"github.com/psewda/typing/pkg/middlewares"
+	"github.com/psewda/typing/pkg/retry"
 	"github.com/psewda/typing/pkg/server"
 	"github.com/psewda/typing/pkg/signin/auth/googleauth"
+	"github.com/psewda/typing/pkg/signin/userinfo"
 	"github.com/psewda/typing/pkg/signin/userinfo/googleuserinfo"
+	"github.com/psewda/typing/pkg/storage/attachstore/drvattachstore"
+	"github.com/psewda/typing/pkg/storage/backup"
+	"github.com/psewda/typing/pkg/storage/cache"
+	"github.com/psewda/typing/pkg/storage/compress"
+	"github.com/psewda/typing/pkg/storage/drvpool"
+	"github.com/psewda/typing/pkg/storage/migrate"
+	"github.com/psewda/typing/pkg/storage/notelock"
 	"github.com/psewda/typing/pkg/storage/notestore/drvnotestore"
+	"github.com/psewda/typing/pkg/storage/offline"
 	"github.com/psewda/typing/pkg/storage/sectionstore/drvsectionstore"
 )
 
@@ -27,6 +43,19 @@ const (
 	envVarPort            = "TYPING_PORT"
 	envVarLogLevel        = "TYPING_LOG_LEVEL"
 	envVarClientCred      = "TYPING_CLIENT_CRED"
+	envVarCompression     = "TYPING_COMPRESSION"
+	envVarMaxRetries      = "TYPING_MAX_RETRIES"
+	envVarCacheRedis      = "TYPING_CACHE_REDIS"
+	envVarReadTimeout     = "TYPING_READ_TIMEOUT"
+	envVarWriteTimeout    = "TYPING_WRITE_TIMEOUT"
+	envVarOfflineDir      = "TYPING_OFFLINE_DIR"
+	envVarSyncPolicy      = "TYPING_SYNC_POLICY"
+	envVarAccessToken     = "TYPING_ACCESS_TOKEN"
+	envVarBackupSchedule  = "TYPING_BACKUP_SCHEDULE"
+	cmdMigrate            = "migrate"
+	cmdBackup             = "backup"
+	cmdRestore            = "restore"
+	cmdUpgrade            = "upgrade"
 	buildTypeDebug        = "DEBUG"
 	buildTypeRelease      = "RELEASE"
 	defaultClientCredFile = "/etc/typing/google_client_cred.json"
@@ -38,6 +67,17 @@ var (
 	clientCred []byte
 	logger     *log.Logger
 	verFlag    bool
+	encoding   string
+	retries    = retry.DefaultConfig()
+	drvBreaker *breaker.Breaker
+	noteLocker = notelock.New(notelock.DefaultTimeout)
+	noteCache  cache.Backend
+	timeouts   = middlewares.DefaultTimeouts()
+	localStore *offline.Store
+	migrations *migrate.Runner
+	schedule   *backup.Schedule
+	target     backup.Target
+	backups    *backup.Scheduler
 )
 
 func init() {
@@ -76,6 +116,76 @@ func init() {
 		p = server.GetRandPort()
 	}
 	port = p
+
+	// set compression of note content
+	encoding = strings.ToLower(strings.TrimSpace(os.Getenv(envVarCompression)))
+	if err := compress.Check(encoding); err != nil {
+		logger.Fatal("error occurred while reading compression", err)
+	}
+
+	// set retry limit of cloud storage calls
+	if r, ok := parseMaxRetries(os.Getenv(envVarMaxRetries)); ok {
+		retries.MaxRetries = r
+	}
+
+	// set deadlines of storage requests in seconds
+	if t, ok := parseTimeout(os.Getenv(envVarReadTimeout)); ok {
+		timeouts.Read = t
+	}
+	if t, ok := parseTimeout(os.Getenv(envVarWriteTimeout)); ok {
+		timeouts.Write = t
+	}
+
+	// set circuit breaker of google drive calls
+	drvBreaker = breaker.New("drive", breaker.DefaultConfig(), logger)
+
+	// set cache of notes, the redis server is used when configured
+	noteCache = cache.NewMemory(cache.DefaultCapacity)
+	if addr := strings.TrimSpace(os.Getenv(envVarCacheRedis)); len(addr) > 0 {
+		r, err := cache.NewRedis(addr)
+		if err != nil {
+			logger.Fatal("error occurred while reading redis address", err)
+		}
+		noteCache = r
+	}
+
+	// set local store of offline mode, the notes are synced in background
+	if dir := strings.TrimSpace(os.Getenv(envVarOfflineDir)); len(dir) > 0 {
+		initLocalStore(dir)
+	}
+
+	// set schedule of automatic backups, the snapshots go to the target
+	if path := strings.TrimSpace(os.Getenv(envVarBackupSchedule)); len(path) > 0 {
+		initSchedule(path)
+	}
+}
+
+func initLocalStore(dir string) {
+	config := offline.DefaultConfig()
+	if policy := strings.ToLower(strings.TrimSpace(os.Getenv(envVarSyncPolicy))); len(policy) > 0 {
+		if err := offline.CheckPolicy(policy); err != nil {
+			logger.Fatal("error occurred while reading sync policy", err)
+		}
+		config.Policy = policy
+	}
+	s, err := offline.New(dir, config)
+	if err != nil {
+		logger.Fatal("error occurred while creating local store", err)
+	}
+	localStore = s
+	migrations = migrate.NewRunner(dir)
+}
+
+func initSchedule(path string) {
+	s, err := backup.ReadSchedule(path)
+	if err != nil {
+		logger.Fatal("error occurred while reading backup schedule", err)
+	}
+	t, err := s.Target()
+	if err != nil {
+		logger.Fatal("error occurred while creating backup target", err)
+	}
+	schedule, target = s, t
 }
 
 func main() {
@@ -86,18 +196,62 @@ func main() {
 		return
 	}
 
+	// run the storage command instead of the server
+	switch flag.Arg(0) {
+	case cmdMigrate:
+		os.Exit(runMigrate(flag.Args()[1:]))
+	case cmdBackup:
+		os.Exit(runBackup(flag.Args()[1:]))
+	case cmdRestore:
+		os.Exit(runRestore(flag.Args()[1:]))
+	case cmdUpgrade:
+		os.Exit(runUpgrade(flag.Args()[1:]))
+	}
+
 	// create new api server
 	server := server.New(true, logger)
 
+	// fail the storage requests fast while drive is down, only attachments
+	// go to drive in offline mode
+	breakerPrefix := "/api/v1/storage"
+	if localStore != nil {
+		breakerPrefix = "/api/v1/storage/notes/:nid/attachments"
+	}
+	server.Use(middlewares.CircuitBreaker(drvBreaker, breakerPrefix))
+
+	// cancel the backend calls of requests taking too long, the requests
+	// streaming all notes get the stream deadline
+	server.Use(middlewares.Timeout(timeouts, "/api/v1",
+		"/api/v1/storage/backup", "/api/v1/storage/restore", "/api/v1/storage/notes/export"))
+
 	// initialize ioc container
 	container := initIoC()
 
 	// register api controllers
 	server.RegisterController(controllers.NewVersionController())
+	server.RegisterController(controllers.NewHealthController(noteLocker, drvBreaker))
 	server.RegisterController(ctrlv1.NewAuthController(container))
 	server.RegisterController(ctrlv1.NewUserinfoController(container))
 	server.RegisterController(ctrlv1.NewNotestoreController(container))
 	server.RegisterController(ctrlv1.NewSectionstoreController(container))
+	server.RegisterController(ctrlv1.NewAttachstoreController(container))
+	server.RegisterController(ctrlv1.NewLinksController(container))
+	server.RegisterController(ctrlv1.NewEncryptionController(container))
+	server.RegisterController(ctrlv1.NewExportController(container))
+	server.RegisterController(ctrlv1.NewImportController(container))
+	server.RegisterController(ctrlv1.NewBackupController(container))
+	if localStore != nil {
+		server.RegisterController(ctrlv1.NewSyncController(container))
+		server.RegisterController(ctrlv1.NewMigrateController(container))
+		stop := localStore.Start()
+		defer stop()
+		defer migrations.Stop()
+	}
+	if backups != nil {
+		server.RegisterController(ctrlv1.NewScheduleController(container))
+		stop := backups.Start()
+		defer stop()
+	}
 
 	// run the api server
 	if err := server.Run(port); err != nil {
@@ -124,6 +278,233 @@ func parseFlags() {
 	flag.Parse()
 }
 
+// runMigrate copies the notes of user between storage backends, and prints
+// the migration report. The interrupted migration resumes on the next run.
+func runMigrate(args []string) int {
+	set := flag.NewFlagSet(cmdMigrate, flag.ContinueOnError)
+	from := set.String("from", migrate.BackendDrive, "source storage backend, 'drive' or 'fs'")
+	to := set.String("to", migrate.BackendFS, "destination storage backend, 'drive' or 'fs'")
+	dryRun := set.Bool("dry-run", false, "report the notes to be migrated without writing them")
+	verify := set.Bool("verify", false, "compare the migrated notes with the source notes")
+	token := set.String("token", os.Getenv(envVarAccessToken), "google access token of user")
+	if err := set.Parse(args); err != nil {
+		return 2
+	}
+	if localStore == nil {
+		logger.Error("error occurred while reading fs backend", errors.New(envVarOfflineDir+" is not set"))
+		return 1
+	}
+	if len(*token) == 0 {
+		logger.Error("error occurred while reading access token", errors.New("access token is empty"))
+		return 1
+	}
+
+	// the interrupt stops the migration after the current note
+	ctx := interruptible()
+	pool := drvpool.New(drvpool.DefaultTTL, retries.Wrap)
+	ui, backends, err := migrateBackends(pool, *token)
+	if err != nil {
+		logger.Error("error occurred while creating storage backends", err)
+		return 1
+	}
+	options := migrate.Options{DryRun: *dryRun, Verify: *verify}
+	report, err := migrations.Migrator(ui, backends).Run(ctx, *from, *to, options)
+	if err != nil {
+		logger.Error("error occurred while migrating notes", err)
+		return 1
+	}
+
+	j, _ := json.MarshalIndent(report, utils.Empty, "  ")
+	fmt.Println(string(j))
+	if len(report.Failures) > 0 || len(report.Mismatches) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runBackup writes the archive of all user notes in the backend to file.
+func runBackup(args []string) int {
+	set := flag.NewFlagSet(cmdBackup, flag.ContinueOnError)
+	from := set.String("from", defaultBackend(), "storage backend to back up, 'drive' or 'fs'")
+	out := set.String("out", fmt.Sprintf("typing-backup-%s.tar.gz", time.Now().UTC().Format("20060102-150405")),
+		"path of backup archive")
+	token := set.String("token", os.Getenv(envVarAccessToken), "google access token of user")
+	if err := set.Parse(args); err != nil {
+		return 2
+	}
+	archiver, ok := cliArchiver(*from, *token)
+	if !ok {
+		return 1
+	}
+
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		logger.Error("error occurred while creating backup archive", err)
+		return 1
+	}
+	m, err := archiver.Backup(interruptible(), f)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		os.Remove(*out)
+		logger.Error("error occurred while backing up notes", err)
+		return 1
+	}
+
+	j, _ := json.MarshalIndent(m, utils.Empty, "  ")
+	fmt.Println(string(j))
+	return 0
+}
+
+// runRestore imports the notes of backup archive in the backend, and prints
+// the restore report.
+func runRestore(args []string) int {
+	set := flag.NewFlagSet(cmdRestore, flag.ContinueOnError)
+	to := set.String("to", defaultBackend(), "storage backend to restore in, 'drive' or 'fs'")
+	in := set.String("in", utils.Empty, "path of backup archive")
+	collision := set.String("collision", backup.CollisionSkip,
+		"restore of note having same id or name as existing note, 'skip', 'overwrite' or 'rename'")
+	token := set.String("token", os.Getenv(envVarAccessToken), "google access token of user")
+	if err := set.Parse(args); err != nil {
+		return 2
+	}
+	if err := backup.CheckCollision(*collision); err != nil {
+		logger.Error("error occurred while reading collision", err)
+		return 2
+	}
+	archiver, ok := cliArchiver(*to, *token)
+	if !ok {
+		return 1
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		logger.Error("error occurred while opening backup archive", err)
+		return 1
+	}
+	defer f.Close()
+	report, err := archiver.Restore(interruptible(), f, *collision)
+	if err != nil {
+		logger.Error("error occurred while restoring notes", err)
+		return 1
+	}
+
+	j, _ := json.MarshalIndent(report, utils.Empty, "  ")
+	fmt.Println(string(j))
+	if len(report.Failures) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runUpgrade rewrites the content of all user notes in the current format
+// version, and prints the notes upgraded. The content is upgraded on read
+// anyway, the rewrite only saves the upgrade on every read.
+func runUpgrade(args []string) int {
+	set := flag.NewFlagSet(cmdUpgrade, flag.ContinueOnError)
+	name := set.String("backend", defaultBackend(), "storage backend to upgrade, 'drive' or 'fs'")
+	token := set.String("token", os.Getenv(envVarAccessToken), "google access token of user")
+	if err := set.Parse(args); err != nil {
+		return 2
+	}
+	b, ok := cliBackend(*name, *token)
+	if !ok {
+		return 1
+	}
+
+	ctx := interruptible()
+	notes, err := b.Notestore.GetAll(ctx)
+	if err != nil {
+		logger.Error("error occurred while reading notes", err)
+		return 1
+	}
+	report := struct {
+		Notes    int               `json:"notes"`
+		Upgraded []string          `json:"upgraded"`
+		Failures map[string]string `json:"failures,omitempty"`
+	}{Notes: len(notes), Upgraded: make([]string, 0)}
+	ss := drvsectionstore.NewWithContent(b.Content, nil)
+	for _, n := range notes {
+		upgraded, err := ss.Migrate(ctx, n.ID)
+		if err != nil {
+			if ctx.Err() != nil {
+				logger.Error("error occurred while upgrading notes", ctx.Err())
+				return 1
+			}
+			if report.Failures == nil {
+				report.Failures = make(map[string]string)
+			}
+			report.Failures[n.ID] = err.Error()
+			continue
+		}
+		if upgraded {
+			report.Upgraded = append(report.Upgraded, n.ID)
+		}
+	}
+
+	j, _ := json.MarshalIndent(report, utils.Empty, "  ")
+	fmt.Println(string(j))
+	if len(report.Failures) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// cliArchiver returns the archiver of the named backend, the errors are
+// logged.
+func cliArchiver(name, token string) (backup.Archiver, bool) {
+	b, ok := cliBackend(name, token)
+	if !ok {
+		return nil, false
+	}
+	return backup.New(b), true
+}
+
+// cliBackend returns the named storage backend of user, the errors are
+// logged.
+func cliBackend(name, token string) (*migrate.Backend, bool) {
+	if len(token) == 0 {
+		logger.Error("error occurred while reading access token", errors.New("access token is empty"))
+		return nil, false
+	}
+	pool := drvpool.New(drvpool.DefaultTTL, retries.Wrap)
+	_, backends, err := migrateBackends(pool, token)
+	if err != nil {
+		logger.Error("error occurred while creating storage backends", err)
+		return nil, false
+	}
+	b, err := backends(name)
+	if err != nil {
+		logger.Error("error occurred while creating storage backends", err)
+		return nil, false
+	}
+	return b, true
+}
+
+// defaultBackend returns the backend serving the notes, the local store in
+// offline mode and drive otherwise.
+func defaultBackend() string {
+	if localStore != nil {
+		return migrate.BackendFS
+	}
+	return migrate.BackendDrive
+}
+
+// interruptible returns the context cancelled by the interrupt signal.
+func interruptible() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+	go func() {
+		<-quit
+		cancel()
+	}()
+	return ctx
+}
+
 func parsePort(p string) (uint16, bool) {
 	if len(p) > 0 {
 		if v, err := strconv.Atoi(p); err == nil {
@@ -135,6 +516,28 @@ func parsePort(p string) (uint16, bool) {
 	return 0, false
 }
 
+func parseMaxRetries(r string) (int, bool) {
+	if len(r) > 0 {
+		if v, err := strconv.Atoi(r); err == nil {
+			if v >= 0 && v <= 10 {
+				return v, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func parseTimeout(t string) (time.Duration, bool) {
+	if len(t) > 0 {
+		if v, err := strconv.Atoi(t); err == nil {
+			if v >= 0 && v <= 600 {
+				return time.Duration(v) * time.Second, true
+			}
+		}
+	}
+	return 0, false
+}
+
 func parseLogLevel(level string) (log.LevelType, bool) {
 	switch strings.ToUpper(strings.TrimSpace(level)) {
 	case "DEBUG":
@@ -151,27 +554,231 @@ func parseLogLevel(level string) (log.LevelType, bool) {
 }
 
 func initIoC() *ioc.DefaultContainer {
+	// the drive sessions are shared by requests having the same token, the
+	// breaker wraps the retries, so the retried call is counted only once
+	pool := drvpool.New(drvpool.DefaultTTL, retries.Wrap, drvBreaker.Wrap)
+
 	aufn := func(params ...interface{}) (interface{}, error) {
 		return googleauth.New(clientCred)
 	}
 	uifn := func(params ...interface{}) (interface{}, error) {
-		client := params[0].(*http.Client)
-		return googleuserinfo.New(client)
+		session, err := pool.Get(params[0].(string))
+		if err != nil {
+			return nil, err
+		}
+		return googleuserinfo.New(session.Client)
 	}
 	nsfn := func(params ...interface{}) (interface{}, error) {
-		client := params[0].(*http.Client)
-		return drvnotestore.New(client)
+		session, err := pool.Get(params[0].(string))
+		if err != nil {
+			return nil, err
+		}
+		ns := drvnotestore.NewWithService(session.Service)
+		return cache.NewNotestore(ns, noteCache, session.Userinfo, cache.DefaultConfig()), nil
 	}
 	ssfn := func(params ...interface{}) (interface{}, error) {
-		client := params[0].(*http.Client)
-		return drvsectionstore.New(client)
+		session, err := pool.Get(params[0].(string))
+		if err != nil {
+			return nil, err
+		}
+		// the session user is fetched once for the author and the lock key
+		ss, err := drvsectionstore.NewWithService(session.Service, session.Userinfo, encoding)
+		if err != nil {
+			return nil, err
+		}
+		// the uncached notestore revalidates the cached note content
+		ns := drvnotestore.NewWithService(session.Service)
+		lss := notelock.NewSectionstore(ss, noteLocker, session.Userinfo)
+		return cache.NewSectionstore(lss, ns, noteCache, session.Userinfo, cache.DefaultConfig()), nil
+	}
+	asfn := func(params ...interface{}) (interface{}, error) {
+		session, err := pool.Get(params[0].(string))
+		if err != nil {
+			return nil, err
+		}
+		return drvattachstore.NewWithService(session.Service), nil
 	}
 
+	// the local store serves the notes in offline mode, the attachments
+	// still go to drive
+	if localStore != nil {
+		nsfn = func(params ...interface{}) (interface{}, error) {
+			ui, remote, err := localSession(pool, params[0].(string))
+			if err != nil {
+				return nil, err
+			}
+			return offline.NewNotestore(localStore, ui, remote), nil
+		}
+		ssfn = func(params ...interface{}) (interface{}, error) {
+			ui, _, err := localSession(pool, params[0].(string))
+			if err != nil {
+				return nil, err
+			}
+			ss := drvsectionstore.NewWithContent(offline.NewContent(localStore, ui), ui)
+			return notelock.NewSectionstore(ss, noteLocker, ui), nil
+		}
+	}
 	container := ioc.New()
 	container.Add(ioc.InstanceTypeAuth, aufn)
 	container.Add(ioc.InstanceTypeUserinfo, uifn)
 	container.Add(ioc.InstanceTypeNotestore, nsfn)
 	container.Add(ioc.InstanceTypeSectionstore, ssfn)
+	container.Add(ioc.InstanceTypeAttachstore, asfn)
+	container.Add(ioc.InstanceTypeArchiver, func(params ...interface{}) (interface{}, error) {
+		b, err := storageBackend(pool, params[0].(string))
+		if err != nil {
+			return nil, err
+		}
+		return backup.New(b), nil
+	})
+	if localStore != nil {
+		container.Add(ioc.InstanceTypeSyncer, func(params ...interface{}) (interface{}, error) {
+			ui, remote, err := localSession(pool, params[0].(string))
+			if err != nil {
+				return nil, err
+			}
+			return offline.NewSyncer(localStore, ui, remote), nil
+		})
+		container.Add(ioc.InstanceTypeMigrator, func(params ...interface{}) (interface{}, error) {
+			ui, backends, err := migrateBackends(pool, params[0].(string))
+			if err != nil {
+				return nil, err
+			}
+			return migrations.Migrator(ui, backends), nil
+		})
+	}
+	if schedule != nil {
+		backups = backup.NewScheduler(schedule, target, scheduledSource(pool), logger)
+		container.Add(ioc.InstanceTypeReporter, func(params ...interface{}) (interface{}, error) {
+			session, err := pool.Get(params[0].(string))
+			if err != nil {
+				return nil, err
+			}
+			return backups.Reporter(session.Userinfo), nil
+		})
+	}
 
 	return container
 }
+
+// localSession returns the userinfo remembering the user of access token,
+// and the drive notes synced with the local store.
+func localSession(pool *drvpool.Pool, accessToken string) (userinfo.Userinfo, offline.Remote, error) {
+	session, err := pool.Get(accessToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := drvsectionstore.NewDriveContent(session.Service, encoding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ui := localStore.Userinfo(accessToken, session.Userinfo)
+	remote := offline.NewRemote(drvnotestore.NewWithService(session.Service), content)
+	return ui, remote, nil
+}
+
+// migrateBackends returns the userinfo remembering the user of access token,
+// and the storage backends of the user for migration, backup and restore.
+func migrateBackends(pool *drvpool.Pool, accessToken string) (userinfo.Userinfo, migrate.Backends, error) {
+	session, err := pool.Get(accessToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := drvsectionstore.NewDriveContent(session.Service, encoding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ui := session.Userinfo
+	if localStore != nil {
+		ui = localStore.Userinfo(accessToken, session.Userinfo)
+	}
+	backends := func(name string) (*migrate.Backend, error) {
+		switch {
+		case name == migrate.BackendDrive:
+			return &migrate.Backend{
+				Name:      name,
+				Notestore: drvnotestore.NewWithService(session.Service),
+				Content:   notelock.NewContent(content, noteLocker, ui),
+			}, nil
+		case name == migrate.BackendFS && localStore != nil:
+			return &migrate.Backend{
+				Name:      name,
+				Notestore: offline.NewNotestore(localStore, ui, nil),
+				Content:   notelock.NewContent(offline.NewContent(localStore, ui), noteLocker, ui),
+			}, nil
+		case name == migrate.BackendFS:
+			return nil, errs.NewValidationError(envVarOfflineDir + " is not set")
+		default:
+			msg := fmt.Sprintf("storage backend '%s' is unknown", name)
+			return nil, errs.NewValidationError(msg)
+		}
+	}
+	return ui, backends, nil
+}
+
+// scheduledSource returns the archiver of scheduled user, the access token
+// is renewed by the refresh token of user on every backup.
+func scheduledSource(pool *drvpool.Pool) backup.Source {
+	return func(ctx context.Context, u *backup.ScheduledUser) (backup.Archiver, error) {
+		a, err := googleauth.New(clientCred)
+		if err != nil {
+			return nil, err
+		}
+		token, err := a.Refresh(ctx, u.RefreshToken)
+		if err != nil {
+			return nil, err
+		}
+		session, err := pool.Get(token.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+
+		// the refresh token of other user never backs up into the user folder
+		user, err := session.Userinfo.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(user.Email, u.Email) {
+			return nil, fmt.Errorf("refresh token is of user '%s'", user.Email)
+		}
+		b, err := storageBackend(pool, token.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+		return backup.New(b), nil
+	}
+}
+
+// storageBackend returns the storage backend serving the notes of user, the
+// local store in offline mode and drive otherwise.
+func storageBackend(pool *drvpool.Pool, accessToken string) (*migrate.Backend, error) {
+	if localStore != nil {
+		ui, remote, err := localSession(pool, accessToken)
+		if err != nil {
+			return nil, err
+		}
+		return &migrate.Backend{
+			Name:      migrate.BackendFS,
+			Notestore: offline.NewNotestore(localStore, ui, remote),
+			Content:   notelock.NewContent(offline.NewContent(localStore, ui), noteLocker, ui),
+		}, nil
+	}
+
+	session, err := pool.Get(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	content, err := drvsectionstore.NewDriveContent(session.Service, encoding)
+	if err != nil {
+		return nil, err
+	}
+	// the cached notestore drops the cached notes changed by restore
+	ns := drvnotestore.NewWithService(session.Service)
+	return &migrate.Backend{
+		Name:      migrate.BackendDrive,
+		Notestore: cache.NewNotestore(ns, noteCache, session.Userinfo, cache.DefaultConfig()),
+		Content:   notelock.NewContent(content, noteLocker, session.Userinfo),
+	}, nil
+}
@@ -39,8 +39,12 @@ func GetValueString(value, def string) string {
 	return value
 }
 
-// Error creates a new error by injecting/formatting the inner error.
+// Error creates a new error by injecting/formatting the inner error. The
+// inner error is wrapped, so it is still found by errors.Is and errors.As.
 func Error(value string, inner error) error {
+	if len(value) > 0 && inner != nil {
+		return fmt.Errorf("%s: [%w]", value, inner)
+	}
 	msg := AppendError(value, inner)
 	return errors.New(GetValueString(msg, "error"))
 }
@@ -70,30 +74,32 @@ func CheckLocalhostURL(u string) error {
 }
 
 // ClientWithToken creates a new http client and injects
-// the access token in the authorization header.
+// the access token in the authorization header. Each client has
+// its own transport, so the concurrent requests never share the token.
 func ClientWithToken(accessToken string) *http.Client {
-	client := http.DefaultClient
-	original := http.DefaultTransport
-	client.Transport = TransportFunc(func(req *http.Request) (*http.Response, error) {
-		req.Header.Add(echo.HeaderAuthorization, fmt.Sprintf("Bearer %s", accessToken))
-		return original.RoundTrip(req)
-	})
-	return client
+	return &http.Client{
+		Transport: TransportFunc(func(req *http.Request) (*http.Response, error) {
+			// transport must not modify the original request
+			r := req.Clone(req.Context())
+			r.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %s", accessToken))
+			return http.DefaultTransport.RoundTrip(r)
+		}),
+	}
 }
 
 // ClientWithJSON creates a new http client. It has an internal transport
 // function which build custom response with the specified json and
 // http status code. It is useful for testing http calls.
 func ClientWithJSON(j string, code int) *http.Client {
-	client := http.DefaultClient
-	client.Transport = TransportFunc(func(req *http.Request) (*http.Response, error) {
-		return &http.Response{
-			StatusCode: code,
-			Body:       ioutil.NopCloser(bytes.NewBufferString(j)),
-			Header:     map[string][]string{"Content-Type": {"application/json"}},
-		}, nil
-	})
-	return client
+	return &http.Client{
+		Transport: TransportFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: code,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(j)),
+				Header:     map[string][]string{"Content-Type": {"application/json"}},
+			}, nil
+		}),
+	}
 }
 
 // ValidateStruct checks all validation rules on struct fields. It returns
@@ -156,10 +162,35 @@ func BuildHTTPError(err error, msg string) *echo.HTTPError {
 		}
 	}
 
-	// check any other error
+	// check "Validation" error
+	if _, ok := err.(*errs.ValidationError); ok {
+		return &echo.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+	}
+
+	// check "Encryption" error
+	if _, ok := err.(*errs.EncryptionError); ok {
+		return &echo.HTTPError{
+			Code:    http.StatusForbidden,
+			Message: err.Error(),
+		}
+	}
+
+	// check "Conflict" error
+	if _, ok := err.(*errs.ConflictError); ok {
+		return &echo.HTTPError{
+			Code:    http.StatusConflict,
+			Message: err.Error(),
+		}
+	}
+
+	// check any other error, the cause is kept for the middlewares
 	return &echo.HTTPError{
-		Code:    http.StatusInternalServerError,
-		Message: msg,
+		Code:     http.StatusInternalServerError,
+		Message:  msg,
+		Internal: err,
 	}
 }
 
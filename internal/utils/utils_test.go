@@ -95,6 +95,26 @@ var _ = Describe("utility functions", func() {
 			t := fmt.Sprintf("Bearer %s", accessToken)
 			Expect(string(at)).Should(Equal(t))
 		})
+
+		It("should keep the token isolated per client", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.Header.Get(echo.HeaderAuthorization)))
+			}))
+			defer ts.Close()
+
+			client1 := utils.ClientWithToken("token1")
+			client2 := utils.ClientWithToken("token2")
+			Expect(client1).ShouldNot(BeIdenticalTo(http.DefaultClient))
+			Expect(http.DefaultClient.Transport).Should(BeNil())
+
+			for token, client := range map[string]*http.Client{"token1": client1, "token2": client2} {
+				res, err := client.Get(ts.URL)
+				Expect(err).ShouldNot(HaveOccurred())
+				at, _ := ioutil.ReadAll(res.Body)
+				res.Body.Close()
+				Expect(string(at)).Should(Equal("Bearer " + token))
+			}
+		})
 	})
 
 	Context("utility function: ClientWithJSON", func() {
@@ -189,6 +209,27 @@ var _ = Describe("utility functions", func() {
 			Expect(httpError.Code).Should(Equal(http.StatusNotFound))
 		})
 
+		It("should be bad request error", func() {
+			err := errs.NewValidationError("msg")
+			httpError := utils.BuildHTTPError(err, utils.Empty)
+			Expect(httpError.Code).Should(Equal(http.StatusBadRequest))
+			Expect(httpError.Message).Should(Equal("msg"))
+		})
+
+		It("should be forbidden error", func() {
+			err := errs.NewEncryptionError("msg")
+			httpError := utils.BuildHTTPError(err, utils.Empty)
+			Expect(httpError.Code).Should(Equal(http.StatusForbidden))
+			Expect(httpError.Message).Should(Equal("msg"))
+		})
+
+		It("should be conflict error", func() {
+			err := errs.NewConflictError("msg")
+			httpError := utils.BuildHTTPError(err, utils.Empty)
+			Expect(httpError.Code).Should(Equal(http.StatusConflict))
+			Expect(httpError.Message).Should(Equal("msg"))
+		})
+
 		It("should be internal server error", func() {
 			err := errors.New("msg")
 			httpError := utils.BuildHTTPError(err, utils.Empty)
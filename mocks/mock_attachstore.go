@@ -0,0 +1,110 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/psewda/typing/pkg/storage/attachstore (interfaces: Attachstore)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	gomock "github.com/golang/mock/gomock"
+	attachstore "github.com/psewda/typing/pkg/storage/attachstore"
+	io "io"
+	reflect "reflect"
+)
+
+// MockAttachstore is a mock of Attachstore interface
+type MockAttachstore struct {
+	ctrl     *gomock.Controller
+	recorder *MockAttachstoreMockRecorder
+}
+
+// MockAttachstoreMockRecorder is the mock recorder for MockAttachstore
+type MockAttachstoreMockRecorder struct {
+	mock *MockAttachstore
+}
+
+// NewMockAttachstore creates a new mock instance
+func NewMockAttachstore(ctrl *gomock.Controller) *MockAttachstore {
+	mock := &MockAttachstore{ctrl: ctrl}
+	mock.recorder = &MockAttachstoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAttachstore) EXPECT() *MockAttachstoreMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method
+func (m *MockAttachstore) Create(arg0 context.Context, arg1 string, arg2 *attachstore.WritableAttachment, arg3 io.Reader) (*attachstore.Attachment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*attachstore.Attachment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create
+func (mr *MockAttachstoreMockRecorder) Create(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockAttachstore)(nil).Create), arg0, arg1, arg2, arg3)
+}
+
+// Delete mocks base method
+func (m *MockAttachstore) Delete(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockAttachstoreMockRecorder) Delete(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockAttachstore)(nil).Delete), arg0, arg1, arg2)
+}
+
+// DeleteAll mocks base method
+func (m *MockAttachstore) DeleteAll(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAll", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAll indicates an expected call of DeleteAll
+func (mr *MockAttachstoreMockRecorder) DeleteAll(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAll", reflect.TypeOf((*MockAttachstore)(nil).DeleteAll), arg0, arg1)
+}
+
+// Get mocks base method
+func (m *MockAttachstore) Get(arg0 context.Context, arg1, arg2 string) (*attachstore.Attachment, io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*attachstore.Attachment)
+	ret1, _ := ret[1].(io.ReadCloser)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Get indicates an expected call of Get
+func (mr *MockAttachstoreMockRecorder) Get(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockAttachstore)(nil).Get), arg0, arg1, arg2)
+}
+
+// GetAll mocks base method
+func (m *MockAttachstore) GetAll(arg0 context.Context, arg1 string) ([]*attachstore.Attachment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAll", arg0, arg1)
+	ret0, _ := ret[0].([]*attachstore.Attachment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAll indicates an expected call of GetAll
+func (mr *MockAttachstoreMockRecorder) GetAll(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockAttachstore)(nil).GetAll), arg0, arg1)
+}
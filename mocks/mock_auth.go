@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	auth "github.com/psewda/typing/pkg/signin/auth"
 	reflect "reflect"
@@ -34,18 +35,18 @@ func (m *MockAuth) EXPECT() *MockAuthMockRecorder {
 }
 
 // Exchange mocks base method
-func (m *MockAuth) Exchange(arg0 string) (*auth.Token, error) {
+func (m *MockAuth) Exchange(arg0 context.Context, arg1 string) (*auth.Token, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Exchange", arg0)
+	ret := m.ctrl.Call(m, "Exchange", arg0, arg1)
 	ret0, _ := ret[0].(*auth.Token)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Exchange indicates an expected call of Exchange
-func (mr *MockAuthMockRecorder) Exchange(arg0 interface{}) *gomock.Call {
+func (mr *MockAuthMockRecorder) Exchange(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exchange", reflect.TypeOf((*MockAuth)(nil).Exchange), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exchange", reflect.TypeOf((*MockAuth)(nil).Exchange), arg0, arg1)
 }
 
 // GetURL mocks base method
@@ -63,30 +64,30 @@ func (mr *MockAuthMockRecorder) GetURL(arg0, arg1 interface{}) *gomock.Call {
 }
 
 // Refresh mocks base method
-func (m *MockAuth) Refresh(arg0 string) (*auth.Token, error) {
+func (m *MockAuth) Refresh(arg0 context.Context, arg1 string) (*auth.Token, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Refresh", arg0)
+	ret := m.ctrl.Call(m, "Refresh", arg0, arg1)
 	ret0, _ := ret[0].(*auth.Token)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Refresh indicates an expected call of Refresh
-func (mr *MockAuthMockRecorder) Refresh(arg0 interface{}) *gomock.Call {
+func (mr *MockAuthMockRecorder) Refresh(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockAuth)(nil).Refresh), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockAuth)(nil).Refresh), arg0, arg1)
 }
 
 // Revoke mocks base method
-func (m *MockAuth) Revoke(arg0 string) error {
+func (m *MockAuth) Revoke(arg0 context.Context, arg1 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Revoke", arg0)
+	ret := m.ctrl.Call(m, "Revoke", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Revoke indicates an expected call of Revoke
-func (mr *MockAuthMockRecorder) Revoke(arg0 interface{}) *gomock.Call {
+func (mr *MockAuthMockRecorder) Revoke(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockAuth)(nil).Revoke), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockAuth)(nil).Revoke), arg0, arg1)
 }
@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	notestore "github.com/psewda/typing/pkg/storage/notestore"
 	reflect "reflect"
@@ -34,75 +35,75 @@ func (m *MockNotestore) EXPECT() *MockNotestoreMockRecorder {
 }
 
 // Create mocks base method
-func (m *MockNotestore) Create(arg0 *notestore.WritableNote) (*notestore.Note, error) {
+func (m *MockNotestore) Create(arg0 context.Context, arg1 *notestore.WritableNote) (*notestore.Note, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", arg0)
+	ret := m.ctrl.Call(m, "Create", arg0, arg1)
 	ret0, _ := ret[0].(*notestore.Note)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create
-func (mr *MockNotestoreMockRecorder) Create(arg0 interface{}) *gomock.Call {
+func (mr *MockNotestoreMockRecorder) Create(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockNotestore)(nil).Create), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockNotestore)(nil).Create), arg0, arg1)
 }
 
 // Delete mocks base method
-func (m *MockNotestore) Delete(arg0 string) error {
+func (m *MockNotestore) Delete(arg0 context.Context, arg1 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret := m.ctrl.Call(m, "Delete", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete
-func (mr *MockNotestoreMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+func (mr *MockNotestoreMockRecorder) Delete(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockNotestore)(nil).Delete), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockNotestore)(nil).Delete), arg0, arg1)
 }
 
 // Get mocks base method
-func (m *MockNotestore) Get(arg0 string) (*notestore.Note, error) {
+func (m *MockNotestore) Get(arg0 context.Context, arg1 string) (*notestore.Note, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Get", arg0)
+	ret := m.ctrl.Call(m, "Get", arg0, arg1)
 	ret0, _ := ret[0].(*notestore.Note)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Get indicates an expected call of Get
-func (mr *MockNotestoreMockRecorder) Get(arg0 interface{}) *gomock.Call {
+func (mr *MockNotestoreMockRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockNotestore)(nil).Get), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockNotestore)(nil).Get), arg0, arg1)
 }
 
 // GetAll mocks base method
-func (m *MockNotestore) GetAll() ([]*notestore.Note, error) {
+func (m *MockNotestore) GetAll(arg0 context.Context) ([]*notestore.Note, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAll")
+	ret := m.ctrl.Call(m, "GetAll", arg0)
 	ret0, _ := ret[0].([]*notestore.Note)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAll indicates an expected call of GetAll
-func (mr *MockNotestoreMockRecorder) GetAll() *gomock.Call {
+func (mr *MockNotestoreMockRecorder) GetAll(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockNotestore)(nil).GetAll))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockNotestore)(nil).GetAll), arg0)
 }
 
 // Update mocks base method
-func (m *MockNotestore) Update(arg0 string, arg1 *notestore.WritableNote) (*notestore.Note, error) {
+func (m *MockNotestore) Update(arg0 context.Context, arg1 string, arg2 *notestore.WritableNote) (*notestore.Note, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Update", arg0, arg1)
+	ret := m.ctrl.Call(m, "Update", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*notestore.Note)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Update indicates an expected call of Update
-func (mr *MockNotestoreMockRecorder) Update(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockNotestoreMockRecorder) Update(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockNotestore)(nil).Update), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockNotestore)(nil).Update), arg0, arg1, arg2)
 }
@@ -5,7 +5,9 @@
 package mocks
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
+	schema "github.com/psewda/typing/pkg/schema"
 	sectionstore "github.com/psewda/typing/pkg/storage/sectionstore"
 	reflect "reflect"
 )
@@ -33,76 +35,120 @@ func (m *MockSectionstore) EXPECT() *MockSectionstoreMockRecorder {
 	return m.recorder
 }
 
+// Batch mocks base method
+func (m *MockSectionstore) Batch(arg0 context.Context, arg1 string, arg2 []*sectionstore.Operation) ([]*sectionstore.OperationResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Batch", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]*sectionstore.OperationResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Batch indicates an expected call of Batch
+func (mr *MockSectionstoreMockRecorder) Batch(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Batch", reflect.TypeOf((*MockSectionstore)(nil).Batch), arg0, arg1, arg2)
+}
+
 // Create mocks base method
-func (m *MockSectionstore) Create(arg0 string, arg1 *sectionstore.WritableSection) (*sectionstore.Section, error) {
+func (m *MockSectionstore) Create(arg0 context.Context, arg1 string, arg2 *sectionstore.WritableSection) (*sectionstore.Section, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", arg0, arg1)
+	ret := m.ctrl.Call(m, "Create", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*sectionstore.Section)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create
-func (mr *MockSectionstoreMockRecorder) Create(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockSectionstoreMockRecorder) Create(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSectionstore)(nil).Create), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSectionstore)(nil).Create), arg0, arg1, arg2)
 }
 
 // Delete mocks base method
-func (m *MockSectionstore) Delete(arg0, arg1 string) error {
+func (m *MockSectionstore) Delete(arg0 context.Context, arg1, arg2 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", arg0, arg1)
+	ret := m.ctrl.Call(m, "Delete", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete
-func (mr *MockSectionstoreMockRecorder) Delete(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockSectionstoreMockRecorder) Delete(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockSectionstore)(nil).Delete), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockSectionstore)(nil).Delete), arg0, arg1, arg2)
 }
 
 // Get mocks base method
-func (m *MockSectionstore) Get(arg0, arg1 string) (*sectionstore.Section, error) {
+func (m *MockSectionstore) Get(arg0 context.Context, arg1, arg2 string) (*sectionstore.Section, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Get", arg0, arg1)
+	ret := m.ctrl.Call(m, "Get", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*sectionstore.Section)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Get indicates an expected call of Get
-func (mr *MockSectionstoreMockRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockSectionstoreMockRecorder) Get(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockSectionstore)(nil).Get), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockSectionstore)(nil).Get), arg0, arg1, arg2)
 }
 
 // GetAll mocks base method
-func (m *MockSectionstore) GetAll(arg0 string) ([]*sectionstore.Section, error) {
+func (m *MockSectionstore) GetAll(arg0 context.Context, arg1 string, arg2 *sectionstore.Query) ([]*sectionstore.Section, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAll", arg0)
+	ret := m.ctrl.Call(m, "GetAll", arg0, arg1, arg2)
 	ret0, _ := ret[0].([]*sectionstore.Section)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAll indicates an expected call of GetAll
-func (mr *MockSectionstoreMockRecorder) GetAll(arg0 interface{}) *gomock.Call {
+func (mr *MockSectionstoreMockRecorder) GetAll(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockSectionstore)(nil).GetAll), arg0, arg1, arg2)
+}
+
+// GetSchema mocks base method
+func (m *MockSectionstore) GetSchema(arg0 context.Context, arg1 string) (*schema.Schema, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSchema", arg0, arg1)
+	ret0, _ := ret[0].(*schema.Schema)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSchema indicates an expected call of GetSchema
+func (mr *MockSectionstoreMockRecorder) GetSchema(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSchema", reflect.TypeOf((*MockSectionstore)(nil).GetSchema), arg0, arg1)
+}
+
+// SetSchema mocks base method
+func (m *MockSectionstore) SetSchema(arg0 context.Context, arg1 string, arg2 *schema.Schema) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSchema", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSchema indicates an expected call of SetSchema
+func (mr *MockSectionstoreMockRecorder) SetSchema(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockSectionstore)(nil).GetAll), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSchema", reflect.TypeOf((*MockSectionstore)(nil).SetSchema), arg0, arg1, arg2)
 }
 
 // Update mocks base method
-func (m *MockSectionstore) Update(arg0, arg1 string, arg2 *sectionstore.WritableSection) (*sectionstore.Section, error) {
+func (m *MockSectionstore) Update(arg0 context.Context, arg1, arg2 string, arg3 *sectionstore.WritableSection) (*sectionstore.Section, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Update", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "Update", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(*sectionstore.Section)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Update indicates an expected call of Update
-func (mr *MockSectionstoreMockRecorder) Update(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockSectionstoreMockRecorder) Update(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockSectionstore)(nil).Update), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockSectionstore)(nil).Update), arg0, arg1, arg2, arg3)
 }
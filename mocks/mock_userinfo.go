@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	userinfo "github.com/psewda/typing/pkg/signin/userinfo"
 	reflect "reflect"
@@ -34,16 +35,16 @@ func (m *MockUserinfo) EXPECT() *MockUserinfoMockRecorder {
 }
 
 // Get mocks base method
-func (m *MockUserinfo) Get() (*userinfo.User, error) {
+func (m *MockUserinfo) Get(arg0 context.Context) (*userinfo.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Get")
+	ret := m.ctrl.Call(m, "Get", arg0)
 	ret0, _ := ret[0].(*userinfo.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Get indicates an expected call of Get
-func (mr *MockUserinfoMockRecorder) Get() *gomock.Call {
+func (mr *MockUserinfoMockRecorder) Get(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockUserinfo)(nil).Get))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockUserinfo)(nil).Get), arg0)
 }
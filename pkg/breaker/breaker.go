@@ -0,0 +1,249 @@
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/psewda/typing/pkg/log"
+)
+
+// State is the state of circuit breaker.
+type State string
+
+const (
+	// StateClosed lets all calls go to the backend.
+	StateClosed State = "closed"
+
+	// StateOpen fails all calls fast without calling the backend.
+	StateOpen State = "open"
+
+	// StateHalfOpen lets a single probe call go to the backend. The
+	// result of probe closes or opens the breaker again.
+	StateHalfOpen State = "half-open"
+)
+
+// ErrOpen is returned by the transport when the breaker is open.
+var ErrOpen = errors.New("circuit breaker is open, storage is unavailable")
+
+// Config has the limits of circuit breaker.
+type Config struct {
+	// Threshold is the number of consecutive failures opening the breaker.
+	Threshold int
+
+	// Cooldown is the duration for which the breaker stays open before
+	// it half-opens to probe the backend.
+	Cooldown time.Duration
+}
+
+// DefaultConfig returns the config used by cloud storage calls.
+func DefaultConfig() Config {
+	return Config{
+		Threshold: 5,
+		Cooldown:  30 * time.Second,
+	}
+}
+
+// Status is the point in time status of circuit breaker.
+type Status struct {
+	Name       string `json:"name"`
+	State      State  `json:"state"`
+	Failures   int    `json:"failures"`
+	RetryAfter int    `json:"retryAfter,omitempty"`
+}
+
+// Breaker is the circuit breaker of a single storage backend. It counts
+// the consecutive failures of backend calls, and opens after the threshold
+// so the next calls fail fast instead of waiting for the timeout.
+type Breaker struct {
+	name     string
+	config   Config
+	logger   *log.Logger
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+	mutex    sync.Mutex
+}
+
+// Name returns the name of storage backend.
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state of breaker. The open breaker is reported
+// as half-open once the cooldown is over.
+func (b *Breaker) State() State {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.current()
+}
+
+// Status returns the current status of breaker. The retry after is the
+// remaining cooldown in seconds when the breaker is open.
+func (b *Breaker) Status() Status {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	s := Status{
+		Name:     b.name,
+		State:    b.current(),
+		Failures: b.failures,
+	}
+	if s.State == StateOpen {
+		s.RetryAfter = seconds(b.openedAt.Add(b.config.Cooldown).Sub(time.Now()))
+	}
+	return s
+}
+
+// Allow reports whether the backend can be called. In half-open state,
+// only one probe call is allowed at a time.
+func (b *Breaker) Allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.current() {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.setState(StateHalfOpen)
+		b.probing = true
+	}
+	return true
+}
+
+// Success records the successful backend call, and closes the breaker.
+func (b *Breaker) Success() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	b.setState(StateClosed)
+}
+
+// Failure records the failed backend call. It opens the breaker when the
+// failures reach the threshold or the half-open probe fails.
+func (b *Breaker) Failure() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+	if b.state == StateOpen {
+		return
+	}
+	if b.probing || b.failures >= b.config.Threshold {
+		b.probing = false
+		b.openedAt = time.Now()
+		b.setState(StateOpen)
+	}
+}
+
+// Wrap returns the transport which records the result of each call in
+// the breaker, and fails fast with ErrOpen while the breaker is open.
+func (b *Breaker) Wrap(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &transport{
+		breaker: b,
+		next:    rt,
+	}
+}
+
+// New creates a new circuit breaker of the storage backend. The state
+// changes are logged when the logger is not nil.
+func New(name string, c Config, logger *log.Logger) *Breaker {
+	if c.Threshold <= 0 {
+		c.Threshold = DefaultConfig().Threshold
+	}
+	if c.Cooldown <= 0 {
+		c.Cooldown = DefaultConfig().Cooldown
+	}
+
+	return &Breaker{
+		name:   name,
+		config: c,
+		logger: logger,
+		state:  StateClosed,
+	}
+}
+
+// current returns the state, taking the cooldown into account. The
+// caller must hold the lock.
+func (b *Breaker) current() State {
+	if b.state == StateOpen && !time.Now().Before(b.openedAt.Add(b.config.Cooldown)) {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// setState changes the state and logs it. The caller must hold the lock.
+func (b *Breaker) setState(s State) {
+	if b.state == s {
+		return
+	}
+
+	from := b.state
+	b.state = s
+	if b.logger == nil {
+		return
+	}
+
+	msg := fmt.Sprintf("circuit breaker of '%s' storage changed from %s to %s", b.name, from, s)
+	if s == StateOpen {
+		b.logger.Warn(fmt.Sprintf("%s after %d failures", msg, b.failures))
+		return
+	}
+	b.logger.Info(msg)
+}
+
+type transport struct {
+	breaker *Breaker
+	next    http.RoundTripper
+}
+
+// RoundTrip sends the request when the breaker allows it. The network
+// errors and server errors are counted as failures, the canceled requests
+// are not counted at all.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.breaker.Allow() {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, ErrOpen
+	}
+
+	res, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil && req.Context().Err() != nil:
+		t.breaker.release()
+	case err != nil || res.StatusCode >= http.StatusInternalServerError:
+		t.breaker.Failure()
+	default:
+		t.breaker.Success()
+	}
+	return res, err
+}
+
+// release frees the probe of half-open breaker without recording any
+// result, e.g. when the caller cancels the request.
+func (b *Breaker) release() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.probing = false
+}
+
+// seconds rounds up the duration to whole seconds, at least one.
+func seconds(d time.Duration) int {
+	s := int((d + time.Second - 1) / time.Second)
+	if s < 1 {
+		return 1
+	}
+	return s
+}
@@ -0,0 +1,142 @@
+package breaker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/pkg/breaker"
+)
+
+func TestBreaker(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "breaker-suite")
+}
+
+var _ = Describe("circuit breaker", func() {
+	config := breaker.Config{
+		Threshold: 3,
+		Cooldown:  50 * time.Millisecond,
+	}
+
+	// newServer returns the fake server which responds with the status
+	// code stored in the code value.
+	newServer := func(code *int32) (*httptest.Server, *int32) {
+		var calls int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(int(atomic.LoadInt32(code)))
+		}))
+		return ts, &calls
+	}
+
+	Context("state", func() {
+		It("should open after consecutive failures", func() {
+			b := breaker.New("drive", config, nil)
+			b.Failure()
+			b.Failure()
+			Expect(b.State()).Should(Equal(breaker.StateClosed))
+			b.Failure()
+			Expect(b.State()).Should(Equal(breaker.StateOpen))
+			Expect(b.Allow()).Should(BeFalse())
+		})
+
+		It("should reset failures on success", func() {
+			b := breaker.New("drive", config, nil)
+			b.Failure()
+			b.Failure()
+			b.Success()
+			b.Failure()
+			Expect(b.State()).Should(Equal(breaker.StateClosed))
+			Expect(b.Status().Failures).Should(Equal(1))
+		})
+
+		It("should half-open after cooldown and allow a single probe", func() {
+			b := breaker.New("drive", breaker.Config{Threshold: 1, Cooldown: 10 * time.Millisecond}, nil)
+			b.Failure()
+			Eventually(b.State).Should(Equal(breaker.StateHalfOpen))
+			Expect(b.Allow()).Should(BeTrue())
+			Expect(b.Allow()).Should(BeFalse())
+		})
+
+		It("should close when probe succeeds", func() {
+			b := breaker.New("drive", breaker.Config{Threshold: 1, Cooldown: 10 * time.Millisecond}, nil)
+			b.Failure()
+			Eventually(b.State).Should(Equal(breaker.StateHalfOpen))
+			Expect(b.Allow()).Should(BeTrue())
+			b.Success()
+			Expect(b.State()).Should(Equal(breaker.StateClosed))
+		})
+
+		It("should open again when probe fails", func() {
+			b := breaker.New("drive", breaker.Config{Threshold: 2, Cooldown: 10 * time.Millisecond}, nil)
+			b.Failure()
+			b.Failure()
+			Eventually(b.State).Should(Equal(breaker.StateHalfOpen))
+			Expect(b.Allow()).Should(BeTrue())
+			b.Failure()
+			Expect(b.State()).Should(Equal(breaker.StateOpen))
+			Expect(b.Status().RetryAfter).Should(Equal(1))
+		})
+	})
+
+	Context("transport", func() {
+		It("should fail fast when backend is down", func() {
+			code := int32(http.StatusServiceUnavailable)
+			ts, calls := newServer(&code)
+			defer ts.Close()
+
+			b := breaker.New("drive", config, nil)
+			client := &http.Client{Transport: b.Wrap(http.DefaultTransport)}
+			for i := 0; i < 3; i++ {
+				res, err := client.Get(ts.URL)
+				Expect(err).ShouldNot(HaveOccurred())
+				res.Body.Close()
+			}
+
+			_, err := client.Get(ts.URL)
+			Expect(err).Should(MatchError(ContainSubstring(breaker.ErrOpen.Error())))
+			Expect(*calls).Should(BeEquivalentTo(3))
+		})
+
+		It("should close when backend recovers", func() {
+			code := int32(http.StatusInternalServerError)
+			ts, calls := newServer(&code)
+			defer ts.Close()
+
+			b := breaker.New("drive", config, nil)
+			client := &http.Client{Transport: b.Wrap(http.DefaultTransport)}
+			for i := 0; i < 3; i++ {
+				res, _ := client.Get(ts.URL)
+				res.Body.Close()
+			}
+			Expect(b.State()).Should(Equal(breaker.StateOpen))
+
+			atomic.StoreInt32(&code, http.StatusOK)
+			Eventually(b.State).Should(Equal(breaker.StateHalfOpen))
+			res, err := client.Get(ts.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(res.StatusCode).Should(Equal(http.StatusOK))
+			Expect(b.State()).Should(Equal(breaker.StateClosed))
+			Expect(*calls).Should(BeEquivalentTo(4))
+		})
+
+		It("should not count client errors as failures", func() {
+			code := int32(http.StatusNotFound)
+			ts, _ := newServer(&code)
+			defer ts.Close()
+
+			b := breaker.New("drive", config, nil)
+			client := &http.Client{Transport: b.Wrap(http.DefaultTransport)}
+			for i := 0; i < 5; i++ {
+				res, _ := client.Get(ts.URL)
+				res.Body.Close()
+			}
+			Expect(b.State()).Should(Equal(breaker.StateClosed))
+		})
+	})
+})
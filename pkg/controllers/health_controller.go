@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/psewda/typing/pkg/breaker"
+	"github.com/psewda/typing/pkg/storage/notelock"
+	"github.com/psewda/typing/pkg/types"
+)
+
+const (
+	// HealthStatusOK is the status when all storage backends are available.
+	HealthStatusOK = "ok"
+
+	// HealthStatusDegraded is the status when any storage backend is not
+	// available, i.e. its circuit breaker is not closed.
+	HealthStatusDegraded = "degraded"
+)
+
+// HealthController represents all operations on health endpoint.
+type HealthController struct {
+	locker   *notelock.Locker
+	breakers []*breaker.Breaker
+}
+
+// AddRoutes configures all routes of health endpoint
+// in the 'echo' server runtime.
+func (c *HealthController) AddRoutes(e *echo.Echo) {
+	if e != nil {
+		e.GET("/api/health", c.GetHealth)
+	}
+}
+
+// NewHealthController creates a new instance of health controller. The
+// locker reports the contention of note locks, the nil locker skips it.
+// The breakers are the circuit breakers of storage backends.
+func NewHealthController(locker *notelock.Locker, breakers ...*breaker.Breaker) *HealthController {
+	return &HealthController{
+		locker:   locker,
+		breakers: breakers,
+	}
+}
+
+// GetHealth returns the state of storage backends and the contention of
+// note locks to the client.
+func (c *HealthController) GetHealth(ctx echo.Context) error {
+	health := types.HealthValue{
+		Status:   HealthStatusOK,
+		Backends: make([]breaker.Status, 0, len(c.breakers)),
+	}
+	for _, b := range c.breakers {
+		s := b.Status()
+		if s.State != breaker.StateClosed {
+			health.Status = HealthStatusDegraded
+		}
+		health.Backends = append(health.Backends, s)
+	}
+	if c.locker != nil {
+		stats := c.locker.Stats()
+		health.Locks = &stats
+	}
+	return ctx.JSON(http.StatusOK, health)
+}
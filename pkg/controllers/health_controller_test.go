@@ -0,0 +1,70 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/pkg/breaker"
+	"github.com/psewda/typing/pkg/controllers"
+	"github.com/psewda/typing/pkg/storage/notelock"
+	"github.com/psewda/typing/pkg/types"
+)
+
+var _ = Describe("health controller", func() {
+	getHealth := func(b *breaker.Breaker) (int, types.HealthValue) {
+		req := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+		rec := httptest.NewRecorder()
+		ctx := echo.New().NewContext(req, rec)
+
+		controllers.NewHealthController(nil, b).GetHealth(ctx)
+		var health types.HealthValue
+		json.Unmarshal(rec.Body.Bytes(), &health)
+		return rec.Code, health
+	}
+
+	Context("get health", func() {
+		It("should return ok when breaker closed", func() {
+			code, health := getHealth(breaker.New("drive", breaker.DefaultConfig(), nil))
+			Expect(code).Should(Equal(http.StatusOK))
+			Expect(health.Status).Should(Equal(controllers.HealthStatusOK))
+			Expect(health.Backends).Should(HaveLen(1))
+			Expect(health.Backends[0].Name).Should(Equal("drive"))
+			Expect(health.Backends[0].State).Should(Equal(breaker.StateClosed))
+			Expect(health.Locks).Should(BeNil())
+		})
+
+		It("should return degraded when breaker open", func() {
+			b := breaker.New("drive", breaker.Config{Threshold: 1, Cooldown: time.Minute}, nil)
+			b.Failure()
+
+			code, health := getHealth(b)
+			Expect(code).Should(Equal(http.StatusOK))
+			Expect(health.Status).Should(Equal(controllers.HealthStatusDegraded))
+			Expect(health.Backends[0].State).Should(Equal(breaker.StateOpen))
+			Expect(health.Backends[0].RetryAfter).Should(Equal(60))
+		})
+
+		It("should return lock contention when locker", func() {
+			locker := notelock.New(0)
+			unlock, _ := locker.Lock(context.Background(), "nid")
+			defer unlock()
+
+			req := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+			rec := httptest.NewRecorder()
+			ctx := echo.New().NewContext(req, rec)
+			controllers.NewHealthController(locker).GetHealth(ctx)
+
+			var health types.HealthValue
+			json.Unmarshal(rec.Body.Bytes(), &health)
+			Expect(health.Locks).ShouldNot(BeNil())
+			Expect(health.Locks.Acquired).Should(BeEquivalentTo(1))
+			Expect(health.Locks.Held).Should(Equal(1))
+		})
+	})
+})
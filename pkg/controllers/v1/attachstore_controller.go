@@ -0,0 +1,185 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/middlewares"
+	"github.com/psewda/typing/pkg/storage/attachstore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+// AttachstoreController represents all operations on attachstore endpoint.
+type AttachstoreController struct {
+	container ioc.Container
+}
+
+// AddRoutes configures all routes of attachstore endpoint
+// in the 'echo' server runtime.
+func (c *AttachstoreController) AddRoutes(e *echo.Echo) {
+	if e != nil {
+		a := middlewares.Authorization()
+		group := e.Group("/api/v1/storage/notes/:nid/attachments", a)
+		limit := middleware.BodyLimit(fmt.Sprintf("%dM", attachstore.MaxSize>>20+1))
+		group.POST(utils.Empty, c.CreateAttachment, limit)
+		group.GET(utils.Empty, c.GetAttachments)
+		group.GET("/:id", c.GetAttachment)
+		group.DELETE("/:id", c.DeleteAttachment)
+	}
+}
+
+// CreateAttachment uploads the multipart 'file' field as a new attachment
+// of the note. The optional 'section' field links it to the section.
+func (c *AttachstoreController) CreateAttachment(ctx echo.Context) error {
+	as := c.getAttachstore(ctx)
+	nid := ctx.Param("nid")
+
+	fh, err := ctx.FormFile("file")
+	if err != nil {
+		msg := "multipart 'file' field is required"
+		ctx.Logger().Warn(utils.AppendError(msg, err))
+		return &echo.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+	if fh.Size > attachstore.MaxSize {
+		msg := fmt.Sprintf("attachment must be less than %d bytes", attachstore.MaxSize)
+		ctx.Logger().Warn(msg)
+		return &echo.HTTPError{
+			Code:    http.StatusRequestEntityTooLarge,
+			Message: msg,
+		}
+	}
+
+	// check all rules on attachment validation
+	a := &attachstore.WritableAttachment{
+		Name:        fh.Filename,
+		ContentType: utils.GetValueString(fh.Header.Get(echo.HeaderContentType), echo.MIMEOctetStream),
+		SectionID:   ctx.FormValue("section"),
+	}
+	if err := a.Validate(); err != nil {
+		msg := err.Error()
+		ctx.Logger().Warn(msg)
+		return &echo.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+
+	// the section must exist in the note
+	if len(a.SectionID) > 0 {
+		ss := c.getSectionstore(ctx)
+		if _, err := ss.Get(ctx.Request().Context(), nid, a.SectionID); err != nil {
+			msg := "section retrival error"
+			ctx.Logger().Error(utils.AppendError(msg, err))
+			return utils.BuildHTTPError(err, msg)
+		}
+	}
+
+	content, err := fh.Open()
+	if err != nil {
+		msg := "attachment reading error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+	defer content.Close()
+
+	attachment, err := as.Create(ctx.Request().Context(), nid, a, content)
+	if err != nil {
+		msg := "attachment creation error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	location := path.Join(ctx.Request().URL.Path, attachment.ID)
+	ctx.Response().Header().Add(echo.HeaderLocation, location)
+	return ctx.JSON(http.StatusCreated, attachment)
+}
+
+// GetAttachments fetches all attachments of the note and returns to the
+// client. The 'section' query param returns only attachments of the section.
+func (c *AttachstoreController) GetAttachments(ctx echo.Context) error {
+	as := c.getAttachstore(ctx)
+	nid := ctx.Param("nid")
+	sid := ctx.QueryParam("section")
+
+	attachments, err := as.GetAll(ctx.Request().Context(), nid)
+	if err != nil {
+		msg := "attachment retrival error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	if len(sid) > 0 {
+		filtered := make([]*attachstore.Attachment, 0)
+		for _, a := range attachments {
+			if a.SectionID == sid {
+				filtered = append(filtered, a)
+			}
+		}
+		attachments = filtered
+	}
+
+	return ctx.JSON(http.StatusOK, attachments)
+}
+
+// GetAttachment streams the attachment content to the client
+// with its content type.
+func (c *AttachstoreController) GetAttachment(ctx echo.Context) error {
+	as := c.getAttachstore(ctx)
+	nid := ctx.Param("nid")
+	id := ctx.Param("id")
+
+	attachment, content, err := as.Get(ctx.Request().Context(), nid, id)
+	if err != nil {
+		msg := "attachment retrival error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+	defer content.Close()
+
+	disposition := fmt.Sprintf("attachment; filename=%q", attachment.Name)
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, disposition)
+	return ctx.Stream(http.StatusOK, attachment.ContentType, content)
+}
+
+// DeleteAttachment removes the attachment from the note.
+func (c *AttachstoreController) DeleteAttachment(ctx echo.Context) error {
+	as := c.getAttachstore(ctx)
+	nid := ctx.Param("nid")
+	id := ctx.Param("id")
+
+	err := as.Delete(ctx.Request().Context(), nid, id)
+	if err != nil {
+		msg := "attachment deletion error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// NewAttachstoreController creates a new instance of attachstore controller.
+func NewAttachstoreController(c ioc.Container) *AttachstoreController {
+	return &AttachstoreController{
+		container: c,
+	}
+}
+
+func (c *AttachstoreController) getAttachstore(ctx echo.Context) attachstore.Attachstore {
+	accessToken := ctx.Get(middlewares.ContextKeyAccessToken).(string)
+	instance, _ := c.container.GetInstance(ioc.InstanceTypeAttachstore, accessToken)
+	return instance.(attachstore.Attachstore)
+}
+
+func (c *AttachstoreController) getSectionstore(ctx echo.Context) sectionstore.Sectionstore {
+	accessToken := ctx.Get(middlewares.ContextKeyAccessToken).(string)
+	instance, _ := c.container.GetInstance(ioc.InstanceTypeSectionstore, accessToken)
+	return instance.(sectionstore.Sectionstore)
+}
@@ -0,0 +1,182 @@
+package v1_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	ctrlv1 "github.com/psewda/typing/pkg/controllers/v1"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/storage/attachstore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+var _ = Describe("attachstore controller", func() {
+	var (
+		mockContainer    *mocks.MockContainer
+		mockAttachstore  *mocks.MockAttachstore
+		mockSectionstore *mocks.MockSectionstore
+		rec              *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		mockContainer = mocks.NewMockContainer(mockCtrl)
+		mockAttachstore = mocks.NewMockAttachstore(mockCtrl)
+		mockSectionstore = mocks.NewMockSectionstore(mockCtrl)
+		rec = httptest.NewRecorder()
+	})
+
+	Context("create new attachment", func() {
+		newReq := func(section string) *http.Request {
+			body := new(bytes.Buffer)
+			writer := multipart.NewWriter(body)
+			header := make(textproto.MIMEHeader)
+			header.Set("Content-Disposition", `form-data; name="file"; filename="image.png"`)
+			header.Set(echo.HeaderContentType, "image/png")
+			part, _ := writer.CreatePart(header)
+			part.Write([]byte("content"))
+			if len(section) > 0 {
+				writer.WriteField("section", section)
+			}
+			writer.Close()
+
+			req := httptest.NewRequest(http.MethodPost, attachmentsRoute, body)
+			req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+			return req
+		}
+
+		It("should create the attachment when correct input", func() {
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeAttachstore, gomock.Any()).Return(mockAttachstore, nil)
+			mockAttachstore.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, nid string, a *attachstore.WritableAttachment, content interface{}) (*attachstore.Attachment, error) {
+					Expect(a.Name).Should(Equal("image.png"))
+					Expect(a.ContentType).Should(Equal("image/png"))
+					return &attachstore.Attachment{ID: "aid", Name: a.Name}, nil
+				})
+			ctx := newCtx(newReq(""), rec, withAccessToken())
+
+			ctrlv1.NewAttachstoreController(mockContainer).CreateAttachment(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusCreated))
+			Expect(rec.Header().Get(echo.HeaderLocation)).Should(HavePrefix(attachmentsRoute))
+		})
+
+		It("should check the section when section attachment", func() {
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeAttachstore, gomock.Any()).Return(mockAttachstore, nil)
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeSectionstore, gomock.Any()).Return(mockSectionstore, nil)
+			mockSectionstore.EXPECT().Get(gomock.Any(), gomock.Any(), "sid").Return(nil, errs.NewNotFoundError("error"))
+			ctx := newCtx(newReq("sid"), rec, withAccessToken())
+
+			err := ctrlv1.NewAttachstoreController(mockContainer).CreateAttachment(ctx)
+			httpError := toHTTPError(err)
+			Expect(httpError).Should(HaveOccurred())
+			Expect(httpError.Code).Should(Equal(http.StatusNotFound))
+		})
+
+		It("should create section attachment when section exists", func() {
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeAttachstore, gomock.Any()).Return(mockAttachstore, nil)
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeSectionstore, gomock.Any()).Return(mockSectionstore, nil)
+			mockSectionstore.EXPECT().Get(gomock.Any(), gomock.Any(), "sid").Return(&sectionstore.Section{ID: "sid"}, nil)
+			mockAttachstore.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&attachstore.Attachment{ID: "aid"}, nil)
+			ctx := newCtx(newReq("sid"), rec, withAccessToken())
+
+			ctrlv1.NewAttachstoreController(mockContainer).CreateAttachment(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusCreated))
+		})
+
+		It("should return error when no file", func() {
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeAttachstore, gomock.Any()).Return(mockAttachstore, nil)
+			req := httptest.NewRequest(http.MethodPost, attachmentsRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewAttachstoreController(mockContainer).CreateAttachment(ctx)
+			httpError := toHTTPError(err)
+			Expect(httpError).Should(HaveOccurred())
+			Expect(httpError.Code).Should(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("get attachments", func() {
+		It("should return attachments of the section", func() {
+			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockAttachstore, nil)
+			attachments := []*attachstore.Attachment{
+				{ID: "aid1", SectionID: "sid1"},
+				{ID: "aid2", SectionID: "sid2"},
+				{ID: "aid3"},
+			}
+			mockAttachstore.EXPECT().GetAll(gomock.Any(), gomock.Any()).Return(attachments, nil)
+			req := httptest.NewRequest(http.MethodGet, attachmentsRoute+"?section=sid2", nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewAttachstoreController(mockContainer).GetAttachments(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+
+			var a []*attachstore.Attachment
+			json.NewDecoder(rec.Body).Decode(&a)
+			Expect(a).Should(HaveLen(1))
+			Expect(a[0].ID).Should(Equal("aid2"))
+		})
+
+		It("should download the attachment content", func() {
+			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockAttachstore, nil)
+			attachment := &attachstore.Attachment{ID: "aid", Name: "doc.pdf", ContentType: "application/pdf"}
+			content := ioutil.NopCloser(bytes.NewBufferString("content"))
+			mockAttachstore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(attachment, content, nil)
+			req := httptest.NewRequest(http.MethodGet, attachmentRouteID, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewAttachstoreController(mockContainer).GetAttachment(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+			Expect(rec.Header().Get(echo.HeaderContentType)).Should(Equal("application/pdf"))
+			Expect(rec.Header().Get(echo.HeaderContentDisposition)).Should(ContainSubstring("doc.pdf"))
+			Expect(rec.Body.String()).Should(Equal("content"))
+		})
+
+		It("should return error when inner error", func() {
+			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockAttachstore, nil)
+			mockAttachstore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil, errors.New("error"))
+			req := httptest.NewRequest(http.MethodGet, attachmentRouteID, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewAttachstoreController(mockContainer).GetAttachment(ctx)
+			httpError := toHTTPError(err)
+			Expect(httpError).Should(HaveOccurred())
+			Expect(httpError.Code).Should(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Context("delete attachment", func() {
+		It("should succeed when correct attachment id", func() {
+			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockAttachstore, nil)
+			mockAttachstore.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			req := httptest.NewRequest(http.MethodDelete, attachmentRouteID, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewAttachstoreController(mockContainer).DeleteAttachment(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusNoContent))
+		})
+
+		It("should return error when wrong attachment id", func() {
+			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockAttachstore, nil)
+			mockAttachstore.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any()).Return(errs.NewNotFoundError("error"))
+			req := httptest.NewRequest(http.MethodDelete, attachmentRouteID, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewAttachstoreController(mockContainer).DeleteAttachment(ctx)
+			httpError := toHTTPError(err)
+			Expect(httpError).Should(HaveOccurred())
+			Expect(httpError.Code).Should(Equal(http.StatusNotFound))
+		})
+	})
+})
@@ -64,7 +64,7 @@ func (c *AuthController) Exchange(ctx echo.Context) error {
 		}
 	}
 
-	token, err := auth.Exchange(code)
+	token, err := auth.Exchange(ctx.Request().Context(), code)
 	if err != nil {
 		msg := "token exchange failed, check the authorization code"
 		ctx.Logger().Error(utils.AppendError(msg, err))
@@ -90,7 +90,7 @@ func (c *AuthController) Refresh(ctx echo.Context) error {
 		}
 	}
 
-	token, err := auth.Refresh(refreshToken)
+	token, err := auth.Refresh(ctx.Request().Context(), refreshToken)
 	if err != nil {
 		msg := "access token refresh failed, check the token"
 		ctx.Logger().Error(utils.AppendError(msg, err))
@@ -117,7 +117,7 @@ func (c *AuthController) Revoke(ctx echo.Context) error {
 		}
 	}
 
-	err := auth.Revoke(token)
+	err := auth.Revoke(ctx.Request().Context(), token)
 	if err != nil {
 		msg := "token revocation failed, check the token value"
 		ctx.Logger().Error(utils.AppendError(msg, err))
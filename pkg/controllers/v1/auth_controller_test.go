@@ -86,7 +86,7 @@ var _ = Describe("auth controller", func() {
 				RefreshToken: "refresh-token",
 				Expiry:       time.Now().Add(time.Second * 30),
 			}
-			mockAuth.EXPECT().Exchange(gomock.Any()).Return(token, nil)
+			mockAuth.EXPECT().Exchange(gomock.Any(), gomock.Any()).Return(token, nil)
 			req := httptest.NewRequest(http.MethodPost, tokenRoute, nil)
 			form := url.Values{}
 			form.Add("auth_code", "valid-authcode")
@@ -117,7 +117,7 @@ var _ = Describe("auth controller", func() {
 
 		It("should return error when inner error", func() {
 			mockContainer.EXPECT().GetInstance(gomock.Any()).Return(mockAuth, nil)
-			mockAuth.EXPECT().Exchange(gomock.Any()).Return(nil, errors.New("error"))
+			mockAuth.EXPECT().Exchange(gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
 			req := httptest.NewRequest(http.MethodPost, tokenRoute, nil)
 			form := url.Values{}
 			form.Add("auth_code", "valid-authcode")
@@ -139,7 +139,7 @@ var _ = Describe("auth controller", func() {
 				RefreshToken: "refresh-token",
 				Expiry:       time.Now().Add(time.Second * 30),
 			}
-			mockAuth.EXPECT().Refresh(gomock.Any()).Return(token, nil)
+			mockAuth.EXPECT().Refresh(gomock.Any(), gomock.Any()).Return(token, nil)
 			req := httptest.NewRequest(http.MethodPost, refreshRoute, nil)
 			form := url.Values{}
 			form.Add("refresh_token", "valid-token")
@@ -170,7 +170,7 @@ var _ = Describe("auth controller", func() {
 
 		It("should return error when inner error", func() {
 			mockContainer.EXPECT().GetInstance(gomock.Any()).Return(mockAuth, nil)
-			mockAuth.EXPECT().Refresh(gomock.Any()).Return(nil, errors.New("error"))
+			mockAuth.EXPECT().Refresh(gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
 			req := httptest.NewRequest(http.MethodPost, tokenRoute, nil)
 			form := url.Values{}
 			form.Add("refresh_token", "valid-token")
@@ -187,7 +187,7 @@ var _ = Describe("auth controller", func() {
 	Context("revoke token", func() {
 		It("should succeed when valid token", func() {
 			mockContainer.EXPECT().GetInstance(gomock.Any()).Return(mockAuth, nil)
-			mockAuth.EXPECT().Revoke(gomock.Any()).Return(nil)
+			mockAuth.EXPECT().Revoke(gomock.Any(), gomock.Any()).Return(nil)
 			req := httptest.NewRequest(http.MethodPost, revokeRoute, nil)
 			form := url.Values{}
 			form.Add("token", "valid-token")
@@ -211,7 +211,7 @@ var _ = Describe("auth controller", func() {
 
 		It("should return error when inner error", func() {
 			mockContainer.EXPECT().GetInstance(gomock.Any()).Return(mockAuth, nil)
-			mockAuth.EXPECT().Revoke(gomock.Any()).Return(errors.New("error"))
+			mockAuth.EXPECT().Revoke(gomock.Any(), gomock.Any()).Return(errors.New("error"))
 			req := httptest.NewRequest(http.MethodGet, revokeRoute, nil)
 			form := url.Values{}
 			form.Add("token", "valid-token")
@@ -0,0 +1,114 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/middlewares"
+	"github.com/psewda/typing/pkg/storage/backup"
+)
+
+// BackupController represents the backup and restore of user notes. The
+// requests have the stream deadline, see middlewares.Timeouts. The restored
+// archive is limited to max archive size.
+type BackupController struct {
+	container ioc.Container
+}
+
+// archiveWriter writes the archive to the response, the response header is
+// sent on the first write.
+type archiveWriter struct {
+	response *echo.Response
+	name     string
+}
+
+// AddRoutes configures all routes of backup endpoint
+// in the 'echo' server runtime.
+func (c *BackupController) AddRoutes(e *echo.Echo) {
+	if e != nil {
+		a := middlewares.Authorization()
+		e.GET("/api/v1/storage/backup", c.Backup, a)
+		e.POST("/api/v1/storage/restore", c.Restore, a,
+			middleware.BodyLimit(fmt.Sprintf("%dB", backup.MaxArchiveSize)))
+	}
+}
+
+// Backup streams the archive of all user notes to the client. The response
+// is sent on the first archive write, so the error before it is still sent
+// as error response. The error after it cuts the archive, and the cut
+// archive has no manifest, so it is never restored.
+func (c *BackupController) Backup(ctx echo.Context) error {
+	name := fmt.Sprintf("typing-backup-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+	w := &archiveWriter{response: ctx.Response(), name: name}
+	if _, err := c.getArchiver(ctx).Backup(ctx.Request().Context(), w); err != nil {
+		msg := "backup creation error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		if ctx.Response().Committed {
+			return nil
+		}
+		return utils.BuildHTTPError(err, msg)
+	}
+	if !ctx.Response().Committed {
+		w.commit()
+	}
+	return nil
+}
+
+// Restore imports the notes of archive in the request body. The query
+// param 'collision' tells how the note having the same id or name as an
+// existing note is restored, 'skip' (default), 'overwrite' or 'rename'.
+func (c *BackupController) Restore(ctx echo.Context) error {
+	collision := ctx.QueryParam("collision")
+	if len(collision) == 0 {
+		collision = backup.CollisionSkip
+	}
+	if err := backup.CheckCollision(collision); err != nil {
+		msg := "query param validation failed"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return &echo.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+
+	report, err := c.getArchiver(ctx).Restore(ctx.Request().Context(), ctx.Request().Body, collision)
+	if err != nil {
+		msg := "backup restore error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	return ctx.JSON(http.StatusOK, report)
+}
+
+// NewBackupController creates a new instance of backup controller.
+func NewBackupController(c ioc.Container) *BackupController {
+	return &BackupController{
+		container: c,
+	}
+}
+
+func (w *archiveWriter) Write(p []byte) (int, error) {
+	if !w.response.Committed {
+		w.commit()
+	}
+	return w.response.Write(p)
+}
+
+func (w *archiveWriter) commit() {
+	h := w.response.Header()
+	h.Set(echo.HeaderContentType, "application/gzip")
+	h.Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", w.name))
+	w.response.WriteHeader(http.StatusOK)
+}
+
+func (c *BackupController) getArchiver(ctx echo.Context) backup.Archiver {
+	accessToken := ctx.Get(middlewares.ContextKeyAccessToken).(string)
+	a, _ := c.container.GetInstance(ioc.InstanceTypeArchiver, accessToken)
+	return a.(backup.Archiver)
+}
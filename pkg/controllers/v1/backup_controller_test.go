@@ -0,0 +1,152 @@
+package v1_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	ctrlv1 "github.com/psewda/typing/pkg/controllers/v1"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/middlewares"
+	"github.com/psewda/typing/pkg/storage/backup"
+)
+
+var _ = Describe("backup controller", func() {
+	var (
+		mockContainer *mocks.MockContainer
+		mockArchiver  *mocks.MockArchiver
+		rec           *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		mockContainer = mocks.NewMockContainer(mockCtrl)
+		mockArchiver = mocks.NewMockArchiver(mockCtrl)
+		rec = httptest.NewRecorder()
+	})
+
+	Context("backup", func() {
+		It("should stream the archive when backup created", func() {
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeArchiver, gomock.Any()).Return(mockArchiver, nil)
+			mockArchiver.EXPECT().Backup(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ interface{}, w io.Writer) (*backup.Manifest, error) {
+					w.Write([]byte("archive"))
+					return &backup.Manifest{Version: backup.FormatVersion}, nil
+				})
+			req := httptest.NewRequest(http.MethodGet, backupRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewBackupController(mockContainer).Backup(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+			Expect(rec.Header().Get(echo.HeaderContentType)).Should(Equal("application/gzip"))
+			Expect(rec.Header().Get(echo.HeaderContentDisposition)).Should(ContainSubstring("typing-backup-"))
+			Expect(rec.Body.String()).Should(Equal("archive"))
+		})
+
+		It("should return error when backup failed", func() {
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeArchiver, gomock.Any()).Return(mockArchiver, nil)
+			mockArchiver.EXPECT().Backup(gomock.Any(), gomock.Any()).Return(nil, errs.NewUnauthorizedError())
+			req := httptest.NewRequest(http.MethodGet, backupRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewBackupController(mockContainer).Backup(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusUnauthorized))
+		})
+		It("should cut the archive when backup failed after write", func() {
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeArchiver, gomock.Any()).Return(mockArchiver, nil)
+			mockArchiver.EXPECT().Backup(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ interface{}, w io.Writer) (*backup.Manifest, error) {
+					w.Write([]byte("arch"))
+					return nil, errs.NewUnauthorizedError()
+				})
+			req := httptest.NewRequest(http.MethodGet, backupRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewBackupController(mockContainer).Backup(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+			Expect(rec.Body.String()).Should(Equal("arch"))
+		})
+
+		It("should stream the archive past the request deadline", func() {
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeArchiver, gomock.Any()).Return(mockArchiver, nil)
+			mockArchiver.EXPECT().Backup(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, w io.Writer) (*backup.Manifest, error) {
+					w.Write([]byte("arch"))
+					time.Sleep(50 * time.Millisecond)
+					if err := ctx.Err(); err != nil {
+						return nil, err
+					}
+					w.Write([]byte("ive"))
+					return &backup.Manifest{Version: backup.FormatVersion}, nil
+				})
+			e := echo.New()
+			e.Logger.SetOutput(ioutil.Discard)
+			timeouts := middlewares.Timeouts{Read: 10 * time.Millisecond, Write: 10 * time.Millisecond}
+			e.Use(middlewares.Timeout(timeouts, "/api/v1", backupRoute))
+			ctrlv1.NewBackupController(mockContainer).AddRoutes(e)
+			req := httptest.NewRequest(http.MethodGet, backupRoute, nil)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer access-token")
+
+			e.ServeHTTP(rec, req)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+			Expect(rec.Body.String()).Should(Equal("archive"))
+		})
+	})
+
+	Context("restore", func() {
+		It("should return the report when archive restored", func() {
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeArchiver, gomock.Any()).Return(mockArchiver, nil)
+			mockArchiver.EXPECT().Restore(gomock.Any(), gomock.Any(), backup.CollisionRename).
+				Return(&backup.Report{Notes: 2, Renamed: 2}, nil)
+			req := httptest.NewRequest(http.MethodPost, restoreRoute+"?collision=rename", strings.NewReader("archive"))
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewBackupController(mockContainer).Restore(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+
+			var report backup.Report
+			json.Unmarshal(rec.Body.Bytes(), &report)
+			Expect(report.Renamed).Should(Equal(2))
+		})
+
+		It("should skip the colliding notes when no collision param", func() {
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeArchiver, gomock.Any()).Return(mockArchiver, nil)
+			mockArchiver.EXPECT().Restore(gomock.Any(), gomock.Any(), backup.CollisionSkip).Return(&backup.Report{}, nil)
+			req := httptest.NewRequest(http.MethodPost, restoreRoute, strings.NewReader("archive"))
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewBackupController(mockContainer).Restore(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+		})
+
+		It("should return error when invalid collision param", func() {
+			req := httptest.NewRequest(http.MethodPost, restoreRoute+"?collision=merge", strings.NewReader("archive"))
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewBackupController(mockContainer).Restore(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusBadRequest))
+		})
+
+		It("should return error when archive corrupted", func() {
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeArchiver, gomock.Any()).Return(mockArchiver, nil)
+			mockArchiver.EXPECT().Restore(gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(nil, errs.NewValidationError("error"))
+			req := httptest.NewRequest(http.MethodPost, restoreRoute, strings.NewReader("archive"))
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewBackupController(mockContainer).Restore(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusBadRequest))
+		})
+	})
+})
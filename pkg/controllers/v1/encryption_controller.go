@@ -0,0 +1,92 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/middlewares"
+	"github.com/psewda/typing/pkg/storage/encrypt"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+// HeaderNewEncryptionKey is the header having the new passphrase
+// used for re-encrypting the note content.
+const HeaderNewEncryptionKey = "X-Typing-New-Key"
+
+// EncryptionController represents all operations on note encryption.
+type EncryptionController struct {
+	container ioc.Container
+}
+
+// AddRoutes configures all routes of encryption endpoint
+// in the 'echo' server runtime.
+func (c *EncryptionController) AddRoutes(e *echo.Echo) {
+	if e != nil {
+		a := middlewares.Authorization()
+		enc := middlewares.Encryption()
+		e.POST("/api/v1/storage/notes/:id/rotate-key", c.RotateKey, a, enc)
+	}
+}
+
+// RotateKey re-encrypts the note content using the new encryption key. The
+// current key is read from the encryption key header, and the note without
+// current key is encrypted for the first time.
+func (c *EncryptionController) RotateKey(ctx echo.Context) error {
+	id := ctx.Param("id")
+	to, err := encrypt.NewCipher(ctx.Request().Header.Get(HeaderNewEncryptionKey))
+	if err != nil {
+		msg := "new encryption key is invalid"
+		ctx.Logger().Warn(utils.AppendError(msg, err))
+		return &echo.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: msg + ", " + err.Error(),
+		}
+	}
+
+	from, _ := ctx.Get(middlewares.ContextKeyCipher).(*encrypt.Cipher)
+	ns, ss := c.getStores(ctx)
+	if err := encrypt.Rotate(ctx.Request().Context(), ns, ss, id, from, to); err != nil {
+		msg := "encryption key rotation error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// NewEncryptionController creates a new instance of encryption controller.
+func NewEncryptionController(c ioc.Container) *EncryptionController {
+	return &EncryptionController{
+		container: c,
+	}
+}
+
+// getStores returns the stores without encryption decorators, because
+// the rotation reads and writes the encrypted values itself.
+func (c *EncryptionController) getStores(ctx echo.Context) (notestore.Notestore, sectionstore.Sectionstore) {
+	accessToken := ctx.Get(middlewares.ContextKeyAccessToken).(string)
+	ns, _ := c.container.GetInstance(ioc.InstanceTypeNotestore, accessToken)
+	ss, _ := c.container.GetInstance(ioc.InstanceTypeSectionstore, accessToken)
+	return ns.(notestore.Notestore), ss.(sectionstore.Sectionstore)
+}
+
+// withNoteEncryption wraps the notestore in encryption decorator when
+// the request has the encryption key.
+func withNoteEncryption(ctx echo.Context, ns notestore.Notestore) notestore.Notestore {
+	if c, ok := ctx.Get(middlewares.ContextKeyCipher).(*encrypt.Cipher); ok {
+		return encrypt.NewNotestore(ns, c)
+	}
+	return ns
+}
+
+// withSectionEncryption wraps the sectionstore in encryption decorator
+// when the request has the encryption key.
+func withSectionEncryption(ctx echo.Context, ss sectionstore.Sectionstore) sectionstore.Sectionstore {
+	if c, ok := ctx.Get(middlewares.ContextKeyCipher).(*encrypt.Cipher); ok {
+		return encrypt.NewSectionstore(ss, c)
+	}
+	return ss
+}
@@ -0,0 +1,69 @@
+package v1_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	ctrlv1 "github.com/psewda/typing/pkg/controllers/v1"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/storage/notestore"
+)
+
+var _ = Describe("encryption controller", func() {
+	var (
+		mockContainer    *mocks.MockContainer
+		mockNotestore    *mocks.MockNotestore
+		mockSectionstore *mocks.MockSectionstore
+		rec              *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		mockContainer = mocks.NewMockContainer(mockCtrl)
+		mockNotestore = mocks.NewMockNotestore(mockCtrl)
+		mockSectionstore = mocks.NewMockSectionstore(mockCtrl)
+		rec = httptest.NewRecorder()
+	})
+
+	Context("rotate encryption key", func() {
+		It("should encrypt the note when new key", func() {
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeNotestore, gomock.Any()).Return(mockNotestore, nil)
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeSectionstore, gomock.Any()).Return(mockSectionstore, nil)
+			mockNotestore.EXPECT().Get(gomock.Any(), "id").Return(&notestore.Note{ID: "id", Name: "note"}, nil)
+			mockSectionstore.EXPECT().GetSchema(gomock.Any(), "id").Return(nil, errs.NewNotFoundError("error"))
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "id", nil).Return(nil, nil)
+			req := httptest.NewRequest(http.MethodPost, rotateKeyRoute, nil)
+			req.Header.Set(ctrlv1.HeaderNewEncryptionKey, "new-passphrase")
+			ctx := newCtx(req, rec, withAccessToken())
+			ctx.SetParamNames("id")
+			ctx.SetParamValues("id")
+
+			ctrlv1.NewEncryptionController(mockContainer).RotateKey(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusNoContent))
+		})
+
+		It("should return error when new key is missing", func() {
+			req := httptest.NewRequest(http.MethodPost, rotateKeyRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewEncryptionController(mockContainer).RotateKey(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusBadRequest))
+		})
+
+		It("should return error when note not found", func() {
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeNotestore, gomock.Any()).Return(mockNotestore, nil)
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeSectionstore, gomock.Any()).Return(mockSectionstore, nil)
+			mockNotestore.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, errs.NewNotFoundError("error"))
+			req := httptest.NewRequest(http.MethodPost, rotateKeyRoute, nil)
+			req.Header.Set(ctrlv1.HeaderNewEncryptionKey, "new-passphrase")
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewEncryptionController(mockContainer).RotateKey(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusNotFound))
+		})
+	})
+})
@@ -0,0 +1,116 @@
+package v1
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/middlewares"
+	"github.com/psewda/typing/pkg/storage/convert"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+// ExportController represents the export of notes in other formats.
+type ExportController struct {
+	container ioc.Container
+}
+
+// AddRoutes configures all routes of export endpoint
+// in the 'echo' server runtime.
+func (c *ExportController) AddRoutes(e *echo.Echo) {
+	if e != nil {
+		a := middlewares.Authorization()
+		enc := middlewares.Encryption()
+		e.GET("/api/v1/storage/notes/export", c.ExportNotes, a, enc)
+		e.GET("/api/v1/storage/notes/:id/export", c.ExportNote, a, enc)
+	}
+}
+
+// ExportNote renders the note in the format of query param 'format' and
+// returns the document to the client. The 'markdown' (default) format has
+// the whole note, the 'csv' format has the data sections as rows. The query
+// param 'data' tells the rendering of section data in markdown, either
+// 'list' (default) or 'table'.
+func (c *ExportController) ExportNote(ctx echo.Context) error {
+	format, o, err := exportOptions(ctx, convert.FormatMarkdown, convert.FormatCSV)
+	if err != nil {
+		return err
+	}
+
+	exporter := convert.NewExporter(c.getStores(ctx))
+	name, doc, contentType := utils.Empty, []byte(nil), "text/markdown; charset=utf-8"
+	if format == convert.FormatCSV {
+		name, doc, err = exporter.CSV(ctx.Request().Context(), ctx.Param("id"))
+		contentType = "text/csv; charset=utf-8"
+	} else {
+		name, doc, err = exporter.Markdown(ctx.Request().Context(), ctx.Param("id"), o)
+	}
+	if err != nil {
+		msg := "note export error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", name))
+	return ctx.Blob(http.StatusOK, contentType, doc)
+}
+
+// ExportNotes renders all notes in the format of query param 'format', and
+// returns the zip archive having the document of each note to the client.
+func (c *ExportController) ExportNotes(ctx echo.Context) error {
+	_, o, err := exportOptions(ctx, convert.FormatMarkdown)
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := convert.NewExporter(c.getStores(ctx)).MarkdownArchive(ctx.Request().Context(), buf, o); err != nil {
+		msg := "notes export error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="typing-notes.zip"`)
+	return ctx.Stream(http.StatusOK, "application/zip", buf)
+}
+
+// NewExportController creates a new instance of export controller.
+func NewExportController(c ioc.Container) *ExportController {
+	return &ExportController{
+		container: c,
+	}
+}
+
+func (c *ExportController) getStores(ctx echo.Context) (notestore.Notestore, sectionstore.Sectionstore) {
+	accessToken := ctx.Get(middlewares.ContextKeyAccessToken).(string)
+	ns, _ := c.container.GetInstance(ioc.InstanceTypeNotestore, accessToken)
+	ss, _ := c.container.GetInstance(ioc.InstanceTypeSectionstore, accessToken)
+	return withNoteEncryption(ctx, ns.(notestore.Notestore)),
+		withSectionEncryption(ctx, ss.(sectionstore.Sectionstore))
+}
+
+// exportOptions returns the export format and the markdown options from
+// the query params, the first format is the default.
+func exportOptions(ctx echo.Context, formats ...string) (string, convert.MarkdownOptions, error) {
+	o := convert.MarkdownOptions{Data: ctx.QueryParam("data")}
+	format := utils.GetValueString(ctx.QueryParam("format"), formats[0])
+	err := fmt.Errorf("format '%s' is not supported", format)
+	for _, f := range formats {
+		if f == format {
+			err = o.Validate()
+		}
+	}
+	if err != nil {
+		msg := "query param validation failed"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return format, o, &echo.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+	return format, o, nil
+}
@@ -0,0 +1,125 @@
+package v1_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	ctrlv1 "github.com/psewda/typing/pkg/controllers/v1"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+var _ = Describe("export controller", func() {
+	var (
+		mockContainer    *mocks.MockContainer
+		mockNotestore    *mocks.MockNotestore
+		mockSectionstore *mocks.MockSectionstore
+		rec              *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		mockContainer = mocks.NewMockContainer(mockCtrl)
+		mockNotestore = mocks.NewMockNotestore(mockCtrl)
+		mockSectionstore = mocks.NewMockSectionstore(mockCtrl)
+		rec = httptest.NewRecorder()
+	})
+
+	expectStores := func() {
+		mockContainer.EXPECT().GetInstance(ioc.InstanceTypeNotestore, gomock.Any()).Return(mockNotestore, nil)
+		mockContainer.EXPECT().GetInstance(ioc.InstanceTypeSectionstore, gomock.Any()).Return(mockSectionstore, nil)
+	}
+
+	Context("export note", func() {
+		It("should return the markdown document when note exists", func() {
+			expectStores()
+			note := &notestore.Note{ID: "id", Name: "My Note"}
+			mockNotestore.EXPECT().Get(gomock.Any(), "id").Return(note, nil)
+			mockNotestore.EXPECT().GetAll(gomock.Any()).Return([]*notestore.Note{note}, nil)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "id", nil).
+				Return([]*sectionstore.Section{{ID: "sid", Name: "section", Data: map[string]string{"k": "v"}}}, nil)
+			req := httptest.NewRequest(http.MethodGet, exportRoute+"?format=markdown&data=table", nil)
+			ctx := newCtx(req, rec, withAccessToken())
+			ctx.SetParamNames("id")
+			ctx.SetParamValues("id")
+
+			ctrlv1.NewExportController(mockContainer).ExportNote(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+			Expect(rec.Header().Get(echo.HeaderContentType)).Should(HavePrefix("text/markdown"))
+			Expect(rec.Header().Get(echo.HeaderContentDisposition)).Should(ContainSubstring("my-note.md"))
+			Expect(rec.Body.String()).Should(ContainSubstring("| k | v |"))
+		})
+
+		It("should return the csv when csv format", func() {
+			expectStores()
+			mockNotestore.EXPECT().Get(gomock.Any(), "id").Return(&notestore.Note{ID: "id", Name: "Team"}, nil)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "id", nil).
+				Return([]*sectionstore.Section{{ID: "sid", Name: "ann", Data: map[string]string{"role": "lead"}}}, nil)
+			req := httptest.NewRequest(http.MethodGet, exportRoute+"?format=csv", nil)
+			ctx := newCtx(req, rec, withAccessToken())
+			ctx.SetParamNames("id")
+			ctx.SetParamValues("id")
+
+			ctrlv1.NewExportController(mockContainer).ExportNote(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+			Expect(rec.Header().Get(echo.HeaderContentType)).Should(HavePrefix("text/csv"))
+			Expect(rec.Header().Get(echo.HeaderContentDisposition)).Should(ContainSubstring("team.csv"))
+			Expect(rec.Body.String()).Should(Equal("name,labels,role\nann,,lead\n"))
+		})
+
+		It("should return error when unsupported format", func() {
+			req := httptest.NewRequest(http.MethodGet, exportRoute+"?format=pdf", nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewExportController(mockContainer).ExportNote(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusBadRequest))
+		})
+
+		It("should return error when note not found", func() {
+			expectStores()
+			mockNotestore.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, errs.NewNotFoundError("error"))
+			req := httptest.NewRequest(http.MethodGet, exportRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewExportController(mockContainer).ExportNote(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("export notes", func() {
+		It("should return the zip archive when notes exist", func() {
+			expectStores()
+			mockNotestore.EXPECT().GetAll(gomock.Any()).Return([]*notestore.Note{{ID: "id", Name: "note"}}, nil)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "id", nil).Return(nil, nil)
+			req := httptest.NewRequest(http.MethodGet, exportAllRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewExportController(mockContainer).ExportNotes(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+			Expect(rec.Header().Get(echo.HeaderContentType)).Should(Equal("application/zip"))
+			Expect(rec.Body.Len()).ShouldNot(BeZero())
+		})
+
+		It("should return error when invalid data option", func() {
+			req := httptest.NewRequest(http.MethodGet, exportAllRoute+"?data=tree", nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewExportController(mockContainer).ExportNotes(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusBadRequest))
+		})
+
+		It("should return error when csv format", func() {
+			req := httptest.NewRequest(http.MethodGet, exportAllRoute+"?format=csv", nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewExportController(mockContainer).ExportNotes(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusBadRequest))
+		})
+	})
+})
@@ -0,0 +1,167 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/middlewares"
+	"github.com/psewda/typing/pkg/storage/convert"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+// ImportController represents the import of notes from other formats.
+type ImportController struct {
+	container ioc.Container
+}
+
+// AddRoutes configures all routes of import endpoint
+// in the 'echo' server runtime.
+func (c *ImportController) AddRoutes(e *echo.Echo) {
+	if e != nil {
+		a := middlewares.Authorization()
+		enc := middlewares.Encryption()
+		limit := middleware.BodyLimit(fmt.Sprintf("%dM", convert.MaxImportSize>>20+1))
+		e.POST("/api/v1/storage/notes/import", c.ImportNotes, a, enc, limit)
+		e.POST("/api/v1/storage/notes/:id/import", c.ImportSections, a, enc,
+			middleware.BodyLimit(fmt.Sprintf("%dM", convert.MaxFileSize>>20+1)))
+	}
+}
+
+// ImportNotes creates the notes from the multipart 'files' field in the
+// format of query param 'format'. The 'markdown' (default) file is a note,
+// the 'keep' file is a google keep note of google takeout and the 'enex'
+// file has many evernote notes. The zip file has many files of the format.
+// The report tells the result of each note and its content which is not
+// imported, the note failing the validation does not stop the import.
+func (c *ImportController) ImportNotes(ctx echo.Context) error {
+	var importer func(*convert.Importer, context.Context, []*convert.File) (*convert.ImportReport, error)
+	format := utils.GetValueString(ctx.QueryParam("format"), convert.FormatMarkdown)
+	switch format {
+	case convert.FormatMarkdown:
+		importer = (*convert.Importer).Markdown
+	case convert.FormatKeep:
+		importer = (*convert.Importer).Keep
+	case convert.FormatEvernote:
+		importer = (*convert.Importer).Evernote
+	default:
+		msg := "query param validation failed"
+		ctx.Logger().Error(utils.AppendError(msg, fmt.Errorf("format '%s' is not supported", format)))
+		return &echo.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+
+	files, err := formFiles(ctx)
+	if err != nil {
+		return err
+	}
+
+	report, err := importer(convert.NewImporter(c.getStores(ctx)), ctx.Request().Context(), files)
+	if err != nil {
+		msg := "notes import error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	return ctx.JSON(http.StatusOK, report)
+}
+
+// ImportSections creates and updates the data sections of note from the csv
+// in the request body, each row is the section. The query param 'key' is
+// the column matching the row with the existing section for the update.
+func (c *ImportController) ImportSections(ctx echo.Context) error {
+	format := utils.GetValueString(ctx.QueryParam("format"), convert.FormatCSV)
+	if format != convert.FormatCSV {
+		msg := "query param validation failed"
+		ctx.Logger().Error(utils.AppendError(msg, fmt.Errorf("format '%s' is not supported", format)))
+		return &echo.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+
+	data, err := ioutil.ReadAll(ctx.Request().Body)
+	if err != nil {
+		msg := "request body reading error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	o := convert.CSVOptions{Key: ctx.QueryParam("key")}
+	report, err := convert.NewImporter(c.getStores(ctx)).CSV(ctx.Request().Context(), ctx.Param("id"), data, o)
+	if err != nil {
+		msg := "sections import error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		httpErr := utils.BuildHTTPError(err, msg)
+		if report != nil {
+			// the report tells which rows are applied before the error
+			return ctx.JSON(httpErr.Code, report)
+		}
+		return httpErr
+	}
+
+	return ctx.JSON(http.StatusOK, report)
+}
+
+// NewImportController creates a new instance of import controller.
+func NewImportController(c ioc.Container) *ImportController {
+	return &ImportController{
+		container: c,
+	}
+}
+
+func (c *ImportController) getStores(ctx echo.Context) (notestore.Notestore, sectionstore.Sectionstore) {
+	accessToken := ctx.Get(middlewares.ContextKeyAccessToken).(string)
+	ns, _ := c.container.GetInstance(ioc.InstanceTypeNotestore, accessToken)
+	ss, _ := c.container.GetInstance(ioc.InstanceTypeSectionstore, accessToken)
+	return withNoteEncryption(ctx, ns.(notestore.Notestore)),
+		withSectionEncryption(ctx, ss.(sectionstore.Sectionstore))
+}
+
+// formFiles reads all files of the multipart 'files' field.
+func formFiles(ctx echo.Context) ([]*convert.File, error) {
+	form, err := ctx.MultipartForm()
+	if err != nil || len(form.File["files"]) == 0 {
+		msg := "multipart 'files' field is required"
+		ctx.Logger().Warn(utils.AppendError(msg, err))
+		return nil, &echo.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+
+	var files []*convert.File
+	for _, fh := range form.File["files"] {
+		if fh.Size > convert.MaxFileSize {
+			msg := fmt.Sprintf("file '%s' must be less than %d bytes", fh.Filename, convert.MaxFileSize)
+			ctx.Logger().Warn(msg)
+			return nil, &echo.HTTPError{
+				Code:    http.StatusRequestEntityTooLarge,
+				Message: msg,
+			}
+		}
+		f, err := fh.Open()
+		if err != nil {
+			msg := "file reading error"
+			ctx.Logger().Error(utils.AppendError(msg, err))
+			return nil, utils.BuildHTTPError(err, msg)
+		}
+		data, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			msg := "file reading error"
+			ctx.Logger().Error(utils.AppendError(msg, err))
+			return nil, utils.BuildHTTPError(err, msg)
+		}
+		files = append(files, &convert.File{Name: fh.Filename, Data: data})
+	}
+	return files, nil
+}
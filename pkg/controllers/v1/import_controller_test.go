@@ -0,0 +1,182 @@
+package v1_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	ctrlv1 "github.com/psewda/typing/pkg/controllers/v1"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/storage/convert"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+var _ = Describe("import controller", func() {
+	var (
+		mockContainer    *mocks.MockContainer
+		mockNotestore    *mocks.MockNotestore
+		mockSectionstore *mocks.MockSectionstore
+		rec              *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		mockContainer = mocks.NewMockContainer(mockCtrl)
+		mockNotestore = mocks.NewMockNotestore(mockCtrl)
+		mockSectionstore = mocks.NewMockSectionstore(mockCtrl)
+		rec = httptest.NewRecorder()
+	})
+
+	expectStores := func() {
+		mockContainer.EXPECT().GetInstance(ioc.InstanceTypeNotestore, gomock.Any()).Return(mockNotestore, nil)
+		mockContainer.EXPECT().GetInstance(ioc.InstanceTypeSectionstore, gomock.Any()).Return(mockSectionstore, nil)
+	}
+
+	newReq := func(query string, files map[string]string) *http.Request {
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		for name, content := range files {
+			part, _ := writer.CreateFormFile("files", name)
+			part.Write([]byte(content))
+		}
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, importRoute+query, body)
+		req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+		return req
+	}
+
+	Context("import notes", func() {
+		It("should create the notes and report each file", func() {
+			expectStores()
+			mockNotestore.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&notestore.Note{ID: "id"}, nil)
+			mockSectionstore.EXPECT().Create(gomock.Any(), "id", gomock.Any()).Return(&sectionstore.Section{ID: "sid"}, nil)
+			req := newReq("?format=markdown", map[string]string{
+				"good.md": "# Good\n\n## Section\n\nkey: value\n",
+				"bad.md":  "---\nlabels: [a\n---\n",
+			})
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewImportController(mockContainer).ImportNotes(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+
+			var report convert.ImportReport
+			json.NewDecoder(rec.Body).Decode(&report)
+			Expect(report.Notes).Should(Equal(2))
+			Expect(report.Imported).Should(Equal(1))
+			Expect(report.Failed).Should(Equal(1))
+		})
+
+		It("should create the notes of evernote export", func() {
+			expectStores()
+			mockNotestore.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&notestore.Note{ID: "id"}, nil)
+			req := newReq("?format=enex", map[string]string{
+				"notes.enex": "<en-export><note><title>Trip</title><tag>travel</tag></note></en-export>",
+			})
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewImportController(mockContainer).ImportNotes(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+
+			var report convert.ImportReport
+			json.NewDecoder(rec.Body).Decode(&report)
+			Expect(report.Imported).Should(Equal(1))
+			Expect(report.Results[0].Name).Should(Equal("Trip"))
+		})
+
+		It("should return error when no files", func() {
+			req := newReq("", nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewImportController(mockContainer).ImportNotes(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusBadRequest))
+		})
+
+		It("should return error when unsupported format", func() {
+			req := newReq("?format=pdf", map[string]string{"note.md": "# Note"})
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewImportController(mockContainer).ImportNotes(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusBadRequest))
+		})
+
+		It("should return error when unauthorized", func() {
+			expectStores()
+			mockNotestore.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, errs.NewUnauthorizedError())
+			req := newReq("", map[string]string{"note.md": "# Note"})
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewImportController(mockContainer).ImportNotes(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	Context("import sections", func() {
+		It("should upsert the sections of csv rows", func() {
+			expectStores()
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "id", nil).
+				Return([]*sectionstore.Section{{ID: "sid", Name: "ann", Data: map[string]string{"role": "dev"}}}, nil)
+			mockSectionstore.EXPECT().Batch(gomock.Any(), "id", gomock.Any()).
+				Return([]*sectionstore.OperationResult{{ID: "sid"}, {ID: "sid2"}}, nil)
+			req := httptest.NewRequest(http.MethodPost, importCSVRoute+"?key=name", strings.NewReader("name,role\nann,lead\nbob,qa\n"))
+			ctx := newCtx(req, rec, withAccessToken())
+			ctx.SetParamNames("id")
+			ctx.SetParamValues("id")
+
+			ctrlv1.NewImportController(mockContainer).ImportSections(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+
+			var report convert.CSVReport
+			json.NewDecoder(rec.Body).Decode(&report)
+			Expect(report.Updated).Should(Equal(1))
+			Expect(report.Created).Should(Equal(1))
+		})
+
+		It("should return the report with error status when batch failed", func() {
+			expectStores()
+			mockSectionstore.EXPECT().Batch(gomock.Any(), "id", gomock.Any()).Return(nil, errs.NewUnauthorizedError())
+			req := httptest.NewRequest(http.MethodPost, importCSVRoute, strings.NewReader("name\nann\n"))
+			ctx := newCtx(req, rec, withAccessToken())
+			ctx.SetParamNames("id")
+			ctx.SetParamValues("id")
+
+			err := ctrlv1.NewImportController(mockContainer).ImportSections(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(rec.Code).Should(Equal(http.StatusUnauthorized))
+
+			var report convert.CSVReport
+			json.NewDecoder(rec.Body).Decode(&report)
+			Expect(report.Failed).Should(Equal(1))
+			Expect(report.Results[0].Errors).ShouldNot(BeEmpty())
+		})
+
+		It("should return error when key column missing", func() {
+			expectStores()
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "id", nil).Return(nil, nil)
+			req := httptest.NewRequest(http.MethodPost, importCSVRoute+"?key=email", strings.NewReader("name\nann\n"))
+			ctx := newCtx(req, rec, withAccessToken())
+			ctx.SetParamNames("id")
+			ctx.SetParamValues("id")
+
+			err := ctrlv1.NewImportController(mockContainer).ImportSections(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusBadRequest))
+		})
+
+		It("should return error when unsupported format", func() {
+			req := httptest.NewRequest(http.MethodPost, importCSVRoute+"?format=xlsx", strings.NewReader("name"))
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewImportController(mockContainer).ImportSections(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusBadRequest))
+		})
+	})
+})
@@ -0,0 +1,81 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/middlewares"
+	"github.com/psewda/typing/pkg/storage/links"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+// LinksController represents all operations on links between notes.
+type LinksController struct {
+	container ioc.Container
+}
+
+// AddRoutes configures all routes of links endpoint
+// in the 'echo' server runtime.
+func (c *LinksController) AddRoutes(e *echo.Echo) {
+	if e != nil {
+		a := middlewares.Authorization()
+		enc := middlewares.Encryption()
+		e.GET("/api/v1/storage/notes/:id/backlinks", c.GetBacklinks, a, enc)
+		e.GET("/api/v1/storage/links/dangling", c.GetDanglingLinks, a, enc)
+	}
+}
+
+// GetBacklinks fetches all sections linking to the note and returns to the client.
+func (c *LinksController) GetBacklinks(ctx echo.Context) error {
+	ns, ss := c.getStores(ctx)
+	id := ctx.Param("id")
+
+	// the note must exist for backlinks
+	if _, err := ns.Get(ctx.Request().Context(), id); err != nil {
+		msg := "note retrival error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	backlinks, err := links.New(ns, ss).Backlinks(ctx.Request().Context(), id)
+	if err != nil {
+		msg := "backlink retrival error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	return ctx.JSON(http.StatusOK, backlinks)
+}
+
+// GetDanglingLinks fetches all links whose target note or section doesn't
+// exist, e.g. after deletion, and returns to the client.
+func (c *LinksController) GetDanglingLinks(ctx echo.Context) error {
+	ns, ss := c.getStores(ctx)
+
+	dangling, err := links.New(ns, ss).Dangling(ctx.Request().Context())
+	if err != nil {
+		msg := "dangling link retrival error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	return ctx.JSON(http.StatusOK, dangling)
+}
+
+// NewLinksController creates a new instance of links controller.
+func NewLinksController(c ioc.Container) *LinksController {
+	return &LinksController{
+		container: c,
+	}
+}
+
+func (c *LinksController) getStores(ctx echo.Context) (notestore.Notestore, sectionstore.Sectionstore) {
+	accessToken := ctx.Get(middlewares.ContextKeyAccessToken).(string)
+	ns, _ := c.container.GetInstance(ioc.InstanceTypeNotestore, accessToken)
+	ss, _ := c.container.GetInstance(ioc.InstanceTypeSectionstore, accessToken)
+	return withNoteEncryption(ctx, ns.(notestore.Notestore)),
+		withSectionEncryption(ctx, ss.(sectionstore.Sectionstore))
+}
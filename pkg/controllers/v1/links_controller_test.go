@@ -0,0 +1,97 @@
+package v1_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	ctrlv1 "github.com/psewda/typing/pkg/controllers/v1"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/storage/links"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+var _ = Describe("links controller", func() {
+	var (
+		mockContainer    *mocks.MockContainer
+		mockNotestore    *mocks.MockNotestore
+		mockSectionstore *mocks.MockSectionstore
+		rec              *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		mockContainer = mocks.NewMockContainer(mockCtrl)
+		mockNotestore = mocks.NewMockNotestore(mockCtrl)
+		mockSectionstore = mocks.NewMockSectionstore(mockCtrl)
+		rec = httptest.NewRecorder()
+
+		mockContainer.EXPECT().GetInstance(ioc.InstanceTypeNotestore, gomock.Any()).Return(mockNotestore, nil)
+		mockContainer.EXPECT().GetInstance(ioc.InstanceTypeSectionstore, gomock.Any()).Return(mockSectionstore, nil)
+	})
+
+	Context("get backlinks", func() {
+		It("should return backlinks when note exists", func() {
+			mockNotestore.EXPECT().Get(gomock.Any(), "id").Return(&notestore.Note{ID: "id"}, nil)
+			mockNotestore.EXPECT().GetAll(gomock.Any()).Return([]*notestore.Note{{ID: "nid"}}, nil)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "nid", nil).Return([]*sectionstore.Section{
+				{ID: "sid", Links: []*sectionstore.Link{{NoteID: "id"}}},
+			}, nil)
+			req := httptest.NewRequest(http.MethodGet, backlinksRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+			ctx.SetParamNames("id")
+			ctx.SetParamValues("id")
+
+			ctrlv1.NewLinksController(mockContainer).GetBacklinks(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+
+			var backlinks []*links.Backlink
+			json.Unmarshal(rec.Body.Bytes(), &backlinks)
+			Expect(backlinks).Should(HaveLen(1))
+			Expect(backlinks[0].SectionID).Should(Equal("sid"))
+		})
+
+		It("should return error when note not found", func() {
+			mockNotestore.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, errs.NewNotFoundError("error"))
+			req := httptest.NewRequest(http.MethodGet, backlinksRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewLinksController(mockContainer).GetBacklinks(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("get dangling links", func() {
+		It("should return dangling links when correct setup", func() {
+			mockNotestore.EXPECT().GetAll(gomock.Any()).Return([]*notestore.Note{{ID: "nid"}}, nil)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "nid", nil).Return([]*sectionstore.Section{
+				{ID: "sid", Links: []*sectionstore.Link{{NoteID: "deleted"}}},
+			}, nil)
+			req := httptest.NewRequest(http.MethodGet, danglingRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewLinksController(mockContainer).GetDanglingLinks(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+
+			var dangling []*links.DanglingLink
+			json.Unmarshal(rec.Body.Bytes(), &dangling)
+			Expect(dangling).Should(HaveLen(1))
+			Expect(dangling[0].Link.NoteID).Should(Equal("deleted"))
+		})
+
+		It("should return error when notestore failure", func() {
+			mockNotestore.EXPECT().GetAll(gomock.Any()).Return(nil, errors.New("error"))
+			req := httptest.NewRequest(http.MethodGet, danglingRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewLinksController(mockContainer).GetDanglingLinks(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusInternalServerError))
+		})
+	})
+})
@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/middlewares"
+	"github.com/psewda/typing/pkg/storage/migrate"
+)
+
+// MigrateController represents all operations on the migration of notes
+// between storage backends. It is registered only when the local file
+// system backend is configured.
+type MigrateController struct {
+	container ioc.Container
+}
+
+// migrateSpec is the request body of migration start.
+type migrateSpec struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	migrate.Options
+}
+
+// AddRoutes configures all routes of migrate endpoint
+// in the 'echo' server runtime.
+func (c *MigrateController) AddRoutes(e *echo.Echo) {
+	if e != nil {
+		a := middlewares.Authorization()
+		group := e.Group("/api/v1/admin/migrate", a)
+		group.GET(utils.Empty, c.GetMigration)
+		group.POST(utils.Empty, c.StartMigration)
+	}
+}
+
+// StartMigration begins the migration of user notes in background, and
+// returns the running job to the client.
+func (c *MigrateController) StartMigration(ctx echo.Context) error {
+	spec := new(migrateSpec)
+	if err := ctx.Bind(spec); err != nil {
+		msg := "spec validation failed"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return &echo.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+
+	job, err := c.getMigrator(ctx).Start(ctx.Request().Context(), spec.From, spec.To, spec.Options)
+	if err != nil {
+		msg := "migration start error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	return ctx.JSON(http.StatusAccepted, job)
+}
+
+// GetMigration returns the last migration job of user, the report is set
+// when the migration is finished.
+func (c *MigrateController) GetMigration(ctx echo.Context) error {
+	job, err := c.getMigrator(ctx).Job(ctx.Request().Context())
+	if err != nil {
+		msg := "migration retrival error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	return ctx.JSON(http.StatusOK, job)
+}
+
+// NewMigrateController creates a new instance of migrate controller.
+func NewMigrateController(c ioc.Container) *MigrateController {
+	return &MigrateController{
+		container: c,
+	}
+}
+
+func (c *MigrateController) getMigrator(ctx echo.Context) migrate.Migrator {
+	accessToken := ctx.Get(middlewares.ContextKeyAccessToken).(string)
+	m, _ := c.container.GetInstance(ioc.InstanceTypeMigrator, accessToken)
+	return m.(migrate.Migrator)
+}
@@ -0,0 +1,89 @@
+package v1_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	ctrlv1 "github.com/psewda/typing/pkg/controllers/v1"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/storage/migrate"
+)
+
+var _ = Describe("migrate controller", func() {
+	var (
+		mockContainer *mocks.MockContainer
+		mockMigrator  *mocks.MockMigrator
+		rec           *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		mockContainer = mocks.NewMockContainer(mockCtrl)
+		mockMigrator = mocks.NewMockMigrator(mockCtrl)
+		rec = httptest.NewRecorder()
+
+		mockContainer.EXPECT().GetInstance(ioc.InstanceTypeMigrator, gomock.Any()).Return(mockMigrator, nil)
+	})
+
+	Context("start migration", func() {
+		It("should return the running job when correct spec", func() {
+			options := migrate.Options{DryRun: true, Verify: true}
+			mockMigrator.EXPECT().Start(gomock.Any(), migrate.BackendDrive, migrate.BackendFS, options).
+				Return(&migrate.Job{From: migrate.BackendDrive, To: migrate.BackendFS, Running: true}, nil)
+			j := `{ "from": "drive", "to": "fs", "dryRun": true, "verify": true }`
+			req := httptest.NewRequest(http.MethodPost, migrateRoute, strings.NewReader(j))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewMigrateController(mockContainer).StartMigration(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusAccepted))
+
+			var job migrate.Job
+			json.Unmarshal(rec.Body.Bytes(), &job)
+			Expect(job.Running).Should(BeTrue())
+		})
+
+		It("should return error when migration already running", func() {
+			mockMigrator.EXPECT().Start(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(nil, errs.NewConflictError("error"))
+			j := `{ "from": "drive", "to": "fs" }`
+			req := httptest.NewRequest(http.MethodPost, migrateRoute, strings.NewReader(j))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewMigrateController(mockContainer).StartMigration(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusConflict))
+		})
+	})
+
+	Context("get migration", func() {
+		It("should return the job when migration started", func() {
+			mockMigrator.EXPECT().Job(gomock.Any()).Return(&migrate.Job{Report: &migrate.Report{Copied: 2}}, nil)
+			req := httptest.NewRequest(http.MethodGet, migrateRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewMigrateController(mockContainer).GetMigration(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+
+			var job migrate.Job
+			json.Unmarshal(rec.Body.Bytes(), &job)
+			Expect(job.Report.Copied).Should(Equal(2))
+		})
+
+		It("should return error when no migration", func() {
+			mockMigrator.EXPECT().Job(gomock.Any()).Return(nil, errs.NewNotFoundError("error"))
+			req := httptest.NewRequest(http.MethodGet, migrateRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewMigrateController(mockContainer).GetMigration(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusNotFound))
+		})
+	})
+})
@@ -8,7 +8,10 @@ import (
 	"github.com/psewda/typing/internal/utils"
 	"github.com/psewda/typing/pkg/ioc"
 	"github.com/psewda/typing/pkg/middlewares"
+	"github.com/psewda/typing/pkg/storage/attachstore"
+	"github.com/psewda/typing/pkg/storage/links"
 	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
 )
 
 // NotestoreController represents all operations on notestore endpoint.
@@ -21,7 +24,8 @@ type NotestoreController struct {
 func (c *NotestoreController) AddRoutes(e *echo.Echo) {
 	if e != nil {
 		a := middlewares.Authorization()
-		group := e.Group("/api/v1/storage/notes", a)
+		enc := middlewares.Encryption()
+		group := e.Group("/api/v1/storage/notes", a, enc)
 		group.POST(utils.Empty, c.CreateNote)
 		group.GET(utils.Empty, c.GetNotes)
 		group.GET("/:id", c.GetNote)
@@ -55,7 +59,7 @@ func (c *NotestoreController) CreateNote(ctx echo.Context) error {
 		}
 	}
 
-	note, err := ns.Create(n)
+	note, err := ns.Create(ctx.Request().Context(), n)
 	if err != nil {
 		msg := "note creation error"
 		ctx.Logger().Error(utils.AppendError(msg, err))
@@ -70,7 +74,7 @@ func (c *NotestoreController) CreateNote(ctx echo.Context) error {
 func (c *NotestoreController) GetNotes(ctx echo.Context) error {
 	ns := c.getNotestore(ctx)
 
-	notes, err := ns.GetAll()
+	notes, err := ns.GetAll(ctx.Request().Context())
 	if err != nil {
 		msg := "note retrival error"
 		ctx.Logger().Error(utils.AppendError(msg, err))
@@ -85,7 +89,7 @@ func (c *NotestoreController) GetNote(ctx echo.Context) error {
 	ns := c.getNotestore(ctx)
 	id := ctx.Param("id")
 
-	note, err := ns.Get(id)
+	note, err := ns.Get(ctx.Request().Context(), id)
 	if err != nil {
 		msg := "note retrival error"
 		ctx.Logger().Error(utils.AppendError(msg, err))
@@ -122,7 +126,7 @@ func (c *NotestoreController) UpdateNote(ctx echo.Context) error {
 	}
 
 	// try to update the note
-	note, err := ns.Update(id, n)
+	note, err := ns.Update(ctx.Request().Context(), id, n)
 	if err != nil {
 		msg := "note updation error"
 		ctx.Logger().Error(utils.AppendError(msg, err))
@@ -132,18 +136,38 @@ func (c *NotestoreController) UpdateNote(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, note)
 }
 
-// DeleteNote removes the note from cloud storage.
+// DeleteNote removes the note and all its attachments from cloud storage.
+// The links of other notes to the deleted note are dangling, so they are
+// returned to the client. The response has no content when no link.
 func (c *NotestoreController) DeleteNote(ctx echo.Context) error {
 	ns := c.getNotestore(ctx)
+	as := c.getAttachstore(ctx)
 	id := ctx.Param("id")
 
-	err := ns.Delete(id)
+	// attachments are deleted first, so that a failure never
+	// leaves the attachments without the note
+	err := as.DeleteAll(ctx.Request().Context(), id)
+	if err != nil {
+		msg := "attachment deletion error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	err = ns.Delete(ctx.Request().Context(), id)
 	if err != nil {
 		msg := "note deletion error"
 		ctx.Logger().Error(utils.AppendError(msg, err))
 		return utils.BuildHTTPError(err, msg)
 	}
 
+	// the note is already deleted, so the link lookup failure is not an error
+	dangling, err := links.New(ns, c.getSectionstore(ctx)).DanglingTo(ctx.Request().Context(), id)
+	if err != nil {
+		ctx.Logger().Warn(utils.AppendError("dangling link retrival error", err))
+	}
+	if len(dangling) > 0 {
+		return ctx.JSON(http.StatusOK, dangling)
+	}
 	return ctx.NoContent(http.StatusNoContent)
 }
 
@@ -156,7 +180,18 @@ func NewNotestoreController(c ioc.Container) *NotestoreController {
 
 func (c *NotestoreController) getNotestore(ctx echo.Context) notestore.Notestore {
 	accessToken := ctx.Get(middlewares.ContextKeyAccessToken).(string)
-	client := utils.ClientWithToken(accessToken)
-	instance, _ := c.container.GetInstance(ioc.InstanceTypeNotestore, client)
-	return instance.(notestore.Notestore)
+	instance, _ := c.container.GetInstance(ioc.InstanceTypeNotestore, accessToken)
+	return withNoteEncryption(ctx, instance.(notestore.Notestore))
+}
+
+func (c *NotestoreController) getSectionstore(ctx echo.Context) sectionstore.Sectionstore {
+	accessToken := ctx.Get(middlewares.ContextKeyAccessToken).(string)
+	instance, _ := c.container.GetInstance(ioc.InstanceTypeSectionstore, accessToken)
+	return withSectionEncryption(ctx, instance.(sectionstore.Sectionstore))
+}
+
+func (c *NotestoreController) getAttachstore(ctx echo.Context) attachstore.Attachstore {
+	accessToken := ctx.Get(middlewares.ContextKeyAccessToken).(string)
+	instance, _ := c.container.GetInstance(ioc.InstanceTypeAttachstore, accessToken)
+	return instance.(attachstore.Attachstore)
 }
@@ -13,7 +13,10 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/psewda/typing/mocks"
 	ctrlv1 "github.com/psewda/typing/pkg/controllers/v1"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/storage/links"
 	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
 )
 
 var _ = Describe("notestore controller", func() {
@@ -43,7 +46,7 @@ var _ = Describe("notestore controller", func() {
 				Name: "note",
 			}
 			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockNotestore, nil)
-			mockNotestore.EXPECT().Create(gomock.Any()).Return(note, nil)
+			mockNotestore.EXPECT().Create(gomock.Any(), gomock.Any()).Return(note, nil)
 			req := newReq(`{"name": "note"}`)
 			ctx := newCtx(req, rec, withAccessToken())
 			ctx.SetPath(notesRoute)
@@ -73,7 +76,7 @@ var _ = Describe("notestore controller", func() {
 
 		It("should return error when inner error", func() {
 			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockNotestore, nil)
-			mockNotestore.EXPECT().Create(gomock.Any()).Return(nil, errors.New("error"))
+			mockNotestore.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
 			req := newReq(`{"name": "note"}`)
 			ctx := newCtx(req, rec, withAccessToken())
 
@@ -97,7 +100,7 @@ var _ = Describe("notestore controller", func() {
 					Name: "note2",
 				},
 			}
-			mockNotestore.EXPECT().GetAll().Return(notes, nil)
+			mockNotestore.EXPECT().GetAll(gomock.Any()).Return(notes, nil)
 			req := httptest.NewRequest(http.MethodGet, notesRoute, nil)
 			ctx := newCtx(req, rec, withAccessToken())
 
@@ -114,7 +117,7 @@ var _ = Describe("notestore controller", func() {
 
 		It("should return error when inner error", func() {
 			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockNotestore, nil)
-			mockNotestore.EXPECT().GetAll().Return(nil, errors.New("error"))
+			mockNotestore.EXPECT().GetAll(gomock.Any()).Return(nil, errors.New("error"))
 			req := httptest.NewRequest(http.MethodGet, notesRoute, nil)
 			ctx := newCtx(req, rec, withAccessToken())
 
@@ -134,7 +137,7 @@ var _ = Describe("notestore controller", func() {
 				Description: "desc",
 				Labels:      []string{"label1", "label2"},
 			}
-			mockNotestore.EXPECT().Get(gomock.Any()).Return(note, nil)
+			mockNotestore.EXPECT().Get(gomock.Any(), gomock.Any()).Return(note, nil)
 			req := httptest.NewRequest(http.MethodGet, noteRouteWithID, nil)
 			ctx := newCtx(req, rec, withAccessToken())
 
@@ -150,7 +153,7 @@ var _ = Describe("notestore controller", func() {
 
 		It("should return error when inner error", func() {
 			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockNotestore, nil)
-			mockNotestore.EXPECT().Get(gomock.Any()).Return(nil, errors.New("error"))
+			mockNotestore.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
 			req := httptest.NewRequest(http.MethodGet, noteRouteWithID, nil)
 			ctx := newCtx(req, rec, withAccessToken())
 
@@ -177,7 +180,7 @@ var _ = Describe("notestore controller", func() {
 				Description: "desc",
 				Labels:      []string{"label1", "label2"},
 			}
-			mockNotestore.EXPECT().Update(gomock.Any(), gomock.Any()).Return(note, nil)
+			mockNotestore.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(note, nil)
 			req := newReq(`{"name": "note"}`)
 			ctx := newCtx(req, rec, withAccessToken())
 
@@ -204,7 +207,7 @@ var _ = Describe("notestore controller", func() {
 
 		It("should return error when inner error", func() {
 			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockNotestore, nil)
-			mockNotestore.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
+			mockNotestore.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
 			req := newReq(`{"name": "note"}`)
 			ctx := newCtx(req, rec, withAccessToken())
 
@@ -216,18 +219,63 @@ var _ = Describe("notestore controller", func() {
 	})
 
 	Context("delete note", func() {
+		var mockAttachstore *mocks.MockAttachstore
+
+		BeforeEach(func() {
+			mockAttachstore = mocks.NewMockAttachstore(mockCtrl)
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeNotestore, gomock.Any()).Return(mockNotestore, nil)
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeAttachstore, gomock.Any()).Return(mockAttachstore, nil)
+		})
+
 		It("should succeed when correct note id", func() {
-			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockNotestore, nil)
-			mockNotestore.EXPECT().Delete(gomock.Any()).Return(nil)
+			mockAttachstore.EXPECT().DeleteAll(gomock.Any(), gomock.Any()).Return(nil)
+			mockNotestore.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(nil)
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeSectionstore, gomock.Any()).Return(mocks.NewMockSectionstore(mockCtrl), nil)
+			mockNotestore.EXPECT().GetAll(gomock.Any()).Return(nil, nil)
 			req := httptest.NewRequest(http.MethodDelete, noteRouteWithID, nil)
 			ctx := newCtx(req, rec, withAccessToken())
 			ctrlv1.NewNotestoreController(mockContainer).DeleteNote(ctx)
 			Expect(rec.Code).Should(Equal(http.StatusNoContent))
 		})
 
+		It("should return the dangling links when other notes link to it", func() {
+			mockSectionstore := mocks.NewMockSectionstore(mockCtrl)
+			mockAttachstore.EXPECT().DeleteAll(gomock.Any(), "id").Return(nil)
+			mockNotestore.EXPECT().Delete(gomock.Any(), "id").Return(nil)
+			mockContainer.EXPECT().GetInstance(ioc.InstanceTypeSectionstore, gomock.Any()).Return(mockSectionstore, nil)
+			mockNotestore.EXPECT().GetAll(gomock.Any()).Return([]*notestore.Note{{ID: "other"}}, nil)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "other", nil).Return([]*sectionstore.Section{
+				{ID: "sid", Links: []*sectionstore.Link{{NoteID: "id"}}},
+			}, nil)
+			req := httptest.NewRequest(http.MethodDelete, noteRouteWithID, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+			ctx.SetParamNames("id")
+			ctx.SetParamValues("id")
+
+			ctrlv1.NewNotestoreController(mockContainer).DeleteNote(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+
+			var dangling []*links.DanglingLink
+			json.NewDecoder(rec.Body).Decode(&dangling)
+			Expect(dangling).Should(HaveLen(1))
+			Expect(dangling[0].NoteID).Should(Equal("other"))
+			Expect(dangling[0].SectionID).Should(Equal("sid"))
+		})
+
 		It("should return error when inner error", func() {
-			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockNotestore, nil)
-			mockNotestore.EXPECT().Delete(gomock.Any()).Return(errors.New("error"))
+			mockAttachstore.EXPECT().DeleteAll(gomock.Any(), gomock.Any()).Return(nil)
+			mockNotestore.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(errors.New("error"))
+			req := httptest.NewRequest(http.MethodDelete, noteRouteWithID, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewNotestoreController(mockContainer).DeleteNote(ctx)
+			httpError := toHTTPError(err)
+			Expect(httpError).Should(HaveOccurred())
+			Expect(httpError.Code).Should(Equal(http.StatusInternalServerError))
+		})
+
+		It("should not delete note when attachment deletion error", func() {
+			mockAttachstore.EXPECT().DeleteAll(gomock.Any(), gomock.Any()).Return(errors.New("error"))
 			req := httptest.NewRequest(http.MethodDelete, noteRouteWithID, nil)
 			ctx := newCtx(req, rec, withAccessToken())
 
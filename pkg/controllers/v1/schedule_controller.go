@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/middlewares"
+	"github.com/psewda/typing/pkg/storage/backup"
+)
+
+// ScheduleController represents the status of scheduled backups. It is
+// registered only when the backup schedule is configured.
+type ScheduleController struct {
+	container ioc.Container
+}
+
+// AddRoutes configures all routes of schedule endpoint
+// in the 'echo' server runtime.
+func (c *ScheduleController) AddRoutes(e *echo.Echo) {
+	if e != nil {
+		a := middlewares.Authorization()
+		e.GET("/api/v1/storage/backup/schedule", c.GetStatus, a)
+	}
+}
+
+// GetStatus returns the last run, last error and stored snapshots of the
+// scheduled backups of user.
+func (c *ScheduleController) GetStatus(ctx echo.Context) error {
+	status, err := c.getReporter(ctx).Status(ctx.Request().Context())
+	if err != nil {
+		msg := "backup schedule retrival error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	return ctx.JSON(http.StatusOK, status)
+}
+
+// NewScheduleController creates a new instance of schedule controller.
+func NewScheduleController(c ioc.Container) *ScheduleController {
+	return &ScheduleController{
+		container: c,
+	}
+}
+
+func (c *ScheduleController) getReporter(ctx echo.Context) backup.Reporter {
+	accessToken := ctx.Get(middlewares.ContextKeyAccessToken).(string)
+	r, _ := c.container.GetInstance(ioc.InstanceTypeReporter, accessToken)
+	return r.(backup.Reporter)
+}
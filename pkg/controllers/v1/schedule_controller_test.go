@@ -0,0 +1,57 @@
+package v1_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	ctrlv1 "github.com/psewda/typing/pkg/controllers/v1"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/storage/backup"
+)
+
+var _ = Describe("schedule controller", func() {
+	var (
+		mockContainer *mocks.MockContainer
+		mockReporter  *mocks.MockReporter
+		rec           *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		mockContainer = mocks.NewMockContainer(mockCtrl)
+		mockReporter = mocks.NewMockReporter(mockCtrl)
+		rec = httptest.NewRecorder()
+
+		mockContainer.EXPECT().GetInstance(ioc.InstanceTypeReporter, gomock.Any()).Return(mockReporter, nil)
+	})
+
+	Context("get status", func() {
+		It("should return the status when user scheduled", func() {
+			status := &backup.Status{Email: "user@example.com", Snapshots: []*backup.Snapshot{{Name: "snapshot"}}}
+			mockReporter.EXPECT().Status(gomock.Any()).Return(status, nil)
+			req := httptest.NewRequest(http.MethodGet, scheduleRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewScheduleController(mockContainer).GetStatus(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+
+			var s backup.Status
+			json.Unmarshal(rec.Body.Bytes(), &s)
+			Expect(s.Snapshots).Should(HaveLen(1))
+		})
+
+		It("should return error when user not scheduled", func() {
+			mockReporter.EXPECT().Status(gomock.Any()).Return(nil, errs.NewNotFoundError("error"))
+			req := httptest.NewRequest(http.MethodGet, scheduleRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewScheduleController(mockContainer).GetStatus(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusNotFound))
+		})
+	})
+})
@@ -1,13 +1,18 @@
 package v1
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"path"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/psewda/typing/internal/utils"
 	"github.com/psewda/typing/pkg/ioc"
 	"github.com/psewda/typing/pkg/middlewares"
+	"github.com/psewda/typing/pkg/schema"
 	"github.com/psewda/typing/pkg/storage/sectionstore"
 )
 
@@ -21,12 +26,22 @@ type SectionstoreController struct {
 func (c *SectionstoreController) AddRoutes(e *echo.Echo) {
 	if e != nil {
 		a := middlewares.Authorization()
-		group := e.Group("/api/v1/storage/notes/:nid/sections", a)
+		enc := middlewares.Encryption()
+		group := e.Group("/api/v1/storage/notes/:nid/sections", a, enc)
 		group.POST(utils.Empty, c.CreateSection)
 		group.GET(utils.Empty, c.GetSections)
 		group.GET("/:id", c.GetSection)
 		group.PUT("/:id", c.UpdateSection)
 		group.DELETE("/:id", c.DeleteSection)
+
+		// custom methods like 'sections:batch' are routed by the path param,
+		// because the router always treats ':' as the start of path param
+		group.POST(":method", c.invokeMethod)
+
+		schemaGroup := e.Group("/api/v1/storage/notes/:nid/schema", a, enc)
+		schemaGroup.GET(utils.Empty, c.GetSchema)
+		schemaGroup.PUT(utils.Empty, c.SetSchema)
+		schemaGroup.DELETE(utils.Empty, c.DeleteSchema)
 	}
 }
 
@@ -56,7 +71,7 @@ func (c *SectionstoreController) CreateSection(ctx echo.Context) error {
 		}
 	}
 
-	section, err := ss.Create(nid, s)
+	section, err := ss.Create(ctx.Request().Context(), nid, s)
 	if err != nil {
 		msg := "section creation error"
 		ctx.Logger().Error(utils.AppendError(msg, err))
@@ -69,11 +84,22 @@ func (c *SectionstoreController) CreateSection(ctx echo.Context) error {
 }
 
 // GetSections fetches all sections from the note and returns to the client.
+// The sections can be filtered and sorted by using query params.
 func (c *SectionstoreController) GetSections(ctx echo.Context) error {
 	ss := c.getSectionstore(ctx)
 	nid := ctx.Param("nid")
 
-	sections, err := ss.GetAll(nid)
+	q, err := parseQuery(ctx)
+	if err != nil {
+		msg := err.Error()
+		ctx.Logger().Warn(msg)
+		return &echo.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+
+	sections, err := ss.GetAll(ctx.Request().Context(), nid, q)
 	if err != nil {
 		msg := "section retrival error"
 		ctx.Logger().Error(utils.AppendError(msg, err))
@@ -89,7 +115,7 @@ func (c *SectionstoreController) GetSection(ctx echo.Context) error {
 	nid := ctx.Param("nid")
 	id := ctx.Param("id")
 
-	section, err := ss.Get(nid, id)
+	section, err := ss.Get(ctx.Request().Context(), nid, id)
 	if err != nil {
 		msg := "section retrival error"
 		ctx.Logger().Error(utils.AppendError(msg, err))
@@ -127,7 +153,7 @@ func (c *SectionstoreController) UpdateSection(ctx echo.Context) error {
 	}
 
 	// try to update the section
-	section, err := ss.Update(nid, id, s)
+	section, err := ss.Update(ctx.Request().Context(), nid, id, s)
 	if err != nil {
 		msg := "section updation error"
 		ctx.Logger().Error(utils.AppendError(msg, err))
@@ -143,7 +169,7 @@ func (c *SectionstoreController) DeleteSection(ctx echo.Context) error {
 	nid := ctx.Param("nid")
 	id := ctx.Param("id")
 
-	err := ss.Delete(nid, id)
+	err := ss.Delete(ctx.Request().Context(), nid, id)
 	if err != nil {
 		msg := "section deletion error"
 		ctx.Logger().Error(utils.AppendError(msg, err))
@@ -153,6 +179,112 @@ func (c *SectionstoreController) DeleteSection(ctx echo.Context) error {
 	return ctx.NoContent(http.StatusNoContent)
 }
 
+// BatchSections applies many create, update and delete operations on the
+// note atomically and returns the result of each operation to the client.
+func (c *SectionstoreController) BatchSections(ctx echo.Context) error {
+	ss := c.getSectionstore(ctx)
+	nid := ctx.Param("nid")
+	batch := new(struct {
+		Operations []*sectionstore.Operation `json:"operations"`
+	})
+
+	if err := ctx.Bind(batch); err != nil {
+		msg := "spec validation failed"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return &echo.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+
+	// check all rules on operation validation
+	err := sectionstore.CheckOperations(batch.Operations)
+	if err != nil {
+		msg := err.Error()
+		ctx.Logger().Warn(msg)
+		return &echo.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+
+	results, err := ss.Batch(ctx.Request().Context(), nid, batch.Operations)
+	if err != nil {
+		msg := "section batch error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"results": results,
+	})
+}
+
+// GetSchema fetches the schema of section data declared by the note.
+func (c *SectionstoreController) GetSchema(ctx echo.Context) error {
+	ss := c.getSectionstore(ctx)
+	nid := ctx.Param("nid")
+
+	sch, err := ss.GetSchema(ctx.Request().Context(), nid)
+	if err != nil {
+		msg := "schema retrival error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	return ctx.JSON(http.StatusOK, sch)
+}
+
+// SetSchema declares the schema of section data in the note. The existing
+// sections must satisfy the schema, otherwise it is rejected.
+func (c *SectionstoreController) SetSchema(ctx echo.Context) error {
+	ss := c.getSectionstore(ctx)
+	nid := ctx.Param("nid")
+	sch := new(schema.Schema)
+
+	if err := ctx.Bind(sch); err != nil {
+		msg := "spec validation failed"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return &echo.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+
+	// check the schema itself
+	err := sch.Check()
+	if err != nil {
+		msg := err.Error()
+		ctx.Logger().Warn(msg)
+		return &echo.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+
+	if err := ss.SetSchema(ctx.Request().Context(), nid, sch); err != nil {
+		msg := "schema updation error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	return ctx.JSON(http.StatusOK, sch)
+}
+
+// DeleteSchema removes the schema declaration from the note.
+func (c *SectionstoreController) DeleteSchema(ctx echo.Context) error {
+	ss := c.getSectionstore(ctx)
+	nid := ctx.Param("nid")
+
+	if err := ss.SetSchema(ctx.Request().Context(), nid, nil); err != nil {
+		msg := "schema deletion error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
 // NewSectionstoreController creates a new instance of sectionstore controller.
 func NewSectionstoreController(c ioc.Container) *SectionstoreController {
 	return &SectionstoreController{
@@ -162,7 +294,73 @@ func NewSectionstoreController(c ioc.Container) *SectionstoreController {
 
 func (c *SectionstoreController) getSectionstore(ctx echo.Context) sectionstore.Sectionstore {
 	accessToken := ctx.Get(middlewares.ContextKeyAccessToken).(string)
-	client := utils.ClientWithToken(accessToken)
-	instance, _ := c.container.GetInstance(ioc.InstanceTypeSectionstore, client)
-	return instance.(sectionstore.Sectionstore)
+	instance, _ := c.container.GetInstance(ioc.InstanceTypeSectionstore, accessToken)
+	return withSectionEncryption(ctx, instance.(sectionstore.Sectionstore))
+}
+
+func (c *SectionstoreController) invokeMethod(ctx echo.Context) error {
+	switch ctx.Param("method") {
+	case ":batch":
+		return c.BatchSections(ctx)
+	default:
+		return echo.ErrNotFound
+	}
+}
+
+func parseQuery(ctx echo.Context) (*sectionstore.Query, error) {
+	params := ctx.QueryParams()
+	q := &sectionstore.Query{
+		Name:      ctx.QueryParam("name"),
+		Labels:    params["label"],
+		CreatedBy: ctx.QueryParam("createdBy"),
+		UpdatedBy: ctx.QueryParam("updatedBy"),
+		Sort:      ctx.QueryParam("sort"),
+	}
+
+	// metadata and data filters are passed as 'metadata.key=value'
+	// and 'data.key=value' query params
+	for name := range params {
+		if strings.HasPrefix(name, "metadata.") {
+			if q.Metadata == nil {
+				q.Metadata = make(map[string]string)
+			}
+			q.Metadata[name[len("metadata."):]] = params.Get(name)
+		}
+		if strings.HasPrefix(name, "data.") {
+			if q.Data == nil {
+				q.Data = make(map[string]string)
+			}
+			q.Data[name[len("data."):]] = params.Get(name)
+		}
+	}
+
+	// projection fields are passed as comma separated values
+	for _, f := range strings.Split(ctx.QueryParam("fields"), ",") {
+		if f = strings.TrimSpace(f); len(f) > 0 {
+			q.Fields = append(q.Fields, f)
+		}
+	}
+
+	times := map[string]*time.Time{
+		"createdAfter":  &q.CreatedAfter,
+		"createdBefore": &q.CreatedBefore,
+		"updatedAfter":  &q.UpdatedAfter,
+		"updatedBefore": &q.UpdatedBefore,
+	}
+	for name, t := range times {
+		value := ctx.QueryParam(name)
+		if len(value) > 0 {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				msg := fmt.Sprintf("query param '%s' must be in RFC3339 format", name)
+				return nil, errors.New(msg)
+			}
+			*t = parsed
+		}
+	}
+
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	return q, nil
 }
@@ -1,8 +1,10 @@
 package v1_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -13,6 +15,8 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/psewda/typing/mocks"
 	ctrlv1 "github.com/psewda/typing/pkg/controllers/v1"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/schema"
 	"github.com/psewda/typing/pkg/storage/sectionstore"
 )
 
@@ -43,7 +47,7 @@ var _ = Describe("sectionstore controller", func() {
 				Name: "section",
 			}
 			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
-			mockSectionstore.EXPECT().Create(gomock.Any(), gomock.Any()).Return(section, nil)
+			mockSectionstore.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(section, nil)
 			req := newReq(`{"name": "section"}`)
 			ctx := newCtx(req, rec, withAccessToken())
 
@@ -70,9 +74,32 @@ var _ = Describe("sectionstore controller", func() {
 			Expect(httpError.Code).Should(Equal(http.StatusBadRequest))
 		})
 
+		It("should return error when wrong section kind", func() {
+			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
+			req := newReq(`{"name": "section", "kind": "image"}`)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewSectionstoreController(mockContainer).CreateSection(ctx)
+			httpError := toHTTPError(err)
+			Expect(httpError).Should(HaveOccurred())
+			Expect(httpError.Code).Should(Equal(http.StatusBadRequest))
+		})
+
+		It("should return error when data violates note schema", func() {
+			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
+			mockSectionstore.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errs.NewValidationError("error"))
+			req := newReq(`{"name": "section"}`)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewSectionstoreController(mockContainer).CreateSection(ctx)
+			httpError := toHTTPError(err)
+			Expect(httpError).Should(HaveOccurred())
+			Expect(httpError.Code).Should(Equal(http.StatusBadRequest))
+		})
+
 		It("should return error when inner error", func() {
 			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
-			mockSectionstore.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
+			mockSectionstore.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
 			req := newReq(`{"name": "section"}`)
 			ctx := newCtx(req, rec, withAccessToken())
 
@@ -96,7 +123,7 @@ var _ = Describe("sectionstore controller", func() {
 					Name: "section2",
 				},
 			}
-			mockSectionstore.EXPECT().GetAll(gomock.Any()).Return(sections, nil)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), gomock.Any(), gomock.Any()).Return(sections, nil)
 			req := httptest.NewRequest(http.MethodGet, sectionsRoute, nil)
 			ctx := newCtx(req, rec, withAccessToken())
 
@@ -113,7 +140,7 @@ var _ = Describe("sectionstore controller", func() {
 
 		It("should return error when inner error", func() {
 			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
-			mockSectionstore.EXPECT().GetAll(gomock.Any()).Return(nil, errors.New("error"))
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
 			req := httptest.NewRequest(http.MethodGet, sectionsRoute, nil)
 			ctx := newCtx(req, rec, withAccessToken())
 
@@ -122,6 +149,55 @@ var _ = Describe("sectionstore controller", func() {
 			Expect(httpError).Should(HaveOccurred())
 			Expect(httpError.Code).Should(Equal(http.StatusInternalServerError))
 		})
+
+		It("should pass the query to sectionstore", func() {
+			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, nid string, q *sectionstore.Query) ([]*sectionstore.Section, error) {
+					Expect(q.CreatedBy).Should(Equal("uid"))
+					Expect(q.Sort).Should(Equal("-dateUpdated"))
+					Expect(q.UpdatedAfter.IsZero()).Should(BeFalse())
+					return nil, nil
+				})
+			url := sectionsRoute + "?createdBy=uid&sort=-dateUpdated&updatedAfter=2021-02-10T10:00:00Z"
+			req := httptest.NewRequest(http.MethodGet, url, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewSectionstoreController(mockContainer).GetSections(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+		})
+
+		It("should pass the filters and fields to sectionstore", func() {
+			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, nid string, q *sectionstore.Query) ([]*sectionstore.Section, error) {
+					Expect(q.Name).Should(Equal("contact"))
+					Expect(q.Labels).Should(ConsistOf("label1", "label2"))
+					Expect(q.Metadata).Should(HaveKeyWithValue("region", "eu"))
+					Expect(q.Data).Should(HaveKeyWithValue("email", ""))
+					Expect(q.Fields).Should(Equal([]string{"id", "name"}))
+					return nil, nil
+				})
+			url := sectionsRoute + "?name=contact&label=label1&label=label2&metadata.region=eu&data.email=&fields=id,name"
+			req := httptest.NewRequest(http.MethodGet, url, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewSectionstoreController(mockContainer).GetSections(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+		})
+
+		It("should return error when wrong query", func() {
+			for _, q := range []string{"?sort=label", "?createdAfter=yesterday", "?fields=id,color"} {
+				mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
+				req := httptest.NewRequest(http.MethodGet, sectionsRoute+q, nil)
+				ctx := newCtx(req, rec, withAccessToken())
+
+				err := ctrlv1.NewSectionstoreController(mockContainer).GetSections(ctx)
+				httpError := toHTTPError(err)
+				Expect(httpError).Should(HaveOccurred())
+				Expect(httpError.Code).Should(Equal(http.StatusBadRequest))
+			}
+		})
 	})
 
 	Context("get section by id", func() {
@@ -131,7 +207,7 @@ var _ = Describe("sectionstore controller", func() {
 				ID:   "hftg5wgs5dfs7",
 				Name: "section",
 			}
-			mockSectionstore.EXPECT().Get(gomock.Any(), gomock.Any()).Return(section, nil)
+			mockSectionstore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(section, nil)
 			req := httptest.NewRequest(http.MethodGet, sectionRouteWithID, nil)
 			ctx := newCtx(req, rec, withAccessToken())
 
@@ -146,7 +222,7 @@ var _ = Describe("sectionstore controller", func() {
 
 		It("should return error when inner error", func() {
 			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
-			mockSectionstore.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
+			mockSectionstore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
 			req := httptest.NewRequest(http.MethodGet, sectionRouteWithID, nil)
 			ctx := newCtx(req, rec, withAccessToken())
 
@@ -171,7 +247,7 @@ var _ = Describe("sectionstore controller", func() {
 				ID:   "hftg5wgs5dfs7",
 				Name: "section",
 			}
-			mockSectionstore.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(section, nil)
+			mockSectionstore.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(section, nil)
 			req := newReq(`{"name": "section"}`)
 			ctx := newCtx(req, rec, withAccessToken())
 
@@ -197,7 +273,7 @@ var _ = Describe("sectionstore controller", func() {
 
 		It("should return error when inner error", func() {
 			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
-			mockSectionstore.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
+			mockSectionstore.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
 			req := newReq(`{"name": "section"}`)
 			ctx := newCtx(req, rec, withAccessToken())
 
@@ -208,10 +284,153 @@ var _ = Describe("sectionstore controller", func() {
 		})
 	})
 
+	Context("batch sections", func() {
+		newReq := func(j string) *http.Request {
+			reader := strings.NewReader(j)
+			req := httptest.NewRequest(http.MethodPost, batchRoute, reader)
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			return req
+		}
+
+		It("should apply all operations when correct input", func() {
+			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
+			results := []*sectionstore.OperationResult{
+				{Type: sectionstore.OperationCreate, ID: "secid1", Section: &sectionstore.Section{ID: "secid1"}},
+				{Type: sectionstore.OperationDelete, ID: "secid2"},
+			}
+			mockSectionstore.EXPECT().Batch(gomock.Any(), gomock.Any(), gomock.Len(2)).Return(results, nil)
+			req := newReq(`{"operations": [
+				{"op": "create", "section": {"name": "section"}},
+				{"op": "delete", "id": "secid2"}
+			]}`)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewSectionstoreController(mockContainer).BatchSections(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+
+			var body struct {
+				Results []*sectionstore.OperationResult
+			}
+			json.NewDecoder(rec.Body).Decode(&body)
+			Expect(body.Results).Should(HaveLen(2))
+			Expect(body.Results[0].ID).Should(Equal("secid1"))
+		})
+
+		It("should route the custom method to batch", func() {
+			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
+			mockSectionstore.EXPECT().Batch(gomock.Any(), "nid", gomock.Any()).Return(nil, nil)
+			req := newReq(`{"operations": [{"op": "delete", "id": "secid"}]}`)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer access-token")
+
+			e := echo.New()
+			e.Logger.SetOutput(ioutil.Discard)
+			ctrlv1.NewSectionstoreController(mockContainer).AddRoutes(e)
+			e.ServeHTTP(rec, req)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+		})
+
+		It("should return error when wrong operation", func() {
+			inputs := []string{
+				`{"operations": []}`,
+				`{"operations": [{"op": "move", "id": "secid"}]}`,
+				`{"operations": [{"op": "update", "section": {"name": "section"}}]}`,
+				`{"operations": [{"op": "create", "section": {"name": ""}}]}`,
+			}
+			for _, j := range inputs {
+				mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
+				ctx := newCtx(newReq(j), rec, withAccessToken())
+
+				err := ctrlv1.NewSectionstoreController(mockContainer).BatchSections(ctx)
+				httpError := toHTTPError(err)
+				Expect(httpError).Should(HaveOccurred())
+				Expect(httpError.Code).Should(Equal(http.StatusBadRequest))
+			}
+		})
+
+		It("should return error when section not found", func() {
+			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
+			mockSectionstore.EXPECT().Batch(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errs.NewNotFoundError("error"))
+			req := newReq(`{"operations": [{"op": "delete", "id": "secid"}]}`)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewSectionstoreController(mockContainer).BatchSections(ctx)
+			httpError := toHTTPError(err)
+			Expect(httpError).Should(HaveOccurred())
+			Expect(httpError.Code).Should(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("note schema", func() {
+		newReq := func(j string) *http.Request {
+			reader := strings.NewReader(j)
+			req := httptest.NewRequest(http.MethodPut, schemaRoute, reader)
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			return req
+		}
+
+		It("should return the schema when declared", func() {
+			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
+			sch := &schema.Schema{Required: []string{"email"}}
+			mockSectionstore.EXPECT().GetSchema(gomock.Any(), gomock.Any()).Return(sch, nil)
+			req := httptest.NewRequest(http.MethodGet, schemaRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewSectionstoreController(mockContainer).GetSchema(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+
+			var s schema.Schema
+			json.NewDecoder(rec.Body).Decode(&s)
+			Expect(s.Required).Should(ContainElement("email"))
+		})
+
+		It("should set the schema when correct input", func() {
+			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
+			mockSectionstore.EXPECT().SetSchema(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			req := newReq(`{"required": ["email"], "properties": {"email": {"format": "email"}}}`)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewSectionstoreController(mockContainer).SetSchema(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+		})
+
+		It("should return error when wrong schema", func() {
+			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
+			req := newReq(`{"properties": {"email": {"type": "array"}}}`)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewSectionstoreController(mockContainer).SetSchema(ctx)
+			httpError := toHTTPError(err)
+			Expect(httpError).Should(HaveOccurred())
+			Expect(httpError.Code).Should(Equal(http.StatusBadRequest))
+		})
+
+		It("should return error when sections violate schema", func() {
+			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
+			mockSectionstore.EXPECT().SetSchema(gomock.Any(), gomock.Any(), gomock.Any()).Return(errs.NewValidationError("error"))
+			req := newReq(`{"required": ["email"], "properties": {"email": {}}}`)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewSectionstoreController(mockContainer).SetSchema(ctx)
+			httpError := toHTTPError(err)
+			Expect(httpError).Should(HaveOccurred())
+			Expect(httpError.Code).Should(Equal(http.StatusBadRequest))
+		})
+
+		It("should remove the schema", func() {
+			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
+			mockSectionstore.EXPECT().SetSchema(gomock.Any(), gomock.Any(), gomock.Nil()).Return(nil)
+			req := httptest.NewRequest(http.MethodDelete, schemaRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewSectionstoreController(mockContainer).DeleteSchema(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusNoContent))
+		})
+	})
+
 	Context("delete note", func() {
 		It("should succeed when correct section id", func() {
 			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
-			mockSectionstore.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(nil)
+			mockSectionstore.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
 			req := httptest.NewRequest(http.MethodDelete, sectionRouteWithID, nil)
 			ctx := newCtx(req, rec, withAccessToken())
 			ctrlv1.NewSectionstoreController(mockContainer).DeleteSection(ctx)
@@ -220,7 +439,7 @@ var _ = Describe("sectionstore controller", func() {
 
 		It("should return error when inner error", func() {
 			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockSectionstore, nil)
-			mockSectionstore.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(errors.New("error"))
+			mockSectionstore.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("error"))
 			req := httptest.NewRequest(http.MethodDelete, sectionRouteWithID, nil)
 			ctx := newCtx(req, rec, withAccessToken())
 
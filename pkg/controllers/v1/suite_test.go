@@ -22,6 +22,23 @@ const (
 	noteRouteWithID    = "/api/v1/storage/notes/id"
 	sectionsRoute      = "/api/v1/storage/notes/nid/sections"
 	sectionRouteWithID = "/api/v1/storage/notes/nid/sections/id"
+	schemaRoute        = "/api/v1/storage/notes/nid/schema"
+	batchRoute         = "/api/v1/storage/notes/nid/sections:batch"
+	attachmentsRoute   = "/api/v1/storage/notes/nid/attachments"
+	attachmentRouteID  = "/api/v1/storage/notes/nid/attachments/id"
+	backlinksRoute     = "/api/v1/storage/notes/id/backlinks"
+	danglingRoute      = "/api/v1/storage/links/dangling"
+	rotateKeyRoute     = "/api/v1/storage/notes/id/rotate-key"
+	syncRoute          = "/api/v1/storage/sync"
+	conflictRoute      = "/api/v1/storage/sync/conflicts/id"
+	migrateRoute       = "/api/v1/admin/migrate"
+	backupRoute        = "/api/v1/storage/backup"
+	restoreRoute       = "/api/v1/storage/restore"
+	scheduleRoute      = "/api/v1/storage/backup/schedule"
+	exportRoute        = "/api/v1/storage/notes/id/export"
+	exportAllRoute     = "/api/v1/storage/notes/export"
+	importRoute        = "/api/v1/storage/notes/import"
+	importCSVRoute     = "/api/v1/storage/notes/id/import"
 )
 
 var mockCtrl *gomock.Controller
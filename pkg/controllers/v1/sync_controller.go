@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/middlewares"
+	"github.com/psewda/typing/pkg/storage/offline"
+)
+
+// SyncController represents all operations on the sync of local store. It
+// is registered only when the server runs in offline mode.
+type SyncController struct {
+	container ioc.Container
+}
+
+// AddRoutes configures all routes of sync endpoint
+// in the 'echo' server runtime.
+func (c *SyncController) AddRoutes(e *echo.Echo) {
+	if e != nil {
+		a := middlewares.Authorization()
+		group := e.Group("/api/v1/storage/sync", a)
+		group.GET("", c.GetStatus)
+		group.POST("", c.Sync)
+		group.POST("/conflicts/:id", c.ResolveConflict)
+	}
+}
+
+// GetStatus returns the pending changes, conflicts and conflict policy of
+// the local store.
+func (c *SyncController) GetStatus(ctx echo.Context) error {
+	status, err := c.getSyncer(ctx).Status(ctx.Request().Context())
+	if err != nil {
+		msg := "sync status retrival error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	return ctx.JSON(http.StatusOK, status)
+}
+
+// Sync pushes the local changes and pulls the cloud storage changes now,
+// and returns the sync status to the client.
+func (c *SyncController) Sync(ctx echo.Context) error {
+	status, err := c.getSyncer(ctx).Sync(ctx.Request().Context())
+	if err != nil {
+		msg := "local store sync error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	return ctx.JSON(http.StatusOK, status)
+}
+
+// ResolveConflict settles the sync conflict of the note. The 'keep' query
+// param tells which note is kept, either 'local', 'remote' or 'both'.
+func (c *SyncController) ResolveConflict(ctx echo.Context) error {
+	id := ctx.Param("id")
+	keep := ctx.QueryParam("keep")
+	if err := c.getSyncer(ctx).Resolve(ctx.Request().Context(), id, keep); err != nil {
+		msg := "sync conflict resolution error"
+		ctx.Logger().Error(utils.AppendError(msg, err))
+		return utils.BuildHTTPError(err, msg)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// NewSyncController creates a new instance of sync controller.
+func NewSyncController(c ioc.Container) *SyncController {
+	return &SyncController{
+		container: c,
+	}
+}
+
+func (c *SyncController) getSyncer(ctx echo.Context) offline.Syncer {
+	accessToken := ctx.Get(middlewares.ContextKeyAccessToken).(string)
+	s, _ := c.container.GetInstance(ioc.InstanceTypeSyncer, accessToken)
+	return s.(offline.Syncer)
+}
@@ -0,0 +1,94 @@
+package v1_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	ctrlv1 "github.com/psewda/typing/pkg/controllers/v1"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/ioc"
+	"github.com/psewda/typing/pkg/storage/offline"
+)
+
+var _ = Describe("sync controller", func() {
+	var (
+		mockContainer *mocks.MockContainer
+		mockSyncer    *mocks.MockSyncer
+		rec           *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		mockContainer = mocks.NewMockContainer(mockCtrl)
+		mockSyncer = mocks.NewMockSyncer(mockCtrl)
+		rec = httptest.NewRecorder()
+
+		mockContainer.EXPECT().GetInstance(ioc.InstanceTypeSyncer, gomock.Any()).Return(mockSyncer, nil)
+	})
+
+	Context("get sync status", func() {
+		It("should return the status when correct setup", func() {
+			mockSyncer.EXPECT().Status(gomock.Any()).Return(&offline.Status{
+				Pending:   2,
+				Conflicts: []*offline.Conflict{{NoteID: "nid", Reason: "changed"}},
+			}, nil)
+			req := httptest.NewRequest(http.MethodGet, syncRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewSyncController(mockContainer).GetStatus(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+
+			var status offline.Status
+			json.Unmarshal(rec.Body.Bytes(), &status)
+			Expect(status.Pending).Should(Equal(2))
+			Expect(status.Conflicts).Should(HaveLen(1))
+		})
+	})
+
+	Context("sync now", func() {
+		It("should return the status after sync", func() {
+			mockSyncer.EXPECT().Sync(gomock.Any()).Return(&offline.Status{}, nil)
+			req := httptest.NewRequest(http.MethodPost, syncRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			ctrlv1.NewSyncController(mockContainer).Sync(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusOK))
+		})
+
+		It("should return error when sync failure", func() {
+			mockSyncer.EXPECT().Sync(gomock.Any()).Return(nil, errors.New("error"))
+			req := httptest.NewRequest(http.MethodPost, syncRoute, nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewSyncController(mockContainer).Sync(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Context("resolve sync conflict", func() {
+		It("should resolve the conflict when keep param", func() {
+			mockSyncer.EXPECT().Resolve(gomock.Any(), "id", offline.KeepLocal).Return(nil)
+			req := httptest.NewRequest(http.MethodPost, conflictRoute+"?keep=local", nil)
+			ctx := newCtx(req, rec, withAccessToken())
+			ctx.SetParamNames("id")
+			ctx.SetParamValues("id")
+
+			ctrlv1.NewSyncController(mockContainer).ResolveConflict(ctx)
+			Expect(rec.Code).Should(Equal(http.StatusNoContent))
+		})
+
+		It("should return error when no conflict", func() {
+			mockSyncer.EXPECT().Resolve(gomock.Any(), gomock.Any(), gomock.Any()).Return(errs.NewNotFoundError("error"))
+			req := httptest.NewRequest(http.MethodPost, conflictRoute+"?keep=remote", nil)
+			ctx := newCtx(req, rec, withAccessToken())
+
+			err := ctrlv1.NewSyncController(mockContainer).ResolveConflict(ctx)
+			Expect(toHTTPError(err).Code).Should(Equal(http.StatusNotFound))
+		})
+	})
+})
@@ -27,7 +27,7 @@ func (c *UserinfoController) AddRoutes(e *echo.Echo) {
 // GetUser returns the user detail to the client.
 func (c *UserinfoController) GetUser(ctx echo.Context) error {
 	ui := c.getUserinfo(ctx)
-	u, err := ui.Get()
+	u, err := ui.Get(ctx.Request().Context())
 	if err != nil {
 		msg := "error occurred while fetching user"
 		ctx.Logger().Error(utils.AppendError(msg, err))
@@ -46,7 +46,6 @@ func NewUserinfoController(c ioc.Container) *UserinfoController {
 
 func (c *UserinfoController) getUserinfo(ctx echo.Context) userinfo.Userinfo {
 	accessToken := ctx.Get(middlewares.ContextKeyAccessToken).(string)
-	client := utils.ClientWithToken(accessToken)
-	instance, _ := c.container.GetInstance(ioc.InstanceTypeUserinfo, client)
+	instance, _ := c.container.GetInstance(ioc.InstanceTypeUserinfo, accessToken)
 	return instance.(userinfo.Userinfo)
 }
@@ -27,7 +27,7 @@ var _ = Describe("userinfo controller", func() {
 				Email:   "email@mail.com",
 				Picture: "https://lh3.googleusercontent.com/AOh14GiShoGb1kvP=q01-b",
 			}
-			mockUserinfo.EXPECT().Get().Return(user, nil)
+			mockUserinfo.EXPECT().Get(gomock.Any()).Return(user, nil)
 			req := httptest.NewRequest(http.MethodGet, uiRoute, nil)
 			ctx := newCtx(req, rec, withAccessToken())
 			ctrlv1.NewUserinfoController(mockContainer).GetUser(ctx)
@@ -45,7 +45,7 @@ var _ = Describe("userinfo controller", func() {
 			mockUserinfo := mocks.NewMockUserinfo(mockCtrl)
 			rec := httptest.NewRecorder()
 			mockContainer.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(mockUserinfo, nil)
-			mockUserinfo.EXPECT().Get().Return(nil, errors.New("error"))
+			mockUserinfo.EXPECT().Get(gomock.Any()).Return(nil, errors.New("error"))
 
 			req := httptest.NewRequest(http.MethodGet, uiRoute, nil)
 			ctx := newCtx(req, rec, withAccessToken())
@@ -1,5 +1,7 @@
 package errs
 
+import "context"
+
 // NotFoundError is NotFound error struct.
 type NotFoundError struct {
 	message string
@@ -20,6 +22,39 @@ func (e *UnauthorizedError) Error() string {
 	return e.message
 }
 
+// ValidationError is Validation error struct. It is used when the
+// input data fails the validation rules known only to the storage.
+type ValidationError struct {
+	message string
+}
+
+// Error returns error message as string.
+func (e *ValidationError) Error() string {
+	return e.message
+}
+
+// EncryptionError is Encryption error struct. It is used when the
+// encrypted content can't be decrypted using the given key.
+type EncryptionError struct {
+	message string
+}
+
+// Error returns error message as string.
+func (e *EncryptionError) Error() string {
+	return e.message
+}
+
+// ConflictError is Conflict error struct. It is used when the
+// resource is busy and the request can't be applied now.
+type ConflictError struct {
+	message string
+}
+
+// Error returns error message as string.
+func (e *ConflictError) Error() string {
+	return e.message
+}
+
 // NewNotFoundError creates new instance of NotFoundError.
 func NewNotFoundError(m string) *NotFoundError {
 	return &NotFoundError{
@@ -33,3 +68,32 @@ func NewUnauthorizedError() *UnauthorizedError {
 		message: "authorization token is invalid or expired",
 	}
 }
+
+// NewValidationError creates new instance of ValidationError.
+func NewValidationError(m string) *ValidationError {
+	return &ValidationError{
+		message: m,
+	}
+}
+
+// NewEncryptionError creates new instance of EncryptionError.
+func NewEncryptionError(m string) *EncryptionError {
+	return &EncryptionError{
+		message: m,
+	}
+}
+
+// NewConflictError creates new instance of ConflictError.
+func NewConflictError(m string) *ConflictError {
+	return &ConflictError{
+		message: m,
+	}
+}
+
+// IsFatal tells whether the error stops the bulk operation on many notes.
+// The unauthorized error fails all next calls, and the cancelled context
+// stops the work, other errors fail only the single note.
+func IsFatal(ctx context.Context, err error) bool {
+	_, ok := err.(*UnauthorizedError)
+	return ok || ctx.Err() != nil
+}
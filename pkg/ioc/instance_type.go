@@ -12,4 +12,19 @@ const (
 
 	// InstanceTypeSectionstore is the enum member of type sectionstore.
 	InstanceTypeSectionstore
+
+	// InstanceTypeAttachstore is the enum member of type attachstore.
+	InstanceTypeAttachstore
+
+	// InstanceTypeSyncer is the enum member of type syncer.
+	InstanceTypeSyncer
+
+	// InstanceTypeMigrator is the enum member of type migrator.
+	InstanceTypeMigrator
+
+	// InstanceTypeArchiver is the enum member of type archiver.
+	InstanceTypeArchiver
+
+	// InstanceTypeReporter is the enum member of type backup reporter.
+	InstanceTypeReporter
 )
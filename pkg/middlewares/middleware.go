@@ -1,17 +1,31 @@
 package middlewares
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/breaker"
+	"github.com/psewda/typing/pkg/storage/encrypt"
 )
 
 // ContextKeyAccessToken is the key for access token value.
 // nolint:gosec
 const ContextKeyAccessToken = "CTX_KEY_ACCESS_TOKEN"
 
+// ContextKeyCipher is the key for encryption cipher value.
+const ContextKeyCipher = "CTX_KEY_CIPHER"
+
+// HeaderEncryptionKey is the header having the passphrase used for
+// encrypting the note content.
+const HeaderEncryptionKey = "X-Typing-Key"
+
 // Authorization middleware authorizes http request by validating
 // bearer token in authorization header. If validation is
 // successful, it inserts the access token in the echo context.
@@ -43,6 +57,144 @@ func Authorization() echo.MiddlewareFunc {
 	}
 }
 
+// Encryption middleware builds the cipher from the passphrase in encryption
+// key header. If the header is present, it inserts the cipher in the echo
+// context, so the note content is encrypted for the request.
+func Encryption() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			value := ctx.Request().Header.Get(HeaderEncryptionKey)
+			if len(value) > 0 {
+				c, err := encrypt.NewCipher(value)
+				if err != nil {
+					msg := "encryption key is invalid"
+					ctx.Logger().Warn(utils.AppendError(msg, err))
+					return &echo.HTTPError{
+						Code:    http.StatusBadRequest,
+						Message: fmt.Sprintf("%s, %s", msg, err.Error()),
+					}
+				}
+				ctx.Set(ContextKeyCipher, c)
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// CircuitBreaker middleware fails the requests fast while the circuit breaker
+// of storage backend is open. It is applied only on the routes having the path
+// prefix, and responds 503 with 'Retry-After' header. The server error of the
+// request opening the breaker, or refused by the half-open breaker while
+// other request probes the backend, is also converted to 503.
+func CircuitBreaker(b *breaker.Breaker, prefix string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if !strings.HasPrefix(ctx.Path(), prefix) {
+				return next(ctx)
+			}
+			if b.State() == breaker.StateOpen {
+				return unavailable(ctx, b)
+			}
+
+			// the request refused by the half-open breaker fails with ErrOpen
+			err := next(ctx)
+			if he, ok := err.(*echo.HTTPError); ok && he.Code == http.StatusInternalServerError {
+				if errors.Is(he.Internal, breaker.ErrOpen) || b.State() == breaker.StateOpen {
+					return unavailable(ctx, b)
+				}
+			}
+			if errors.Is(err, breaker.ErrOpen) {
+				return unavailable(ctx, b)
+			}
+			return err
+		}
+	}
+}
+
+// Timeouts has the deadlines of requests calling the storage backend.
+type Timeouts struct {
+	// Read is the deadline of GET and HEAD requests.
+	Read time.Duration
+
+	// Write is the deadline of other requests, which usually download and
+	// upload the whole note content.
+	Write time.Duration
+
+	// Stream is the deadline of requests streaming all notes of the user,
+	// like backup, restore and export. The stream request is cancelled anyway when
+	// the client goes away.
+	Stream time.Duration
+}
+
+// DefaultTimeouts returns the deadlines used by the timeout middleware. The
+// stream requests have no deadline.
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Read:  30 * time.Second,
+		Write: 60 * time.Second,
+	}
+}
+
+// Timeout middleware sets the deadline on the request context, so the storage
+// calls of the request are cancelled when it passes. It is applied only on the
+// routes having the path prefix, and responds 504 when the request fails after
+// the deadline. The stream routes get the stream deadline, their response is
+// already sent when the deadline passes. The zero timeout keeps the request
+// without deadline.
+func Timeout(t Timeouts, prefix string, streams ...string) echo.MiddlewareFunc {
+	isStream := make(map[string]bool, len(streams))
+	for _, s := range streams {
+		isStream[s] = true
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if !strings.HasPrefix(ctx.Path(), prefix) {
+				return next(ctx)
+			}
+			req := ctx.Request()
+			timeout := t.Write
+			if isStream[ctx.Path()] {
+				timeout = t.Stream
+			} else if req.Method == http.MethodGet || req.Method == http.MethodHead {
+				timeout = t.Read
+			}
+			if timeout <= 0 {
+				return next(ctx)
+			}
+
+			c, cancel := context.WithTimeout(req.Context(), timeout)
+			defer cancel()
+			ctx.SetRequest(req.WithContext(c))
+
+			err := next(ctx)
+			if err != nil && c.Err() == context.DeadlineExceeded {
+				msg := fmt.Sprintf("request is not completed in %s, storage took too long to respond", timeout)
+				ctx.Logger().Warn(msg)
+				return &echo.HTTPError{
+					Code:    http.StatusGatewayTimeout,
+					Message: msg,
+				}
+			}
+			return err
+		}
+	}
+}
+
+func unavailable(ctx echo.Context, b *breaker.Breaker) error {
+	s := b.Status()
+	if s.RetryAfter == 0 {
+		// the half-open breaker has the probe result soon
+		s.RetryAfter = 1
+	}
+	msg := fmt.Sprintf("%s storage is unavailable, retry after %d seconds", s.Name, s.RetryAfter)
+	ctx.Logger().Warn(msg)
+	ctx.Response().Header().Set("Retry-After", strconv.Itoa(s.RetryAfter))
+	return &echo.HTTPError{
+		Code:    http.StatusServiceUnavailable,
+		Message: msg,
+	}
+}
+
 func fetchToken(value string) string {
 	const scheme = "Bearer"
 	if strings.HasPrefix(value, scheme) {
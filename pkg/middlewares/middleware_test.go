@@ -5,10 +5,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/breaker"
 	"github.com/psewda/typing/pkg/middlewares"
 )
 
@@ -50,6 +53,200 @@ var _ = Describe("middleware", func() {
 			Expect(httpError.Code).Should(Equal(http.StatusUnauthorized))
 		})
 	})
+
+	Context("encryption middleware", func() {
+		It("should set the cipher when encryption key", func() {
+			ctx := newCtx()
+			ctx.Request().Header.Add(middlewares.HeaderEncryptionKey, "passphrase")
+			middleware := middlewares.Encryption()
+			handler := middleware(func(ctx echo.Context) error { return nil })
+			Expect(handler(ctx)).Should(Succeed())
+			Expect(ctx.Get(middlewares.ContextKeyCipher)).ShouldNot(BeNil())
+		})
+
+		It("should skip the cipher when no encryption key", func() {
+			ctx := newCtx()
+			middleware := middlewares.Encryption()
+			handler := middleware(func(ctx echo.Context) error { return nil })
+			Expect(handler(ctx)).Should(Succeed())
+			Expect(ctx.Get(middlewares.ContextKeyCipher)).Should(BeNil())
+		})
+
+		It("should throw error when short encryption key", func() {
+			ctx := newCtx()
+			ctx.Request().Header.Add(middlewares.HeaderEncryptionKey, "short")
+			middleware := middlewares.Encryption()
+			handler := middleware(func(ctx echo.Context) error { return nil })
+			httpError := toHTTPError(handler(ctx))
+
+			Expect(httpError).Should(HaveOccurred())
+			Expect(httpError.Code).Should(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("circuit breaker middleware", func() {
+		const prefix = "/api/v1/storage"
+
+		newBreaker := func(failures int) *breaker.Breaker {
+			b := breaker.New("drive", breaker.Config{Threshold: 1, Cooldown: time.Minute}, nil)
+			for i := 0; i < failures; i++ {
+				b.Failure()
+			}
+			return b
+		}
+
+		It("should call the handler when breaker closed", func() {
+			ctx := newCtx()
+			ctx.SetPath(prefix + "/notes")
+			middleware := middlewares.CircuitBreaker(newBreaker(0), prefix)
+			handler := middleware(func(ctx echo.Context) error { return nil })
+			Expect(handler(ctx)).Should(Succeed())
+		})
+
+		It("should fail fast when breaker open", func() {
+			called := false
+			ctx := newCtx()
+			ctx.SetPath(prefix + "/notes")
+			middleware := middlewares.CircuitBreaker(newBreaker(1), prefix)
+			handler := middleware(func(ctx echo.Context) error {
+				called = true
+				return nil
+			})
+			httpError := toHTTPError(handler(ctx))
+
+			Expect(called).Should(BeFalse())
+			Expect(httpError.Code).Should(Equal(http.StatusServiceUnavailable))
+			Expect(ctx.Response().Header().Get("Retry-After")).Should(Equal("60"))
+		})
+
+		It("should convert server error when breaker opened", func() {
+			b := newBreaker(0)
+			ctx := newCtx()
+			ctx.SetPath(prefix + "/notes")
+			middleware := middlewares.CircuitBreaker(b, prefix)
+			handler := middleware(func(ctx echo.Context) error {
+				b.Failure()
+				return echo.NewHTTPError(http.StatusInternalServerError)
+			})
+			httpError := toHTTPError(handler(ctx))
+			Expect(httpError.Code).Should(Equal(http.StatusServiceUnavailable))
+		})
+
+		It("should fail fast when half-open breaker probing", func() {
+			started, release := make(chan struct{}), make(chan struct{})
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				<-release
+			}))
+			defer ts.Close()
+
+			b := breaker.New("drive", breaker.Config{Threshold: 1, Cooldown: 10 * time.Millisecond}, nil)
+			b.Failure()
+			time.Sleep(20 * time.Millisecond)
+			client := &http.Client{Transport: b.Wrap(nil)}
+			middleware := middlewares.CircuitBreaker(b, prefix)
+			handler := middleware(func(ctx echo.Context) error {
+				res, err := client.Get(ts.URL)
+				if err != nil {
+					return utils.BuildHTTPError(utils.Error("note download error", err), "note download error")
+				}
+				res.Body.Close()
+				return nil
+			})
+
+			probe := make(chan error)
+			go func() {
+				ctx := newCtx()
+				ctx.SetPath(prefix + "/notes")
+				probe <- handler(ctx)
+			}()
+			<-started
+
+			ctx := newCtx()
+			ctx.SetPath(prefix + "/notes")
+			httpError := toHTTPError(handler(ctx))
+			Expect(httpError.Code).Should(Equal(http.StatusServiceUnavailable))
+			Expect(ctx.Response().Header().Get("Retry-After")).Should(Equal("1"))
+
+			close(release)
+			Expect(<-probe).Should(Succeed())
+			Expect(b.State()).Should(Equal(breaker.StateClosed))
+		})
+
+		It("should skip the routes without prefix", func() {
+			ctx := newCtx()
+			ctx.SetPath("/api/health")
+			middleware := middlewares.CircuitBreaker(newBreaker(1), prefix)
+			handler := middleware(func(ctx echo.Context) error { return nil })
+			Expect(handler(ctx)).Should(Succeed())
+		})
+	})
+
+	Context("timeout middleware", func() {
+		const prefix = "/api/v1"
+		timeouts := middlewares.Timeouts{Read: 10 * time.Millisecond, Write: time.Minute}
+
+		It("should set the deadline on request context", func() {
+			ctx := newCtx()
+			ctx.SetPath(prefix + "/storage/notes")
+			middleware := middlewares.Timeout(timeouts, prefix)
+			handler := middleware(func(ctx echo.Context) error {
+				_, ok := ctx.Request().Context().Deadline()
+				Expect(ok).Should(BeTrue())
+				return nil
+			})
+			Expect(handler(ctx)).Should(Succeed())
+		})
+
+		It("should throw timeout error when deadline passed", func() {
+			ctx := newCtx()
+			ctx.SetPath(prefix + "/storage/notes")
+			middleware := middlewares.Timeout(timeouts, prefix)
+			handler := middleware(func(ctx echo.Context) error {
+				<-ctx.Request().Context().Done()
+				return echo.NewHTTPError(http.StatusInternalServerError)
+			})
+			httpError := toHTTPError(handler(ctx))
+			Expect(httpError.Code).Should(Equal(http.StatusGatewayTimeout))
+		})
+
+		It("should use write timeout when not read request", func() {
+			ctx := newCtx()
+			ctx.Request().Method = http.MethodPost
+			ctx.SetPath(prefix + "/storage/notes")
+			middleware := middlewares.Timeout(timeouts, prefix)
+			handler := middleware(func(ctx echo.Context) error {
+				deadline, _ := ctx.Request().Context().Deadline()
+				Expect(time.Until(deadline)).Should(BeNumerically(">", time.Second))
+				return nil
+			})
+			Expect(handler(ctx)).Should(Succeed())
+		})
+
+		It("should use stream timeout when stream route", func() {
+			ctx := newCtx()
+			ctx.SetPath(prefix + "/storage/backup")
+			middleware := middlewares.Timeout(timeouts, prefix, prefix+"/storage/backup")
+			handler := middleware(func(ctx echo.Context) error {
+				_, ok := ctx.Request().Context().Deadline()
+				Expect(ok).Should(BeFalse())
+				return nil
+			})
+			Expect(handler(ctx)).Should(Succeed())
+		})
+
+		It("should skip the routes without prefix", func() {
+			ctx := newCtx()
+			ctx.SetPath("/api/health")
+			middleware := middlewares.Timeout(timeouts, prefix)
+			handler := middleware(func(ctx echo.Context) error {
+				_, ok := ctx.Request().Context().Deadline()
+				Expect(ok).Should(BeFalse())
+				return nil
+			})
+			Expect(handler(ctx)).Should(Succeed())
+		})
+	})
 })
 
 func newCtx() echo.Context {
@@ -0,0 +1,210 @@
+package retry
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config has the limits of retry with exponential backoff.
+type Config struct {
+	// MaxRetries is the max number of retries after the first attempt.
+	MaxRetries int
+
+	// BaseDelay is the delay before the first retry. It is doubled on
+	// each next retry, and the random jitter is applied on it.
+	BaseDelay time.Duration
+
+	// MaxDelay is the max delay between the attempts. The request is not
+	// retried when the server asks to retry after a longer time.
+	MaxDelay time.Duration
+}
+
+// DefaultConfig returns the config used by cloud storage calls.
+func DefaultConfig() Config {
+	return Config{
+		MaxRetries: 3,
+		BaseDelay:  500 * time.Millisecond,
+		MaxDelay:   10 * time.Second,
+	}
+}
+
+// Wrap returns the transport which retries the transient failures of
+// the wrapped transport. The zero max retries returns it as it is.
+func (c Config) Wrap(rt http.RoundTripper) http.RoundTripper {
+	if c.MaxRetries <= 0 {
+		return rt
+	}
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &transport{
+		config: c,
+		next:   rt,
+		rnd:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+type transport struct {
+	config Config
+	next   http.RoundTripper
+	rnd    *rand.Rand
+	mutex  sync.Mutex
+}
+
+// RoundTrip sends the request and retries it on the transient failure. The
+// non-idempotent requests are retried only when the server surely rejected
+// them, i.e. on rate limit responses.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, err := bufferBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		r := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(req.Context())
+			r.Body = body
+		}
+
+		res, err := t.next.RoundTrip(r)
+		if attempt >= t.config.MaxRetries || !retryable(req.Method, res, err) {
+			return res, err
+		}
+
+		delay := t.backoff(attempt)
+		if res != nil {
+			if after, ok := retryAfter(res); ok {
+				if after > t.config.MaxDelay {
+					return res, err
+				}
+				delay = after
+			}
+			drain(res)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the exponential delay with jitter. The delay is picked
+// randomly between the half and the full exponential delay.
+func (t *transport) backoff(attempt int) time.Duration {
+	delay := t.config.BaseDelay << uint(attempt)
+	if delay > t.config.MaxDelay || delay <= 0 {
+		delay = t.config.MaxDelay
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	half := int64(delay / 2)
+	return time.Duration(half + t.rnd.Int63n(half+1))
+}
+
+func retryable(method string, res *http.Response, err error) bool {
+	// the failed connection may be processed by the server
+	if err != nil {
+		return idempotent(method)
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		return isRateLimited(res)
+	case http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent(method)
+	default:
+		return false
+	}
+}
+
+// idempotent checks the request can be sent again safely. The patch is
+// idempotent for drive api, because it replaces the fields and content.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// isRateLimited checks the drive api rate limit error, which is
+// sent as forbidden response having the rate limit reason.
+func isRateLimited(res *http.Response) bool {
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	return bytes.Contains(body, []byte("rateLimitExceeded")) ||
+		bytes.Contains(body, []byte("userRateLimitExceeded"))
+}
+
+// retryAfter parses the 'Retry-After' header having either
+// delay seconds or http date.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	value := strings.TrimSpace(res.Header.Get("Retry-After"))
+	if len(value) == 0 {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		delay := time.Until(t)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// bufferBody reads the request body in memory, so it can be sent again
+// on retry. It returns the copy of request, because the transport must
+// not modify the original request.
+func bufferBody(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return req, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	r := req.Clone(req.Context())
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	return r, nil
+}
+
+func drain(res *http.Response) {
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+}
@@ -0,0 +1,157 @@
+package retry_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/pkg/retry"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+func TestRetry(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "retry-suite")
+}
+
+var _ = Describe("retry with backoff", func() {
+	config := retry.Config{
+		MaxRetries: 3,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   10 * time.Millisecond,
+	}
+
+	// newServer returns the fake server which fails the first calls
+	// with the status code, and then succeeds.
+	newServer := func(failures int32, code int, header map[string]string) (*httptest.Server, *int32) {
+		var calls int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			if atomic.AddInt32(&calls, 1) <= failures {
+				for k, v := range header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(code)
+				return
+			}
+			w.Write(body)
+		}))
+		return ts, &calls
+	}
+
+	newClient := func(c retry.Config) *http.Client {
+		return &http.Client{Transport: c.Wrap(http.DefaultTransport)}
+	}
+
+	It("should retry when transient server error", func() {
+		ts, calls := newServer(2, http.StatusServiceUnavailable, nil)
+		defer ts.Close()
+
+		res, err := newClient(config).Get(ts.URL)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(res.StatusCode).Should(Equal(http.StatusOK))
+		Expect(*calls).Should(BeEquivalentTo(3))
+	})
+
+	It("should return the error response when retries exhausted", func() {
+		ts, calls := newServer(10, http.StatusInternalServerError, nil)
+		defer ts.Close()
+
+		res, err := newClient(config).Get(ts.URL)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(res.StatusCode).Should(Equal(http.StatusInternalServerError))
+		Expect(*calls).Should(BeEquivalentTo(4))
+	})
+
+	It("should send the same body when retrying", func() {
+		ts, calls := newServer(1, http.StatusBadGateway, nil)
+		defer ts.Close()
+
+		req, _ := http.NewRequest(http.MethodPatch, ts.URL, ioutil.NopCloser(strings.NewReader("content")))
+		res, err := newClient(config).Do(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		body, _ := ioutil.ReadAll(res.Body)
+		Expect(string(body)).Should(Equal("content"))
+		Expect(*calls).Should(BeEquivalentTo(2))
+	})
+
+	It("should not retry create when server error", func() {
+		ts, calls := newServer(1, http.StatusInternalServerError, nil)
+		defer ts.Close()
+
+		res, err := newClient(config).Post(ts.URL, "text/plain", strings.NewReader("content"))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(res.StatusCode).Should(Equal(http.StatusInternalServerError))
+		Expect(*calls).Should(BeEquivalentTo(1))
+	})
+
+	It("should retry create when rate limited", func() {
+		ts, calls := newServer(1, http.StatusTooManyRequests, nil)
+		defer ts.Close()
+
+		res, err := newClient(config).Post(ts.URL, "text/plain", strings.NewReader("content"))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(res.StatusCode).Should(Equal(http.StatusOK))
+		Expect(*calls).Should(BeEquivalentTo(2))
+	})
+
+	It("should not retry when client error", func() {
+		ts, calls := newServer(1, http.StatusNotFound, nil)
+		defer ts.Close()
+
+		res, _ := newClient(config).Get(ts.URL)
+		Expect(res.StatusCode).Should(Equal(http.StatusNotFound))
+		Expect(*calls).Should(BeEquivalentTo(1))
+	})
+
+	It("should honor retry-after header", func() {
+		ts, calls := newServer(1, http.StatusTooManyRequests, map[string]string{"Retry-After": "1"})
+		defer ts.Close()
+
+		c := config
+		c.MaxDelay = 2 * time.Second
+		start := time.Now()
+		res, err := newClient(c).Get(ts.URL)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(res.StatusCode).Should(Equal(http.StatusOK))
+		Expect(time.Since(start)).Should(BeNumerically(">=", time.Second))
+		Expect(*calls).Should(BeEquivalentTo(2))
+	})
+
+	It("should not retry when retry-after is longer than max delay", func() {
+		ts, calls := newServer(1, http.StatusTooManyRequests, map[string]string{"Retry-After": "60"})
+		defer ts.Close()
+
+		res, _ := newClient(config).Get(ts.URL)
+		Expect(res.StatusCode).Should(Equal(http.StatusTooManyRequests))
+		Expect(*calls).Should(BeEquivalentTo(1))
+	})
+
+	It("should retry drive call when rate limit exceeded", func() {
+		var calls int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{ "error": { "code": 403, "errors": [ { "reason": "userRateLimitExceeded" } ] } }`))
+				return
+			}
+			w.Write([]byte(`{ "id": "nid", "name": "note.json" }`))
+		}))
+		defer ts.Close()
+
+		service, _ := drive.NewService(context.Background(), option.WithHTTPClient(newClient(config)), option.WithEndpoint(ts.URL))
+		file, err := service.Files.Get("nid").Do()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(file.Id).Should(Equal("nid"))
+		Expect(calls).Should(BeEquivalentTo(2))
+	})
+})
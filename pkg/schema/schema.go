@@ -0,0 +1,266 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/psewda/typing/pkg/errs"
+)
+
+const (
+	// TypeObject is the schema type of the section data map.
+	TypeObject = "object"
+
+	// TypeString is the schema type of string data value.
+	TypeString = "string"
+
+	// TypeNumber is the schema type of decimal number data value.
+	TypeNumber = "number"
+
+	// TypeInteger is the schema type of integer number data value.
+	TypeInteger = "integer"
+
+	// TypeBoolean is the schema type of 'true' or 'false' data value.
+	TypeBoolean = "boolean"
+)
+
+const (
+	// FormatEmail checks the value is an email address.
+	FormatEmail = "email"
+
+	// FormatDate checks the value is a full date like '2021-02-12'.
+	FormatDate = "date"
+
+	// FormatDateTime checks the value is a RFC3339 date and time.
+	FormatDateTime = "date-time"
+
+	// FormatURI checks the value is an absolute uri.
+	FormatURI = "uri"
+)
+
+// Schema is the subset of json schema which is used to validate the
+// section data. The root schema describes the data map as an 'object'
+// and each property describes a single data value. All data values are
+// strings, so non-string types check that the value can be parsed.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+}
+
+// Check verifies the schema itself. It returns validation error when the
+// schema uses unsupported type or format, or has an invalid pattern or
+// length.
+func (s *Schema) Check() error {
+	if err := s.check(); err != nil {
+		return errs.NewValidationError(err.Error())
+	}
+	return nil
+}
+
+func (s *Schema) check() error {
+	if s.Type != TypeObject && len(s.Type) > 0 {
+		return fmt.Errorf("schema type must be '%s'", TypeObject)
+	}
+
+	for _, name := range s.Required {
+		if _, ok := s.Properties[name]; !ok {
+			msg := fmt.Sprintf("required property '%s' is not defined in schema", name)
+			return errors.New(msg)
+		}
+	}
+
+	for _, name := range sortedKeys(s.Properties) {
+		if err := checkProperty(name, s.Properties[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks the data against the schema. It returns error having
+// all failure messages when the data doesn't satisfy the schema.
+func (s *Schema) Validate(data map[string]string) error {
+	var msgs []string
+	for _, name := range s.Required {
+		if _, ok := data[name]; !ok {
+			msgs = append(msgs, fmt.Sprintf("data '%s' is required by note schema", name))
+		}
+	}
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p, ok := s.Properties[k]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				msgs = append(msgs, fmt.Sprintf("data '%s' is not allowed by note schema", k))
+			}
+			continue
+		}
+		if msg := validateValue(k, data[k], p); len(msg) > 0 {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	if len(msgs) > 0 {
+		return errors.New(strings.Join(msgs, ", "))
+	}
+	return nil
+}
+
+func checkProperty(name string, p *Schema) error {
+	if p == nil {
+		return fmt.Errorf("property '%s' can't be null", name)
+	}
+
+	switch p.Type {
+	case TypeString, TypeNumber, TypeInteger, TypeBoolean, "":
+	default:
+		msg := fmt.Sprintf("property '%s' has unsupported type '%s'", name, p.Type)
+		return errors.New(msg)
+	}
+
+	switch p.Format {
+	case FormatEmail, FormatDate, FormatDateTime, FormatURI, "":
+	default:
+		msg := fmt.Sprintf("property '%s' has unsupported format '%s'", name, p.Format)
+		return errors.New(msg)
+	}
+
+	if len(p.Pattern) > 0 {
+		if _, err := regexp.Compile(p.Pattern); err != nil {
+			return fmt.Errorf("property '%s' has invalid pattern", name)
+		}
+	}
+
+	if (p.MinLength != nil && *p.MinLength < 0) || (p.MaxLength != nil && *p.MaxLength < 0) {
+		return fmt.Errorf("property '%s' can't have negative length", name)
+	}
+	if p.MinLength != nil && p.MaxLength != nil && *p.MinLength > *p.MaxLength {
+		return fmt.Errorf("property '%s' has minLength greater than maxLength", name)
+	}
+
+	if len(p.Properties) > 0 || len(p.Required) > 0 {
+		return fmt.Errorf("property '%s' can't have nested properties", name)
+	}
+	return nil
+}
+
+func validateValue(name, value string, p *Schema) string {
+	if msg := validateType(name, value, p); len(msg) > 0 {
+		return msg
+	}
+
+	if len(p.Enum) > 0 && !contains(p.Enum, value) {
+		return fmt.Sprintf("data '%s' must be one of '%s'", name, strings.Join(p.Enum, "', '"))
+	}
+
+	length := len([]rune(value))
+	if p.MinLength != nil && length < *p.MinLength {
+		return fmt.Sprintf("data '%s' must be at least %d chars", name, *p.MinLength)
+	}
+	if p.MaxLength != nil && length > *p.MaxLength {
+		return fmt.Sprintf("data '%s' must be at most %d chars", name, *p.MaxLength)
+	}
+
+	if len(p.Pattern) > 0 {
+		if ok, _ := regexp.MatchString(p.Pattern, value); !ok {
+			return fmt.Sprintf("data '%s' must match pattern '%s'", name, p.Pattern)
+		}
+	}
+
+	if !isFormat(value, p.Format) {
+		return fmt.Sprintf("data '%s' must be in '%s' format", name, p.Format)
+	}
+	return ""
+}
+
+func validateType(name, value string, p *Schema) string {
+	var number float64
+	var err error
+	switch p.Type {
+	case TypeNumber:
+		number, err = strconv.ParseFloat(value, 64)
+	case TypeInteger:
+		var i int64
+		i, err = strconv.ParseInt(value, 10, 64)
+		number = float64(i)
+	case TypeBoolean:
+		_, err = strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Sprintf("data '%s' must be a boolean", name)
+		}
+		return ""
+	default:
+		return ""
+	}
+
+	if err != nil {
+		return fmt.Sprintf("data '%s' must be a %s", name, p.Type)
+	}
+	if p.Minimum != nil && number < *p.Minimum {
+		return fmt.Sprintf("data '%s' must be greater than or equal to %v", name, *p.Minimum)
+	}
+	if p.Maximum != nil && number > *p.Maximum {
+		return fmt.Sprintf("data '%s' must be less than or equal to %v", name, *p.Maximum)
+	}
+	return ""
+}
+
+func isFormat(value, format string) bool {
+	var err error
+	switch format {
+	case FormatEmail:
+		_, err = mail.ParseAddress(value)
+	case FormatDate:
+		_, err = time.Parse("2006-01-02", value)
+	case FormatDateTime:
+		_, err = time.Parse(time.RFC3339, value)
+	case FormatURI:
+		var u *url.URL
+		u, err = url.Parse(value)
+		if err == nil && !u.IsAbs() {
+			err = errors.New("uri is not absolute")
+		}
+	}
+	return err == nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]*Schema) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
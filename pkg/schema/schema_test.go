@@ -0,0 +1,148 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/schema"
+)
+
+func TestSchema(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "schema-suite")
+}
+
+var _ = Describe("section data schema", func() {
+	newSchema := func(j string) *schema.Schema {
+		var s schema.Schema
+		err := json.Unmarshal([]byte(j), &s)
+		Expect(err).ShouldNot(HaveOccurred())
+		return &s
+	}
+
+	Context("check schema", func() {
+		It("should pass when supported keywords", func() {
+			s := newSchema(`{
+				"type": "object",
+				"required": ["email"],
+				"properties": {
+					"email": { "type": "string", "format": "email" },
+					"age": { "type": "integer", "minimum": 0 }
+				}
+			}`)
+			Expect(s.Check()).ShouldNot(HaveOccurred())
+		})
+
+		It("should fail when root type is not object", func() {
+			s := newSchema(`{ "type": "array" }`)
+			Expect(s.Check()).Should(HaveOccurred())
+		})
+
+		It("should fail when required property is not defined", func() {
+			s := newSchema(`{ "required": ["email"] }`)
+			Expect(s.Check()).Should(HaveOccurred())
+		})
+
+		It("should fail when unsupported property type", func() {
+			s := newSchema(`{ "properties": { "tags": { "type": "array" } } }`)
+			Expect(s.Check()).Should(HaveOccurred())
+		})
+
+		It("should fail when invalid pattern", func() {
+			s := newSchema(`{ "properties": { "code": { "pattern": "[a-" } } }`)
+			Expect(s.Check()).Should(HaveOccurred())
+		})
+
+		It("should fail when negative length", func() {
+			for _, j := range []string{
+				`{ "properties": { "code": { "minLength": -1 } } }`,
+				`{ "properties": { "code": { "maxLength": -1 } } }`,
+			} {
+				err := newSchema(j).Check()
+				Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+			}
+		})
+
+		It("should fail when minLength greater than maxLength", func() {
+			s := newSchema(`{ "properties": { "code": { "minLength": 5, "maxLength": 2 } } }`)
+			err := s.Check()
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+			Expect(err.Error()).Should(ContainSubstring("minLength"))
+		})
+	})
+
+	Context("validate data", func() {
+		s := newSchema(`{
+			"type": "object",
+			"required": ["name", "email"],
+			"additionalProperties": false,
+			"properties": {
+				"name": { "type": "string", "minLength": 2, "maxLength": 10 },
+				"email": { "type": "string", "format": "email" },
+				"age": { "type": "integer", "minimum": 18, "maximum": 99 },
+				"active": { "type": "boolean" },
+				"plan": { "enum": ["free", "paid"] },
+				"zip": { "pattern": "^[0-9]{5}$" }
+			}
+		}`)
+
+		It("should pass when data satisfies schema", func() {
+			err := s.Validate(map[string]string{
+				"name":   "john",
+				"email":  "john@example.com",
+				"age":    "30",
+				"active": "true",
+				"plan":   "free",
+				"zip":    "12345",
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should fail when required data is missing", func() {
+			err := s.Validate(map[string]string{"name": "john"})
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).Should(ContainSubstring("'email' is required"))
+		})
+
+		It("should fail when additional data is not allowed", func() {
+			err := s.Validate(map[string]string{
+				"name":  "john",
+				"email": "john@example.com",
+				"phone": "555",
+			})
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).Should(ContainSubstring("'phone' is not allowed"))
+		})
+
+		It("should fail when any value is wrong", func() {
+			values := map[string]string{
+				"name":   "j",
+				"email":  "john",
+				"age":    "ten",
+				"active": "yes",
+				"plan":   "gold",
+				"zip":    "1234",
+			}
+			for k, v := range values {
+				data := map[string]string{
+					"name":  "john",
+					"email": "john@example.com",
+				}
+				data[k] = v
+				Expect(s.Validate(data)).Should(HaveOccurred(), k)
+			}
+		})
+
+		It("should fail when number is out of range", func() {
+			err := s.Validate(map[string]string{
+				"name":  "john",
+				"email": "john@example.com",
+				"age":   "12",
+			})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
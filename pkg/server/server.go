@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"net"
 	"net/http"
 	"time"
 
@@ -22,6 +23,7 @@ type Server struct {
 	Running bool
 	echo    *echo.Echo
 	logger  *log.Logger
+	cancel  context.CancelFunc
 }
 
 // RegisterController adds the specified controller and its routes
@@ -32,6 +34,11 @@ func (s *Server) RegisterController(ctrl controllers.Controller) {
 	}
 }
 
+// Use adds the middlewares which run on all routes after the router.
+func (s *Server) Use(middlewares ...echo.MiddlewareFunc) {
+	s.echo.Use(middlewares...)
+}
+
 // Run starts the http server and returns
 // the error object for any failure.
 func (s *Server) Run(port uint16) error {
@@ -51,6 +58,12 @@ func (s *Server) Shutdown() {
 		const waitTime = time.Second * 5
 		ctx, cancel := context.WithTimeout(context.Background(), waitTime)
 		defer cancel()
+
+		// cancel the in-flight requests which are still running near the
+		// deadline, so their storage calls are stopped
+		stop := time.AfterFunc(waitTime-time.Second, s.cancel)
+		defer stop.Stop()
+		defer s.cancel()
 		if err := s.echo.Shutdown(ctx); err != nil {
 			s.logger.Fatal("error in server shutdown, stopping the service", err)
 		}
@@ -75,10 +88,16 @@ func New(banner bool, logger *log.Logger) *Server {
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 
+	// the request contexts derive from the base context, which is cancelled
+	// on shutdown
+	base, cancel := context.WithCancel(context.Background())
+	e.Server.BaseContext = func(net.Listener) context.Context { return base }
+
 	return &Server{
 		Running: false,
 		echo:    e,
 		logger:  logger,
+		cancel:  cancel,
 	}
 }
 
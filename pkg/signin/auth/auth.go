@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"time"
 )
 
@@ -11,13 +12,13 @@ type Auth interface {
 	GetURL(redirect, state string) string
 
 	// Exchange converts authorization code into token.
-	Exchange(code string) (*Token, error)
+	Exchange(ctx context.Context, code string) (*Token, error)
 
 	// Refresh renews access token using refresh token.
-	Refresh(refreshToken string) (*Token, error)
+	Refresh(ctx context.Context, refreshToken string) (*Token, error)
 
 	// Revoke cancels the access/refresh token and reset the authorization workflow.
-	Revoke(token string) error
+	Revoke(ctx context.Context, token string) error
 }
 
 // Token represents the credentials used to authorize.
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/psewda/typing/internal/utils"
@@ -43,8 +44,8 @@ func (ga *GoogleAuth) GetURL(redirect, state string) string {
 }
 
 // Exchange converts the authorization code to access token.
-func (ga *GoogleAuth) Exchange(code string) (*auth.Token, error) {
-	token, err := ga.config.Exchange(context.Background(), code)
+func (ga *GoogleAuth) Exchange(ctx context.Context, code string) (*auth.Token, error) {
+	token, err := ga.config.Exchange(ctx, code)
 	if err != nil {
 		return nil, utils.Error("error on converting auth code into token", err)
 	}
@@ -57,8 +58,8 @@ func (ga *GoogleAuth) Exchange(code string) (*auth.Token, error) {
 }
 
 // Refresh renews access token using refresh token.
-func (ga *GoogleAuth) Refresh(refreshToken string) (*auth.Token, error) {
-	at, expiresIn, err := ga.doRefresh(refreshToken)
+func (ga *GoogleAuth) Refresh(ctx context.Context, refreshToken string) (*auth.Token, error) {
+	at, expiresIn, err := ga.doRefresh(ctx, refreshToken)
 	if err != nil {
 		msg := "error on doing token refresh"
 		return nil, utils.Error(msg, err)
@@ -72,13 +73,12 @@ func (ga *GoogleAuth) Refresh(refreshToken string) (*auth.Token, error) {
 }
 
 // Revoke cancels the access/refresh token and resets the authorization workflow.
-func (ga *GoogleAuth) Revoke(token string) error {
-	client := http.DefaultClient
+func (ga *GoogleAuth) Revoke(ctx context.Context, token string) error {
 	values := url.Values{}
 	values.Set("token", token)
 	revokeURL := toRevokeURL(ga.config.Endpoint.TokenURL)
 
-	res, err := client.PostForm(revokeURL, values)
+	res, err := postForm(ctx, revokeURL, values)
 	if err != nil {
 		return utils.Error("token revocation failed", err)
 	}
@@ -104,15 +104,14 @@ func New(cred []byte) (*GoogleAuth, error) {
 	}, nil
 }
 
-func (ga *GoogleAuth) doRefresh(refreshToken string) (string, int, error) {
-	client := http.DefaultClient
+func (ga *GoogleAuth) doRefresh(ctx context.Context, refreshToken string) (string, int, error) {
 	values := url.Values{}
 	values.Set("client_id", ga.config.ClientID)
 	values.Set("client_secret", ga.config.ClientSecret)
 	values.Set("refresh_token", refreshToken)
 	values.Set("grant_type", "refresh_token")
 
-	res, err := client.PostForm(ga.config.Endpoint.TokenURL, values)
+	res, err := postForm(ctx, ga.config.Endpoint.TokenURL, values)
 	if err != nil {
 		return utils.Empty, 0, utils.Error("token refresh failed", err)
 	}
@@ -135,6 +134,15 @@ func (ga *GoogleAuth) doRefresh(refreshToken string) (string, int, error) {
 	return b.AccessToken, b.ExpiresIn, nil
 }
 
+func postForm(ctx context.Context, u string, values url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return http.DefaultClient.Do(req)
+}
+
 func toRevokeURL(tokenURL string) string {
 	u, _ := url.Parse(tokenURL)
 	return fmt.Sprintf("%s://%s/revoke", u.Scheme, u.Host)
@@ -1,6 +1,7 @@
 package googleauth_test
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -85,7 +86,7 @@ var _ = Describe("googleauth", func() {
 
 			cred := strings.Replace(credTemplate, "{token_url}", ts.URL, 1)
 			ga, _ := googleauth.New([]byte(cred))
-			token, err := ga.Exchange("valid-authcode")
+			token, err := ga.Exchange(context.Background(), "valid-authcode")
 
 			Expect(token).ShouldNot(BeNil())
 			Expect(token.AccessToken).Should(Equal(accessToken))
@@ -101,7 +102,7 @@ var _ = Describe("googleauth", func() {
 
 			cred := strings.Replace(credTemplate, "{token_url}", ts.URL, 1)
 			ga, _ := googleauth.New([]byte(cred))
-			_, err := ga.Exchange("wrong-authcode")
+			_, err := ga.Exchange(context.Background(), "wrong-authcode")
 			Expect(err).Should(HaveOccurred())
 		})
 	})
@@ -131,7 +132,7 @@ var _ = Describe("googleauth", func() {
 			}
 			cred := strings.Replace(credTemplate, "{token_url}", ts.URL, 1)
 			ga, _ := googleauth.New([]byte(cred))
-			token, err := ga.Refresh("refresh-token")
+			token, err := ga.Refresh(context.Background(), "refresh-token")
 
 			Expect(err).ShouldNot(HaveOccurred())
 			Expect(token.AccessToken).Should(Equal("access-token"))
@@ -146,7 +147,7 @@ var _ = Describe("googleauth", func() {
 			}
 			cred := strings.Replace(credTemplate, "{token_url}", ts.URL, 1)
 			ga, _ := googleauth.New([]byte(cred))
-			_, err := ga.Refresh("refresh-token")
+			_, err := ga.Refresh(context.Background(), "refresh-token")
 			Expect(err).Should(HaveOccurred())
 		})
 	})
@@ -171,7 +172,7 @@ var _ = Describe("googleauth", func() {
 			}
 			cred := strings.Replace(credTemplate, "{token_url}", ts.URL, 1)
 			ga, _ := googleauth.New([]byte(cred))
-			err := ga.Revoke("valid-token")
+			err := ga.Revoke(context.Background(), "valid-token")
 			Expect(err).ShouldNot(HaveOccurred())
 		})
 
@@ -181,7 +182,7 @@ var _ = Describe("googleauth", func() {
 			}
 			cred := strings.Replace(credTemplate, "{token_url}", ts.URL, 1)
 			ga, _ := googleauth.New([]byte(cred))
-			err := ga.Revoke("wrong-token")
+			err := ga.Revoke(context.Background(), "wrong-token")
 			Expect(err).Should(HaveOccurred())
 		})
 	})
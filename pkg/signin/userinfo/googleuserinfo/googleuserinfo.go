@@ -19,8 +19,8 @@ type GoogleUserinfo struct {
 
 // Get returns the basic detail of user. It
 // fetches user detail using google's oauth api.
-func (gu *GoogleUserinfo) Get() (*userinfo.User, error) {
-	ui, err := gu.service.Userinfo.Get().Do()
+func (gu *GoogleUserinfo) Get(ctx context.Context) (*userinfo.User, error) {
+	ui, err := gu.service.Userinfo.Get().Context(ctx).Do()
 	if err != nil {
 		if utils.GetStatusCode(err) == http.StatusUnauthorized {
 			return nil, errs.NewUnauthorizedError()
@@ -1,6 +1,7 @@
 package googleuserinfo_test
 
 import (
+	"context"
 	"net/http"
 	"testing"
 
@@ -27,7 +28,7 @@ var _ = Describe("google userinfo", func() {
 				}`
 			client := utils.ClientWithJSON(j, http.StatusOK)
 			gui, _ := googleuserinfo.New(client)
-			u, err := gui.Get()
+			u, err := gui.Get(context.Background())
 
 			Expect(u).ShouldNot(BeNil())
 			Expect(u.Name).Should(Equal("username"))
@@ -38,7 +39,7 @@ var _ = Describe("google userinfo", func() {
 		It("should return error when authorization failure", func() {
 			client := utils.ClientWithJSON("{}", http.StatusUnauthorized)
 			gui, _ := googleuserinfo.New(client)
-			_, err := gui.Get()
+			_, err := gui.Get(context.Background())
 			Expect(err).Should(HaveOccurred())
 			Expect(err).Should(BeAssignableToTypeOf(errs.NewUnauthorizedError()))
 		})
@@ -46,7 +47,7 @@ var _ = Describe("google userinfo", func() {
 		It("should return error when any inner error", func() {
 			client := utils.ClientWithJSON("error", http.StatusInternalServerError)
 			gui, _ := googleuserinfo.New(client)
-			_, err := gui.Get()
+			_, err := gui.Get(context.Background())
 			Expect(err).Should(HaveOccurred())
 		})
 	})
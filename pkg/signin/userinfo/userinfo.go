@@ -1,9 +1,14 @@
 package userinfo
 
+import (
+	"context"
+	"sync"
+)
+
 // Userinfo is the base interface for user information.
 type Userinfo interface {
 	// Get returns the user who is associated with the token.
-	Get() (*User, error)
+	Get(ctx context.Context) (*User, error)
 }
 
 // User represents user information.
@@ -13,3 +18,32 @@ type User struct {
 	Email   string `json:"email"`
 	Picture string `json:"picture"`
 }
+
+// Cached returns the userinfo which fetches the user only once, so the
+// callers in a single request share the same user. The error is not
+// cached, the next call fetches the user again.
+func Cached(ui Userinfo) Userinfo {
+	return &cached{
+		userinfo: ui,
+	}
+}
+
+type cached struct {
+	userinfo Userinfo
+	user     *User
+	mutex    sync.Mutex
+}
+
+func (c *cached) Get(ctx context.Context) (*User, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.user == nil {
+		user, err := c.userinfo.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.user = user
+	}
+	return c.user, nil
+}
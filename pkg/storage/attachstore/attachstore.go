@@ -0,0 +1,69 @@
+package attachstore
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/psewda/typing/internal/utils"
+)
+
+// MaxSize is the max size of attachment content in bytes.
+const MaxSize = 10 << 20
+
+// Attachstore is the base interface having all operations on attachment.
+type Attachstore interface {
+	// Create uploads the content as a new attachment of the note.
+	Create(ctx context.Context, nid string, a *WritableAttachment, content io.Reader) (*Attachment, error)
+
+	// GetAll fetches all attachments of the note.
+	GetAll(ctx context.Context, nid string) ([]*Attachment, error)
+
+	// Get returns the attachment detail and its content. The caller
+	// must close the content after reading it.
+	Get(ctx context.Context, nid, aid string) (*Attachment, io.ReadCloser, error)
+
+	// Delete removes the attachment from the note.
+	Delete(ctx context.Context, nid, aid string) error
+
+	// DeleteAll removes all attachments of the note.
+	DeleteAll(ctx context.Context, nid string) error
+}
+
+// WritableAttachment is used for creating attachment. The attachment
+// belongs to the section when the section id is set, otherwise it
+// belongs to the note itself.
+type WritableAttachment struct {
+	Name        string `json:"name,omitempty" validate:"required,notblank,max=100"`
+	ContentType string `json:"contentType,omitempty" validate:"required,max=100"`
+	SectionID   string `json:"sectionId,omitempty" validate:"max=50"`
+}
+
+// Validate checks all validation rules on writable attachment fields. It
+// returns error on any validation failure.
+func (a *WritableAttachment) Validate() error {
+	return utils.ValidateStruct(a, messages)
+}
+
+// Attachment represents full detail about attachment.
+type Attachment struct {
+	ID          string    `json:"id,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	ContentType string    `json:"contentType,omitempty"`
+	Size        int64     `json:"size"`
+	NoteID      string    `json:"noteId,omitempty"`
+	SectionID   string    `json:"sectionId,omitempty"`
+	DateCreated time.Time `json:"dateCreated,omitempty"`
+}
+
+var messages map[string]string
+
+func init() {
+	messages = make(map[string]string)
+	messages["name.required"] = "name is required field"
+	messages["name.notblank"] = "name can't be empty value"
+	messages["name.max"] = "name must be less than 100 chars"
+	messages["contenttype.required"] = "content type is required field"
+	messages["contenttype.max"] = "content type must be less than 100 chars"
+	messages["sectionid.max"] = "section id must be less than 50 chars"
+}
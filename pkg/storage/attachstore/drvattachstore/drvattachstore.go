@@ -0,0 +1,229 @@
+package drvattachstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/storage/attachstore"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	appdir         = "appDataFolder"
+	fileFields     = "id, name, mimeType, size, properties, createdTime"
+	fileListFields = "files(id, name, mimeType, size, properties, createdTime)"
+
+	propType       = "type"
+	propNote       = "note"
+	propSection    = "section"
+	typeAttachment = "attachment"
+)
+
+// ExcludeQuery is the drive search query which excludes the attachment
+// files. Attachments are stored in the same app data folder as notes,
+// so note listing must skip them.
+const ExcludeQuery = "not properties has { key='" + propType + "' and value='" + typeAttachment + "' }"
+
+// IsAttachment tells whether the drive file is an attachment.
+func IsAttachment(f *drive.File) bool {
+	return f != nil && f.Properties[propType] == typeAttachment
+}
+
+// DrvAttachstore is the attachstore implementation using google drive
+// api. Each attachment is a separate file in app data folder, linked to
+// the note by file property.
+type DrvAttachstore struct {
+	service *drive.Service
+}
+
+// Create uploads the content as a new attachment file on google drive.
+func (as *DrvAttachstore) Create(ctx context.Context, nid string, a *attachstore.WritableAttachment, content io.Reader) (*attachstore.Attachment, error) {
+	if err := checkAttachment(a); err != nil {
+		return nil, utils.Error("attachment validation failed", err)
+	}
+
+	props := map[string]string{
+		propType: typeAttachment,
+		propNote: nid,
+	}
+	if len(a.SectionID) > 0 {
+		props[propSection] = strings.TrimSpace(a.SectionID)
+	}
+
+	f := drive.File{
+		Name:       strings.TrimSpace(a.Name),
+		MimeType:   a.ContentType,
+		Parents:    []string{appdir},
+		Properties: props,
+	}
+	file, err := as.service.Files.Create(&f).
+		Media(content, googleapi.ContentType(a.ContentType)).
+		Fields(fileFields).Context(ctx).Do()
+	if err != nil {
+		if utils.GetStatusCode(err) == http.StatusUnauthorized {
+			return nil, errs.NewUnauthorizedError()
+		}
+		return nil, utils.Error("attachment upload error", err)
+	}
+
+	return toAttachment(file), nil
+}
+
+// GetAll fetches all attachments of the note from google drive.
+func (as *DrvAttachstore) GetAll(ctx context.Context, nid string) ([]*attachstore.Attachment, error) {
+	files, err := list(ctx, as.service, nid)
+	if err != nil {
+		return nil, err
+	}
+
+	var attachments []*attachstore.Attachment
+	for _, f := range files {
+		attachments = append(attachments, toAttachment(f))
+	}
+	return attachments, nil
+}
+
+// Get returns the attachment detail and downloads its content from google drive.
+func (as *DrvAttachstore) Get(ctx context.Context, nid, aid string) (*attachstore.Attachment, io.ReadCloser, error) {
+	file, err := getFile(ctx, as.service, nid, aid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := as.service.Files.Get(file.Id).Context(ctx).Download()
+	if err != nil {
+		if utils.GetStatusCode(err) == http.StatusUnauthorized {
+			return nil, nil, errs.NewUnauthorizedError()
+		}
+		return nil, nil, utils.Error("attachment download error", err)
+	}
+
+	return toAttachment(file), res.Body, nil
+}
+
+// Delete removes the attachment file from google drive.
+func (as *DrvAttachstore) Delete(ctx context.Context, nid, aid string) error {
+	file, err := getFile(ctx, as.service, nid, aid)
+	if err != nil {
+		return err
+	}
+	return deleteFile(ctx, as.service, file.Id)
+}
+
+// DeleteAll removes all attachment files of the note from google drive.
+func (as *DrvAttachstore) DeleteAll(ctx context.Context, nid string) error {
+	files, err := list(ctx, as.service, nid)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if err := deleteFile(ctx, as.service, f.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// New creates a new instance of google drive attachstore.
+func New(c *http.Client) (*DrvAttachstore, error) {
+	service, err := drive.New(c)
+	if err != nil {
+		return nil, utils.Error("drive service creation error", err)
+	}
+	return NewWithService(service), nil
+}
+
+// NewWithService creates a new instance of google drive attachstore
+// using the existing drive service.
+func NewWithService(service *drive.Service) *DrvAttachstore {
+	return &DrvAttachstore{
+		service: service,
+	}
+}
+
+func checkAttachment(a *attachstore.WritableAttachment) error {
+	if a == nil {
+		return errors.New("attachment is nil")
+	}
+	return a.Validate()
+}
+
+func list(ctx context.Context, service *drive.Service, nid string) ([]*drive.File, error) {
+	q := fmt.Sprintf("properties has { key='%s' and value='%s' }", propNote, escape(nid))
+	list, err := service.Files.List().Spaces(appdir).Q(q).Fields(fileListFields).Context(ctx).Do()
+	if err != nil {
+		if utils.GetStatusCode(err) == http.StatusUnauthorized {
+			return nil, errs.NewUnauthorizedError()
+		}
+		return nil, utils.Error("attachment listing error", err)
+	}
+	return list.Files, nil
+}
+
+func getFile(ctx context.Context, service *drive.Service, nid, aid string) (*drive.File, error) {
+	file, err := service.Files.Get(aid).Fields(fileFields).Context(ctx).Do()
+	if err != nil {
+		if utils.GetStatusCode(err) == http.StatusUnauthorized {
+			return nil, errs.NewUnauthorizedError()
+		}
+		if utils.GetStatusCode(err) == http.StatusNotFound {
+			return nil, buildNotFoundError(aid)
+		}
+		return nil, utils.Error("attachment retrival error", err)
+	}
+
+	// the file must be an attachment of the same note
+	if file.Properties[propType] != typeAttachment || file.Properties[propNote] != nid {
+		return nil, buildNotFoundError(aid)
+	}
+	return file, nil
+}
+
+func deleteFile(ctx context.Context, service *drive.Service, id string) error {
+	err := service.Files.Delete(id).Context(ctx).Do()
+	if err != nil {
+		if utils.GetStatusCode(err) == http.StatusUnauthorized {
+			return errs.NewUnauthorizedError()
+		}
+		if utils.GetStatusCode(err) == http.StatusNotFound {
+			return buildNotFoundError(id)
+		}
+		return utils.Error("attachment deletion error", err)
+	}
+	return nil
+}
+
+func toAttachment(f *drive.File) *attachstore.Attachment {
+	a := attachstore.Attachment{
+		ID:          f.Id,
+		Name:        f.Name,
+		ContentType: f.MimeType,
+		Size:        f.Size,
+		NoteID:      f.Properties[propNote],
+		SectionID:   f.Properties[propSection],
+	}
+
+	if t, err := time.Parse(time.RFC3339, f.CreatedTime); err == nil {
+		a.DateCreated = t
+	}
+	return &a
+}
+
+func escape(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return strings.ReplaceAll(value, "'", `\'`)
+}
+
+func buildNotFoundError(id string) *errs.NotFoundError {
+	msg := fmt.Sprintf("attachment with id '%s' not found", id)
+	return errs.NewNotFoundError(msg)
+}
@@ -0,0 +1,180 @@
+package drvattachstore_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/storage/attachstore"
+	"github.com/psewda/typing/pkg/storage/attachstore/drvattachstore"
+	"google.golang.org/api/drive/v3"
+)
+
+func TestDrvAttachstore(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "drvattachstore-suite")
+}
+
+var _ = Describe("googledrive attachstore", func() {
+	const file = `{
+			"id": "aid",
+			"name": "image.png",
+			"mimeType": "image/png",
+			"size": "7",
+			"properties": { "type": "attachment", "note": "nid", "section": "sid" },
+			"createdTime": "2021-02-12T07:20:50.52Z"
+		}`
+
+	Context("create new attachment", func() {
+		It("should upload the content linked to the note", func() {
+			verifyReq := func(req *http.Request) {
+				_, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+				reader := multipart.NewReader(req.Body, params["boundary"])
+
+				var f drive.File
+				p, _ := reader.NextPart()
+				json.NewDecoder(p).Decode(&f)
+				Expect(f.Name).Should(Equal("image.png"))
+				Expect(f.Parents).Should(ContainElement("appDataFolder"))
+				Expect(f.Properties).Should(HaveKeyWithValue("type", "attachment"))
+				Expect(f.Properties).Should(HaveKeyWithValue("note", "nid"))
+				Expect(f.Properties).Should(HaveKeyWithValue("section", "sid"))
+
+				p, _ = reader.NextPart()
+				content, _ := ioutil.ReadAll(p)
+				Expect(string(content)).Should(Equal("content"))
+			}
+
+			client := http.DefaultClient
+			client.Transport = utils.TransportFunc(func(req *http.Request) (*http.Response, error) {
+				verifyReq(req)
+				return buildResponse(http.StatusOK, file), nil
+			})
+
+			das, _ := drvattachstore.New(client)
+			a, err := das.Create(context.Background(), "nid", &attachstore.WritableAttachment{
+				Name:        "image.png",
+				ContentType: "image/png",
+				SectionID:   "sid",
+			}, strings.NewReader("content"))
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(a.ID).Should(Equal("aid"))
+			Expect(a.NoteID).Should(Equal("nid"))
+			Expect(a.SectionID).Should(Equal("sid"))
+			Expect(a.Size).Should(Equal(int64(7)))
+			Expect(a.DateCreated).ShouldNot(BeZero())
+		})
+
+		It("should return error when wrong input", func() {
+			das, _ := drvattachstore.New(http.DefaultClient)
+			_, err := das.Create(context.Background(), "nid", &attachstore.WritableAttachment{
+				Name: "image.png",
+			}, strings.NewReader("content"))
+
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should return error when authorization failure", func() {
+			client := utils.ClientWithJSON("{}", http.StatusUnauthorized)
+			das, _ := drvattachstore.New(client)
+			_, err := das.Create(context.Background(), "nid", &attachstore.WritableAttachment{
+				Name:        "image.png",
+				ContentType: "image/png",
+			}, strings.NewReader("content"))
+
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewUnauthorizedError()))
+		})
+	})
+
+	Context("get attachments", func() {
+		It("should list the attachments of the note", func() {
+			client := http.DefaultClient
+			client.Transport = utils.TransportFunc(func(req *http.Request) (*http.Response, error) {
+				q := req.URL.Query().Get("q")
+				Expect(q).Should(Equal("properties has { key='note' and value='nid' }"))
+				return buildResponse(http.StatusOK, `{ "files": [`+file+`] }`), nil
+			})
+
+			das, _ := drvattachstore.New(client)
+			attachments, err := das.GetAll(context.Background(), "nid")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(attachments).Should(HaveLen(1))
+			Expect(attachments[0].Name).Should(Equal("image.png"))
+		})
+
+		It("should return the attachment content", func() {
+			client := http.DefaultClient
+			client.Transport = utils.TransportFunc(func(req *http.Request) (*http.Response, error) {
+				if req.URL.Query().Get("alt") == "media" {
+					return buildResponse(http.StatusOK, "content"), nil
+				}
+				return buildResponse(http.StatusOK, file), nil
+			})
+
+			das, _ := drvattachstore.New(client)
+			a, content, err := das.Get(context.Background(), "nid", "aid")
+			Expect(err).ShouldNot(HaveOccurred())
+			defer content.Close()
+
+			data, _ := ioutil.ReadAll(content)
+			Expect(a.ContentType).Should(Equal("image/png"))
+			Expect(string(data)).Should(Equal("content"))
+		})
+
+		It("should return error when attachment of other note", func() {
+			client := utils.ClientWithJSON(file, http.StatusOK)
+			das, _ := drvattachstore.New(client)
+			_, _, err := das.Get(context.Background(), "other", "aid")
+
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewNotFoundError("msg")))
+		})
+	})
+
+	Context("delete attachments", func() {
+		It("should delete all attachments of the note", func() {
+			deleted := 0
+			client := http.DefaultClient
+			client.Transport = utils.TransportFunc(func(req *http.Request) (*http.Response, error) {
+				if req.Method == http.MethodDelete {
+					deleted++
+					return buildResponse(http.StatusNoContent, ""), nil
+				}
+				return buildResponse(http.StatusOK, `{ "files": [`+file+`,`+file+`] }`), nil
+			})
+
+			das, _ := drvattachstore.New(client)
+			err := das.DeleteAll(context.Background(), "nid")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(deleted).Should(Equal(2))
+		})
+
+		It("should return error when wrong attachment id", func() {
+			client := utils.ClientWithJSON("{}", http.StatusNotFound)
+			das, _ := drvattachstore.New(client)
+			err := das.Delete(context.Background(), "nid", "aid")
+
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewNotFoundError("msg")))
+		})
+	})
+})
+
+func buildResponse(code int, j string) *http.Response {
+	return &http.Response{
+		StatusCode: code,
+		Body:       ioutil.NopCloser(bytes.NewBufferString(j)),
+		Header:     map[string][]string{"Content-Type": {"application/json"}},
+	}
+}
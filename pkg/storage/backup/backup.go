@@ -0,0 +1,452 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/storage/migrate"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore/drvsectionstore"
+)
+
+const (
+	// FormatVersion is the version of backup archive written by the
+	// archiver. The archive of newer version is not restored.
+	FormatVersion = 1
+
+	// MaxArchiveSize is the max size of all files in the restored archive,
+	// the compressed archive is limited to the same size.
+	MaxArchiveSize = 256 << 20
+
+	// CollisionSkip keeps the existing note and skips the archived note.
+	CollisionSkip = "skip"
+
+	// CollisionOverwrite replaces the existing note with the archived note.
+	CollisionOverwrite = "overwrite"
+
+	// CollisionRename restores the archived note as a new renamed note.
+	CollisionRename = "rename"
+
+	manifestFile = "manifest.json"
+	notesDir     = "notes/"
+	contentDir   = "content/"
+	renameSuffix = " (restored)"
+	maxFileSize  = 32 << 20
+	maxEntries   = 20000
+)
+
+// Archiver backs up and restores all notes of the user.
+type Archiver interface {
+	// Backup writes the archive of all notes and their content.
+	Backup(ctx context.Context, w io.Writer) (*Manifest, error)
+
+	// Restore imports the notes of archive. The collision tells how the
+	// note having the same id or name as an existing note is restored.
+	Restore(ctx context.Context, r io.Reader, collision string) (*Report, error)
+}
+
+// Manifest is the last file of backup archive. It has the sha256
+// checksums of all other files in the archive.
+type Manifest struct {
+	Version  int               `json:"version"`
+	Created  time.Time         `json:"created"`
+	Notes    int               `json:"notes"`
+	Sections int               `json:"sections"`
+	Files    map[string]string `json:"files"`
+}
+
+// Report is the outcome of restore.
+type Report struct {
+	Notes       int        `json:"notes"`
+	Created     int        `json:"created"`
+	Overwritten int        `json:"overwritten"`
+	Renamed     int        `json:"renamed"`
+	Skipped     int        `json:"skipped"`
+	Failures    []*Failure `json:"failures,omitempty"`
+}
+
+// Failure is the archived note failed to restore.
+type Failure struct {
+	NoteID string `json:"noteId"`
+	Name   string `json:"name,omitempty"`
+	Error  string `json:"error"`
+}
+
+// BackendArchiver is the archiver of the notes in storage backend. The
+// archive has the note metadata and the whole note content, so the
+// sections are restored with their ids and dates. The restored notes get
+// new ids, and the links between them are rewritten. The backend content
+// must take the note lock on upload, see notelock.NewContent, so restore
+// never races with the section changes.
+type BackendArchiver struct {
+	backend *migrate.Backend
+}
+
+// file is the single file of backup archive.
+type file struct {
+	path string
+	data []byte
+}
+
+// Backup streams the archive while reading the notes one by one, so only
+// one note is held in memory. The manifest is the last file, so the archive
+// cut by the failure has no manifest and is never restored.
+func (a *BackendArchiver) Backup(ctx context.Context, w io.Writer) (*Manifest, error) {
+	notes, err := a.backend.Notestore.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		return notes[i].DateCreated.Before(notes[j].DateCreated)
+	})
+
+	m := &Manifest{
+		Version: FormatVersion,
+		Created: time.Now().UTC(),
+		Notes:   len(notes),
+		Files:   make(map[string]string),
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, n := range notes {
+		content, err := a.backend.Content.Download(ctx, n.ID)
+		if err != nil {
+			return nil, err
+		}
+		sections, err := drvsectionstore.Sections(content)
+		if err != nil {
+			msg := fmt.Sprintf("note content error of note '%s'", n.ID)
+			return nil, utils.Error(msg, err)
+		}
+		m.Sections += len(sections)
+
+		meta, _ := json.Marshal(n)
+		for _, f := range []*file{{path: notePath(n.ID), data: meta}, {path: contentPath(n.ID), data: content}} {
+			m.Files[f.path] = checksum(f.data)
+			if err := writeFile(tw, f, m.Created); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	manifest, _ := json.MarshalIndent(m, utils.Empty, "  ")
+	if err := writeFile(tw, &file{path: manifestFile, data: manifest}, m.Created); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, utils.Error("backup archive write error", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, utils.Error("backup archive write error", err)
+	}
+	return m, nil
+}
+
+// Restore checks the archive before restoring any note, so the corrupted
+// archive restores nothing. The notes are created first, so the links
+// between notes are rewritten with the new note ids while restoring the
+// content. The note failure is reported and the restore goes on.
+func (a *BackendArchiver) Restore(ctx context.Context, r io.Reader, collision string) (*Report, error) {
+	if err := CheckCollision(collision); err != nil {
+		return nil, errs.NewValidationError(err.Error())
+	}
+	notes, contents, err := read(r)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := a.backend.Notestore.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	find := finder(existing)
+
+	report := &Report{Notes: len(notes)}
+	ids := make(map[string]string)
+	overwritten := make(map[string]bool)
+	var restored []*notestore.Note
+	for _, n := range notes {
+		cur := find(n)
+		if cur != nil && collision == CollisionSkip {
+			// the links to the skipped note go to the existing note
+			ids[n.ID] = cur.ID
+			report.Skipped++
+			continue
+		}
+		note, err := a.restoreNote(ctx, n, cur, collision, report)
+		if err != nil {
+			if errs.IsFatal(ctx, err) {
+				return nil, err
+			}
+			report.fail(n, err)
+			continue
+		}
+		ids[n.ID] = note.ID
+		overwritten[n.ID] = cur != nil && collision == CollisionOverwrite
+		restored = append(restored, n)
+	}
+
+	for _, n := range restored {
+		err := a.restoreContent(ctx, ids[n.ID], contents[n.ID], ids, overwritten[n.ID])
+		if err != nil {
+			if errs.IsFatal(ctx, err) {
+				return nil, err
+			}
+			report.fail(n, err)
+		}
+	}
+	return report, nil
+}
+
+// restoreNote creates the archived note, or overwrites the existing note
+// having the same id or name. The nil existing note is created as is.
+func (a *BackendArchiver) restoreNote(ctx context.Context, n, cur *notestore.Note,
+	collision string, report *Report) (*notestore.Note, error) {
+	w := n.Writable()
+	switch {
+	case cur != nil && collision == CollisionOverwrite:
+		note, err := a.backend.Notestore.Update(ctx, cur.ID, w)
+		if err == nil {
+			report.Overwritten++
+		}
+		return note, err
+	case cur != nil:
+		w.Name = renamed(n.Name)
+		note, err := a.backend.Notestore.Create(ctx, w)
+		if err == nil {
+			report.Renamed++
+		}
+		return note, err
+	default:
+		note, err := a.backend.Notestore.Create(ctx, w)
+		if err == nil {
+			report.Created++
+		}
+		return note, err
+	}
+}
+
+// restoreContent uploads the archived content with relinked notes. The
+// overwritten note without archived content gets the empty document, so
+// its existing sections are removed.
+func (a *BackendArchiver) restoreContent(ctx context.Context, id string, content []byte,
+	ids map[string]string, overwritten bool) error {
+	if len(bytes.TrimSpace(content)) == 0 {
+		if !overwritten {
+			return nil
+		}
+		content = drvsectionstore.Empty()
+	}
+	content, err := drvsectionstore.Relink(content, ids)
+	if err != nil {
+		return err
+	}
+	_, err = a.backend.Content.Upload(ctx, id, content)
+	return err
+}
+
+// finder returns the func finding the existing note having the same id
+// or name as the archived note.
+func finder(existing []*notestore.Note) func(n *notestore.Note) *notestore.Note {
+	byID := make(map[string]*notestore.Note, len(existing))
+	byName := make(map[string]*notestore.Note, len(existing))
+	for _, n := range existing {
+		byID[n.ID] = n
+		byName[n.Name] = n
+	}
+	return func(n *notestore.Note) *notestore.Note {
+		if cur, ok := byID[n.ID]; ok {
+			return cur
+		}
+		return byName[n.Name]
+	}
+}
+
+// New creates a new archiver of the notes in storage backend.
+func New(b *migrate.Backend) *BackendArchiver {
+	return &BackendArchiver{
+		backend: b,
+	}
+}
+
+// CheckCollision validates the collision option of restore.
+func CheckCollision(collision string) error {
+	switch collision {
+	case CollisionSkip, CollisionOverwrite, CollisionRename:
+		return nil
+	default:
+		return fmt.Errorf("collision must be one of '%s', '%s' or '%s'",
+			CollisionSkip, CollisionOverwrite, CollisionRename)
+	}
+}
+
+// read returns the archived notes in creation order and their contents. It
+// fails when any file is missing or has wrong checksum, or when the archive
+// is beyond the max entries or size.
+func read(r io.Reader) ([]*notestore.Note, map[string][]byte, error) {
+	m, files, err := readArchive(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	for path, data := range files {
+		sum, ok := m.Files[path]
+		if !ok || sum != checksum(data) {
+			msg := fmt.Sprintf("file '%s' has wrong checksum", path)
+			return nil, nil, errs.NewValidationError(msg)
+		}
+	}
+
+	var notes []*notestore.Note
+	contents := make(map[string][]byte)
+	for path := range m.Files {
+		if _, ok := files[path]; !ok {
+			msg := fmt.Sprintf("file '%s' is missing in archive", path)
+			return nil, nil, errs.NewValidationError(msg)
+		}
+		if !strings.HasPrefix(path, notesDir) {
+			continue
+		}
+		n := new(notestore.Note)
+		if err := json.Unmarshal(files[path], n); err != nil || len(n.ID) == 0 {
+			return nil, nil, invalid(fmt.Sprintf("file '%s' is not valid note", path), err)
+		}
+		notes = append(notes, n)
+		contents[n.ID] = files[contentPath(n.ID)]
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		return notes[i].DateCreated.Before(notes[j].DateCreated)
+	})
+	return notes, contents, nil
+}
+
+// readArchive returns the manifest and other files of the archive. The
+// manifest must be the last file.
+func readArchive(r io.Reader) (*Manifest, map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, invalid("archive is not gzip compressed", err)
+	}
+	tr := tar.NewReader(gz)
+
+	var m *Manifest
+	var total int64
+	files := make(map[string][]byte)
+	for entries := 0; ; entries++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, invalid("archive is not valid", err)
+		}
+		if m != nil {
+			return nil, nil, errs.NewValidationError("manifest is not the last file of archive")
+		}
+		if entries == maxEntries {
+			msg := fmt.Sprintf("archive must have at most %d files", maxEntries)
+			return nil, nil, errs.NewValidationError(msg)
+		}
+		data, err := readFile(tr)
+		if err != nil {
+			return nil, nil, err
+		}
+		if total += int64(len(data)); total > MaxArchiveSize {
+			msg := fmt.Sprintf("archive must be less than %d bytes", MaxArchiveSize)
+			return nil, nil, errs.NewValidationError(msg)
+		}
+		if hdr.Name == manifestFile {
+			if m, err = readManifest(data); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		files[hdr.Name] = data
+	}
+	if m == nil {
+		return nil, nil, errs.NewValidationError("archive has no manifest")
+	}
+	return m, files, nil
+}
+
+func readManifest(data []byte) (*Manifest, error) {
+	m := new(Manifest)
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, invalid("manifest is not valid", err)
+	}
+	if m.Version < 1 || m.Version > FormatVersion {
+		msg := fmt.Sprintf("archive version %d is not supported", m.Version)
+		return nil, errs.NewValidationError(msg)
+	}
+	return m, nil
+}
+
+func readFile(tr *tar.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(tr, maxFileSize+1))
+	if err != nil {
+		return nil, invalid("archive is not valid", err)
+	}
+	if len(data) > maxFileSize {
+		return nil, errs.NewValidationError("archive file is too large")
+	}
+	return data, nil
+}
+
+func writeFile(tw *tar.Writer, f *file, date time.Time) error {
+	hdr := &tar.Header{
+		Name:    f.path,
+		Mode:    0600,
+		Size:    int64(len(f.data)),
+		ModTime: date,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return utils.Error("backup archive write error", err)
+	}
+	if _, err := tw.Write(f.data); err != nil {
+		return utils.Error("backup archive write error", err)
+	}
+	return nil
+}
+
+func notePath(id string) string {
+	return notesDir + url.PathEscape(id) + ".json"
+}
+
+func contentPath(id string) string {
+	return contentDir + url.PathEscape(id) + ".json"
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func invalid(msg string, err error) error {
+	return errs.NewValidationError(utils.AppendError(msg, err))
+}
+
+func (r *Report) fail(n *notestore.Note, err error) {
+	r.Failures = append(r.Failures, &Failure{NoteID: n.ID, Name: n.Name, Error: err.Error()})
+}
+
+// renamed adds the restore suffix to the note name, the name is cut to
+// keep it in the max length of note name.
+func renamed(name string) string {
+	runes := []rune(name)
+	if max := 100 - len([]rune(renameSuffix)); len(runes) > max {
+		runes = runes[:max]
+	}
+	return strings.TrimSpace(string(runes)) + renameSuffix
+}
@@ -0,0 +1,309 @@
+package backup_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/storage/backup"
+	"github.com/psewda/typing/pkg/storage/migrate"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/offline"
+	"github.com/psewda/typing/pkg/storage/sectionstore/drvsectionstore"
+)
+
+func TestBackup(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "backup-suite")
+}
+
+var _ = Describe("backup", func() {
+	var (
+		src     *memStore
+		archive *bytes.Buffer
+		first   *notestore.Note
+	)
+	ctx := context.Background()
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	backend := func(s *memStore) *migrate.Backend {
+		return &migrate.Backend{Name: migrate.BackendDrive, Notestore: s, Content: s}
+	}
+
+	BeforeEach(func() {
+		src = newMemStore()
+		first = src.add("first", created, `{ "version": 3, "sections": [ { "id": "s1", "name": "section" } ] }`)
+		src.add("second", created.Add(time.Hour), fmt.Sprintf(`[ { "id": "s2", "links": [ { "noteId": "%s" } ] } ]`, first.ID))
+		src.add("empty", created.Add(2*time.Hour), "")
+
+		archive = new(bytes.Buffer)
+		m, err := backup.New(backend(src)).Backup(ctx, archive)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(m.Notes).Should(Equal(3))
+		Expect(m.Sections).Should(Equal(2))
+		Expect(m.Files).Should(HaveLen(6))
+	})
+
+	Context("restore archive", func() {
+		It("should create the notes and relink them when new backend", func() {
+			dst := newMemStore()
+			report, err := backup.New(backend(dst)).Restore(ctx, archive, backup.CollisionSkip)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Notes).Should(Equal(3))
+			Expect(report.Created).Should(Equal(3))
+			Expect(report.Failures).Should(BeEmpty())
+
+			restored, second := dst.byName("first"), dst.byName("second")
+			Expect(restored.ID).ShouldNot(Equal(first.ID))
+			sections, _ := drvsectionstore.Sections(dst.content[second.ID])
+			Expect(sections[0].Links[0].NoteID).Should(Equal(restored.ID))
+			sections, _ = drvsectionstore.Sections(dst.content[restored.ID])
+			Expect(sections[0].Name).Should(Equal("section"))
+		})
+
+		It("should restore the notes in local store", func() {
+			dir, _ := ioutil.TempDir("", "typing-backup")
+			defer os.RemoveAll(dir)
+			store, _ := offline.New(filepath.Join(dir, "fs"), offline.DefaultConfig())
+			ns := offline.NewNotestore(store, nil, nil)
+			b := &migrate.Backend{Name: migrate.BackendFS, Notestore: ns, Content: offline.NewContent(store, nil)}
+
+			report, err := backup.New(b).Restore(ctx, archive, backup.CollisionSkip)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Created).Should(Equal(3))
+			notes, _ := ns.GetAll(ctx)
+			Expect(notes).Should(HaveLen(3))
+		})
+
+		It("should keep the existing notes when skip collision", func() {
+			report, err := backup.New(backend(src)).Restore(ctx, archive, backup.CollisionSkip)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Skipped).Should(Equal(3))
+			Expect(src.notes).Should(HaveLen(3))
+		})
+
+		It("should replace the existing notes when overwrite collision", func() {
+			src.Update(ctx, first.ID, &notestore.WritableNote{Name: "first", Labels: []string{"changed"}})
+			src.content[first.ID] = []byte("[]")
+			report, err := backup.New(backend(src)).Restore(ctx, archive, backup.CollisionOverwrite)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Overwritten).Should(Equal(3))
+			Expect(src.notes).Should(HaveLen(3))
+			Expect(src.notes[first.ID].Labels).Should(BeEmpty())
+			sections, _ := drvsectionstore.Sections(src.content[first.ID])
+			Expect(sections).Should(HaveLen(1))
+		})
+
+		It("should clear the sections of overwritten note having no archived content", func() {
+			empty := src.byName("empty")
+			src.content[empty.ID] = []byte(`{ "version": 3, "sections": [ { "id": "s3", "name": "added" } ] }`)
+			report, err := backup.New(backend(src)).Restore(ctx, archive, backup.CollisionOverwrite)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Failures).Should(BeEmpty())
+			sections, err := drvsectionstore.Sections(src.content[empty.ID])
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sections).Should(BeEmpty())
+		})
+
+		It("should restore the renamed notes when rename collision", func() {
+			dst := newMemStore()
+			dst.add("first", created, "")
+			report, err := backup.New(backend(dst)).Restore(ctx, archive, backup.CollisionRename)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Renamed).Should(Equal(1))
+			Expect(report.Created).Should(Equal(2))
+			Expect(dst.byName("first (restored)")).ShouldNot(BeNil())
+		})
+
+		It("should return error when invalid collision", func() {
+			_, err := backup.New(backend(src)).Restore(ctx, archive, "merge")
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+		})
+
+		It("should restore nothing when wrong checksum", func() {
+			data := writeArchive(
+				file{"notes/n1.json", `{ "id": "n1", "name": "first" }`},
+				file{"manifest.json", `{ "version": 1, "files": { "notes/n1.json": "00" } }`})
+			dst := newMemStore()
+			_, err := backup.New(backend(dst)).Restore(ctx, data, backup.CollisionSkip)
+
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+			Expect(dst.notes).Should(BeEmpty())
+		})
+
+		It("should restore nothing when archive has no manifest", func() {
+			data := writeArchive(file{"notes/n1.json", `{ "id": "n1", "name": "first" }`})
+			dst := newMemStore()
+			_, err := backup.New(backend(dst)).Restore(ctx, data, backup.CollisionSkip)
+
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+			Expect(dst.notes).Should(BeEmpty())
+		})
+
+		It("should restore nothing when manifest is not last file", func() {
+			data := writeArchive(
+				file{"manifest.json", `{ "version": 1, "files": {} }`},
+				file{"notes/n1.json", `{ "id": "n1", "name": "first" }`})
+			dst := newMemStore()
+			_, err := backup.New(backend(dst)).Restore(ctx, data, backup.CollisionSkip)
+
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+			Expect(dst.notes).Should(BeEmpty())
+		})
+
+		It("should return error when archive has too many files", func() {
+			files := make([]file, 20001)
+			for i := range files {
+				files[i] = file{fmt.Sprintf("notes/n%d.json", i), "{}"}
+			}
+			_, err := backup.New(backend(src)).Restore(ctx, writeArchive(files...), backup.CollisionSkip)
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+			Expect(err.Error()).Should(ContainSubstring("at most"))
+		})
+
+		It("should return error when newer archive version", func() {
+			data := writeArchive(file{"manifest.json", `{ "version": 99, "files": {} }`})
+			_, err := backup.New(backend(src)).Restore(ctx, data, backup.CollisionSkip)
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+		})
+
+		It("should return error when not archive", func() {
+			_, err := backup.New(backend(src)).Restore(ctx, bytes.NewBufferString("notes"), backup.CollisionSkip)
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+		})
+	})
+})
+
+type file struct {
+	path string
+	data string
+}
+
+func writeArchive(files ...file) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		tw.WriteHeader(&tar.Header{Name: f.path, Mode: 0600, Size: int64(len(f.data))})
+		tw.Write([]byte(f.data))
+	}
+	tw.Close()
+	gz.Close()
+	return buf
+}
+
+// memStore is the in-memory storage backend.
+type memStore struct {
+	notes   map[string]*notestore.Note
+	content map[string][]byte
+	seq     int
+	mutex   sync.Mutex
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		notes:   make(map[string]*notestore.Note),
+		content: make(map[string][]byte),
+	}
+}
+
+func (s *memStore) add(name string, date time.Time, content string) *notestore.Note {
+	note, _ := s.Create(context.Background(), &notestore.WritableNote{Name: name})
+	note.DateCreated = date
+	note.DateUpdated = date
+	s.content[note.ID] = []byte(content)
+	return note
+}
+
+func (s *memStore) byName(name string) *notestore.Note {
+	for _, n := range s.notes {
+		if n.Name == name {
+			return n
+		}
+	}
+	return nil
+}
+
+func (s *memStore) Create(ctx context.Context, n *notestore.WritableNote) (*notestore.Note, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.seq++
+	note := &notestore.Note{
+		ID:          fmt.Sprintf("note-%d-%d", s.seq, time.Now().UnixNano()),
+		Name:        n.Name,
+		Labels:      n.Labels,
+		Metadata:    n.Metadata,
+		DateCreated: time.Now().UTC(),
+		DateUpdated: time.Now().UTC(),
+	}
+	s.notes[note.ID] = note
+	return note, nil
+}
+
+func (s *memStore) GetAll(ctx context.Context) ([]*notestore.Note, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var notes []*notestore.Note
+	for _, n := range s.notes {
+		notes = append(notes, n)
+	}
+	return notes, nil
+}
+
+func (s *memStore) Get(ctx context.Context, id string) (*notestore.Note, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if n, ok := s.notes[id]; ok {
+		return n, nil
+	}
+	return nil, errs.NewNotFoundError("note not found")
+}
+
+func (s *memStore) Update(ctx context.Context, id string, n *notestore.WritableNote) (*notestore.Note, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	note := s.notes[id]
+	note.Name = n.Name
+	note.Labels = n.Labels
+	note.Metadata = n.Metadata
+	return note, nil
+}
+
+func (s *memStore) Delete(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.notes, id)
+	delete(s.content, id)
+	return nil
+}
+
+func (s *memStore) Download(ctx context.Context, nid string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.notes[nid]; !ok {
+		return nil, errs.NewNotFoundError("note not found")
+	}
+	return s.content[nid], nil
+}
+
+func (s *memStore) Upload(ctx context.Context, nid string, content []byte) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.content[nid] = content
+	return 0, nil
+}
@@ -0,0 +1,242 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/errs"
+)
+
+const (
+	amzDateLayout = "20060102T150405Z"
+	defaultRegion = "us-east-1"
+)
+
+// S3Config is the config of S3 compatible target. The bucket is addressed
+// in the path, like 'https://endpoint/bucket/key', which works with AWS
+// and most S3 compatible storages.
+type S3Config struct {
+	Endpoint  string `json:"endpoint"`
+	Region    string `json:"region,omitempty"`
+	Bucket    string `json:"bucket"`
+	Prefix    string `json:"prefix,omitempty"`
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+}
+
+// S3Target is the target storing the snapshots in S3 compatible storage.
+// The requests are signed with AWS signature version 4.
+type S3Target struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// listResult is the response of list objects v2.
+type listResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// Put uploads the snapshot to the bucket.
+func (t *S3Target) Put(ctx context.Context, name string, data []byte) error {
+	_, err := t.do(ctx, http.MethodPut, t.key(name), nil, data)
+	return err
+}
+
+// List returns the snapshots in the folder of bucket.
+func (t *S3Target) List(ctx context.Context, folder string) ([]*Snapshot, error) {
+	prefix := t.key(folder) + "/"
+	var snapshots []*Snapshot
+	token := utils.Empty
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if len(token) > 0 {
+			query.Set("continuation-token", token)
+		}
+		body, err := t.do(ctx, http.MethodGet, utils.Empty, query, nil)
+		if err != nil {
+			return nil, err
+		}
+		result := new(listResult)
+		if err := xml.Unmarshal(body, result); err != nil {
+			return nil, utils.Error("snapshot listing error", err)
+		}
+		for _, c := range result.Contents {
+			name := folder + "/" + strings.TrimPrefix(c.Key, prefix)
+			if s := toSnapshot(name, c.Size); s != nil {
+				snapshots = append(snapshots, s)
+			}
+		}
+		if !result.IsTruncated || len(result.NextContinuationToken) == 0 {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sortSnapshots(snapshots)
+	return snapshots, nil
+}
+
+// Delete removes the snapshot from the bucket.
+func (t *S3Target) Delete(ctx context.Context, name string) error {
+	_, err := t.do(ctx, http.MethodDelete, t.key(name), nil, nil)
+	return err
+}
+
+// NewS3Target creates a new target storing the snapshots in S3 compatible
+// storage.
+func NewS3Target(c S3Config) (*S3Target, error) {
+	if len(c.Endpoint) == 0 || len(c.Bucket) == 0 {
+		return nil, errors.New("s3 endpoint and bucket are required")
+	}
+	if len(c.AccessKey) == 0 || len(c.SecretKey) == 0 {
+		return nil, errors.New("s3 access key and secret key are required")
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(c.Endpoint, "/"))
+	if err != nil || len(endpoint.Host) == 0 {
+		return nil, fmt.Errorf("s3 endpoint '%s' is not valid url", c.Endpoint)
+	}
+	if len(c.Region) == 0 {
+		c.Region = defaultRegion
+	}
+	c.Prefix = strings.Trim(c.Prefix, "/")
+	return &S3Target{
+		config:   c,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// key returns the object key of the snapshot name.
+func (t *S3Target) key(name string) string {
+	if len(t.config.Prefix) == 0 {
+		return name
+	}
+	return t.config.Prefix + "/" + name
+}
+
+// do sends the signed request of the object key, the empty key is the
+// request of bucket itself.
+func (t *S3Target) do(ctx context.Context, method, key string, query url.Values, body []byte) ([]byte, error) {
+	path := t.endpoint.Path + "/" + t.config.Bucket
+	if len(key) > 0 {
+		path += "/" + key
+	}
+	u := *t.endpoint
+	u.Path = path
+	u.RawPath = escapePath(path)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, utils.Error("s3 request error", err)
+	}
+	t.sign(req, body, time.Now().UTC())
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return nil, utils.Error("s3 request error", err)
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxFileSize))
+	if err != nil {
+		return nil, utils.Error("s3 response error", err)
+	}
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, errs.NewNotFoundError(fmt.Sprintf("s3 object '%s' not found", key))
+	case res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusUnauthorized:
+		return nil, errs.NewUnauthorizedError()
+	case res.StatusCode >= 300:
+		return nil, fmt.Errorf("s3 request failed with status %d: %s", res.StatusCode, bytes.TrimSpace(data))
+	}
+	return data, nil
+}
+
+// sign adds the AWS signature version 4 to the request.
+func (t *S3Target) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format(amzDateLayout)
+	day := now.Format("20060102")
+	payload := checksum(body)
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payload)
+
+	signed := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payload,
+		"x-amz-date:" + amzDate,
+		utils.Empty,
+		signed,
+		payload,
+	}, "\n")
+	scope := strings.Join([]string{day, t.config.Region, "s3", "aws4_request"}, "/")
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, checksum([]byte(canonical))}, "\n")
+
+	key := []byte("AWS4" + t.config.SecretKey)
+	for _, part := range []string{day, t.config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		t.config.AccessKey, scope, signed, signature))
+}
+
+// escapePath encodes the path as the canonical uri of signature, all bytes
+// except the unreserved bytes and slash are encoded.
+func escapePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		parts[i] = escape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+// canonicalQuery encodes the query sorted by key as the canonical query of
+// signature.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			pairs = append(pairs, escape(k)+"="+escape(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
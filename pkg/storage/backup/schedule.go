@@ -0,0 +1,358 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/log"
+	"github.com/psewda/typing/pkg/signin/userinfo"
+)
+
+const (
+	// DefaultInterval is the default time between two snapshots of user.
+	DefaultInterval = 24 * time.Hour
+
+	// DefaultDaily is the default number of latest days keeping a snapshot.
+	DefaultDaily = 7
+
+	// DefaultWeekly is the default number of latest weeks keeping a snapshot.
+	DefaultWeekly = 4
+
+	maxCheckInterval = 15 * time.Minute
+)
+
+// Schedule is the config of scheduled backups, read from the schedule file.
+// The snapshots go to the local directory or the S3 compatible storage.
+type Schedule struct {
+	Interval  string           `json:"interval,omitempty"`
+	Dir       string           `json:"dir,omitempty"`
+	S3        *S3Config        `json:"s3,omitempty"`
+	Retention Retention        `json:"retention"`
+	Users     []*ScheduledUser `json:"users"`
+	interval  time.Duration
+}
+
+// ScheduledUser is the user backed up by the schedule. The refresh token
+// renews the access token of the user on every backup.
+type ScheduledUser struct {
+	Email        string `json:"email"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Retention tells the snapshots kept in the target. The newest snapshot of
+// each of the latest days and weeks is kept, the other snapshots are
+// deleted. The latest snapshot is always kept.
+type Retention struct {
+	Daily  int `json:"daily"`
+	Weekly int `json:"weekly"`
+}
+
+// Reporter reports the scheduled backups of the user.
+type Reporter interface {
+	// Status returns the state of scheduled backups of the user.
+	Status(ctx context.Context) (*Status, error)
+}
+
+// Source returns the archiver of the notes of scheduled user.
+type Source func(ctx context.Context, u *ScheduledUser) (Archiver, error)
+
+// Status is the state of scheduled backups of user.
+type Status struct {
+	Email       string      `json:"email"`
+	Interval    string      `json:"interval"`
+	Retention   Retention   `json:"retention"`
+	LastRun     *time.Time  `json:"lastRun,omitempty"`
+	LastSuccess *time.Time  `json:"lastSuccess,omitempty"`
+	LastError   string      `json:"lastError,omitempty"`
+	Snapshots   []*Snapshot `json:"snapshots"`
+}
+
+// Scheduler takes the snapshots of scheduled users periodically. The user
+// is backed up when its latest snapshot is older than the interval, so the
+// restarted server does not take the extra snapshots.
+type Scheduler struct {
+	schedule *Schedule
+	target   Target
+	source   Source
+	logger   *log.Logger
+	status   map[string]*Status
+	mutex    sync.Mutex
+}
+
+// UserReporter is the reporter of single user, it is bound to the user of
+// the userinfo.
+type UserReporter struct {
+	scheduler *Scheduler
+	userinfo  userinfo.Userinfo
+}
+
+// ReadSchedule reads and validates the schedule file.
+func ReadSchedule(path string) (*Schedule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, utils.Error("schedule file read error", err)
+	}
+	s := new(Schedule)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, utils.Error("schedule file is not valid json", err)
+	}
+	if err := s.check(); err != nil {
+		return nil, utils.Error("schedule validation failed", err)
+	}
+	return s, nil
+}
+
+// Target returns the target of the schedule.
+func (s *Schedule) Target() (Target, error) {
+	if s.S3 != nil {
+		return NewS3Target(*s.S3)
+	}
+	return NewDirTarget(s.Dir)
+}
+
+// Start runs the scheduled backups in background. It returns the func
+// stopping the backups.
+func (s *Scheduler) Start() func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		check := s.schedule.interval
+		if check > maxCheckInterval {
+			check = maxCheckInterval
+		}
+		ticker := time.NewTicker(check)
+		defer ticker.Stop()
+		for {
+			s.Run(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Run backs up the scheduled users having no snapshot in the interval, and
+// deletes their snapshots outside the retention.
+func (s *Scheduler) Run(ctx context.Context) {
+	for _, u := range s.schedule.Users {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.backup(ctx, u); err != nil {
+			s.logError(fmt.Sprintf("scheduled backup error of user '%s'", u.Email), err)
+		}
+	}
+}
+
+// Status returns the state of scheduled backups of the user email.
+func (s *Scheduler) Status(email string) (*Status, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	st, ok := s.status[strings.ToLower(email)]
+	if !ok {
+		msg := fmt.Sprintf("no scheduled backup of user '%s'", email)
+		return nil, errs.NewNotFoundError(msg)
+	}
+	c := *st
+	return &c, nil
+}
+
+// Reporter returns the reporter of the user of the userinfo.
+func (s *Scheduler) Reporter(ui userinfo.Userinfo) *UserReporter {
+	return &UserReporter{
+		scheduler: s,
+		userinfo:  ui,
+	}
+}
+
+// NewScheduler creates a new scheduler of backups. The source returns the
+// archiver of the notes of scheduled user, the errors are logged when the
+// logger is not nil.
+func NewScheduler(s *Schedule, t Target, src Source, l *log.Logger) *Scheduler {
+	status := make(map[string]*Status, len(s.Users))
+	for _, u := range s.Users {
+		status[strings.ToLower(u.Email)] = &Status{
+			Email:     u.Email,
+			Interval:  s.interval.String(),
+			Retention: s.Retention,
+		}
+	}
+	return &Scheduler{
+		schedule: s,
+		target:   t,
+		source:   src,
+		logger:   l,
+		status:   status,
+	}
+}
+
+// Status returns the state of scheduled backups of the user.
+func (r *UserReporter) Status(ctx context.Context) (*Status, error) {
+	user, err := r.userinfo.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.scheduler.Status(user.Email)
+}
+
+func (s *Scheduler) backup(ctx context.Context, u *ScheduledUser) error {
+	folder := url.PathEscape(strings.ToLower(u.Email))
+	snapshots, err := s.target.List(ctx, folder)
+	if err != nil {
+		return s.report(u, nil, false, err)
+	}
+	now := time.Now().UTC()
+	if len(snapshots) > 0 && now.Sub(snapshots[0].Date) < s.schedule.interval {
+		return s.report(u, snapshots, false, nil)
+	}
+
+	buf := new(bytes.Buffer)
+	archiver, err := s.source(ctx, u)
+	if err == nil {
+		_, err = archiver.Backup(ctx, buf)
+	}
+	if err == nil {
+		err = s.target.Put(ctx, snapshotName(folder, now), buf.Bytes())
+	}
+	if err != nil {
+		return s.report(u, snapshots, true, err)
+	}
+
+	// the failed delete leaves the snapshot for the next run
+	snapshots, err = s.target.List(ctx, folder)
+	if err != nil {
+		return s.report(u, nil, true, err)
+	}
+	var kept []*Snapshot
+	expired := s.schedule.Retention.expired(snapshots)
+	for _, snap := range snapshots {
+		if !expired[snap] {
+			kept = append(kept, snap)
+			continue
+		}
+		if err := s.target.Delete(ctx, snap.Name); err != nil {
+			s.logError(fmt.Sprintf("snapshot '%s' delete error", snap.Name), err)
+			kept = append(kept, snap)
+		}
+	}
+	return s.report(u, kept, true, nil)
+}
+
+func (s *Scheduler) logError(msg string, err error) {
+	if s.logger != nil {
+		s.logger.Error(msg, err)
+	}
+}
+
+// report records the outcome of user backup in the status, it returns the
+// error itself.
+func (s *Scheduler) report(u *ScheduledUser, snapshots []*Snapshot, ran bool, err error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	st := s.status[strings.ToLower(u.Email)]
+	now := time.Now().UTC()
+	if ran {
+		st.LastRun = &now
+		if err == nil {
+			st.LastSuccess = &now
+		}
+	}
+	if err != nil {
+		st.LastError = err.Error()
+	} else {
+		st.LastError = utils.Empty
+	}
+	if snapshots != nil || err == nil {
+		st.Snapshots = snapshots
+	}
+	return err
+}
+
+// expired returns the snapshots outside the retention, the snapshots are
+// sorted newest first.
+func (r Retention) expired(snapshots []*Snapshot) map[*Snapshot]bool {
+	keep := make(map[*Snapshot]bool)
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	for i, snap := range snapshots {
+		if i == 0 {
+			keep[snap] = true
+		}
+		day := snap.Date.Format("2006-01-02")
+		if !days[day] && len(days) < r.Daily {
+			days[day] = true
+			keep[snap] = true
+		}
+		year, week := snap.Date.ISOWeek()
+		w := fmt.Sprintf("%d-%d", year, week)
+		if !weeks[w] && len(weeks) < r.Weekly {
+			weeks[w] = true
+			keep[snap] = true
+		}
+	}
+
+	expired := make(map[*Snapshot]bool)
+	for _, snap := range snapshots {
+		if !keep[snap] {
+			expired[snap] = true
+		}
+	}
+	return expired
+}
+
+func (s *Schedule) check() error {
+	s.interval = DefaultInterval
+	if len(s.Interval) > 0 {
+		d, err := time.ParseDuration(s.Interval)
+		if err != nil || d < time.Minute {
+			return fmt.Errorf("interval '%s' must be duration of at least 1m", s.Interval)
+		}
+		s.interval = d
+	}
+	if (len(s.Dir) == 0) == (s.S3 == nil) {
+		return errors.New("either dir or s3 target is required")
+	}
+	if s.Retention.Daily < 0 || s.Retention.Weekly < 0 {
+		return errors.New("retention must not be negative")
+	}
+	if s.Retention.Daily == 0 && s.Retention.Weekly == 0 {
+		s.Retention = Retention{Daily: DefaultDaily, Weekly: DefaultWeekly}
+	}
+	if len(s.Users) == 0 {
+		return errors.New("users are required")
+	}
+	emails := make(map[string]bool, len(s.Users))
+	for _, u := range s.Users {
+		email := strings.ToLower(strings.TrimSpace(u.Email))
+		if len(email) == 0 || len(u.RefreshToken) == 0 {
+			return errors.New("user email and refresh token are required")
+		}
+		if emails[email] {
+			return fmt.Errorf("user '%s' is scheduled twice", u.Email)
+		}
+		emails[email] = true
+		u.Email = email
+	}
+	return nil
+}
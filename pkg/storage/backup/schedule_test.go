@@ -0,0 +1,262 @@
+package backup_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/storage/backup"
+	"github.com/psewda/typing/pkg/storage/migrate"
+)
+
+var _ = Describe("scheduled backup", func() {
+	var (
+		dir string
+		src *memStore
+	)
+	ctx := context.Background()
+	layout := "20060102-150405"
+
+	BeforeEach(func() {
+		dir, _ = ioutil.TempDir("", "typing-schedule")
+		src = newMemStore()
+		src.add("first", time.Now(), `[ { "id": "s1" } ]`)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	readSchedule := func(s string) (*backup.Schedule, error) {
+		path := filepath.Join(dir, "schedule.json")
+		ioutil.WriteFile(path, []byte(s), 0600)
+		return backup.ReadSchedule(path)
+	}
+
+	newScheduler := func(retention string) (*backup.Scheduler, string) {
+		target := filepath.Join(dir, "backups")
+		s, err := readSchedule(fmt.Sprintf(`{ "dir": "%s", "retention": %s, "users": [ { "email": "User@example.com", "refreshToken": "token" } ] }`,
+			target, retention))
+		Expect(err).ShouldNot(HaveOccurred())
+		t, _ := s.Target()
+		source := func(ctx context.Context, u *backup.ScheduledUser) (backup.Archiver, error) {
+			return backup.New(&migrate.Backend{Name: migrate.BackendDrive, Notestore: src, Content: src}), nil
+		}
+		return backup.NewScheduler(s, t, source, nil), filepath.Join(target, "user@example.com")
+	}
+
+	seed := func(folder string, ages ...time.Duration) {
+		os.MkdirAll(folder, 0700)
+		for _, age := range ages {
+			name := fmt.Sprintf("typing-backup-%s.tar.gz", time.Now().UTC().Add(-age).Format(layout))
+			ioutil.WriteFile(filepath.Join(folder, name), []byte("snapshot"), 0600)
+		}
+	}
+
+	snapshots := func(folder string) []string {
+		infos, _ := ioutil.ReadDir(folder)
+		var names []string
+		for _, info := range infos {
+			names = append(names, info.Name())
+		}
+		return names
+	}
+
+	Context("read schedule", func() {
+		It("should set the defaults when not configured", func() {
+			s, err := readSchedule(`{ "dir": "/tmp/backups", "users": [ { "email": "user@example.com", "refreshToken": "token" } ] }`)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(s.Retention).Should(Equal(backup.Retention{Daily: backup.DefaultDaily, Weekly: backup.DefaultWeekly}))
+		})
+
+		It("should return error when invalid schedule", func() {
+			schedules := []string{
+				`{ "users": [ { "email": "user@example.com", "refreshToken": "token" } ] }`,
+				`{ "dir": "/tmp", "s3": { "endpoint": "http://s3" }, "users": [ { "email": "user@example.com", "refreshToken": "token" } ] }`,
+				`{ "dir": "/tmp", "interval": "1s", "users": [ { "email": "user@example.com", "refreshToken": "token" } ] }`,
+				`{ "dir": "/tmp", "retention": { "daily": -1 }, "users": [ { "email": "user@example.com", "refreshToken": "token" } ] }`,
+				`{ "dir": "/tmp", "users": [] }`,
+				`{ "dir": "/tmp", "users": [ { "email": "user@example.com" } ] }`,
+				`{ "dir": "/tmp", "users": [ { "email": "a@b.com", "refreshToken": "t" }, { "email": "A@b.com", "refreshToken": "t" } ] }`,
+				`{ "dir": `,
+			}
+			for _, s := range schedules {
+				_, err := readSchedule(s)
+				Expect(err).Should(HaveOccurred(), s)
+			}
+		})
+	})
+
+	Context("run scheduler", func() {
+		It("should take the snapshot once in interval", func() {
+			s, folder := newScheduler(`{}`)
+			s.Run(ctx)
+			s.Run(ctx)
+			Expect(snapshots(folder)).Should(HaveLen(1))
+
+			status, err := s.Status("user@example.com")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(status.LastSuccess).ShouldNot(BeNil())
+			Expect(status.LastError).Should(BeEmpty())
+			Expect(status.Snapshots).Should(HaveLen(1))
+
+			data, _ := ioutil.ReadFile(filepath.Join(folder, snapshots(folder)[0]))
+			dst := newMemStore()
+			report, err := backup.New(&migrate.Backend{Notestore: dst, Content: dst}).
+				Restore(ctx, strings.NewReader(string(data)), backup.CollisionSkip)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Created).Should(Equal(1))
+		})
+
+		It("should delete the snapshots outside daily retention", func() {
+			s, folder := newScheduler(`{ "daily": 2 }`)
+			seed(folder, 25*time.Hour, 49*time.Hour, 73*time.Hour)
+			s.Run(ctx)
+
+			names := snapshots(folder)
+			Expect(names).Should(HaveLen(2))
+			sort.Strings(names)
+			Expect(names[0]).Should(ContainSubstring(time.Now().UTC().Add(-25 * time.Hour).Format("20060102")))
+		})
+
+		It("should keep the snapshot of each week when weekly retention", func() {
+			s, folder := newScheduler(`{ "daily": 1, "weekly": 3 }`)
+			seed(folder, 7*24*time.Hour, 14*24*time.Hour, 21*24*time.Hour)
+			s.Run(ctx)
+			Expect(snapshots(folder)).Should(HaveLen(3))
+		})
+
+		It("should report the error when backup failed", func() {
+			target, _ := backup.NewDirTarget(filepath.Join(dir, "backups"))
+			sc, _ := readSchedule(`{ "dir": "/tmp", "users": [ { "email": "user@example.com", "refreshToken": "token" } ] }`)
+			source := func(ctx context.Context, u *backup.ScheduledUser) (backup.Archiver, error) {
+				return nil, errors.New("token revoked")
+			}
+			s := backup.NewScheduler(sc, target, source, nil)
+			s.Run(ctx)
+
+			status, _ := s.Status("user@example.com")
+			Expect(status.LastRun).ShouldNot(BeNil())
+			Expect(status.LastSuccess).Should(BeNil())
+			Expect(status.LastError).Should(ContainSubstring("token revoked"))
+		})
+
+		It("should return error when user not scheduled", func() {
+			s, _ := newScheduler(`{}`)
+			_, err := s.Status("other@example.com")
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewNotFoundError("msg")))
+		})
+	})
+
+	Context("s3 target", func() {
+		var (
+			server *httptest.Server
+			fake   *fakeS3
+		)
+
+		BeforeEach(func() {
+			fake = &fakeS3{objects: make(map[string][]byte)}
+			server = httptest.NewServer(fake)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should put, list and delete the snapshots", func() {
+			t, err := backup.NewS3Target(backup.S3Config{
+				Endpoint: server.URL, Bucket: "bucket", Prefix: "/typing/", AccessKey: "key", SecretKey: "secret",
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			name := "user%40example.com/typing-backup-20210102-150405.tar.gz"
+			Expect(t.Put(ctx, name, []byte("snapshot"))).Should(Succeed())
+			Expect(t.Put(ctx, "user%40example.com/other.txt", []byte("other"))).Should(Succeed())
+			Expect(fake.objects).Should(HaveKey("bucket/typing/" + name))
+
+			list, err := t.List(ctx, "user%40example.com")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(list).Should(HaveLen(1))
+			Expect(list[0].Name).Should(Equal(name))
+			Expect(list[0].Size).Should(Equal(int64(8)))
+			Expect(list[0].Date).Should(Equal(time.Date(2021, 1, 2, 15, 4, 5, 0, time.UTC)))
+
+			Expect(t.Delete(ctx, name)).Should(Succeed())
+			Expect(fake.objects).ShouldNot(HaveKey("bucket/typing/" + name))
+		})
+
+		It("should return error when access denied", func() {
+			fake.deny = true
+			t, _ := backup.NewS3Target(backup.S3Config{Endpoint: server.URL, Bucket: "bucket", AccessKey: "key", SecretKey: "secret"})
+			err := t.Put(ctx, "user/typing-backup-20210102-150405.tar.gz", []byte("snapshot"))
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewUnauthorizedError()))
+		})
+
+		It("should return error when invalid config", func() {
+			_, err := backup.NewS3Target(backup.S3Config{Endpoint: server.URL, Bucket: "bucket"})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
+
+// fakeS3 is the in-memory S3 server checking the signed headers.
+type fakeS3 struct {
+	objects map[string][]byte
+	deny    bool
+	mutex   sync.Mutex
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	body, _ := ioutil.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	auth := r.Header.Get("Authorization")
+	if s.deny || !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=key/") ||
+		r.Header.Get("x-amz-content-sha256") != hex.EncodeToString(sum[:]) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[key] = body
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet:
+		type content struct {
+			Key  string
+			Size int
+		}
+		result := struct {
+			XMLName  xml.Name `xml:"ListBucketResult"`
+			Contents []content
+		}{}
+		prefix := r.URL.Query().Get("prefix")
+		for k, v := range s.objects {
+			if k = strings.TrimPrefix(k, key+"/"); strings.HasPrefix(k, prefix) {
+				result.Contents = append(result.Contents, content{Key: k, Size: len(v)})
+			}
+		}
+		data, _ := xml.Marshal(result)
+		w.Write(data)
+	}
+}
@@ -0,0 +1,131 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/errs"
+)
+
+const (
+	snapshotPrefix = "typing-backup-"
+	snapshotExt    = ".tar.gz"
+	snapshotLayout = "20060102-150405"
+)
+
+// Target is the storage of backup snapshots. The snapshot name has the
+// folder of user, like 'user/typing-backup-20210102-150405.tar.gz'.
+type Target interface {
+	// Put writes the snapshot, it replaces the snapshot having same name.
+	Put(ctx context.Context, name string, data []byte) error
+
+	// List returns the snapshots in the folder, the newest snapshot first.
+	List(ctx context.Context, folder string) ([]*Snapshot, error)
+
+	// Delete removes the snapshot.
+	Delete(ctx context.Context, name string) error
+}
+
+// Snapshot is the backup archive stored in the target.
+type Snapshot struct {
+	Name string    `json:"name"`
+	Date time.Time `json:"date"`
+	Size int64     `json:"size"`
+}
+
+// DirTarget is the target storing the snapshots in local directory.
+type DirTarget struct {
+	dir string
+}
+
+// Put writes the snapshot atomically, so the interrupted write never
+// leaves the partial snapshot.
+func (t *DirTarget) Put(ctx context.Context, name string, data []byte) error {
+	path := filepath.Join(t.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return utils.Error("snapshot write error", err)
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return utils.Error("snapshot write error", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return utils.Error("snapshot write error", err)
+	}
+	return nil
+}
+
+// List returns the snapshots in the folder of directory.
+func (t *DirTarget) List(ctx context.Context, folder string) ([]*Snapshot, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(t.dir, filepath.FromSlash(folder)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, utils.Error("snapshot listing error", err)
+	}
+
+	var snapshots []*Snapshot
+	for _, info := range infos {
+		if s := toSnapshot(folder+"/"+info.Name(), info.Size()); s != nil && !info.IsDir() {
+			snapshots = append(snapshots, s)
+		}
+	}
+	sortSnapshots(snapshots)
+	return snapshots, nil
+}
+
+// Delete removes the snapshot from directory.
+func (t *DirTarget) Delete(ctx context.Context, name string) error {
+	err := os.Remove(filepath.Join(t.dir, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return errs.NewNotFoundError(fmt.Sprintf("snapshot '%s' not found", name))
+	}
+	if err != nil {
+		return utils.Error("snapshot delete error", err)
+	}
+	return nil
+}
+
+// NewDirTarget creates a new target storing the snapshots in directory.
+func NewDirTarget(dir string) (*DirTarget, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, utils.Error("backup directory creation error", err)
+	}
+	return &DirTarget{
+		dir: dir,
+	}, nil
+}
+
+// snapshotName returns the name of snapshot taken at the date.
+func snapshotName(folder string, date time.Time) string {
+	return folder + "/" + snapshotPrefix + date.UTC().Format(snapshotLayout) + snapshotExt
+}
+
+// toSnapshot returns the snapshot of name, it returns nil when the name is
+// not the snapshot name.
+func toSnapshot(name string, size int64) *Snapshot {
+	base := name[strings.LastIndex(name, "/")+1:]
+	if !strings.HasPrefix(base, snapshotPrefix) || !strings.HasSuffix(base, snapshotExt) {
+		return nil
+	}
+	date, err := time.Parse(snapshotLayout, strings.TrimSuffix(strings.TrimPrefix(base, snapshotPrefix), snapshotExt))
+	if err != nil {
+		return nil
+	}
+	return &Snapshot{Name: name, Date: date, Size: size}
+}
+
+func sortSnapshots(snapshots []*Snapshot) {
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Date.After(snapshots[j].Date)
+	})
+}
@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultCapacity is the default number of entries in memory backend.
+const DefaultCapacity = 10000
+
+// Backend is the base interface of cache storage. The values are opaque
+// bytes, so the backend can keep them out of process.
+type Backend interface {
+	// Get returns the value of the key. The nil value means the key is
+	// missing or expired.
+	Get(key string) ([]byte, error)
+
+	// Set stores the value of the key for the ttl duration.
+	Set(key string, value []byte, ttl time.Duration) error
+
+	// Delete removes the keys, the missing keys are ignored.
+	Delete(keys ...string) error
+}
+
+// Memory is the in-memory backend. It keeps the entries up to the capacity,
+// and evicts the least recently used entry when it is full.
+type Memory struct {
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+	mutex    sync.Mutex
+}
+
+type memoryEntry struct {
+	key    string
+	value  []byte
+	expiry time.Time
+}
+
+// Get returns the value of the key, and marks it as recently used.
+func (m *Memory) Get(key string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	elem, ok := m.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	entry := elem.Value.(*memoryEntry)
+	if !time.Now().Before(entry.expiry) {
+		m.remove(elem)
+		return nil, nil
+	}
+	m.order.MoveToFront(elem)
+	return entry.value, nil
+}
+
+// Set stores the value of the key, evicting the least recently used
+// entry when the capacity is reached.
+func (m *Memory) Set(key string, value []byte, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry := &memoryEntry{
+		key:    key,
+		value:  value,
+		expiry: time.Now().Add(ttl),
+	}
+	if elem, ok := m.entries[key]; ok {
+		elem.Value = entry
+		m.order.MoveToFront(elem)
+		return nil
+	}
+
+	m.entries[key] = m.order.PushFront(entry)
+	for m.order.Len() > m.capacity {
+		m.remove(m.order.Back())
+	}
+	return nil
+}
+
+// Delete removes the keys.
+func (m *Memory) Delete(keys ...string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := m.entries[key]; ok {
+			m.remove(elem)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of entries, including the expired ones which
+// are not evicted yet.
+func (m *Memory) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.order.Len()
+}
+
+// NewMemory creates a new in-memory backend. The zero capacity uses
+// the default capacity.
+func NewMemory(capacity int) *Memory {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+
+	return &Memory{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// remove drops the element. The caller must hold the mutex.
+func (m *Memory) remove(elem *list.Element) {
+	m.order.Remove(elem)
+	delete(m.entries, elem.Value.(*memoryEntry).key)
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/psewda/typing/pkg/signin/userinfo"
+)
+
+// Config has the durations of cached entries.
+type Config struct {
+	// Fresh is the duration for which the entry is served without asking
+	// the storage. It is the max staleness of data changed by other servers
+	// or clients, the changes through the same server invalidate the entry.
+	Fresh time.Duration
+
+	// TTL is the duration for which the entry is kept. After the fresh
+	// duration, the note content is revalidated against the note modified
+	// time, and served again when the note is not changed.
+	TTL time.Duration
+}
+
+// DefaultConfig returns the config used by the storage cache.
+func DefaultConfig() Config {
+	return Config{
+		Fresh: 30 * time.Second,
+		TTL:   10 * time.Minute,
+	}
+}
+
+type entry struct {
+	Version string          `json:"version,omitempty"`
+	Stored  time.Time       `json:"stored"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// cache has the backend operations shared by the store decorators. The
+// backend failures are not returned, the call goes to the storage instead.
+type cache struct {
+	backend  Backend
+	userinfo userinfo.Userinfo
+	config   Config
+}
+
+// key builds the cache key of the user. The nil userinfo keeps a single
+// key space for all users.
+func (c *cache) key(ctx context.Context, parts ...string) (string, error) {
+	owner := "-"
+	if c.userinfo != nil {
+		user, err := c.userinfo.Get(ctx)
+		if err != nil {
+			return "", err
+		}
+		owner = user.ID
+	}
+	return "typing:" + owner + ":" + strings.Join(parts, ":"), nil
+}
+
+// get decodes the cached value in out. The fresh entry is returned as it
+// is. The stale entry is returned only when the revalidate func gives the
+// same version, and the current version is returned otherwise, so the
+// caller can store the reloaded value without asking for it again.
+func (c *cache) get(key string, revalidate func() (string, error), out interface{}) (bool, string) {
+	data, err := c.backend.Get(key)
+	if err != nil || data == nil {
+		return false, ""
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return false, ""
+	}
+	if time.Since(e.Stored) >= c.config.Fresh {
+		if revalidate == nil {
+			return false, ""
+		}
+		version, err := revalidate()
+		if err != nil || version != e.Version {
+			return false, version
+		}
+
+		// the entry is valid, so it is fresh again
+		e.Stored = time.Now()
+		if data, err := json.Marshal(e); err == nil {
+			c.backend.Set(key, data, c.config.TTL)
+		}
+	}
+	return json.Unmarshal(e.Value, out) == nil, e.Version
+}
+
+// put stores the value with its version. The value without version can't
+// be revalidated, so it is kept only for the fresh duration.
+func (c *cache) put(key, version string, value interface{}) {
+	v, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(entry{
+		Version: version,
+		Stored:  time.Now(),
+		Value:   v,
+	})
+	if err != nil {
+		return
+	}
+
+	ttl := c.config.TTL
+	if len(version) == 0 {
+		ttl = c.config.Fresh
+	}
+	c.backend.Set(key, data, ttl)
+}
+
+// invalidate removes the cached entries. The keys are built per user, so
+// the userinfo failure skips the invalidation.
+func (c *cache) invalidate(ctx context.Context, keys ...[]string) {
+	values := make([]string, 0, len(keys))
+	for _, parts := range keys {
+		key, err := c.key(ctx, parts...)
+		if err != nil {
+			return
+		}
+		values = append(values, key)
+	}
+	c.backend.Delete(values...)
+}
+
+func newCache(b Backend, ui userinfo.Userinfo, c Config) *cache {
+	if c.Fresh <= 0 {
+		c.Fresh = DefaultConfig().Fresh
+	}
+	if c.TTL < c.Fresh {
+		c.TTL = c.Fresh
+	}
+
+	return &cache{
+		backend:  b,
+		userinfo: ui,
+		config:   c,
+	}
+}
+
+func notesKey() []string {
+	return []string{"notes"}
+}
+
+func noteKey(id string) []string {
+	return []string{"note", id}
+}
+
+func sectionsKey(nid string) []string {
+	return []string{"sections", nid}
+}
+
+func schemaKey(nid string) []string {
+	return []string{"schema", nid}
+}
@@ -0,0 +1,348 @@
+package cache_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/schema"
+	"github.com/psewda/typing/pkg/signin/userinfo"
+	"github.com/psewda/typing/pkg/storage/cache"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+func TestCache(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "cache-suite")
+}
+
+var _ = Describe("storage cache", func() {
+	var (
+		mockCtrl         *gomock.Controller
+		mockNotestore    *mocks.MockNotestore
+		mockSectionstore *mocks.MockSectionstore
+	)
+
+	config := cache.Config{
+		Fresh: time.Minute,
+		TTL:   time.Hour,
+	}
+	updated := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockNotestore = mocks.NewMockNotestore(mockCtrl)
+		mockSectionstore = mocks.NewMockSectionstore(mockCtrl)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Context("memory backend", func() {
+		It("should evict the least recently used entry", func() {
+			m := cache.NewMemory(2)
+			m.Set("k1", []byte("v1"), time.Minute)
+			m.Set("k2", []byte("v2"), time.Minute)
+			m.Get("k1")
+			m.Set("k3", []byte("v3"), time.Minute)
+
+			Expect(m.Len()).Should(Equal(2))
+			Expect(m.Get("k1")).Should(Equal([]byte("v1")))
+			Expect(m.Get("k2")).Should(BeNil())
+			Expect(m.Get("k3")).Should(Equal([]byte("v3")))
+		})
+
+		It("should expire the entry after ttl", func() {
+			m := cache.NewMemory(0)
+			m.Set("k1", []byte("v1"), 10*time.Millisecond)
+			Expect(m.Get("k1")).ShouldNot(BeNil())
+			Eventually(func() ([]byte, error) { return m.Get("k1") }).Should(BeNil())
+		})
+
+		It("should delete the keys", func() {
+			m := cache.NewMemory(0)
+			m.Set("k1", []byte("v1"), time.Minute)
+			m.Set("k2", []byte("v2"), time.Minute)
+			Expect(m.Delete("k1", "k2", "k3")).Should(Succeed())
+			Expect(m.Len()).Should(BeZero())
+		})
+	})
+
+	Context("redis backend", func() {
+		It("should get, set and delete the keys", func() {
+			addr, stop := startRedis()
+			defer stop()
+
+			r, err := cache.NewRedis(addr)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(r.Get("k1")).Should(BeNil())
+
+			Expect(r.Set("k1", []byte("line1\r\nline2"), time.Minute)).Should(Succeed())
+			Expect(r.Get("k1")).Should(Equal([]byte("line1\r\nline2")))
+
+			Expect(r.Delete("k1")).Should(Succeed())
+			Expect(r.Get("k1")).Should(BeNil())
+		})
+
+		It("should return error when server is down", func() {
+			r, _ := cache.NewRedis("127.0.0.1:1")
+			_, err := r.Get("k1")
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should return error when invalid address", func() {
+			_, err := cache.NewRedis("localhost")
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("notestore", func() {
+		It("should serve the notes from cache", func() {
+			notes := []*notestore.Note{{ID: "id1", Name: "name1"}}
+			mockNotestore.EXPECT().GetAll(gomock.Any()).Return(notes, nil).Times(1)
+			mockNotestore.EXPECT().Get(gomock.Any(), gomock.Eq("id1")).Return(notes[0], nil).Times(1)
+
+			ns := cache.NewNotestore(mockNotestore, cache.NewMemory(0), nil, config)
+			for i := 0; i < 2; i++ {
+				all, err := ns.GetAll(context.Background())
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(all).Should(HaveLen(1))
+				Expect(all[0].Name).Should(Equal("name1"))
+
+				note, err := ns.Get(context.Background(), "id1")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(note.Name).Should(Equal("name1"))
+			}
+		})
+
+		It("should invalidate the notes when updated", func() {
+			gomock.InOrder(
+				mockNotestore.EXPECT().Get(gomock.Any(), gomock.Eq("id1")).Return(&notestore.Note{Name: "name1"}, nil),
+				mockNotestore.EXPECT().Update(gomock.Any(), gomock.Eq("id1"), gomock.Any()).Return(&notestore.Note{Name: "name2"}, nil),
+				mockNotestore.EXPECT().Get(gomock.Any(), gomock.Eq("id1")).Return(&notestore.Note{Name: "name2"}, nil),
+			)
+
+			ns := cache.NewNotestore(mockNotestore, cache.NewMemory(0), nil, config)
+			ns.Get(context.Background(), "id1")
+			ns.Update(context.Background(), "id1", &notestore.WritableNote{Name: "name2"})
+			note, _ := ns.Get(context.Background(), "id1")
+			Expect(note.Name).Should(Equal("name2"))
+		})
+
+		It("should not cache the errors", func() {
+			mockNotestore.EXPECT().Get(gomock.Any(), gomock.Eq("id1")).Return(nil, errs.NewNotFoundError("not found")).Times(2)
+
+			ns := cache.NewNotestore(mockNotestore, cache.NewMemory(0), nil, config)
+			for i := 0; i < 2; i++ {
+				_, err := ns.Get(context.Background(), "id1")
+				Expect(err).Should(BeAssignableToTypeOf(&errs.NotFoundError{}))
+			}
+		})
+
+		It("should key the notes per user", func() {
+			mockNotestore.EXPECT().GetAll(gomock.Any()).Return([]*notestore.Note{}, nil).Times(2)
+			ui1 := mocks.NewMockUserinfo(mockCtrl)
+			ui1.EXPECT().Get(gomock.Any()).Return(&userinfo.User{ID: "uid1"}, nil).AnyTimes()
+			ui2 := mocks.NewMockUserinfo(mockCtrl)
+			ui2.EXPECT().Get(gomock.Any()).Return(&userinfo.User{ID: "uid2"}, nil).AnyTimes()
+
+			backend := cache.NewMemory(0)
+			cache.NewNotestore(mockNotestore, backend, ui1, config).GetAll(context.Background())
+			cache.NewNotestore(mockNotestore, backend, ui2, config).GetAll(context.Background())
+			cache.NewNotestore(mockNotestore, backend, ui1, config).GetAll(context.Background())
+			Expect(backend.Len()).Should(Equal(2))
+		})
+	})
+
+	Context("sectionstore", func() {
+		sections := []*sectionstore.Section{
+			{ID: "sid1", Name: "name1", Labels: []string{"label1"}},
+			{ID: "sid2", Name: "name2"},
+		}
+
+		It("should serve the sections from cache", func() {
+			mockNotestore.EXPECT().Get(gomock.Any(), gomock.Eq("nid")).Return(&notestore.Note{DateUpdated: updated}, nil).Times(1)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), gomock.Eq("nid"), gomock.Nil()).Return(sections, nil).Times(1)
+
+			ss := cache.NewSectionstore(mockSectionstore, mockNotestore, cache.NewMemory(0), nil, config)
+			all, err := ss.GetAll(context.Background(), "nid", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(all).Should(HaveLen(2))
+
+			filtered, _ := ss.GetAll(context.Background(), "nid", &sectionstore.Query{Labels: []string{"label1"}})
+			Expect(filtered).Should(HaveLen(1))
+
+			section, err := ss.Get(context.Background(), "nid", "sid2")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(section.Name).Should(Equal("name2"))
+		})
+
+		It("should revalidate the stale sections", func() {
+			c := cache.Config{Fresh: time.Millisecond, TTL: time.Hour}
+			mockNotestore.EXPECT().Get(gomock.Any(), gomock.Eq("nid")).Return(&notestore.Note{DateUpdated: updated}, nil).Times(2)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), gomock.Eq("nid"), gomock.Nil()).Return(sections, nil).Times(1)
+
+			ss := cache.NewSectionstore(mockSectionstore, mockNotestore, cache.NewMemory(0), nil, c)
+			ss.GetAll(context.Background(), "nid", nil)
+			time.Sleep(5 * time.Millisecond)
+			all, err := ss.GetAll(context.Background(), "nid", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(all).Should(HaveLen(2))
+		})
+
+		It("should reload the sections when note changed", func() {
+			c := cache.Config{Fresh: time.Millisecond, TTL: time.Hour}
+			gomock.InOrder(
+				mockNotestore.EXPECT().Get(gomock.Any(), gomock.Eq("nid")).Return(&notestore.Note{DateUpdated: updated}, nil),
+				mockSectionstore.EXPECT().GetAll(gomock.Any(), gomock.Eq("nid"), gomock.Nil()).Return(sections, nil),
+				mockNotestore.EXPECT().Get(gomock.Any(), gomock.Eq("nid")).Return(&notestore.Note{DateUpdated: updated.Add(time.Second)}, nil),
+				mockSectionstore.EXPECT().GetAll(gomock.Any(), gomock.Eq("nid"), gomock.Nil()).Return(sections[:1], nil),
+			)
+
+			ss := cache.NewSectionstore(mockSectionstore, mockNotestore, cache.NewMemory(0), nil, c)
+			ss.GetAll(context.Background(), "nid", nil)
+			time.Sleep(5 * time.Millisecond)
+			all, _ := ss.GetAll(context.Background(), "nid", nil)
+			Expect(all).Should(HaveLen(1))
+		})
+
+		It("should invalidate the sections when changed", func() {
+			mockNotestore.EXPECT().Get(gomock.Any(), gomock.Eq("nid")).Return(&notestore.Note{DateUpdated: updated}, nil).Times(2)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), gomock.Eq("nid"), gomock.Nil()).Return(sections, nil).Times(2)
+			mockSectionstore.EXPECT().Delete(gomock.Any(), gomock.Eq("nid"), gomock.Eq("sid1")).Return(nil)
+
+			ss := cache.NewSectionstore(mockSectionstore, mockNotestore, cache.NewMemory(0), nil, config)
+			ss.GetAll(context.Background(), "nid", nil)
+			Expect(ss.Delete(context.Background(), "nid", "sid1")).Should(Succeed())
+			ss.GetAll(context.Background(), "nid", nil)
+		})
+
+		It("should pass the missing section to the sectionstore", func() {
+			mockNotestore.EXPECT().Get(gomock.Any(), gomock.Eq("nid")).Return(&notestore.Note{DateUpdated: updated}, nil)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), gomock.Eq("nid"), gomock.Nil()).Return(sections, nil)
+			mockSectionstore.EXPECT().Get(gomock.Any(), gomock.Eq("nid"), gomock.Eq("sid3")).Return(nil, errs.NewNotFoundError("not found"))
+
+			ss := cache.NewSectionstore(mockSectionstore, mockNotestore, cache.NewMemory(0), nil, config)
+			_, err := ss.Get(context.Background(), "nid", "sid3")
+			Expect(err).Should(BeAssignableToTypeOf(&errs.NotFoundError{}))
+		})
+
+		It("should serve the schema from cache", func() {
+			s := &schema.Schema{Properties: map[string]*schema.Schema{"k1": {Type: schema.TypeString}}}
+			mockNotestore.EXPECT().Get(gomock.Any(), gomock.Eq("nid")).Return(&notestore.Note{DateUpdated: updated}, nil)
+			mockSectionstore.EXPECT().GetSchema(gomock.Any(), gomock.Eq("nid")).Return(s, nil).Times(1)
+
+			ss := cache.NewSectionstore(mockSectionstore, mockNotestore, cache.NewMemory(0), nil, config)
+			for i := 0; i < 2; i++ {
+				cached, err := ss.GetSchema(context.Background(), "nid")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(cached.Properties).Should(HaveKey("k1"))
+			}
+		})
+
+		It("should work with redis backend", func() {
+			addr, stop := startRedis()
+			defer stop()
+
+			mockNotestore.EXPECT().Get(gomock.Any(), gomock.Eq("nid")).Return(&notestore.Note{DateUpdated: updated}, nil).Times(1)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), gomock.Eq("nid"), gomock.Nil()).Return(sections, nil).Times(1)
+
+			r, _ := cache.NewRedis(addr)
+			ss := cache.NewSectionstore(mockSectionstore, mockNotestore, r, nil, config)
+			for i := 0; i < 2; i++ {
+				all, err := ss.GetAll(context.Background(), "nid", nil)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(all).Should(HaveLen(2))
+			}
+		})
+	})
+})
+
+// startRedis runs the local stand-in of redis server. It supports only the
+// commands used by the backend, and ignores the expiry.
+func startRedis() (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ShouldNot(HaveOccurred())
+
+	var mutex sync.Mutex
+	values := make(map[string]string)
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			args, err := readCommand(reader)
+			if err != nil {
+				return
+			}
+
+			mutex.Lock()
+			switch strings.ToUpper(args[0]) {
+			case "GET":
+				if v, ok := values[args[1]]; ok {
+					fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(v), v)
+				} else {
+					conn.Write([]byte("$-1\r\n"))
+				}
+			case "SET":
+				values[args[1]] = args[2]
+				conn.Write([]byte("+OK\r\n"))
+			case "DEL":
+				for _, k := range args[1:] {
+					delete(values, k)
+				}
+				fmt.Fprintf(conn, ":%d\r\n", len(args)-1)
+			default:
+				conn.Write([]byte("-ERR unknown command\r\n"))
+			}
+			mutex.Unlock()
+		}
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return l.Addr().String(), func() { l.Close() }
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
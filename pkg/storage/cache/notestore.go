@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/psewda/typing/pkg/signin/userinfo"
+	"github.com/psewda/typing/pkg/storage/notestore"
+)
+
+// Notestore is the read-through cache decorator of the notestore. The note
+// metadata costs the same to revalidate and to reload, so the notes are
+// only served for the fresh duration. The writes through the decorator
+// invalidate the cached notes.
+type Notestore struct {
+	notestore notestore.Notestore
+	cache     *cache
+}
+
+// Create builds a new note and invalidates the note list.
+func (ns *Notestore) Create(ctx context.Context, n *notestore.WritableNote) (*notestore.Note, error) {
+	note, err := ns.notestore.Create(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	ns.cache.invalidate(ctx, notesKey())
+	return note, nil
+}
+
+// GetAll returns the cached note list, or fetches it from the notestore.
+func (ns *Notestore) GetAll(ctx context.Context) ([]*notestore.Note, error) {
+	key, err := ns.cache.key(ctx, notesKey()...)
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []*notestore.Note
+	if ok, _ := ns.cache.get(key, nil, &notes); ok {
+		return notes, nil
+	}
+
+	notes, err = ns.notestore.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ns.cache.put(key, "", notes)
+	return notes, nil
+}
+
+// Get returns the cached note, or fetches it from the notestore.
+func (ns *Notestore) Get(ctx context.Context, id string) (*notestore.Note, error) {
+	key, err := ns.cache.key(ctx, noteKey(id)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var note *notestore.Note
+	if ok, _ := ns.cache.get(key, nil, &note); ok && note != nil {
+		return note, nil
+	}
+
+	note, err = ns.notestore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ns.cache.put(key, "", note)
+	return note, nil
+}
+
+// Update modifies the note and invalidates it in the cache.
+func (ns *Notestore) Update(ctx context.Context, id string, n *notestore.WritableNote) (*notestore.Note, error) {
+	note, err := ns.notestore.Update(ctx, id, n)
+	if err != nil {
+		return nil, err
+	}
+	ns.cache.invalidate(ctx, notesKey(), noteKey(id))
+	return note, nil
+}
+
+// Delete removes the note, and invalidates it with its content.
+func (ns *Notestore) Delete(ctx context.Context, id string) error {
+	err := ns.notestore.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	ns.cache.invalidate(ctx, notesKey(), noteKey(id), sectionsKey(id), schemaKey(id))
+	return nil
+}
+
+// NewNotestore creates a new cache decorator of the notestore. The userinfo
+// keys the entries per user, the nil userinfo shares them for all users.
+func NewNotestore(ns notestore.Notestore, b Backend, ui userinfo.Userinfo, c Config) *Notestore {
+	return &Notestore{
+		notestore: ns,
+		cache:     newCache(b, ui, c),
+	}
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/psewda/typing/internal/utils"
+)
+
+const (
+	redisTimeout  = 2 * time.Second
+	redisMaxConns = 16
+)
+
+// Redis is the backend using any redis compatible server. It speaks the
+// plain RESP protocol, and only needs the GET, SET and DEL commands.
+type Redis struct {
+	addr  string
+	conns chan *redisConn
+}
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Get returns the value of the key.
+func (r *Redis) Get(key string) ([]byte, error) {
+	return r.do("GET", key)
+}
+
+// Set stores the value of the key. The ttl is set in milliseconds.
+func (r *Redis) Set(key string, value []byte, ttl time.Duration) error {
+	ms := strconv.FormatInt(ttl.Milliseconds(), 10)
+	_, err := r.do("SET", key, string(value), "PX", ms)
+	return err
+}
+
+// Delete removes the keys.
+func (r *Redis) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := r.do("DEL", keys...)
+	return err
+}
+
+// NewRedis creates a new redis backend. The address is the host and port
+// of server, the connections are opened on the first use.
+func NewRedis(addr string) (*Redis, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, utils.Error("redis address is invalid", err)
+	}
+
+	return &Redis{
+		addr:  addr,
+		conns: make(chan *redisConn, redisMaxConns),
+	}, nil
+}
+
+// do sends the command and reads the reply. The connection is reused
+// when the command succeeds, otherwise it is closed.
+func (r *Redis) do(cmd string, args ...string) ([]byte, error) {
+	c, err := r.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(redisTimeout)
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+
+	reply, err := c.send(cmd, args)
+	if err != nil {
+		c.conn.Close()
+		return nil, utils.Error(fmt.Sprintf("redis %s command error", cmd), err)
+	}
+
+	select {
+	case r.conns <- c:
+	default:
+		c.conn.Close()
+	}
+	return reply, nil
+}
+
+func (r *Redis) conn() (*redisConn, error) {
+	select {
+	case c := <-r.conns:
+		return c, nil
+	default:
+	}
+
+	conn, err := net.DialTimeout("tcp", r.addr, redisTimeout)
+	if err != nil {
+		return nil, utils.Error("redis connection error", err)
+	}
+	return &redisConn{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}, nil
+}
+
+func (c *redisConn) send(cmd string, args []string) ([]byte, error) {
+	buf := []byte(fmt.Sprintf("*%d\r\n$%d\r\n%s\r\n", len(args)+1, len(cmd), cmd))
+	for _, arg := range args {
+		buf = append(buf, fmt.Sprintf("$%d\r\n", len(arg))...)
+		buf = append(buf, arg...)
+		buf = append(buf, "\r\n"...)
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// read parses the single reply. The nil bulk string is returned as the
+// nil value, the integer and status replies are returned as text.
+func (c *redisConn) read() ([]byte, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, errors.New("malformed reply")
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+', ':':
+		return []byte(line[1:]), nil
+	case '-':
+		return nil, errors.New(line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		value := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, value); err != nil {
+			return nil, err
+		}
+		return value[:n], nil
+	default:
+		return nil, fmt.Errorf("unsupported reply type '%c'", line[0])
+	}
+}
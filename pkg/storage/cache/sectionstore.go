@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/psewda/typing/pkg/schema"
+	"github.com/psewda/typing/pkg/signin/userinfo"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	secstore "github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+// Sectionstore is the read-through cache decorator of the sectionstore. The
+// sections and schema of the note are cached with the note modified time.
+// After the fresh duration, the cheap note lookup revalidates the entry,
+// and the note content is downloaded again only when the note is changed.
+type Sectionstore struct {
+	sectionstore secstore.Sectionstore
+	notestore    notestore.Notestore
+	cache        *cache
+}
+
+// Create adds a new section and invalidates the note content.
+func (ss *Sectionstore) Create(ctx context.Context, nid string, s *secstore.WritableSection) (*secstore.Section, error) {
+	section, err := ss.sectionstore.Create(ctx, nid, s)
+	if err != nil {
+		return nil, err
+	}
+	ss.invalidate(ctx, nid)
+	return section, nil
+}
+
+// GetAll returns the cached sections matching the query. The query is
+// applied on the cached sections, so all queries share the same entry.
+func (ss *Sectionstore) GetAll(ctx context.Context, nid string, q *secstore.Query) ([]*secstore.Section, error) {
+	sections, err := ss.sections(ctx, nid)
+	if err != nil {
+		return nil, err
+	}
+	return q.Apply(sections), nil
+}
+
+// Get returns the cached section. The section missing in the cache is
+// fetched from the sectionstore, so it returns the same not found error.
+func (ss *Sectionstore) Get(ctx context.Context, nid, sid string) (*secstore.Section, error) {
+	sections, err := ss.sections(ctx, nid)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sections {
+		if s.ID == sid {
+			return s, nil
+		}
+	}
+	return ss.sectionstore.Get(ctx, nid, sid)
+}
+
+// Update modifies the section and invalidates the note content.
+func (ss *Sectionstore) Update(ctx context.Context, nid, sid string, s *secstore.WritableSection) (*secstore.Section, error) {
+	section, err := ss.sectionstore.Update(ctx, nid, sid, s)
+	if err != nil {
+		return nil, err
+	}
+	ss.invalidate(ctx, nid)
+	return section, nil
+}
+
+// Delete removes the section and invalidates the note content.
+func (ss *Sectionstore) Delete(ctx context.Context, nid, sid string) error {
+	err := ss.sectionstore.Delete(ctx, nid, sid)
+	if err != nil {
+		return err
+	}
+	ss.invalidate(ctx, nid)
+	return nil
+}
+
+// GetSchema returns the cached schema of the note. The missing schema
+// is not cached.
+func (ss *Sectionstore) GetSchema(ctx context.Context, nid string) (*schema.Schema, error) {
+	key, err := ss.cache.key(ctx, schemaKey(nid)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var s *schema.Schema
+	ok, version := ss.cache.get(key, ss.revalidate(ctx, nid), &s)
+	if ok && s != nil {
+		return s, nil
+	}
+
+	version = ss.version(ctx, nid, version)
+	s, err = ss.sectionstore.GetSchema(ctx, nid)
+	if err != nil {
+		return nil, err
+	}
+	ss.cache.put(key, version, s)
+	return s, nil
+}
+
+// Batch applies all operations and invalidates the note content.
+func (ss *Sectionstore) Batch(ctx context.Context, nid string, ops []*secstore.Operation) ([]*secstore.OperationResult, error) {
+	results, err := ss.sectionstore.Batch(ctx, nid, ops)
+	if err != nil {
+		return nil, err
+	}
+	ss.invalidate(ctx, nid)
+	return results, nil
+}
+
+// SetSchema declares the schema and invalidates the note content.
+func (ss *Sectionstore) SetSchema(ctx context.Context, nid string, s *schema.Schema) error {
+	err := ss.sectionstore.SetSchema(ctx, nid, s)
+	if err != nil {
+		return err
+	}
+	ss.invalidate(ctx, nid)
+	return nil
+}
+
+// NewSectionstore creates a new cache decorator of the sectionstore. The
+// notestore gives the note modified time for revalidation, it must not be
+// cached itself. The userinfo keys the entries per user, the nil userinfo
+// shares them for all users.
+func NewSectionstore(ss secstore.Sectionstore, ns notestore.Notestore, b Backend,
+	ui userinfo.Userinfo, c Config) *Sectionstore {
+	return &Sectionstore{
+		sectionstore: ss,
+		notestore:    ns,
+		cache:        newCache(b, ui, c),
+	}
+}
+
+func (ss *Sectionstore) sections(ctx context.Context, nid string) ([]*secstore.Section, error) {
+	key, err := ss.cache.key(ctx, sectionsKey(nid)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []*secstore.Section
+	ok, version := ss.cache.get(key, ss.revalidate(ctx, nid), &sections)
+	if ok {
+		return sections, nil
+	}
+
+	// the version is read before the content, so the content changed in
+	// between is reloaded on the next revalidation
+	version = ss.version(ctx, nid, version)
+	sections, err = ss.sectionstore.GetAll(ctx, nid, nil)
+	if err != nil {
+		return nil, err
+	}
+	ss.cache.put(key, version, sections)
+	return sections, nil
+}
+
+// revalidate returns the func reading the current version of the note.
+func (ss *Sectionstore) revalidate(ctx context.Context, nid string) func() (string, error) {
+	return func() (string, error) {
+		note, err := ss.notestore.Get(ctx, nid)
+		if err != nil {
+			return "", err
+		}
+		return note.DateUpdated.UTC().Format(time.RFC3339Nano), nil
+	}
+}
+
+// version returns the known version, or reads the current one. The error
+// gives the empty version, so the entry is not revalidated later.
+func (ss *Sectionstore) version(ctx context.Context, nid, known string) string {
+	if len(known) > 0 {
+		return known
+	}
+	version, _ := ss.revalidate(ctx, nid)()
+	return version
+}
+
+// invalidate removes the note content, and the note itself because the
+// content upload changes its modified time.
+func (ss *Sectionstore) invalidate(ctx context.Context, nid string) {
+	ss.cache.invalidate(ctx, sectionsKey(nid), schemaKey(nid), notesKey(), noteKey(nid))
+}
@@ -0,0 +1,106 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// EncodingNone stores the content as it is.
+	EncodingNone = ""
+
+	// EncodingGzip compresses the content using gzip.
+	EncodingGzip = "gzip"
+
+	// EncodingZstd compresses the content using zstandard.
+	EncodingZstd = "zstd"
+)
+
+// PropertyKey is the file property recording the content encoding, so
+// the readers know how the stored content is compressed.
+const PropertyKey = "contentEncoding"
+
+var (
+	magicGzip = []byte{0x1f, 0x8b}
+	magicZstd = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Check returns error when the encoding is not supported.
+func Check(encoding string) error {
+	switch encoding {
+	case EncodingNone, EncodingGzip, EncodingZstd:
+		return nil
+	default:
+		return fmt.Errorf("encoding must be one of '%s' or '%s'", EncodingGzip, EncodingZstd)
+	}
+}
+
+// Encode compresses the content using the encoding. The content is
+// returned as it is when no encoding.
+func Encode(content []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case EncodingGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(content); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case EncodingZstd:
+		w, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer w.Close()
+		return w.EncodeAll(content, nil), nil
+	case EncodingNone:
+		return content, nil
+	default:
+		return nil, Check(encoding)
+	}
+}
+
+// Decode decompresses the content using the encoding.
+func Decode(content []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case EncodingGzip:
+		r, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	case EncodingZstd:
+		r, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return r.DecodeAll(content, nil)
+	case EncodingNone:
+		return content, nil
+	default:
+		return nil, Check(encoding)
+	}
+}
+
+// Detect returns the encoding of the content by its magic number. It is
+// used when the recorded encoding is not available, e.g. in the files
+// written before compression or the files downloaded without properties.
+func Detect(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, magicGzip):
+		return EncodingGzip
+	case bytes.HasPrefix(content, magicZstd):
+		return EncodingZstd
+	default:
+		return EncodingNone
+	}
+}
@@ -0,0 +1,43 @@
+package compress_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/pkg/storage/compress"
+)
+
+func TestCompress(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "compress-suite")
+}
+
+var _ = Describe("content compression", func() {
+	content := []byte(`[ { "id": "sid", "name": "section", "data": { "item1": "value1" } } ]`)
+
+	It("should decode the encoded content when any encoding", func() {
+		for _, encoding := range []string{compress.EncodingNone, compress.EncodingGzip, compress.EncodingZstd} {
+			encoded, err := compress.Encode(content, encoding)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(compress.Detect(encoded)).Should(Equal(encoding))
+
+			decoded, err := compress.Decode(encoded, encoding)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(decoded).Should(Equal(content))
+		}
+	})
+
+	It("should return error when wrong encoding", func() {
+		Expect(compress.Check("lz4")).Should(HaveOccurred())
+		_, err := compress.Encode(content, "lz4")
+		Expect(err).Should(HaveOccurred())
+		_, err = compress.Decode(content, "lz4")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should return error when corrupt content", func() {
+		_, err := compress.Decode([]byte{0x1f, 0x8b, 0x00}, compress.EncodingGzip)
+		Expect(err).Should(HaveOccurred())
+	})
+})
@@ -0,0 +1,129 @@
+package convert_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/storage/convert"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+func TestConvert(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "convert-suite")
+}
+
+var _ = Describe("markdown export", func() {
+	var (
+		mockCtrl         *gomock.Controller
+		mockNotestore    *mocks.MockNotestore
+		mockSectionstore *mocks.MockSectionstore
+	)
+	ctx := context.Background()
+	created := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	note := &notestore.Note{
+		ID:          "n1",
+		Name:        "Release Plan",
+		Description: "Plan of the next release.",
+		Labels:      []string{"work"},
+		Metadata:    map[string]string{"owner": "team"},
+		DateCreated: created,
+		DateUpdated: created,
+	}
+	other := &notestore.Note{ID: "n2", Name: "Release plan", DateCreated: created.Add(time.Hour)}
+	sections := []*sectionstore.Section{
+		{ID: "s1", Name: "Facts", Labels: []string{"key"}, Data: map[string]string{"version": "1.2", "date": "friday\nnoon"}},
+		{ID: "s2", Name: "Notes", Kind: sectionstore.KindText, Text: &sectionstore.Text{Format: sectionstore.TextFormatMarkdown, Body: "Some *notes*."}},
+		{ID: "s3", Name: "Todo", Kind: sectionstore.KindChecklist, Checklist: []*sectionstore.ChecklistItem{{Text: "build", Done: true}, {Text: "ship"}}},
+		{ID: "s4", Name: "Owners", Kind: sectionstore.KindTable, Table: &sectionstore.Table{Columns: []string{"Name", "Role"}, Rows: [][]string{{"ann", "dev|ops"}}}},
+		{ID: "s5", Name: "Script", Kind: sectionstore.KindCode, Code: &sectionstore.Code{Language: "sh", Source: "echo ```"}},
+		{ID: "s6", Name: "Related", Links: []*sectionstore.Link{{NoteID: "n2"}, {NoteID: "gone"}}},
+	}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockNotestore = mocks.NewMockNotestore(mockCtrl)
+		mockSectionstore = mocks.NewMockSectionstore(mockCtrl)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Context("render markdown", func() {
+		It("should render the note as markdown document", func() {
+			doc := string(convert.Markdown(note, sections, []*notestore.Note{note, other}, convert.MarkdownOptions{}))
+
+			Expect(doc).Should(HavePrefix("---\nid: n1\nlabels:\n- work\nmetadata:\n  owner: team\ncreated: 2021-01-02T03:04:05Z\n"))
+			Expect(doc).Should(ContainSubstring("---\n\n# Release Plan\n\nPlan of the next release.\n"))
+			Expect(doc).Should(ContainSubstring("## Facts\n\n`key`\n\ndate\n: friday\n    noon\n\nversion\n: 1.2\n"))
+			Expect(doc).Should(ContainSubstring("## Notes\n\nSome *notes*.\n"))
+			Expect(doc).Should(ContainSubstring("- [x] build\n- [ ] ship\n"))
+			Expect(doc).Should(ContainSubstring("| Name | Role |\n| --- | --- |\n| ann | dev\\|ops |\n"))
+			Expect(doc).Should(ContainSubstring("````sh\necho ```\n````\n"))
+			Expect(doc).Should(ContainSubstring("- [Release plan](release-plan-2.md)\n- `gone` (missing)\n"))
+		})
+
+		It("should render the data as table when table option", func() {
+			doc := string(convert.Markdown(note, sections[:1], nil, convert.MarkdownOptions{Data: convert.DataTable}))
+			Expect(doc).Should(ContainSubstring("| Key | Value |\n| --- | --- |\n| date | friday<br>noon |\n| version | 1.2 |\n"))
+		})
+
+		It("should return unique file names when same names", func() {
+			files := convert.FileNames([]*notestore.Note{other, note, {ID: "n3", Name: "???"}})
+			Expect(files).Should(Equal(map[string]string{"n1": "release-plan.md", "n2": "release-plan-2.md", "n3": "n3.md"}))
+		})
+	})
+
+	Context("export notes", func() {
+		It("should return the document and file name of note", func() {
+			mockNotestore.EXPECT().Get(gomock.Any(), "n1").Return(note, nil)
+			mockNotestore.EXPECT().GetAll(gomock.Any()).Return([]*notestore.Note{note, other}, nil)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "n1", nil).Return(sections, nil)
+
+			name, doc, err := convert.NewExporter(mockNotestore, mockSectionstore).Markdown(ctx, "n1", convert.MarkdownOptions{})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(name).Should(Equal("release-plan.md"))
+			Expect(string(doc)).Should(ContainSubstring("# Release Plan"))
+		})
+
+		It("should write the archive of all notes", func() {
+			mockNotestore.EXPECT().GetAll(gomock.Any()).Return([]*notestore.Note{note, other}, nil)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "n1", nil).Return(sections, nil)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "n2", nil).Return(nil, nil)
+
+			buf := new(bytes.Buffer)
+			err := convert.NewExporter(mockNotestore, mockSectionstore).MarkdownArchive(ctx, buf, convert.MarkdownOptions{})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			r, _ := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			Expect(r.File).Should(HaveLen(2))
+			Expect(r.File[1].Name).Should(Equal("release-plan-2.md"))
+			f, _ := r.File[1].Open()
+			data, _ := ioutil.ReadAll(f)
+			Expect(string(data)).Should(ContainSubstring("# Release plan"))
+		})
+
+		It("should return error when invalid data option", func() {
+			_, _, err := convert.NewExporter(mockNotestore, mockSectionstore).Markdown(ctx, "n1", convert.MarkdownOptions{Data: "tree"})
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+		})
+
+		It("should return error when note not found", func() {
+			mockNotestore.EXPECT().Get(gomock.Any(), "n1").Return(nil, errs.NewNotFoundError("error"))
+			_, _, err := convert.NewExporter(mockNotestore, mockSectionstore).Markdown(ctx, "n1", convert.MarkdownOptions{})
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewNotFoundError("msg")))
+		})
+	})
+})
@@ -0,0 +1,75 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/csv"
+	"sort"
+	"strings"
+
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+const (
+	// ColumnName is the csv column of section name.
+	ColumnName = "name"
+
+	// ColumnLabels is the csv column of section labels.
+	ColumnLabels = "labels"
+
+	// labelSep separates the section labels in the csv cell.
+	labelSep = ";"
+
+	// dataPrefix marks the data key having the name of other column.
+	dataPrefix = "data:"
+)
+
+// CSV renders the data sections as csv, each section is the row and each
+// data key is the column. The name and labels columns come first, the data
+// key colliding with them has the 'data:' prefix. The sections of other
+// kinds have no data, so they are skipped.
+func CSV(sections []*sectionstore.Section) []byte {
+	var rows []*sectionstore.Section
+	keys := make(map[string]bool)
+	for _, s := range sections {
+		if sectionstore.GetKind(s.Kind) != sectionstore.KindData {
+			continue
+		}
+		rows = append(rows, s)
+		for k := range s.Data {
+			keys[k] = true
+		}
+	}
+	columns := make([]string, 0, len(keys))
+	for k := range keys {
+		columns = append(columns, k)
+	}
+	sort.Strings(columns)
+
+	header := []string{ColumnName, ColumnLabels}
+	for _, k := range columns {
+		header = append(header, dataColumn(k))
+	}
+
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	w.Write(header)
+	for _, s := range rows {
+		record := []string{s.Name, strings.Join(s.Labels, labelSep)}
+		for _, k := range columns {
+			record = append(record, s.Data[k])
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+// dataColumn returns the column of data key.
+func dataColumn(key string) string {
+	switch k := strings.ToLower(key); {
+	case k == ColumnName, k == ColumnLabels, strings.HasPrefix(k, dataPrefix):
+		return dataPrefix + key
+	default:
+		return key
+	}
+}
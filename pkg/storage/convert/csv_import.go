@@ -0,0 +1,230 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+// CSVOptions is the options of csv import.
+type CSVOptions struct {
+	// Key is the column matching the row with the existing data section,
+	// the matched section is updated and other rows create new sections.
+	// The 'name' key matches the section name, other key matches the data
+	// value. The empty key creates the section of each row.
+	Key string
+}
+
+// CSVReport is the outcome of csv import, it has the result of each row.
+type CSVReport struct {
+	Rows    int          `json:"rows"`
+	Created int          `json:"created"`
+	Updated int          `json:"updated"`
+	Failed  int          `json:"failed"`
+	Results []*RowResult `json:"results"`
+}
+
+// RowResult is the outcome of single csv row. The row number counts the
+// header as the first row.
+type RowResult struct {
+	Row       int      `json:"row"`
+	Op        string   `json:"op,omitempty"`
+	SectionID string   `json:"sectionId,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// csvColumn is the section field of csv column.
+type csvColumn struct {
+	field string
+	key   string
+}
+
+// row is the csv row converted to the batch operation.
+type row struct {
+	result *RowResult
+	op     *sectionstore.Operation
+}
+
+// csvMapping converts the csv rows to the section operations. The matches
+// are the data sections by the value of key column, and the used key values
+// map to the row having them.
+type csvMapping struct {
+	columns []*csvColumn
+	keyIdx  int
+	matches map[string][]*sectionstore.Section
+	used    map[string]int
+}
+
+// parseCSV converts the csv rows to the batch operations. The row matching
+// the existing section by the key column updates it, the empty cell removes
+// the data key and the missing column keeps it. The row failing validation
+// or having more cells than the header has no operation.
+func parseCSV(data []byte, sections []*sectionstore.Section, o CSVOptions) ([]*row, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, errs.NewValidationError(fmt.Sprintf("csv is not valid: %s", err.Error()))
+	}
+	if len(records) == 0 {
+		return nil, errs.NewValidationError("csv must have the header row")
+	}
+
+	m, err := newCSVMapping(records[0], sections, o.Key)
+	if err != nil {
+		return nil, errs.NewValidationError(err.Error())
+	}
+	var rows []*row
+	for i, record := range records[1:] {
+		if len(strings.TrimSpace(strings.Join(record, utils.Empty))) == 0 {
+			continue
+		}
+		rr := &row{result: &RowResult{Row: i + 2}}
+		rows = append(rows, rr)
+		m.convert(record, rr)
+	}
+	return rows, nil
+}
+
+// newCSVMapping maps the header columns to the section fields, and finds
+// the data sections matched by the key column.
+func newCSVMapping(header []string, sections []*sectionstore.Section, key string) (*csvMapping, error) {
+	columns, keyIdx, err := parseHeader(header, key)
+	if err != nil {
+		return nil, err
+	}
+	m := &csvMapping{
+		columns: columns,
+		keyIdx:  keyIdx,
+		matches: make(map[string][]*sectionstore.Section),
+		used:    make(map[string]int),
+	}
+	if keyIdx < 0 {
+		return m, nil
+	}
+	for _, s := range sections {
+		if sectionstore.GetKind(s.Kind) != sectionstore.KindData {
+			continue
+		}
+		value := s.Name
+		if columns[keyIdx].field != ColumnName {
+			value = s.Data[columns[keyIdx].key]
+		}
+		if value = strings.TrimSpace(value); len(value) > 0 {
+			m.matches[value] = append(m.matches[value], s)
+		}
+	}
+	return m, nil
+}
+
+// convert sets the operation of csv row, or the errors of the row failing
+// the validation.
+func (m *csvMapping) convert(record []string, rr *row) {
+	if n := len(record); n > len(m.columns) && len(strings.TrimSpace(strings.Join(record[len(m.columns):], utils.Empty))) > 0 {
+		rr.result.Errors = append(rr.result.Errors, fmt.Sprintf("row has %d cells, the header has %d columns", n, len(m.columns)))
+		return
+	}
+	cells := make([]string, len(m.columns))
+	copy(cells, record)
+
+	op := &sectionstore.Operation{Type: sectionstore.OperationCreate, Section: new(sectionstore.WritableSection)}
+	if m.keyIdx >= 0 {
+		value := strings.TrimSpace(cells[m.keyIdx])
+		found := m.matches[value]
+		switch {
+		case len(value) == 0:
+		case m.used[value] > 0:
+			rr.result.Errors = append(rr.result.Errors, fmt.Sprintf("key value '%s' is same as row %d", value, m.used[value]))
+			return
+		case len(found) > 1:
+			rr.result.Errors = append(rr.result.Errors, fmt.Sprintf("key value '%s' matches %d sections", value, len(found)))
+			return
+		case len(found) == 1:
+			op = &sectionstore.Operation{Type: sectionstore.OperationUpdate, ID: found[0].ID, Section: found[0].Writable()}
+		}
+		if len(value) > 0 {
+			m.used[value] = rr.result.Row
+		}
+	}
+
+	setCells(op.Section, m.columns, cells)
+	if err := op.Section.Validate(); err != nil {
+		rr.result.Errors = append(rr.result.Errors, err.Error())
+		return
+	}
+	rr.op = op
+	rr.result.Op = op.Type
+}
+
+// parseHeader returns the section field of each column, and the index of
+// key column.
+func parseHeader(header []string, key string) ([]*csvColumn, int, error) {
+	columns := make([]*csvColumn, len(header))
+	seen := make(map[string]bool, len(header))
+	keyIdx := -1
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		if len(h) == 0 {
+			return nil, -1, fmt.Errorf("column %d has no name", i+1)
+		}
+		c := &csvColumn{field: "data", key: h}
+		switch lower := strings.ToLower(h); {
+		case lower == ColumnName, lower == ColumnLabels:
+			c = &csvColumn{field: lower, key: lower}
+		case strings.HasPrefix(lower, dataPrefix):
+			c.key = h[len(dataPrefix):]
+		}
+		id := c.field + "/" + c.key
+		if seen[id] {
+			return nil, -1, fmt.Errorf("column '%s' is duplicate", h)
+		}
+		seen[id] = true
+		columns[i] = c
+		if len(key) > 0 && (strings.EqualFold(h, key) || (c.field != "data" && strings.EqualFold(c.key, key))) {
+			keyIdx = i
+		}
+	}
+	if len(key) > 0 && keyIdx < 0 {
+		return nil, -1, fmt.Errorf("key column '%s' is missing", key)
+	}
+	return columns, keyIdx, nil
+}
+
+// setCells sets the section fields from the row cells.
+func setCells(s *sectionstore.WritableSection, columns []*csvColumn, cells []string) {
+	data := make(map[string]string, len(s.Data)+len(columns))
+	for k, v := range s.Data {
+		data[k] = v
+	}
+	for i, c := range columns {
+		value := strings.TrimSpace(cells[i])
+		switch c.field {
+		case ColumnName:
+			if len(value) > 0 {
+				s.Name = value
+			}
+		case ColumnLabels:
+			s.Labels = nil
+			for _, l := range strings.Split(value, labelSep) {
+				if l = strings.TrimSpace(l); len(l) > 0 {
+					s.Labels = append(s.Labels, l)
+				}
+			}
+		default:
+			if len(value) > 0 {
+				data[c.key] = value
+			} else {
+				delete(data, c.key)
+			}
+		}
+	}
+	s.Data = data
+	if len(data) == 0 {
+		s.Data = nil
+	}
+}
@@ -0,0 +1,148 @@
+package convert_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/storage/convert"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+var _ = Describe("csv", func() {
+	var (
+		mockCtrl         *gomock.Controller
+		mockNotestore    *mocks.MockNotestore
+		mockSectionstore *mocks.MockSectionstore
+	)
+	ctx := context.Background()
+
+	sections := []*sectionstore.Section{
+		{ID: "s1", Name: "ann", Labels: []string{"dev", "ops"}, Data: map[string]string{"role": "lead", "name": "Ann Lee"}, Metadata: map[string]string{"m": "v"}},
+		{ID: "s2", Name: "bob", Data: map[string]string{"city": "Oslo, NO"}},
+		{ID: "s3", Name: "text", Kind: sectionstore.KindText, Text: &sectionstore.Text{Body: "text"}},
+	}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockNotestore = mocks.NewMockNotestore(mockCtrl)
+		mockSectionstore = mocks.NewMockSectionstore(mockCtrl)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Context("render csv", func() {
+		It("should have the row of each data section", func() {
+			Expect(string(convert.CSV(sections))).Should(Equal(strings.Join([]string{
+				"name,labels,city,data:name,role",
+				"ann,dev;ops,,Ann Lee,lead",
+				`bob,,"Oslo, NO",,`,
+				"",
+			}, "\n")))
+		})
+
+		It("should export the csv of note", func() {
+			mockNotestore.EXPECT().Get(gomock.Any(), "id").Return(&notestore.Note{ID: "id", Name: "Team Members"}, nil)
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "id", nil).Return(sections, nil)
+			name, doc, err := convert.NewExporter(mockNotestore, mockSectionstore).CSV(ctx, "id")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(name).Should(Equal("team-members.csv"))
+			Expect(doc).Should(Equal(convert.CSV(sections)))
+		})
+	})
+
+	Context("import csv", func() {
+		It("should create the section of each row when no key", func() {
+			mockSectionstore.EXPECT().Batch(gomock.Any(), "id", gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ string, ops []*sectionstore.Operation) ([]*sectionstore.OperationResult, error) {
+					Expect(ops).Should(HaveLen(2))
+					Expect(ops[0].Type).Should(Equal(sectionstore.OperationCreate))
+					Expect(ops[0].Section.Data).Should(Equal(map[string]string{"role": "lead", "name": "Ann Lee"}))
+					Expect(ops[0].Section.Labels).Should(Equal([]string{"dev", "ops"}))
+					return []*sectionstore.OperationResult{{ID: "n1"}, {ID: "n2"}}, nil
+				})
+			report, err := convert.NewImporter(mockNotestore, mockSectionstore).CSV(ctx, "id", convert.CSV(sections), convert.CSVOptions{})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Created).Should(Equal(2))
+			Expect(report.Results[1].SectionID).Should(Equal("n2"))
+		})
+
+		It("should update the section matching the key column", func() {
+			data := "name,role,city\nann,,Rome\ncarl,dev,\nann,qa,\n,,\n"
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "id", nil).Return(sections, nil)
+			mockSectionstore.EXPECT().Batch(gomock.Any(), "id", gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ string, ops []*sectionstore.Operation) ([]*sectionstore.OperationResult, error) {
+					Expect(ops).Should(HaveLen(2))
+					Expect(ops[0].Type).Should(Equal(sectionstore.OperationUpdate))
+					Expect(ops[0].ID).Should(Equal("s1"))
+					Expect(ops[0].Section.Data).Should(Equal(map[string]string{"name": "Ann Lee", "city": "Rome"}))
+					Expect(ops[0].Section.Labels).Should(Equal([]string{"dev", "ops"}))
+					Expect(ops[0].Section.Metadata).Should(Equal(map[string]string{"m": "v"}))
+					Expect(ops[1].Type).Should(Equal(sectionstore.OperationCreate))
+					return []*sectionstore.OperationResult{{ID: "s1"}, {ID: "n1"}}, nil
+				})
+			report, err := convert.NewImporter(mockNotestore, mockSectionstore).CSV(ctx, "id", []byte(data), convert.CSVOptions{Key: "name"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Rows).Should(Equal(3))
+			Expect(report.Updated).Should(Equal(1))
+			Expect(report.Created).Should(Equal(1))
+			Expect(report.Failed).Should(Equal(1))
+			Expect(report.Results[2].Row).Should(Equal(4))
+			Expect(report.Results[2].Errors).Should(ConsistOf(ContainSubstring("row 2")))
+		})
+
+		It("should report the row failing validation", func() {
+			report, err := convert.NewImporter(mockNotestore, mockSectionstore).CSV(ctx, "id", []byte("role\nlead\n"), convert.CSVOptions{})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Failed).Should(Equal(1))
+			Expect(report.Results[0].Errors).ShouldNot(BeEmpty())
+		})
+
+		It("should report the row having more cells than header", func() {
+			report, err := convert.NewImporter(mockNotestore, mockSectionstore).CSV(ctx, "id", []byte("name,role\nann,lead,extra\n"), convert.CSVOptions{})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Failed).Should(Equal(1))
+			Expect(report.Results[0].Errors).Should(ConsistOf(ContainSubstring("3 cells")))
+		})
+
+		It("should return the report with error when batch failed", func() {
+			data := "name\n" + strings.Repeat("ann\n", sectionstore.MaxOperations+1)
+			gomock.InOrder(
+				mockSectionstore.EXPECT().Batch(gomock.Any(), "id", gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, ops []*sectionstore.Operation) ([]*sectionstore.OperationResult, error) {
+						results := make([]*sectionstore.OperationResult, len(ops))
+						for i := range results {
+							results[i] = &sectionstore.OperationResult{ID: "s1"}
+						}
+						return results, nil
+					}),
+				mockSectionstore.EXPECT().Batch(gomock.Any(), "id", gomock.Any()).Return(nil, errors.New("error")),
+			)
+			report, err := convert.NewImporter(mockNotestore, mockSectionstore).CSV(ctx, "id", []byte(data), convert.CSVOptions{})
+			Expect(err).Should(HaveOccurred())
+			Expect(report.Created).Should(Equal(sectionstore.MaxOperations))
+			Expect(report.Failed).Should(Equal(1))
+			Expect(report.Results[sectionstore.MaxOperations].Op).Should(BeEmpty())
+			Expect(report.Results[sectionstore.MaxOperations].Errors).Should(ConsistOf(ContainSubstring("not applied")))
+		})
+
+		It("should return error when key column missing", func() {
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "id", nil).Return(sections, nil)
+			_, err := convert.NewImporter(mockNotestore, mockSectionstore).CSV(ctx, "id", []byte("name\nann\n"), convert.CSVOptions{Key: "email"})
+			Expect(err).Should(BeAssignableToTypeOf(&errs.ValidationError{}))
+		})
+
+		It("should return error when csv not valid", func() {
+			_, err := convert.NewImporter(mockNotestore, mockSectionstore).CSV(ctx, "id", []byte("name,\"bad\n"), convert.CSVOptions{})
+			Expect(err).Should(BeAssignableToTypeOf(&errs.ValidationError{}))
+		})
+	})
+})
@@ -0,0 +1,220 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+// FormatEvernote is the evernote export format.
+const FormatEvernote = "enex"
+
+// enexDate is the date layout of evernote export.
+const enexDate = "20060102T150405Z"
+
+// enexExport is the root of evernote export having many notes.
+type enexExport struct {
+	XMLName xml.Name   `xml:"en-export"`
+	Notes   []enexNote `xml:"note"`
+}
+
+// enexNote is the note of evernote export, the content is the enml document.
+type enexNote struct {
+	Title      string   `xml:"title"`
+	Content    string   `xml:"content"`
+	Created    string   `xml:"created"`
+	Updated    string   `xml:"updated"`
+	Tags       []string `xml:"tag"`
+	Attributes struct {
+		Author    string `xml:"author"`
+		SourceURL string `xml:"source-url"`
+	} `xml:"note-attributes"`
+	Resources []struct {
+		Mime     string `xml:"mime"`
+		FileName string `xml:"resource-attributes>file-name"`
+	} `xml:"resource"`
+	Tasks []struct {
+		Title string `xml:"title"`
+	} `xml:"task"`
+}
+
+// enmlLine is the text line of enml content, the todo line is the item of
+// checklist.
+type enmlLine struct {
+	text string
+	todo bool
+	done bool
+}
+
+// ParseENEX converts each note of evernote export into the note and
+// sections. The title is the note name, the tags are the note labels, the
+// todo items go to the checklist section and other content to the text
+// section. The dates, author and source go to the metadata. The attachments,
+// encrypted text and tasks are not converted.
+func ParseENEX(file string, data []byte) ([]*Document, error) {
+	var export enexExport
+	if err := xml.Unmarshal(data, &export); err != nil {
+		return nil, utils.Error("evernote export is not valid", err)
+	}
+
+	docs := make([]*Document, 0, len(export.Notes))
+	for _, n := range export.Notes {
+		doc := &Document{Note: new(notestore.WritableNote)}
+		setName(doc, n.Title, file)
+		for _, t := range n.Tags {
+			addLabel(doc, t)
+		}
+		for key, value := range map[string]string{"created": n.Created, "updated": n.Updated} {
+			if t, err := time.Parse(enexDate, value); err == nil {
+				setDate(doc, key, t)
+			}
+		}
+		if len(n.Attributes.Author) > 0 {
+			setValue(doc, "author", n.Attributes.Author)
+		}
+		if len(n.Attributes.SourceURL) > 0 {
+			setValue(doc, "source", n.Attributes.SourceURL)
+		}
+
+		var text []string
+		var items []*sectionstore.ChecklistItem
+		lines, unmapped := parseENML(n.Content)
+		for _, l := range lines {
+			if l.todo {
+				items = append(items, &sectionstore.ChecklistItem{Text: l.text, Done: l.done})
+			} else {
+				text = append(text, l.text)
+			}
+		}
+		addText(doc, "Text", strings.Join(text, "\n"))
+		addChecklist(doc, "Checklist", items)
+
+		doc.Unmapped = append(doc.Unmapped, unmapped...)
+		for _, r := range n.Resources {
+			doc.Unmapped = append(doc.Unmapped, fmt.Sprintf("attachment '%s'", utils.GetValueString(r.FileName, r.Mime)))
+		}
+		for _, t := range n.Tasks {
+			doc.Unmapped = append(doc.Unmapped, fmt.Sprintf("task '%s'", t.Title))
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// enmlParser collects the text lines of enml content. The buffer has the
+// text of current line.
+type enmlParser struct {
+	decoder  *xml.Decoder
+	lines    []*enmlLine
+	unmapped []string
+	line     *enmlLine
+	buf      bytes.Buffer
+}
+
+// enmlElements handle the start of enml elements, other block elements only
+// start the new line.
+var enmlElements = map[string]func(p *enmlParser, e xml.StartElement){
+	"en-todo": func(p *enmlParser, e xml.StartElement) {
+		p.flush()
+		p.line.todo = true
+		p.line.done = attr(e, "checked") == "true"
+	},
+	"en-media": func(p *enmlParser, e xml.StartElement) {
+		p.unmapped = append(p.unmapped, fmt.Sprintf("attachment of type '%s'", attr(e, "type")))
+	},
+	"en-crypt": func(p *enmlParser, e xml.StartElement) {
+		p.unmapped = append(p.unmapped, "encrypted text")
+		// the skip error is returned again by the next token
+		_ = p.decoder.Skip()
+	},
+	"li": func(p *enmlParser, e xml.StartElement) {
+		p.flush()
+		p.buf.WriteString("- ")
+	},
+	"td": cell,
+	"th": cell,
+}
+
+// parseENML returns the text lines of enml content, and the content which
+// is not converted. Each block element starts the new line.
+func parseENML(content string) ([]*enmlLine, []string) {
+	d := xml.NewDecoder(strings.NewReader(content))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+
+	p := &enmlParser{decoder: d, line: new(enmlLine)}
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			p.unmapped = append(p.unmapped, fmt.Sprintf("content after error '%s'", err.Error()))
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if handle, ok := enmlElements[name]; ok {
+				handle(p, t)
+			} else if block(name) {
+				p.flush()
+			}
+		case xml.EndElement:
+			if name := strings.ToLower(t.Name.Local); name == "li" || block(name) {
+				p.flush()
+			}
+		case xml.CharData:
+			p.buf.Write(t)
+		}
+	}
+	p.flush()
+	lines := p.lines
+	for len(lines) > 0 && len(lines[len(lines)-1].text) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines, p.unmapped
+}
+
+// flush ends the current line. The blank line is kept only after the text
+// line, and the blank todo line is dropped.
+func (p *enmlParser) flush() {
+	p.line.text = strings.Join(strings.Fields(p.buf.String()), " ")
+	p.buf.Reset()
+	blank := len(p.line.text) == 0
+	if !(blank && (p.line.todo || len(p.lines) == 0 || len(p.lines[len(p.lines)-1].text) == 0)) {
+		p.lines = append(p.lines, p.line)
+	}
+	p.line = new(enmlLine)
+}
+
+func cell(p *enmlParser, e xml.StartElement) {
+	p.buf.WriteString(" ")
+}
+
+func block(name string) bool {
+	switch name {
+	case "div", "p", "br", "hr", "tr", "pre", "blockquote", "ul", "ol", "table",
+		"h1", "h2", "h3", "h4", "h5", "h6":
+		return true
+	default:
+		return false
+	}
+}
+
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if strings.EqualFold(a.Name.Local, name) {
+			return a.Value
+		}
+	}
+	return utils.Empty
+}
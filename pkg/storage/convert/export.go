@@ -0,0 +1,121 @@
+package convert
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+const (
+	// FormatMarkdown is the markdown export format.
+	FormatMarkdown = "markdown"
+
+	// FormatCSV is the csv export format of note sections.
+	FormatCSV = "csv"
+)
+
+// Exporter renders the notes of user in the export formats.
+type Exporter struct {
+	notestore    notestore.Notestore
+	sectionstore sectionstore.Sectionstore
+}
+
+// Markdown returns the markdown document of the note and its file name.
+func (e *Exporter) Markdown(ctx context.Context, id string, o MarkdownOptions) (string, []byte, error) {
+	if err := o.Validate(); err != nil {
+		return utils.Empty, nil, errs.NewValidationError(err.Error())
+	}
+	n, err := e.notestore.Get(ctx, id)
+	if err != nil {
+		return utils.Empty, nil, err
+	}
+	sections, err := e.sectionstore.GetAll(ctx, id, nil)
+	if err != nil {
+		return utils.Empty, nil, err
+	}
+
+	// all notes resolve the links and the unique file name
+	notes, err := e.notestore.GetAll(ctx)
+	if err != nil {
+		return utils.Empty, nil, err
+	}
+	return FileNames(notes)[id], Markdown(n, sections, notes, o), nil
+}
+
+// MarkdownArchive writes the zip archive having the markdown document of
+// each note. All notes are read before writing, so the failure never leaves
+// the partially written archive.
+func (e *Exporter) MarkdownArchive(ctx context.Context, w io.Writer, o MarkdownOptions) error {
+	if err := o.Validate(); err != nil {
+		return errs.NewValidationError(err.Error())
+	}
+	notes, err := e.notestore.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	docs := make([][]byte, len(notes))
+	for i, n := range notes {
+		sections, err := e.sectionstore.GetAll(ctx, n.ID, nil)
+		if err != nil {
+			return err
+		}
+		docs[i] = Markdown(n, sections, notes, o)
+	}
+
+	files := FileNames(notes)
+	zw := zip.NewWriter(w)
+	for i, n := range notes {
+		hdr := &zip.FileHeader{Name: files[n.ID], Method: zip.Deflate}
+		hdr.Modified = n.DateUpdated
+		f, err := zw.CreateHeader(hdr)
+		if err == nil {
+			_, err = f.Write(docs[i])
+		}
+		if err != nil {
+			return utils.Error("markdown archive write error", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return utils.Error("markdown archive write error", err)
+	}
+	return nil
+}
+
+// CSV returns the csv of the note sections and its file name.
+func (e *Exporter) CSV(ctx context.Context, id string) (string, []byte, error) {
+	n, err := e.notestore.Get(ctx, id)
+	if err != nil {
+		return utils.Empty, nil, err
+	}
+	sections, err := e.sectionstore.GetAll(ctx, id, nil)
+	if err != nil {
+		return utils.Empty, nil, err
+	}
+	name := strings.TrimSuffix(FileNames([]*notestore.Note{n})[id], ".md") + ".csv"
+	return name, CSV(sections), nil
+}
+
+// NewExporter creates a new exporter of the notes in the stores.
+func NewExporter(ns notestore.Notestore, ss sectionstore.Sectionstore) *Exporter {
+	return &Exporter{
+		notestore:    ns,
+		sectionstore: ss,
+	}
+}
+
+// Validate checks the markdown options.
+func (o MarkdownOptions) Validate() error {
+	switch o.Data {
+	case utils.Empty, DataList, DataTable:
+		return nil
+	default:
+		return fmt.Errorf("data must be either '%s' or '%s'", DataList, DataTable)
+	}
+}
@@ -0,0 +1,431 @@
+package convert
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+const (
+	// MaxFileSize is the max size of the imported file.
+	MaxFileSize = 5 << 20
+
+	// MaxImportSize is the max size of all files in single import.
+	MaxImportSize = 25 << 20
+
+	maxImportFiles = 1000
+)
+
+// File is the imported file.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// ImportReport is the outcome of import, it has the result of each note.
+type ImportReport struct {
+	Notes    int           `json:"notes"`
+	Imported int           `json:"imported"`
+	Failed   int           `json:"failed"`
+	Results  []*FileResult `json:"results"`
+}
+
+// FileResult is the outcome of single imported note and its file. The
+// unmapped has the content which is not imported, the errors have the
+// validation failures.
+type FileResult struct {
+	File     string   `json:"file"`
+	NoteID   string   `json:"noteId,omitempty"`
+	Name     string   `json:"name,omitempty"`
+	Sections int      `json:"sections"`
+	Unmapped []string `json:"unmapped,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// Importer creates the notes converted from other formats in the stores.
+type Importer struct {
+	notestore    notestore.Notestore
+	sectionstore sectionstore.Sectionstore
+}
+
+// source is the imported file expanded from the uploaded files.
+type source struct {
+	file string
+	data []byte
+	err  error
+}
+
+// parsed is the document converted from the file.
+type parsed struct {
+	file string
+	doc  *Document
+	err  error
+}
+
+// Markdown imports each markdown file as the note, the zip file imports
+// all markdown files in it. The file failing the validation is reported,
+// and the import goes on with the next file.
+func (i *Importer) Markdown(ctx context.Context, files []*File) (*ImportReport, error) {
+	return i.parse(ctx, files, []string{".md", ".markdown"}, func(file string, data []byte) ([]*Document, error) {
+		doc, err := ParseMarkdown(file, data)
+		if err != nil {
+			return nil, err
+		}
+		return []*Document{doc}, nil
+	})
+}
+
+// Keep imports each google keep note of google takeout, the zip file of
+// takeout imports all keep notes in it.
+func (i *Importer) Keep(ctx context.Context, files []*File) (*ImportReport, error) {
+	return i.parse(ctx, files, []string{".json"}, func(file string, data []byte) ([]*Document, error) {
+		doc, err := ParseKeep(file, data)
+		if err != nil {
+			return nil, err
+		}
+		return []*Document{doc}, nil
+	})
+}
+
+// Evernote imports all notes of each evernote export file.
+func (i *Importer) Evernote(ctx context.Context, files []*File) (*ImportReport, error) {
+	return i.parse(ctx, files, []string{".enex"}, ParseENEX)
+}
+
+// CSV creates and updates the data sections of note from the csv rows. The
+// rows are applied in batches, the row failing the validation is reported
+// and skipped. The failed batch stops the import, the report is returned
+// with the error and has the rows not applied as failed rows.
+func (i *Importer) CSV(ctx context.Context, nid string, data []byte, o CSVOptions) (*CSVReport, error) {
+	var sections []*sectionstore.Section
+	if len(o.Key) > 0 {
+		var err error
+		if sections, err = i.sectionstore.GetAll(ctx, nid, nil); err != nil {
+			return nil, err
+		}
+	}
+	rows, err := parseCSV(data, sections, o)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CSVReport{Rows: len(rows), Results: make([]*RowResult, 0, len(rows))}
+	var valid []*row
+	for _, r := range rows {
+		report.Results = append(report.Results, r.result)
+		if r.op == nil {
+			report.Failed++
+			continue
+		}
+		valid = append(valid, r)
+	}
+	for start := 0; start < len(valid); start += sectionstore.MaxOperations {
+		batch := valid[start:]
+		if len(batch) > sectionstore.MaxOperations {
+			batch = batch[:sectionstore.MaxOperations]
+		}
+		ops := make([]*sectionstore.Operation, len(batch))
+		for j, r := range batch {
+			ops[j] = r.op
+		}
+		results, err := i.sectionstore.Batch(ctx, nid, ops)
+		if err != nil {
+			for _, r := range valid[start:] {
+				r.result.Op = utils.Empty
+				r.result.Errors = append(r.result.Errors, fmt.Sprintf("row is not applied: %s", err.Error()))
+				report.Failed++
+			}
+			return report, err
+		}
+		for j, r := range results {
+			batch[j].result.SectionID = r.ID
+			if batch[j].op.Type == sectionstore.OperationUpdate {
+				report.Updated++
+			} else {
+				report.Created++
+			}
+		}
+	}
+	return report, nil
+}
+
+// NewImporter creates a new importer of the notes in the stores.
+func NewImporter(ns notestore.Notestore, ss sectionstore.Sectionstore) *Importer {
+	return &Importer{
+		notestore:    ns,
+		sectionstore: ss,
+	}
+}
+
+// parse converts the files and the files of the extensions in zip files,
+// and creates the notes. The result of each converted note has the file name.
+func (i *Importer) parse(ctx context.Context, files []*File, exts []string, parse func(string, []byte) ([]*Document, error)) (*ImportReport, error) {
+	var docs []*parsed
+	for _, src := range expand(files, exts) {
+		if src.err != nil {
+			docs = append(docs, &parsed{file: src.file, err: src.err})
+			continue
+		}
+		converted, err := parse(src.file, src.data)
+		if err != nil {
+			docs = append(docs, &parsed{file: src.file, err: err})
+			continue
+		}
+		for _, doc := range converted {
+			docs = append(docs, &parsed{file: src.file, doc: doc})
+		}
+	}
+	return i.create(ctx, docs)
+}
+
+// create validates each document before creating its note. The note whose
+// section fails is deleted, so the file is imported fully or not at all.
+func (i *Importer) create(ctx context.Context, docs []*parsed) (*ImportReport, error) {
+	report := &ImportReport{Notes: len(docs), Results: make([]*FileResult, 0, len(docs))}
+	for _, p := range docs {
+		result := &FileResult{File: p.file}
+		report.Results = append(report.Results, result)
+		if p.err != nil {
+			result.Errors = append(result.Errors, p.err.Error())
+			report.Failed++
+			continue
+		}
+
+		result.Name = p.doc.Note.Name
+		result.Unmapped = p.doc.Unmapped
+		result.Errors = validate(p.doc)
+		if len(result.Errors) > 0 {
+			report.Failed++
+			continue
+		}
+
+		note, err := i.notestore.Create(ctx, p.doc.Note)
+		if err == nil {
+			err = i.createSections(ctx, note.ID, p.doc.Sections, result)
+		}
+		if err != nil {
+			if errs.IsFatal(ctx, err) {
+				return nil, err
+			}
+			result.Errors = append(result.Errors, err.Error())
+			report.Failed++
+			continue
+		}
+		result.NoteID = note.ID
+		result.Sections = len(p.doc.Sections)
+		report.Imported++
+	}
+	return report, nil
+}
+
+// createSections creates the sections of imported note. The note is deleted
+// when any section fails, and the failed deletion is added to the result.
+func (i *Importer) createSections(ctx context.Context, nid string,
+	sections []*sectionstore.WritableSection, result *FileResult) error {
+	for _, s := range sections {
+		if _, err := i.sectionstore.Create(ctx, nid, s); err != nil {
+			if derr := i.notestore.Delete(ctx, nid); derr != nil {
+				msg := fmt.Sprintf("note '%s' is not deleted after section failure", nid)
+				result.Errors = append(result.Errors, utils.AppendError(msg, derr))
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func validate(doc *Document) []string {
+	var failures []string
+	if err := doc.Note.Validate(); err != nil {
+		failures = append(failures, fmt.Sprintf("note: %s", err.Error()))
+	}
+	for _, s := range doc.Sections {
+		if err := s.Validate(); err != nil {
+			failures = append(failures, fmt.Sprintf("section '%s': %s", s.Name, err.Error()))
+		}
+	}
+	return failures
+}
+
+// setName sets the note name, the note without name is named after the
+// file. The long name is cut to the max length.
+func setName(doc *Document, name, file string) {
+	name = oneLine(name)
+	if len(name) == 0 {
+		name = strings.TrimSuffix(path.Base(file), path.Ext(file))
+	}
+	if n := cut(name, 100); n != name {
+		doc.Unmapped = append(doc.Unmapped, "name beyond 100 chars")
+		name = n
+	}
+	doc.Note.Name = name
+}
+
+// addLabel adds the label to the note, the label beyond the max count or
+// length is reported.
+func addLabel(doc *Document, label string) {
+	label = strings.TrimSpace(label)
+	switch {
+	case len(label) == 0:
+	case len(doc.Note.Labels) == 5 || utf8.RuneCountInString(label) > 20:
+		doc.Unmapped = append(doc.Unmapped, fmt.Sprintf("label '%s'", label))
+	default:
+		doc.Note.Labels = append(doc.Note.Labels, label)
+	}
+}
+
+// setValue sets the note metadata, the long value is cut to the max length.
+func setValue(doc *Document, key, value string) {
+	if doc.Note.Metadata == nil {
+		doc.Note.Metadata = make(map[string]string)
+	}
+	if v := cut(value, 100); v != value {
+		doc.Unmapped = append(doc.Unmapped, fmt.Sprintf("metadata '%s' beyond 100 chars", key))
+		value = v
+	}
+	doc.Note.Metadata[key] = value
+}
+
+func setDate(doc *Document, key string, t time.Time) {
+	if !t.IsZero() {
+		setValue(doc, key, t.UTC().Format(time.RFC3339))
+	}
+}
+
+// addText adds the plain text section, the long text is cut to the max
+// length.
+func addText(doc *Document, name, text string) {
+	text = strings.TrimSpace(text)
+	if len(text) == 0 {
+		return
+	}
+	if t := cut(text, 20000); t != text {
+		doc.Unmapped = append(doc.Unmapped, fmt.Sprintf("text of '%s' beyond 20000 chars", name))
+		text = t
+	}
+	doc.Sections = append(doc.Sections, &sectionstore.WritableSection{
+		Name: name,
+		Kind: sectionstore.KindText,
+		Text: &sectionstore.Text{Format: sectionstore.TextFormatPlain, Body: text},
+	})
+}
+
+// addChecklist adds the checklist sections, the items beyond the max count
+// go to the next sections. The empty item is skipped.
+func addChecklist(doc *Document, name string, items []*sectionstore.ChecklistItem) {
+	var section *sectionstore.WritableSection
+	for _, item := range items {
+		if item.Text = cut(oneLine(item.Text), 500); len(item.Text) == 0 {
+			continue
+		}
+		if section == nil || len(section.Checklist) == 100 {
+			section = &sectionstore.WritableSection{Name: name, Kind: sectionstore.KindChecklist}
+			if n := countKind(doc, sectionstore.KindChecklist); n > 0 {
+				section.Name = fmt.Sprintf("%s %d", name, n+1)
+			}
+			doc.Sections = append(doc.Sections, section)
+		}
+		section.Checklist = append(section.Checklist, item)
+	}
+}
+
+func countKind(doc *Document, kind string) int {
+	n := 0
+	for _, s := range doc.Sections {
+		if s.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// cut returns the text having at most max chars.
+func cut(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	return string([]rune(text)[:max])
+}
+
+// expand returns the files with the files of the extensions in zip files
+// in place of the zip files. The expanding stops with the file error when
+// the files pass the max import size or count.
+func expand(files []*File, exts []string) []*source {
+	var expanded []*source
+	var total int
+	add := func(src *source) bool {
+		total += len(src.data)
+		switch {
+		case total > MaxImportSize:
+			src.data, src.err = nil, fmt.Errorf("import must be less than %d bytes", MaxImportSize)
+		case len(expanded) == maxImportFiles:
+			src.data, src.err = nil, fmt.Errorf("import must have at most %d files", maxImportFiles)
+		default:
+			expanded = append(expanded, src)
+			return true
+		}
+		expanded = append(expanded, src)
+		return false
+	}
+	for _, f := range files {
+		if !strings.EqualFold(path.Ext(f.Name), ".zip") {
+			if !add(&source{file: f.Name, data: f.Data}) {
+				return expanded
+			}
+			continue
+		}
+		r, err := zip.NewReader(bytes.NewReader(f.Data), int64(len(f.Data)))
+		if err != nil {
+			expanded = append(expanded, &source{file: f.Name, err: fmt.Errorf("zip file is not valid: %s", err.Error())})
+			continue
+		}
+		for _, zf := range r.File {
+			if zf.FileInfo().IsDir() || strings.HasPrefix(zf.Name, "__MACOSX/") || !hasExt(zf.Name, exts) {
+				continue
+			}
+			data, err := readZipFile(zf)
+			if !add(&source{file: f.Name + "/" + zf.Name, data: data, err: err}) {
+				return expanded
+			}
+		}
+	}
+	return expanded
+}
+
+func hasExt(name string, exts []string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range exts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+func readZipFile(zf *zip.File) ([]byte, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(rc, MaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxFileSize {
+		return nil, fmt.Errorf("file must be less than %d bytes", MaxFileSize)
+	}
+	return data, nil
+}
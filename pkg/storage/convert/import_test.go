@@ -0,0 +1,239 @@
+package convert_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/storage/convert"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+var _ = Describe("markdown import", func() {
+	var (
+		mockCtrl         *gomock.Controller
+		mockNotestore    *mocks.MockNotestore
+		mockSectionstore *mocks.MockSectionstore
+	)
+	ctx := context.Background()
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockNotestore = mocks.NewMockNotestore(mockCtrl)
+		mockSectionstore = mocks.NewMockSectionstore(mockCtrl)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Context("parse markdown", func() {
+		It("should map front matter, headings and section bodies", func() {
+			doc, err := convert.ParseMarkdown("plan.md", []byte(strings.Join([]string{
+				"---",
+				"id: old",
+				"tags: [work, home]",
+				"owner: team",
+				"metadata:",
+				"  status: open",
+				"aliases: [a, b]",
+				"---",
+				"",
+				"# Release Plan",
+				"",
+				"Plan of the next release.",
+				"",
+				"## Facts",
+				"",
+				"`key`",
+				"",
+				"version: 1.2",
+				"- **owner**: ann",
+				"",
+				"date",
+				": friday",
+				"    noon",
+				"",
+				"## Todo",
+				"",
+				"- [x] build",
+				"- [ ] ship",
+				"",
+				"## Owners",
+				"",
+				"| Name | Role |",
+				"| --- | --- |",
+				"| ann | dev\\|ops |",
+				"",
+				"## Script",
+				"",
+				"```sh",
+				"# not heading",
+				"```",
+				"",
+				"## Notes",
+				"",
+				"Some *notes*.",
+			}, "\r\n")))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			n := doc.Note
+			Expect(n.Name).Should(Equal("Release Plan"))
+			Expect(n.Description).Should(Equal("Plan of the next release."))
+			Expect(n.Labels).Should(Equal([]string{"work", "home"}))
+			Expect(n.Metadata).Should(Equal(map[string]string{"owner": "team", "status": "open"}))
+			Expect(doc.Unmapped).Should(ConsistOf(ContainSubstring("aliases")))
+
+			Expect(doc.Sections).Should(HaveLen(5))
+			Expect(doc.Sections[0].Labels).Should(Equal([]string{"key"}))
+			Expect(doc.Sections[0].Data).Should(Equal(map[string]string{"version": "1.2", "owner": "ann", "date": "friday\nnoon"}))
+			Expect(doc.Sections[1].Kind).Should(Equal(sectionstore.KindChecklist))
+			Expect(doc.Sections[1].Checklist[0].Done).Should(BeTrue())
+			Expect(doc.Sections[2].Table.Rows).Should(Equal([][]string{{"ann", "dev|ops"}}))
+			Expect(doc.Sections[3].Code).Should(Equal(&sectionstore.Code{Language: "sh", Source: "# not heading"}))
+			Expect(doc.Sections[4].Text.Body).Should(Equal("Some *notes*."))
+		})
+
+		It("should read back the exported markdown", func() {
+			note := &notestore.Note{ID: "n1", Name: "Plan", Labels: []string{"work"}, Metadata: map[string]string{"owner": "team"}}
+			sections := []*sectionstore.Section{{Name: "Facts", Labels: []string{"key"}, Data: map[string]string{"version": "1.2", "date": "friday\nnoon"}}}
+			for _, data := range []string{convert.DataList, convert.DataTable} {
+				md := convert.Markdown(note, sections, nil, convert.MarkdownOptions{Data: data})
+				doc, err := convert.ParseMarkdown("plan.md", md)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(doc.Note.Name).Should(Equal("Plan"))
+				Expect(doc.Note.Labels).Should(Equal(note.Labels))
+				Expect(doc.Note.Metadata).Should(Equal(note.Metadata))
+				Expect(doc.Sections).Should(HaveLen(1))
+				Expect(doc.Sections[0].Labels).Should(Equal([]string{"key"}))
+				Expect(doc.Sections[0].Data).Should(Equal(sections[0].Data))
+			}
+		})
+
+		It("should name the note after the file when no heading", func() {
+			doc, err := convert.ParseMarkdown("dir/my-note.md", []byte("just text"))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(doc.Note.Name).Should(Equal("my-note"))
+			Expect(doc.Note.Description).Should(Equal("just text"))
+		})
+
+		It("should take the heading as note heading when front matter has title", func() {
+			doc, err := convert.ParseMarkdown("note.md", []byte("---\ntitle: Plan\n---\n# Plan\n\n## Facts\n\nkey: value"))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(doc.Note.Name).Should(Equal("Plan"))
+			Expect(doc.Sections).Should(HaveLen(1))
+			Expect(doc.Sections[0].Name).Should(Equal("Facts"))
+			Expect(doc.Unmapped).Should(BeEmpty())
+
+			doc, err = convert.ParseMarkdown("note.md", []byte("---\ntitle: Plan\n---\n# Other"))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(doc.Note.Name).Should(Equal("Plan"))
+			Expect(doc.Sections).Should(BeEmpty())
+			Expect(doc.Unmapped).Should(ConsistOf(ContainSubstring("heading 'Other'")))
+		})
+
+		It("should return error when front matter not closed", func() {
+			_, err := convert.ParseMarkdown("note.md", []byte("---\nlabels: [a]\n# Note"))
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("import markdown", func() {
+		It("should create notes of markdown and zip files", func() {
+			buf := new(bytes.Buffer)
+			zw := zip.NewWriter(buf)
+			f, _ := zw.Create("notes/second.md")
+			f.Write([]byte("# Second"))
+			f, _ = zw.Create("notes/image.png")
+			f.Write([]byte("png"))
+			zw.Close()
+
+			mockNotestore.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&notestore.Note{ID: "n1"}, nil)
+			mockSectionstore.EXPECT().Create(gomock.Any(), "n1", gomock.Any()).Return(&sectionstore.Section{ID: "s1"}, nil)
+			mockNotestore.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&notestore.Note{ID: "n2"}, nil)
+			report, err := convert.NewImporter(mockNotestore, mockSectionstore).Markdown(ctx, []*convert.File{
+				{Name: "first.md", Data: []byte("# First\n\n## Facts\n\nkey: value")},
+				{Name: "notes.zip", Data: buf.Bytes()},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Notes).Should(Equal(2))
+			Expect(report.Imported).Should(Equal(2))
+			Expect(report.Results[0].Sections).Should(Equal(1))
+			Expect(report.Results[1].File).Should(Equal("notes.zip/notes/second.md"))
+			Expect(report.Results[1].NoteID).Should(Equal("n2"))
+		})
+
+		It("should report the file failing validation", func() {
+			report, err := convert.NewImporter(mockNotestore, mockSectionstore).Markdown(ctx, []*convert.File{
+				{Name: "bad.md", Data: []byte("# Note\n\n## " + strings.Repeat("x", 1000))},
+				{Name: "bad.zip", Data: []byte("zip")},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Failed).Should(Equal(2))
+			Expect(report.Results[0].Errors).Should(ConsistOf(HavePrefix("section")))
+			Expect(report.Results[1].Errors).ShouldNot(BeEmpty())
+		})
+
+		It("should stop with file error when import size passed", func() {
+			buf := new(bytes.Buffer)
+			zw := zip.NewWriter(buf)
+			for i := 0; i < 6; i++ {
+				f, _ := zw.Create(fmt.Sprintf("note%d.md", i))
+				f.Write(bytes.Repeat([]byte("a"), convert.MaxFileSize))
+			}
+			zw.Close()
+
+			// the large files fail the validation, so no note is created
+			report, err := convert.NewImporter(mockNotestore, mockSectionstore).Markdown(ctx, []*convert.File{
+				{Name: "notes.zip", Data: buf.Bytes()},
+				{Name: "last.md", Data: []byte("# Last")},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Notes).Should(Equal(6))
+			Expect(report.Results[4].Errors).ShouldNot(ContainElement(ContainSubstring("import must")))
+			Expect(report.Results[5].File).Should(Equal("notes.zip/note5.md"))
+			Expect(report.Results[5].Errors).Should(ConsistOf(ContainSubstring("import must be less than")))
+		})
+
+		It("should delete the note when section creation fails", func() {
+			mockNotestore.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&notestore.Note{ID: "n1"}, nil)
+			mockSectionstore.EXPECT().Create(gomock.Any(), "n1", gomock.Any()).Return(nil, errors.New("error"))
+			mockNotestore.EXPECT().Delete(gomock.Any(), "n1").Return(nil)
+			report, err := convert.NewImporter(mockNotestore, mockSectionstore).Markdown(ctx, []*convert.File{
+				{Name: "note.md", Data: []byte("# Note\n\n## Facts\n\nkey: value")},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Failed).Should(Equal(1))
+			Expect(report.Results[0].NoteID).Should(BeEmpty())
+		})
+
+		It("should report the failed deletion when section creation fails", func() {
+			mockNotestore.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&notestore.Note{ID: "n1"}, nil)
+			mockSectionstore.EXPECT().Create(gomock.Any(), "n1", gomock.Any()).Return(nil, errors.New("error"))
+			mockNotestore.EXPECT().Delete(gomock.Any(), "n1").Return(errors.New("delete error"))
+			report, err := convert.NewImporter(mockNotestore, mockSectionstore).Markdown(ctx, []*convert.File{
+				{Name: "note.md", Data: []byte("# Note\n\n## Facts\n\nkey: value")},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Failed).Should(Equal(1))
+			Expect(report.Results[0].Errors).Should(ContainElement(ContainSubstring("delete error")))
+		})
+
+		It("should return error when unauthorized", func() {
+			mockNotestore.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, errs.NewUnauthorizedError())
+			_, err := convert.NewImporter(mockNotestore, mockSectionstore).Markdown(ctx, []*convert.File{
+				{Name: "note.md", Data: []byte("# Note")},
+			})
+			Expect(err).Should(BeAssignableToTypeOf(&errs.UnauthorizedError{}))
+		})
+	})
+})
@@ -0,0 +1,118 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+// FormatKeep is the google keep format of google takeout.
+const FormatKeep = "keep"
+
+// keepNote is the note json of google takeout.
+type keepNote struct {
+	Title       string  `json:"title"`
+	TextContent *string `json:"textContent"`
+	ListContent []struct {
+		Text      string `json:"text"`
+		IsChecked bool   `json:"isChecked"`
+	} `json:"listContent"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	Annotations []struct {
+		Title string `json:"title"`
+		URL   string `json:"url"`
+	} `json:"annotations"`
+	Attachments []struct {
+		FilePath string `json:"filePath"`
+	} `json:"attachments"`
+	Sharees []struct {
+		Email string `json:"email"`
+	} `json:"sharees"`
+	Color      string `json:"color"`
+	IsPinned   bool   `json:"isPinned"`
+	IsArchived bool   `json:"isArchived"`
+	IsTrashed  bool   `json:"isTrashed"`
+	Created    int64  `json:"createdTimestampUsec"`
+	Edited     int64  `json:"userEditedTimestampUsec"`
+}
+
+// ParseKeep converts the google keep note of google takeout into the note
+// and sections. The title is the note name, the labels are the note labels,
+// the text is the text section and the list is the checklist section. The
+// web links go to the data section, and the dates and state to the metadata.
+// The attachments and collaborators are not converted.
+func ParseKeep(file string, data []byte) (*Document, error) {
+	var k keepNote
+	if err := json.Unmarshal(data, &k); err != nil {
+		return nil, utils.Error("keep note is not valid json", err)
+	}
+	if k.TextContent == nil && k.ListContent == nil && len(k.Title) == 0 {
+		return nil, fmt.Errorf("keep note has no title, text or list")
+	}
+
+	doc := &Document{Note: new(notestore.WritableNote)}
+	setName(doc, k.Title, file)
+	for _, l := range k.Labels {
+		addLabel(doc, l.Name)
+	}
+	k.setMetadata(doc)
+	k.addSections(doc)
+
+	for _, a := range k.Attachments {
+		doc.Unmapped = append(doc.Unmapped, fmt.Sprintf("attachment '%s'", a.FilePath))
+	}
+	if len(k.Sharees) > 0 {
+		doc.Unmapped = append(doc.Unmapped, fmt.Sprintf("%d collaborators", len(k.Sharees)))
+	}
+	return doc, nil
+}
+
+// setMetadata sets the dates, color and state of keep note in the note
+// metadata.
+func (k *keepNote) setMetadata(doc *Document) {
+	setDate(doc, "created", usec(k.Created))
+	setDate(doc, "updated", usec(k.Edited))
+	if len(k.Color) > 0 && k.Color != "DEFAULT" {
+		setValue(doc, "color", strings.ToLower(k.Color))
+	}
+	for state, ok := range map[string]bool{"pinned": k.IsPinned, "archived": k.IsArchived, "trashed": k.IsTrashed} {
+		if ok {
+			setValue(doc, state, "true")
+		}
+	}
+}
+
+// addSections adds the text, checklist and web links sections of keep
+// note.
+func (k *keepNote) addSections(doc *Document) {
+	if k.TextContent != nil {
+		addText(doc, "Text", *k.TextContent)
+	}
+	var items []*sectionstore.ChecklistItem
+	for _, item := range k.ListContent {
+		items = append(items, &sectionstore.ChecklistItem{Text: item.Text, Done: item.IsChecked})
+	}
+	addChecklist(doc, "Checklist", items)
+
+	if len(k.Annotations) > 0 {
+		links := make(map[string]string)
+		for _, a := range k.Annotations {
+			links[cut(utils.GetValueString(a.Title, a.URL), 50)] = cut(a.URL, 2000)
+		}
+		doc.Sections = append(doc.Sections, &sectionstore.WritableSection{Name: "Links", Data: links})
+	}
+}
+
+func usec(v int64) time.Time {
+	if v <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, v*int64(time.Microsecond))
+}
@@ -0,0 +1,166 @@
+package convert_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	"github.com/psewda/typing/pkg/storage/convert"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+var _ = Describe("keep and evernote import", func() {
+	var (
+		mockCtrl         *gomock.Controller
+		mockNotestore    *mocks.MockNotestore
+		mockSectionstore *mocks.MockSectionstore
+	)
+	ctx := context.Background()
+
+	keep := `{
+		"color": "YELLOW", "isTrashed": false, "isPinned": true, "isArchived": false,
+		"title": "Groceries", "textContent": "for the weekend",
+		"listContent": [{"text": "milk", "isChecked": true}, {"text": "eggs", "isChecked": false}, {"text": " ", "isChecked": false}],
+		"labels": [{"name": "home"}, {"name": "a very very long label name"}],
+		"annotations": [{"title": "Shop", "url": "https://shop.example"}],
+		"attachments": [{"filePath": "photo.jpg", "mimetype": "image/jpeg"}],
+		"createdTimestampUsec": 1609459200000000, "userEditedTimestampUsec": 1609462800000000
+	}`
+
+	enex := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE en-export SYSTEM "http://xml.evernote.com/pub/evernote-export3.dtd">
+<en-export export-date="20210101T000000Z" application="Evernote" version="10">
+  <note>
+    <title>Trip</title>
+    <content><![CDATA[<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE en-note SYSTEM "http://xml.evernote.com/pub/enml2.dtd">
+<en-note><div>Pack&nbsp;light</div><div><br/></div><ul><li>passport</li></ul>
+<div><en-todo checked="true"/>tickets</div><div><en-todo/>hotel</div>
+<en-media type="image/png" hash="abc"/><en-crypt>secret</en-crypt></en-note>]]></content>
+    <created>20210102T030405Z</created>
+    <updated>20210103T030405Z</updated>
+    <tag>travel</tag>
+    <note-attributes><author>ann</author><source-url>https://example.com</source-url></note-attributes>
+    <resource><mime>image/png</mime><resource-attributes><file-name>map.png</file-name></resource-attributes></resource>
+  </note>
+  <note><title></title><content><![CDATA[<en-note>plain</en-note>]]></content></note>
+</en-export>`
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockNotestore = mocks.NewMockNotestore(mockCtrl)
+		mockSectionstore = mocks.NewMockSectionstore(mockCtrl)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Context("parse keep", func() {
+		It("should map title, labels, text, list and dates", func() {
+			doc, err := convert.ParseKeep("Keep/groceries.json", []byte(keep))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			n := doc.Note
+			Expect(n.Name).Should(Equal("Groceries"))
+			Expect(n.Labels).Should(Equal([]string{"home"}))
+			Expect(n.Metadata).Should(Equal(map[string]string{
+				"created": "2021-01-01T00:00:00Z",
+				"updated": "2021-01-01T01:00:00Z",
+				"color":   "yellow",
+				"pinned":  "true",
+			}))
+
+			Expect(doc.Sections).Should(HaveLen(3))
+			Expect(doc.Sections[0].Text.Body).Should(Equal("for the weekend"))
+			Expect(doc.Sections[1].Checklist).Should(Equal([]*sectionstore.ChecklistItem{{Text: "milk", Done: true}, {Text: "eggs"}}))
+			Expect(doc.Sections[2].Data).Should(Equal(map[string]string{"Shop": "https://shop.example"}))
+			Expect(doc.Unmapped).Should(ConsistOf(ContainSubstring("a very very long label name"), ContainSubstring("photo.jpg")))
+		})
+
+		It("should split the long list into many checklists", func() {
+			var items []string
+			for i := 0; i < 150; i++ {
+				items = append(items, fmt.Sprintf(`{"text": "item %d"}`, i))
+			}
+			doc, err := convert.ParseKeep("list.json", []byte(`{"listContent": [`+strings.Join(items, ",")+`]}`))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(doc.Note.Name).Should(Equal("list"))
+			Expect(doc.Sections).Should(HaveLen(2))
+			Expect(doc.Sections[1].Name).Should(Equal("Checklist 2"))
+			Expect(doc.Sections[1].Checklist).Should(HaveLen(50))
+		})
+
+		It("should return error when not keep note", func() {
+			_, err := convert.ParseKeep("labels.json", []byte(`{"other": 1}`))
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("parse evernote", func() {
+		It("should map each note of export", func() {
+			docs, err := convert.ParseENEX("notes.enex", []byte(enex))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(docs).Should(HaveLen(2))
+
+			doc := docs[0]
+			Expect(doc.Note.Name).Should(Equal("Trip"))
+			Expect(doc.Note.Labels).Should(Equal([]string{"travel"}))
+			Expect(doc.Note.Metadata).Should(Equal(map[string]string{
+				"created": "2021-01-02T03:04:05Z",
+				"updated": "2021-01-03T03:04:05Z",
+				"author":  "ann",
+				"source":  "https://example.com",
+			}))
+			Expect(doc.Sections).Should(HaveLen(2))
+			Expect(doc.Sections[0].Text.Body).Should(Equal("Pack light\n\n- passport"))
+			Expect(doc.Sections[1].Checklist).Should(Equal([]*sectionstore.ChecklistItem{{Text: "tickets", Done: true}, {Text: "hotel"}}))
+			Expect(doc.Unmapped).Should(ConsistOf(
+				ContainSubstring("image/png"), Equal("encrypted text"), ContainSubstring("map.png")))
+
+			Expect(docs[1].Note.Name).Should(Equal("notes"))
+			Expect(docs[1].Sections[0].Text.Body).Should(Equal("plain"))
+		})
+
+		It("should return error when not evernote export", func() {
+			_, err := convert.ParseENEX("notes.enex", []byte("<html></html>"))
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("import", func() {
+		It("should create the keep notes of takeout zip", func() {
+			buf := new(bytes.Buffer)
+			zw := zip.NewWriter(buf)
+			f, _ := zw.Create("Takeout/Keep/groceries.json")
+			f.Write([]byte(keep))
+			f, _ = zw.Create("Takeout/Keep/groceries.html")
+			f.Write([]byte("<html></html>"))
+			zw.Close()
+
+			mockNotestore.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&notestore.Note{ID: "n1"}, nil)
+			mockSectionstore.EXPECT().Create(gomock.Any(), "n1", gomock.Any()).Return(&sectionstore.Section{}, nil).Times(3)
+			report, err := convert.NewImporter(mockNotestore, mockSectionstore).Keep(ctx, []*convert.File{{Name: "takeout.zip", Data: buf.Bytes()}})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Imported).Should(Equal(1))
+			Expect(report.Results[0].Unmapped).Should(HaveLen(2))
+		})
+
+		It("should create all notes of evernote export", func() {
+			mockNotestore.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&notestore.Note{ID: "n1"}, nil).Times(2)
+			mockSectionstore.EXPECT().Create(gomock.Any(), "n1", gomock.Any()).Return(&sectionstore.Section{}, nil).Times(3)
+			report, err := convert.NewImporter(mockNotestore, mockSectionstore).Evernote(ctx, []*convert.File{{Name: "notes.enex", Data: []byte(enex)}})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Notes).Should(Equal(2))
+			Expect(report.Imported).Should(Equal(2))
+			Expect(report.Results[0].Name).Should(Equal("Trip"))
+		})
+	})
+})
@@ -0,0 +1,246 @@
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// DataList renders the section data as definition list.
+	DataList = "list"
+
+	// DataTable renders the section data as table of keys and values.
+	DataTable = "table"
+)
+
+// MarkdownOptions is the rendering options of markdown document.
+type MarkdownOptions struct {
+	// Data is the rendering of section data, either 'list' or 'table'. The
+	// empty value renders the definition list.
+	Data string
+}
+
+// frontMatter is the yaml front matter of markdown document.
+type frontMatter struct {
+	ID       string            `yaml:"id,omitempty"`
+	Labels   []string          `yaml:"labels,omitempty"`
+	Metadata map[string]string `yaml:"metadata,omitempty"`
+	Created  *time.Time        `yaml:"created,omitempty"`
+	Updated  *time.Time        `yaml:"updated,omitempty"`
+}
+
+// Markdown renders the note and its sections as markdown document. The
+// labels and metadata of note go to the yaml front matter, the name and
+// description to the heading, and each section to the subsection. The notes
+// resolve the links to the markdown files of other notes, the link of note
+// missing in notes is rendered with the note id.
+func Markdown(n *notestore.Note, sections []*sectionstore.Section, notes []*notestore.Note, o MarkdownOptions) []byte {
+	files := FileNames(notes)
+	names := make(map[string]string, len(notes))
+	for _, note := range notes {
+		names[note.ID] = note.Name
+	}
+
+	buf := new(bytes.Buffer)
+	fm := &frontMatter{
+		ID:       n.ID,
+		Labels:   n.Labels,
+		Metadata: n.Metadata,
+	}
+	if !n.DateCreated.IsZero() {
+		fm.Created = &n.DateCreated
+	}
+	if !n.DateUpdated.IsZero() {
+		fm.Updated = &n.DateUpdated
+	}
+	data, _ := yaml.Marshal(fm)
+	fmt.Fprintf(buf, "---\n%s---\n\n# %s\n", data, oneLine(n.Name))
+	if desc := strings.TrimSpace(n.Description); len(desc) > 0 {
+		fmt.Fprintf(buf, "\n%s\n", desc)
+	}
+
+	for _, s := range sections {
+		fmt.Fprintf(buf, "\n## %s\n", oneLine(s.Name))
+		if len(s.Labels) > 0 {
+			tags := make([]string, 0, len(s.Labels))
+			for _, l := range s.Labels {
+				tags = append(tags, inlineCode(l))
+			}
+			fmt.Fprintf(buf, "\n%s\n", strings.Join(tags, " "))
+		}
+		if body := renderSection(s, o); len(body) > 0 {
+			fmt.Fprintf(buf, "\n%s\n", body)
+		}
+		if len(s.Links) > 0 {
+			buf.WriteString("\nLinks:\n\n")
+			for _, l := range s.Links {
+				fmt.Fprintf(buf, "- %s\n", renderLink(l, files, names))
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// FileNames returns the unique markdown file name of each note, the name
+// is built from the note name. The older note keeps the shorter name.
+func FileNames(notes []*notestore.Note) map[string]string {
+	sorted := make([]*notestore.Note, len(notes))
+	copy(sorted, notes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].DateCreated.Before(sorted[j].DateCreated)
+	})
+
+	files := make(map[string]string, len(notes))
+	used := make(map[string]bool, len(notes))
+	for _, n := range sorted {
+		base := slug(n.Name)
+		if len(base) == 0 {
+			base = slug(n.ID)
+		}
+		name := base + ".md"
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s-%d.md", base, i)
+		}
+		used[name] = true
+		files[n.ID] = name
+	}
+	return files
+}
+
+func renderSection(s *sectionstore.Section, o MarkdownOptions) string {
+	switch sectionstore.GetKind(s.Kind) {
+	case sectionstore.KindText:
+		if s.Text == nil {
+			return ""
+		}
+		return strings.TrimSpace(s.Text.Body)
+	case sectionstore.KindChecklist:
+		var lines []string
+		for _, item := range s.Checklist {
+			mark := " "
+			if item.Done {
+				mark = "x"
+			}
+			lines = append(lines, fmt.Sprintf("- [%s] %s", mark, oneLine(item.Text)))
+		}
+		return strings.Join(lines, "\n")
+	case sectionstore.KindTable:
+		if s.Table == nil {
+			return ""
+		}
+		return renderTable(s.Table.Columns, s.Table.Rows)
+	case sectionstore.KindCode:
+		if s.Code == nil {
+			return ""
+		}
+		fence := "```"
+		for strings.Contains(s.Code.Source, fence) {
+			fence += "`"
+		}
+		return fmt.Sprintf("%s%s\n%s\n%s", fence, s.Code.Language, strings.TrimRight(s.Code.Source, "\n"), fence)
+	default:
+		return renderData(s.Data, o)
+	}
+}
+
+func renderData(data map[string]string, o MarkdownOptions) string {
+	if len(data) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if o.Data == DataTable {
+		rows := make([][]string, 0, len(keys))
+		for _, k := range keys {
+			rows = append(rows, []string{k, data[k]})
+		}
+		return renderTable([]string{"Key", "Value"}, rows)
+	}
+
+	// the definition list of markdown extra, the next lines of value are
+	// indented to stay in the definition
+	var items []string
+	for _, k := range keys {
+		value := strings.ReplaceAll(strings.TrimSpace(data[k]), "\n", "\n    ")
+		items = append(items, fmt.Sprintf("%s\n: %s", oneLine(k), value))
+	}
+	return strings.Join(items, "\n\n")
+}
+
+func renderTable(columns []string, rows [][]string) string {
+	sep := make([]string, len(columns))
+	for i := range sep {
+		sep[i] = "---"
+	}
+	lines := []string{tableRow(columns), tableRow(sep)}
+	for _, r := range rows {
+		lines = append(lines, tableRow(r))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func tableRow(cells []string) string {
+	escaped := make([]string, len(cells))
+	for i, c := range cells {
+		c = strings.ReplaceAll(c, "|", `\|`)
+		escaped[i] = strings.ReplaceAll(strings.ReplaceAll(c, "\r\n", "\n"), "\n", "<br>")
+	}
+	return "| " + strings.Join(escaped, " | ") + " |"
+}
+
+func renderLink(l *sectionstore.Link, files, names map[string]string) string {
+	file, ok := files[l.NoteID]
+	if !ok {
+		return inlineCode(l.NoteID) + " (missing)"
+	}
+	name := strings.NewReplacer("[", `\[`, "]", `\]`).Replace(oneLine(names[l.NoteID]))
+	if len(l.SectionID) > 0 {
+		return fmt.Sprintf("[%s](%s) section %s", name, file, inlineCode(l.SectionID))
+	}
+	return fmt.Sprintf("[%s](%s)", name, file)
+}
+
+// slug returns the lower case name having only letters, digits and dashes.
+func slug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func inlineCode(s string) string {
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		return fence + " " + s + " " + fence
+	}
+	return fence + s + fence
+}
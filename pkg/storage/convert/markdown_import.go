@@ -0,0 +1,423 @@
+package convert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	keyValue  = regexp.MustCompile(`^(?:[-*+]\s+)?([^\s:|>#][^:]*?)\s*:\s+(.*)$`)
+	checkItem = regexp.MustCompile(`^[-*+]\s+\[([ xX])\]\s+(.*)$`)
+	tableSep  = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?$`)
+	tagLine   = regexp.MustCompile("^(`[^`]+`\\s*)+$")
+	listItem  = regexp.MustCompile(`^[-*+]\s+`)
+)
+
+// Document is the note and its sections converted from other format. The
+// unmapped has the content which is not converted.
+type Document struct {
+	Note     *notestore.WritableNote
+	Sections []*sectionstore.WritableSection
+	Unmapped []string
+}
+
+// ParseMarkdown converts the markdown document into the note and sections.
+// The yaml front matter has the labels and metadata of note, the '#'
+// heading is the note name and the text below it is the description. Each
+// '##' heading is the section, its key/value lines or definition list go
+// to the section data. The checklist, table, code block and other text are
+// converted to the section of the same kind. The front matter title wins
+// over the '#' heading. The note without heading is named after the file.
+func ParseMarkdown(file string, data []byte) (*Document, error) {
+	text := strings.ReplaceAll(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), "\r\n", "\n")
+	doc := &Document{Note: new(notestore.WritableNote)}
+
+	lines, err := splitFrontMatter(strings.Split(text, "\n"), doc)
+	if err != nil {
+		return nil, err
+	}
+	desc := splitHeadings(lines, doc)
+
+	if d := strings.TrimSpace(strings.Join(desc, "\n")); len(d) > 0 {
+		if len(doc.Note.Description) > 0 {
+			doc.Unmapped = append(doc.Unmapped, "text below the heading, the front matter has the description")
+		} else {
+			doc.Note.Description = d
+		}
+	}
+	if len(doc.Note.Name) == 0 {
+		doc.Note.Name = strings.TrimSuffix(path.Base(file), path.Ext(file))
+	}
+	return doc, nil
+}
+
+// splitFrontMatter parses the front matter at the top of document, and
+// returns the lines below it.
+func splitFrontMatter(lines []string, doc *Document) ([]string, error) {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return lines, nil
+	}
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if l := strings.TrimSpace(lines[i]); l == "---" || l == "..." {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil, errors.New("front matter is not closed")
+	}
+	if err := parseFrontMatter(strings.Join(lines[1:end], "\n"), doc); err != nil {
+		return nil, err
+	}
+	return lines[end+1:], nil
+}
+
+// splitHeadings adds the section of each heading below the note heading,
+// and returns the description lines above the first section. The heading
+// in code block is the code itself.
+func splitHeadings(lines []string, doc *Document) []string {
+	var (
+		desc    []string
+		body    []string
+		section *sectionstore.WritableSection
+		fence   string
+		titled  bool
+	)
+	flush := func() {
+		if section != nil {
+			parseSection(section, body, doc)
+			doc.Sections = append(doc.Sections, section)
+		}
+		body = nil
+	}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch f := fenceOf(trimmed); {
+		case len(fence) > 0:
+			if closes(trimmed, fence) {
+				fence = utils.Empty
+			}
+		case len(f) > 0:
+			fence = f
+		default:
+			level, heading := headingOf(trimmed)
+			switch {
+			case level == 1 && section == nil && !titled:
+				titled = true
+				setHeading(doc, heading)
+				continue
+			case level == 1 || level == 2:
+				flush()
+				section = &sectionstore.WritableSection{Name: heading}
+				continue
+			}
+		}
+		if section == nil {
+			desc = append(desc, line)
+		} else {
+			body = append(body, line)
+		}
+	}
+	flush()
+	return desc
+}
+
+// setHeading names the note after the first heading, the front matter
+// name wins over it.
+func setHeading(doc *Document, heading string) {
+	if len(doc.Note.Name) == 0 {
+		doc.Note.Name = heading
+	} else if heading != doc.Note.Name {
+		doc.Unmapped = append(doc.Unmapped, fmt.Sprintf("heading '%s', the front matter has the name", heading))
+	}
+}
+
+// parseFrontMatter sets the labels, metadata, name and description of note
+// from the front matter. The other scalar values go to the metadata, and
+// the fields of markdown export are skipped.
+func parseFrontMatter(text string, doc *Document) error {
+	fm := make(yaml.MapSlice, 0)
+	if err := yaml.Unmarshal([]byte(text), &fm); err != nil {
+		return utils.Error("front matter is not valid yaml", err)
+	}
+
+	n := doc.Note
+	for _, item := range fm {
+		key := fmt.Sprint(item.Key)
+		switch strings.ToLower(key) {
+		case "id", "created", "updated":
+		case "labels", "tags":
+			n.Labels = append(n.Labels, toStrings(item.Value)...)
+		case "title", "name":
+			n.Name = strings.TrimSpace(fmt.Sprint(item.Value))
+		case "description", "desc":
+			n.Description = strings.TrimSpace(fmt.Sprint(item.Value))
+		case "metadata":
+			values, ok := item.Value.(yaml.MapSlice)
+			if !ok {
+				doc.Unmapped = append(doc.Unmapped, "front matter 'metadata' is not map")
+				continue
+			}
+			for _, v := range values {
+				setMetadata(n, fmt.Sprint(v.Key), v.Value, doc)
+			}
+		default:
+			setMetadata(n, key, item.Value, doc)
+		}
+	}
+	return nil
+}
+
+func setMetadata(n *notestore.WritableNote, key string, value interface{}, doc *Document) {
+	switch value.(type) {
+	case yaml.MapSlice, []interface{}:
+		doc.Unmapped = append(doc.Unmapped, fmt.Sprintf("front matter '%s' is not scalar", key))
+		return
+	}
+	if n.Metadata == nil {
+		n.Metadata = make(map[string]string)
+	}
+	if value == nil {
+		value = utils.Empty
+	}
+	n.Metadata[key] = fmt.Sprint(value)
+}
+
+// parseSection sets the labels and payload of section from its body. The
+// inline code line below the heading has the labels, and the links list of
+// markdown export is skipped.
+func parseSection(s *sectionstore.WritableSection, body []string, doc *Document) {
+	lines := trimLines(body)
+	if len(lines) > 0 && tagLine.MatchString(strings.TrimSpace(lines[0])) {
+		for _, tag := range strings.Fields(strings.ReplaceAll(lines[0], "`", " ")) {
+			s.Labels = append(s.Labels, tag)
+		}
+		lines = trimLines(lines[1:])
+	}
+	for i, l := range lines {
+		if strings.TrimSpace(l) == "Links:" && allMatch(lines[i+1:], listItem) {
+			doc.Unmapped = append(doc.Unmapped, fmt.Sprintf("links of section '%s'", s.Name))
+			lines = trimLines(lines[:i])
+			break
+		}
+	}
+	if len(lines) > 0 {
+		setPayload(s, lines)
+	}
+}
+
+// setPayload sets the section kind and payload converted from the body.
+// The body not matching other kinds is the markdown text.
+func setPayload(s *sectionstore.WritableSection, lines []string) {
+	if code, ok := parseCode(lines); ok {
+		s.Kind, s.Code = sectionstore.KindCode, code
+		return
+	}
+	if allMatch(lines, checkItem) {
+		s.Kind = sectionstore.KindChecklist
+		for _, l := range lines {
+			if m := checkItem.FindStringSubmatch(strings.TrimSpace(l)); m != nil {
+				s.Checklist = append(s.Checklist, &sectionstore.ChecklistItem{Text: m[2], Done: m[1] != " "})
+			}
+		}
+		return
+	}
+	if columns, rows, ok := parseTable(lines); ok {
+		if len(columns) == 2 && strings.EqualFold(columns[0], "key") && strings.EqualFold(columns[1], "value") {
+			s.Data = make(map[string]string, len(rows))
+			for _, r := range rows {
+				s.Data[r[0]] = r[1]
+			}
+			return
+		}
+		s.Kind, s.Table = sectionstore.KindTable, &sectionstore.Table{Columns: columns, Rows: rows}
+		return
+	}
+	if data, ok := parseData(lines); ok {
+		s.Data = data
+		return
+	}
+	s.Kind = sectionstore.KindText
+	s.Text = &sectionstore.Text{Format: sectionstore.TextFormatMarkdown, Body: strings.Join(lines, "\n")}
+}
+
+// parseData parses the key/value lines and the definition list, it fails
+// when any line is not the part of them.
+func parseData(lines []string) (map[string]string, bool) {
+	data := make(map[string]string)
+	key := utils.Empty
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case len(trimmed) == 0:
+			key = utils.Empty
+		case len(key) > 0 && strings.HasPrefix(line, "    "):
+			data[key] += "\n" + trimmed
+		case strings.HasPrefix(trimmed, ": ") && len(key) > 0 && len(data[key]) == 0:
+			data[key] = strings.TrimSpace(trimmed[1:])
+		case i+1 < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i+1]), ": ") && !strings.HasPrefix(line, " "):
+			key = cleanKey(trimmed)
+			data[key] = utils.Empty
+		default:
+			m := keyValue.FindStringSubmatch(trimmed)
+			if m == nil || strings.HasPrefix(line, " ") {
+				return nil, false
+			}
+			key = cleanKey(m[1])
+			data[key] = strings.TrimSpace(m[2])
+		}
+	}
+	return data, len(data) > 0
+}
+
+// parseTable parses the table having the header and separator lines, the
+// rows are cut or padded to the column count.
+func parseTable(lines []string) ([]string, [][]string, bool) {
+	if len(lines) < 2 || !tableSep.MatchString(strings.TrimSpace(lines[1])) {
+		return nil, nil, false
+	}
+	for _, l := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(l), "|") {
+			return nil, nil, false
+		}
+	}
+
+	columns := splitRow(lines[0])
+	rows := make([][]string, 0, len(lines)-2)
+	for _, l := range lines[2:] {
+		cells := splitRow(l)
+		row := make([]string, len(columns))
+		copy(row, cells)
+		rows = append(rows, row)
+	}
+	return columns, rows, true
+}
+
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, cell.String())
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	cells = append(cells, cell.String())
+	for i, c := range cells {
+		cells[i] = strings.ReplaceAll(strings.TrimSpace(c), "<br>", "\n")
+	}
+	return cells
+}
+
+// parseCode parses the body having only the fenced code block.
+func parseCode(lines []string) (*sectionstore.Code, bool) {
+	first, last := strings.TrimSpace(lines[0]), strings.TrimSpace(lines[len(lines)-1])
+	fence := fenceOf(first)
+	if len(fence) == 0 || len(lines) < 2 || !closes(last, fence) {
+		return nil, false
+	}
+	for _, l := range lines[1 : len(lines)-1] {
+		if closes(strings.TrimSpace(l), fence) {
+			return nil, false
+		}
+	}
+	return &sectionstore.Code{
+		Language: strings.TrimSpace(strings.TrimLeft(first, fence[:1])),
+		Source:   strings.Join(lines[1:len(lines)-1], "\n"),
+	}, true
+}
+
+// fenceOf returns the code fence starting the line, like '```' or '~~~~'.
+func fenceOf(line string) string {
+	for _, c := range []string{"`", "~"} {
+		n := len(line) - len(strings.TrimLeft(line, c))
+		if n >= 3 {
+			return line[:n]
+		}
+	}
+	return utils.Empty
+}
+
+// closes tells whether the line closes the code block of the fence.
+func closes(line, fence string) bool {
+	return strings.HasPrefix(line, fence) && len(strings.Trim(line, fence[:1])) == 0
+}
+
+// headingOf returns the level and text of atx heading line.
+func headingOf(line string) (int, string) {
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	if level == 0 || level > 6 || (len(line) > level && line[level] != ' ') {
+		return 0, utils.Empty
+	}
+	return level, strings.TrimSpace(strings.TrimRight(strings.TrimSpace(line[level:]), "#"))
+}
+
+func cleanKey(key string) string {
+	return strings.Trim(strings.TrimSpace(key), "*_`")
+}
+
+func toStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, strings.TrimSpace(fmt.Sprint(item)))
+		}
+		return values
+	case string:
+		var values []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				values = append(values, item)
+			}
+		}
+		return values
+	case nil:
+		return nil
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+func trimLines(lines []string) []string {
+	for len(lines) > 0 && len(strings.TrimSpace(lines[0])) == 0 {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && len(strings.TrimSpace(lines[len(lines)-1])) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func allMatch(lines []string, re *regexp.Regexp) bool {
+	found := false
+	for _, l := range lines {
+		if t := strings.TrimSpace(l); len(t) > 0 {
+			if !re.MatchString(t) {
+				return false
+			}
+			found = true
+		}
+	}
+	return found
+}
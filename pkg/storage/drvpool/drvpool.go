@@ -0,0 +1,115 @@
+package drvpool
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/signin/userinfo"
+	"github.com/psewda/typing/pkg/signin/userinfo/googleuserinfo"
+	"google.golang.org/api/drive/v3"
+)
+
+// DefaultTTL is the default duration for which the session is reused.
+const DefaultTTL = 10 * time.Minute
+
+// Wrapper wraps the http transport of session client, e.g. to add retry
+// or other resilience behavior on all drive calls.
+type Wrapper func(http.RoundTripper) http.RoundTripper
+
+// Session has the http client and google drive service of a single
+// access token. The session is shared by all requests having the token.
+// The userinfo fetches the user once per session, it is used to key the
+// per user state like locks and cache.
+type Session struct {
+	Client   *http.Client
+	Service  *drive.Service
+	Userinfo userinfo.Userinfo
+	expiry   time.Time
+}
+
+// Pool caches the sessions per access token, so the drive service is not
+// created again on every request. The session is removed after the ttl,
+// and the next request having the same token creates a new session.
+type Pool struct {
+	ttl       time.Duration
+	wrappers  []Wrapper
+	sessions  map[string]*Session
+	lastSweep time.Time
+	mutex     sync.Mutex
+}
+
+// Get returns the session of the access token. It creates a new session
+// when the token has no session or the session is expired.
+func (p *Pool) Get(accessToken string) (*Session, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	p.sweep(now)
+	if s, ok := p.sessions[accessToken]; ok && now.Before(s.expiry) {
+		return s, nil
+	}
+
+	client := utils.ClientWithToken(accessToken)
+	for _, wrap := range p.wrappers {
+		client.Transport = wrap(client.Transport)
+	}
+	service, err := drive.New(client)
+	if err != nil {
+		return nil, utils.Error("drive service creation error", err)
+	}
+
+	ui, err := googleuserinfo.New(client)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		Client:   client,
+		Service:  service,
+		Userinfo: userinfo.Cached(ui),
+		expiry:   now.Add(p.ttl),
+	}
+	p.sessions[accessToken] = s
+	return s, nil
+}
+
+// Len returns the count of cached sessions.
+func (p *Pool) Len() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.sessions)
+}
+
+// New creates a new session pool. The zero ttl uses the default ttl. The
+// wrappers are applied on the client transport in order, so the last
+// wrapper gets the request first.
+func New(ttl time.Duration, wrappers ...Wrapper) *Pool {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return &Pool{
+		ttl:       ttl,
+		wrappers:  wrappers,
+		sessions:  make(map[string]*Session),
+		lastSweep: time.Now(),
+	}
+}
+
+// sweep removes all expired sessions. It runs once per ttl, so the
+// tokens which are never used again don't stay in the pool.
+func (p *Pool) sweep(now time.Time) {
+	if now.Sub(p.lastSweep) < p.ttl {
+		return
+	}
+
+	for token, s := range p.sessions {
+		if !now.Before(s.expiry) {
+			delete(p.sessions, token)
+		}
+	}
+	p.lastSweep = now
+}
@@ -0,0 +1,50 @@
+package drvpool_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/pkg/storage/drvpool"
+)
+
+func TestDrvPool(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "drvpool-suite")
+}
+
+var _ = Describe("drive session pool", func() {
+	It("should reuse the session when same token", func() {
+		pool := drvpool.New(time.Minute)
+		s1, err := pool.Get("token")
+		Expect(err).ShouldNot(HaveOccurred())
+		s2, _ := pool.Get("token")
+
+		Expect(s1.Service).ShouldNot(BeNil())
+		Expect(s1.Userinfo).ShouldNot(BeNil())
+		Expect(s2).Should(BeIdenticalTo(s1))
+		Expect(pool.Len()).Should(Equal(1))
+	})
+
+	It("should create separate sessions when different tokens", func() {
+		pool := drvpool.New(time.Minute)
+		s1, _ := pool.Get("token1")
+		s2, _ := pool.Get("token2")
+
+		Expect(s2).ShouldNot(BeIdenticalTo(s1))
+		Expect(s2.Client).ShouldNot(BeIdenticalTo(s1.Client))
+		Expect(pool.Len()).Should(Equal(2))
+	})
+
+	It("should create new session when expired", func() {
+		pool := drvpool.New(10 * time.Millisecond)
+		s1, _ := pool.Get("token1")
+		pool.Get("token2")
+		time.Sleep(20 * time.Millisecond)
+
+		s2, _ := pool.Get("token1")
+		Expect(s2).ShouldNot(BeIdenticalTo(s1))
+		Expect(pool.Len()).Should(Equal(1))
+	})
+})
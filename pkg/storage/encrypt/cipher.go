@@ -0,0 +1,229 @@
+package encrypt
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/psewda/typing/pkg/errs"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// Prefix marks the encrypted value. The value without prefix is
+	// treated as plain text, so the notes can be encrypted gradually.
+	Prefix = "enc:"
+
+	// MinPassphraseLength is the min length of user passphrase.
+	MinPassphraseLength = 8
+
+	saltSize   = 16
+	keySize    = 32
+	iterations = 100000
+	maxKeys    = 1000
+)
+
+// keys caches the derived keys of all ciphers, because the key derivation
+// is slow by design.
+var keys = newKeyCache(maxKeys)
+
+// Cipher encrypts and decrypts the values with AES-GCM. The key is derived
+// from the user passphrase with PBKDF2. Each passphrase uses a random salt
+// for encryption, and the salt is stored along with the encrypted value.
+// The cipher lives only for the request, only the derived keys outlive it.
+type Cipher struct {
+	passphrase []byte
+	salt       []byte
+
+	// fallback decrypts the values which the cipher can't decrypt
+	fallback *Cipher
+}
+
+// keyCache is the LRU cache of derived keys. The key is found by the hash
+// of its salt and passphrase, so the cache never has the passphrase.
+type keyCache struct {
+	max     int
+	order   *list.List
+	entries map[[sha256.Size]byte]*list.Element
+	mutex   sync.Mutex
+}
+
+// derivedKey is the key derived from the passphrase and salt.
+type derivedKey struct {
+	id   [sha256.Size]byte
+	salt []byte
+	aead cipher.AEAD
+}
+
+// Encrypt returns the base64 encoded encrypted value having the prefix.
+func (c *Cipher) Encrypt(value string) (string, error) {
+	aead, err := c.getAEAD(c.salt)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	// the value layout is salt, nonce and sealed data
+	out := make([]byte, 0, saltSize+len(nonce)+len(value)+aead.Overhead())
+	out = append(out, c.salt...)
+	out = append(out, nonce...)
+	out = aead.Seal(out, nonce, []byte(value), nil)
+	return Prefix + base64.RawURLEncoding.EncodeToString(out), nil
+}
+
+// Decrypt returns the plain value of the encrypted value. The value without
+// prefix is returned as it is. It returns encryption error when the key is
+// wrong or the value is corrupted.
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	in, err := base64.RawURLEncoding.DecodeString(value[len(Prefix):])
+	if err != nil || len(in) < saltSize {
+		return "", errs.NewEncryptionError("encrypted value is corrupted")
+	}
+
+	aead, err := c.getAEAD(in[:saltSize])
+	if err != nil {
+		return "", err
+	}
+
+	in = in[saltSize:]
+	if len(in) < aead.NonceSize() {
+		return "", errs.NewEncryptionError("encrypted value is corrupted")
+	}
+	plain, err := aead.Open(nil, in[:aead.NonceSize()], in[aead.NonceSize():], nil)
+	if err != nil {
+		if c.fallback != nil {
+			return c.fallback.Decrypt(value)
+		}
+		return "", errs.NewEncryptionError("encryption key is wrong or value is corrupted")
+	}
+	return string(plain), nil
+}
+
+// IsEncrypted checks the value is encrypted by any cipher.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// NewCipher creates a new cipher for the passphrase. The cipher of the
+// same passphrase encrypts with the cached key while it is in the cache.
+func NewCipher(passphrase string) (*Cipher, error) {
+	if len(passphrase) < MinPassphraseLength {
+		return nil, errors.New("passphrase must be at least 8 chars")
+	}
+
+	c := &Cipher{passphrase: []byte(passphrase)}
+	if key := keys.get(keyID(c.passphrase, nil)); key != nil {
+		c.salt = key.salt
+		return c, nil
+	}
+
+	c.salt = make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, c.salt); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// newFallback returns the cipher which decrypts the values using either
+// the old or the new cipher. It encrypts the values using the old cipher.
+func newFallback(from, to *Cipher) *Cipher {
+	if from == nil {
+		return to
+	}
+	return &Cipher{
+		passphrase: from.passphrase,
+		salt:       from.salt,
+		fallback:   to,
+	}
+}
+
+func (c *Cipher) getAEAD(salt []byte) (cipher.AEAD, error) {
+	id := keyID(c.passphrase, salt)
+	if key := keys.get(id); key != nil {
+		return key.aead, nil
+	}
+
+	key := pbkdf2.Key(c.passphrase, salt, iterations, keySize, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	salt = append([]byte(nil), salt...)
+	keys.add(&derivedKey{id: id, salt: salt, aead: aead})
+	if bytes.Equal(salt, c.salt) {
+		// the encryption key is found by the passphrase alone
+		keys.add(&derivedKey{id: keyID(c.passphrase, nil), salt: salt, aead: aead})
+	}
+	return aead, nil
+}
+
+// keyID returns the cache id of the key, the empty salt gives the id of
+// encryption key of the passphrase.
+func keyID(passphrase, salt []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write(passphrase)
+	var id [sha256.Size]byte
+	copy(id[:], h.Sum(nil))
+	return id
+}
+
+func newKeyCache(max int) *keyCache {
+	return &keyCache{
+		max:     max,
+		order:   list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+func (k *keyCache) get(id [sha256.Size]byte) *derivedKey {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	e, ok := k.entries[id]
+	if !ok {
+		return nil
+	}
+	k.order.MoveToFront(e)
+	return e.Value.(*derivedKey)
+}
+
+// add caches the key, the least recently used key is evicted when the
+// cache is full.
+func (k *keyCache) add(key *derivedKey) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if e, ok := k.entries[key.id]; ok {
+		e.Value = key
+		k.order.MoveToFront(e)
+		return
+	}
+	k.entries[key.id] = k.order.PushFront(key)
+	if k.order.Len() > k.max {
+		last := k.order.Back()
+		k.order.Remove(last)
+		delete(k.entries, last.Value.(*derivedKey).id)
+	}
+}
@@ -0,0 +1,254 @@
+package encrypt_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/schema"
+	"github.com/psewda/typing/pkg/storage/encrypt"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+func TestEncrypt(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "encrypt-suite")
+}
+
+var _ = Describe("end-to-end encryption", func() {
+	var (
+		mockCtrl         *gomock.Controller
+		mockNotestore    *mocks.MockNotestore
+		mockSectionstore *mocks.MockSectionstore
+		cipher           *encrypt.Cipher
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockNotestore = mocks.NewMockNotestore(mockCtrl)
+		mockSectionstore = mocks.NewMockSectionstore(mockCtrl)
+		cipher, _ = encrypt.NewCipher("passphrase")
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	// toSection mimics the sectionstore which saves the writable section
+	toSection := func(nid string, s *sectionstore.WritableSection) (*sectionstore.Section, error) {
+		return &sectionstore.Section{
+			ID: "sid", Name: s.Name, Data: s.Data, Kind: s.Kind,
+			Text: s.Text, Checklist: s.Checklist, Table: s.Table, Code: s.Code,
+		}, nil
+	}
+
+	Context("cipher", func() {
+		It("should decrypt the encrypted value", func() {
+			encrypted, err := cipher.Encrypt("value")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(encrypted).Should(HavePrefix(encrypt.Prefix))
+			Expect(encrypted).ShouldNot(ContainSubstring("value"))
+
+			plain, err := cipher.Decrypt(encrypted)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(plain).Should(Equal("value"))
+		})
+
+		It("should return plain value as it is", func() {
+			plain, err := cipher.Decrypt("value")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(plain).Should(Equal("value"))
+		})
+
+		It("should return encryption error when wrong key", func() {
+			encrypted, _ := cipher.Encrypt("value")
+			other, _ := encrypt.NewCipher("other-passphrase")
+			_, err := other.Decrypt(encrypted)
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewEncryptionError("msg")))
+		})
+
+		It("should encrypt with cached key when new cipher of same passphrase", func() {
+			encrypted, _ := cipher.Encrypt("value")
+			same, _ := encrypt.NewCipher("passphrase")
+			again, _ := same.Encrypt("value")
+
+			// the encrypted value starts with the salt of key
+			salt := len(encrypt.Prefix) + 20
+			Expect(again[:salt]).Should(Equal(encrypted[:salt]))
+			plain, err := same.Decrypt(encrypted)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(plain).Should(Equal("value"))
+		})
+
+		It("should return encryption error when corrupted value", func() {
+			_, err := cipher.Decrypt(encrypt.Prefix + "!!")
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewEncryptionError("msg")))
+		})
+
+		It("should return error when short passphrase", func() {
+			_, err := encrypt.NewCipher("short")
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("sectionstore decorator", func() {
+		It("should pass only encrypted content to sectionstore", func() {
+			mockSectionstore.EXPECT().GetSchema(gomock.Any(), "nid").Return(nil, errs.NewNotFoundError("error"))
+			mockSectionstore.EXPECT().Create(gomock.Any(), "nid", gomock.Any()).DoAndReturn(
+				func(_ context.Context, nid string, s *sectionstore.WritableSection) (*sectionstore.Section, error) {
+					Expect(s.Name).Should(Equal("section"))
+					Expect(s.Data["item1"]).Should(HavePrefix(encrypt.Prefix))
+					Expect(s.Text.Body).Should(HavePrefix(encrypt.Prefix))
+					return toSection(nid, s)
+				})
+
+			ss := encrypt.NewSectionstore(mockSectionstore, cipher)
+			section, err := ss.Create(context.Background(), "nid", &sectionstore.WritableSection{
+				Name: "section",
+				Data: map[string]string{"item1": "value1"},
+				Kind: sectionstore.KindText,
+				Text: &sectionstore.Text{Body: "secret"},
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(section.Data).Should(HaveKeyWithValue("item1", "value1"))
+			Expect(section.Text.Body).Should(Equal("secret"))
+		})
+
+		It("should apply the query on decrypted sections", func() {
+			value, _ := cipher.Encrypt("value1")
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "nid", nil).Return([]*sectionstore.Section{
+				{ID: "sid1", Data: map[string]string{"item1": value}},
+				{ID: "sid2", Data: map[string]string{"item1": "other"}},
+			}, nil)
+
+			ss := encrypt.NewSectionstore(mockSectionstore, cipher)
+			sections, err := ss.GetAll(context.Background(), "nid", &sectionstore.Query{
+				Data: map[string]string{"item1": "value1"},
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sections).Should(HaveLen(1))
+			Expect(sections[0].ID).Should(Equal("sid1"))
+		})
+
+		It("should check the schema on plain data", func() {
+			min := 18.0
+			sch := &schema.Schema{Properties: map[string]*schema.Schema{
+				"age": {Type: schema.TypeInteger, Minimum: &min},
+			}}
+			mockSectionstore.EXPECT().SetSchema(gomock.Any(), "nid", gomock.Any()).DoAndReturn(
+				func(_ context.Context, nid string, s *schema.Schema) error {
+					Expect(s.Properties["age"].Type).Should(BeEmpty())
+					Expect(s.Properties["age"].Description).Should(HavePrefix(encrypt.Prefix))
+					sch = s
+					return nil
+				})
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "nid", nil).Return(nil, nil)
+			mockSectionstore.EXPECT().GetSchema(gomock.Any(), "nid").DoAndReturn(
+				func(_ context.Context, nid string) (*schema.Schema, error) { return sch, nil }).AnyTimes()
+
+			ss := encrypt.NewSectionstore(mockSectionstore, cipher)
+			Expect(ss.SetSchema(context.Background(), "nid", sch)).Should(Succeed())
+
+			decrypted, err := ss.GetSchema(context.Background(), "nid")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(decrypted.Properties["age"].Type).Should(Equal(schema.TypeInteger))
+
+			_, err = ss.Create(context.Background(), "nid", &sectionstore.WritableSection{
+				Name: "section",
+				Data: map[string]string{"age": "12"},
+			})
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+		})
+
+		It("should return encryption error when wrong key", func() {
+			other, _ := encrypt.NewCipher("other-passphrase")
+			value, _ := other.Encrypt("value1")
+			mockSectionstore.EXPECT().Get(gomock.Any(), "nid", "sid").Return(&sectionstore.Section{
+				ID: "sid", Data: map[string]string{"item1": value},
+			}, nil)
+
+			_, err := encrypt.NewSectionstore(mockSectionstore, cipher).Get(context.Background(), "nid", "sid")
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewEncryptionError("msg")))
+		})
+
+		It("should return validation error when encrypted content is too long", func() {
+			mockSectionstore.EXPECT().GetSchema(gomock.Any(), "nid").Return(nil, errs.NewNotFoundError("error"))
+
+			_, err := encrypt.NewSectionstore(mockSectionstore, cipher).Create(context.Background(), "nid", &sectionstore.WritableSection{
+				Name: "section",
+				Data: map[string]string{"item1": strings.Repeat("a", 2000)},
+			})
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+		})
+	})
+
+	Context("notestore decorator", func() {
+		It("should encrypt the note description", func() {
+			mockNotestore.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, n *notestore.WritableNote) (*notestore.Note, error) {
+					Expect(n.Name).Should(Equal("note"))
+					Expect(n.Description).Should(HavePrefix(encrypt.Prefix))
+					return &notestore.Note{ID: "nid", Name: n.Name, Description: n.Description}, nil
+				})
+
+			note, err := encrypt.NewNotestore(mockNotestore, cipher).Create(context.Background(), &notestore.WritableNote{
+				Name:        "note",
+				Description: "secret",
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(note.Description).Should(Equal("secret"))
+		})
+	})
+
+	Context("key rotation", func() {
+		It("should re-encrypt the note using new key", func() {
+			to, _ := encrypt.NewCipher("new-passphrase")
+			desc, _ := cipher.Encrypt("secret")
+			value, _ := cipher.Encrypt("value1")
+
+			mockNotestore.EXPECT().Get(gomock.Any(), "nid").Return(&notestore.Note{ID: "nid", Name: "note", Description: desc}, nil)
+			mockNotestore.EXPECT().Update(gomock.Any(), "nid", gomock.Any()).DoAndReturn(
+				func(_ context.Context, id string, n *notestore.WritableNote) (*notestore.Note, error) {
+					plain, err := to.Decrypt(n.Description)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(plain).Should(Equal("secret"))
+					return &notestore.Note{ID: id, Name: n.Name, Description: n.Description}, nil
+				})
+			mockSectionstore.EXPECT().GetSchema(gomock.Any(), "nid").Return(nil, errs.NewNotFoundError("error"))
+			mockSectionstore.EXPECT().GetAll(gomock.Any(), "nid", nil).Return([]*sectionstore.Section{
+				{ID: "sid", Name: "section", Data: map[string]string{"item1": value}},
+			}, nil)
+			mockSectionstore.EXPECT().Batch(gomock.Any(), "nid", gomock.Any()).DoAndReturn(
+				func(_ context.Context, nid string, ops []*sectionstore.Operation) ([]*sectionstore.OperationResult, error) {
+					Expect(ops).Should(HaveLen(1))
+					Expect(ops[0].ID).Should(Equal("sid"))
+					plain, err := to.Decrypt(ops[0].Section.Data["item1"])
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(plain).Should(Equal("value1"))
+					return nil, nil
+				})
+
+			err := encrypt.Rotate(context.Background(), mockNotestore, mockSectionstore, "nid", cipher, to)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should return encryption error when wrong old key", func() {
+			to, _ := encrypt.NewCipher("new-passphrase")
+			other, _ := encrypt.NewCipher("other-passphrase")
+			desc, _ := other.Encrypt("secret")
+
+			mockNotestore.EXPECT().Get(gomock.Any(), "nid").Return(&notestore.Note{ID: "nid", Description: desc}, nil)
+			err := encrypt.Rotate(context.Background(), mockNotestore, mockSectionstore, "nid", cipher, to)
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewEncryptionError("msg")))
+		})
+	})
+})
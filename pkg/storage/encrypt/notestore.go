@@ -0,0 +1,120 @@
+package encrypt
+
+import (
+	"context"
+
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/storage/notestore"
+)
+
+// Notestore is the notestore decorator which encrypts the note description
+// before passing it to the wrapped notestore. The name, labels and metadata
+// are kept as plain text, because they are stored in file properties
+// having the tight size limits.
+type Notestore struct {
+	notestore notestore.Notestore
+	cipher    *Cipher
+}
+
+// Create encrypts the note description and builds a new note.
+func (ns *Notestore) Create(ctx context.Context, n *notestore.WritableNote) (*notestore.Note, error) {
+	encrypted, err := encryptNote(ns.cipher, n)
+	if err != nil {
+		return nil, err
+	}
+
+	note, err := ns.notestore.Create(ctx, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	return decryptNote(ns.cipher, note)
+}
+
+// GetAll fetches all notes and decrypts their description.
+func (ns *Notestore) GetAll(ctx context.Context) ([]*notestore.Note, error) {
+	notes, err := ns.notestore.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted := make([]*notestore.Note, 0, len(notes))
+	for _, n := range notes {
+		note, err := decryptNote(ns.cipher, n)
+		if err != nil {
+			return nil, err
+		}
+		decrypted = append(decrypted, note)
+	}
+	return decrypted, nil
+}
+
+// Get returns the note having decrypted description.
+func (ns *Notestore) Get(ctx context.Context, id string) (*notestore.Note, error) {
+	note, err := ns.notestore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return decryptNote(ns.cipher, note)
+}
+
+// Update encrypts the note description and saves the note.
+func (ns *Notestore) Update(ctx context.Context, id string, n *notestore.WritableNote) (*notestore.Note, error) {
+	encrypted, err := encryptNote(ns.cipher, n)
+	if err != nil {
+		return nil, err
+	}
+
+	note, err := ns.notestore.Update(ctx, id, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	return decryptNote(ns.cipher, note)
+}
+
+// Delete removes the note.
+func (ns *Notestore) Delete(ctx context.Context, id string) error {
+	return ns.notestore.Delete(ctx, id)
+}
+
+// NewNotestore creates a new encryption decorator of the notestore.
+func NewNotestore(ns notestore.Notestore, c *Cipher) *Notestore {
+	return &Notestore{
+		notestore: ns,
+		cipher:    c,
+	}
+}
+
+func encryptNote(c *Cipher, n *notestore.WritableNote) (*notestore.WritableNote, error) {
+	if n == nil {
+		return nil, errs.NewValidationError("note is nil")
+	}
+	if err := n.Validate(); err != nil {
+		return nil, errs.NewValidationError(err.Error())
+	}
+
+	encrypted := *n
+	if len(n.Description) > 0 {
+		desc, err := c.Encrypt(n.Description)
+		if err != nil {
+			return nil, err
+		}
+		encrypted.Description = desc
+	}
+
+	// encrypted description must still fit in the storage limit
+	if err := encrypted.Validate(); err != nil {
+		return nil, errs.NewValidationError("desc is too long to be encrypted: " + err.Error())
+	}
+	return &encrypted, nil
+}
+
+func decryptNote(c *Cipher, n *notestore.Note) (*notestore.Note, error) {
+	desc, err := c.Decrypt(n.Description)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted := *n
+	decrypted.Description = desc
+	return &decrypted, nil
+}
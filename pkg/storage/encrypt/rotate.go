@@ -0,0 +1,95 @@
+package encrypt
+
+import (
+	"context"
+
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	secstore "github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+// Rotate re-encrypts the note description, sections and schema using the
+// new cipher. The nil old cipher reads the plain note, so it encrypts the
+// note for the first time. The sections are updated in batches, so the
+// failed rotation can leave the note partially rotated; running it again
+// with the same ciphers completes it, because the values encrypted by
+// the new cipher are still readable.
+func Rotate(ctx context.Context, ns notestore.Notestore, ss secstore.Sectionstore, nid string, from, to *Cipher) error {
+	if to == nil {
+		return errs.NewValidationError("new encryption key is required")
+	}
+
+	// the old cipher falls back to the new one for partially rotated note
+	read := newFallback(from, to)
+
+	note, err := ns.Get(ctx, nid)
+	if err != nil {
+		return err
+	}
+	if len(note.Description) > 0 {
+		plain, err := decryptNote(read, note)
+		if err != nil {
+			return err
+		}
+		if _, err := NewNotestore(ns, to).Update(ctx, nid, plain.Writable()); err != nil {
+			return err
+		}
+	}
+
+	// the schema is read before sections are rotated
+	sch, err := NewSectionstore(ss, read).getSchema(ctx, nid)
+	if err != nil {
+		return err
+	}
+
+	if err := rotateSections(ctx, ss, nid, read, to); err != nil {
+		return err
+	}
+
+	if sch != nil {
+		encrypted, err := encryptSchema(to, sch)
+		if err != nil {
+			return err
+		}
+		return ss.SetSchema(ctx, nid, encrypted)
+	}
+	return nil
+}
+
+// rotateSections re-encrypts all sections of the note, the updates are
+// sent in batches of max operations.
+func rotateSections(ctx context.Context, ss secstore.Sectionstore, nid string, read, to *Cipher) error {
+	sections, err := ss.GetAll(ctx, nid, nil)
+	if err != nil {
+		return err
+	}
+
+	var ops []*secstore.Operation
+	for _, s := range sections {
+		plain, err := decryptSection(read, s)
+		if err != nil {
+			return err
+		}
+		encrypted, err := encryptWritable(to, plain.Writable())
+		if err != nil {
+			return err
+		}
+		ops = append(ops, &secstore.Operation{
+			Type:    secstore.OperationUpdate,
+			ID:      s.ID,
+			Section: encrypted,
+		})
+	}
+
+	for len(ops) > 0 {
+		n := len(ops)
+		if n > secstore.MaxOperations {
+			n = secstore.MaxOperations
+		}
+		if _, err := ss.Batch(ctx, nid, ops[:n]); err != nil {
+			return err
+		}
+		ops = ops[n:]
+	}
+	return nil
+}
@@ -0,0 +1,370 @@
+package encrypt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/schema"
+	secstore "github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+// Sectionstore is the sectionstore decorator which encrypts the section
+// content before passing it to the wrapped sectionstore. The data values,
+// text body, checklist items, table rows and code source are encrypted.
+// The name, labels, metadata and all keys are kept as plain text, because
+// they are needed for listing and have the tight size limits.
+//
+// The encryption makes the values longer, so the values close to the size
+// limits can fail the validation of wrapped sectionstore.
+type Sectionstore struct {
+	sectionstore secstore.Sectionstore
+	cipher       *Cipher
+}
+
+// Create encrypts the section and adds it in the note.
+func (ss *Sectionstore) Create(ctx context.Context, nid string, s *secstore.WritableSection) (*secstore.Section, error) {
+	encrypted, err := ss.encryptSection(ctx, nid, s)
+	if err != nil {
+		return nil, err
+	}
+
+	section, err := ss.sectionstore.Create(ctx, nid, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	return decryptSection(ss.cipher, section)
+}
+
+// GetAll fetches and decrypts all sections of the note. The query is
+// applied after decryption, so it works on the plain values.
+func (ss *Sectionstore) GetAll(ctx context.Context, nid string, q *secstore.Query) ([]*secstore.Section, error) {
+	sections, err := ss.sectionstore.GetAll(ctx, nid, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted := make([]*secstore.Section, 0, len(sections))
+	for _, s := range sections {
+		section, err := decryptSection(ss.cipher, s)
+		if err != nil {
+			return nil, err
+		}
+		decrypted = append(decrypted, section)
+	}
+	return q.Apply(decrypted), nil
+}
+
+// Get returns the decrypted section.
+func (ss *Sectionstore) Get(ctx context.Context, nid, sid string) (*secstore.Section, error) {
+	section, err := ss.sectionstore.Get(ctx, nid, sid)
+	if err != nil {
+		return nil, err
+	}
+	return decryptSection(ss.cipher, section)
+}
+
+// Update encrypts the section and saves it in the note.
+func (ss *Sectionstore) Update(ctx context.Context, nid, sid string, s *secstore.WritableSection) (*secstore.Section, error) {
+	encrypted, err := ss.encryptSection(ctx, nid, s)
+	if err != nil {
+		return nil, err
+	}
+
+	section, err := ss.sectionstore.Update(ctx, nid, sid, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	return decryptSection(ss.cipher, section)
+}
+
+// Delete removes the section from note.
+func (ss *Sectionstore) Delete(ctx context.Context, nid, sid string) error {
+	return ss.sectionstore.Delete(ctx, nid, sid)
+}
+
+// Batch encrypts the sections of all operations and applies them.
+func (ss *Sectionstore) Batch(ctx context.Context, nid string, ops []*secstore.Operation) ([]*secstore.OperationResult, error) {
+	if err := secstore.CheckOperations(ops); err != nil {
+		return nil, err
+	}
+
+	sch, err := ss.getSchema(ctx, nid)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := make([]*secstore.Operation, 0, len(ops))
+	for i, op := range ops {
+		eop := &secstore.Operation{
+			Type: op.Type,
+			ID:   op.ID,
+		}
+		if op.Section != nil {
+			if err := checkSchema(sch, op.Section); err != nil {
+				msg := fmt.Sprintf("operation %d: %s", i, err.Error())
+				return nil, errs.NewValidationError(msg)
+			}
+			if eop.Section, err = encryptWritable(ss.cipher, op.Section); err != nil {
+				return nil, err
+			}
+		}
+		encrypted = append(encrypted, eop)
+	}
+
+	results, err := ss.sectionstore.Batch(ctx, nid, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		if r.Section != nil {
+			if r.Section, err = decryptSection(ss.cipher, r.Section); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return results, nil
+}
+
+// GetSchema returns the decrypted schema of the note.
+func (ss *Sectionstore) GetSchema(ctx context.Context, nid string) (*schema.Schema, error) {
+	sch, err := ss.sectionstore.GetSchema(ctx, nid)
+	if err != nil {
+		return nil, err
+	}
+	return decryptSchema(ss.cipher, sch)
+}
+
+// SetSchema encrypts the schema and declares it in the note. The wrapped
+// sectionstore gets only the required and allowed data keys, because the
+// other rules can't be checked on the encrypted values. Those rules are
+// checked by the decorator itself.
+func (ss *Sectionstore) SetSchema(ctx context.Context, nid string, s *schema.Schema) error {
+	if s == nil {
+		return ss.sectionstore.SetSchema(ctx, nid, nil)
+	}
+	if err := s.Check(); err != nil {
+		return errs.NewValidationError(err.Error())
+	}
+
+	// existing sections must satisfy the new schema
+	sections, err := ss.GetAll(ctx, nid, nil)
+	if err != nil {
+		return err
+	}
+	for _, section := range sections {
+		if err := s.Validate(section.Data); err != nil {
+			msg := fmt.Sprintf("section with id '%s' violates schema: %s", section.ID, err.Error())
+			return errs.NewValidationError(msg)
+		}
+	}
+
+	encrypted, err := encryptSchema(ss.cipher, s)
+	if err != nil {
+		return err
+	}
+	return ss.sectionstore.SetSchema(ctx, nid, encrypted)
+}
+
+// NewSectionstore creates a new encryption decorator of the sectionstore.
+func NewSectionstore(ss secstore.Sectionstore, c *Cipher) *Sectionstore {
+	return &Sectionstore{
+		sectionstore: ss,
+		cipher:       c,
+	}
+}
+
+func (ss *Sectionstore) encryptSection(ctx context.Context, nid string, s *secstore.WritableSection) (*secstore.WritableSection, error) {
+	if s == nil {
+		return nil, errs.NewValidationError("section is nil")
+	}
+	if err := s.Validate(); err != nil {
+		return nil, errs.NewValidationError(err.Error())
+	}
+
+	// the schema rules are checked on plain data values
+	sch, err := ss.getSchema(ctx, nid)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSchema(sch, s); err != nil {
+		return nil, err
+	}
+	return encryptWritable(ss.cipher, s)
+}
+
+func (ss *Sectionstore) getSchema(ctx context.Context, nid string) (*schema.Schema, error) {
+	sch, err := ss.GetSchema(ctx, nid)
+	if err != nil {
+		if _, ok := err.(*errs.NotFoundError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return sch, nil
+}
+
+func checkSchema(sch *schema.Schema, s *secstore.WritableSection) error {
+	if sch != nil {
+		if err := sch.Validate(s.Data); err != nil {
+			return errs.NewValidationError(err.Error())
+		}
+	}
+	return nil
+}
+
+func encryptWritable(c *Cipher, s *secstore.WritableSection) (*secstore.WritableSection, error) {
+	e := &encrypter{fn: c.Encrypt}
+	encrypted := &secstore.WritableSection{
+		Name:      s.Name,
+		Labels:    s.Labels,
+		Metadata:  s.Metadata,
+		Data:      e.data(s.Data),
+		Kind:      s.Kind,
+		Text:      e.text(s.Text),
+		Checklist: e.checklist(s.Checklist),
+		Table:     e.table(s.Table),
+		Code:      e.code(s.Code),
+		Links:     s.Links,
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	// encrypted values must still fit in the storage limits
+	if err := encrypted.Validate(); err != nil {
+		return nil, errs.NewValidationError("content is too long to be encrypted: " + err.Error())
+	}
+	return encrypted, nil
+}
+
+func decryptSection(c *Cipher, s *secstore.Section) (*secstore.Section, error) {
+	e := &encrypter{fn: c.Decrypt}
+	decrypted := *s
+	decrypted.Data = e.data(s.Data)
+	decrypted.Text = e.text(s.Text)
+	decrypted.Checklist = e.checklist(s.Checklist)
+	decrypted.Table = e.table(s.Table)
+	decrypted.Code = e.code(s.Code)
+	if e.err != nil {
+		return nil, e.err
+	}
+	return &decrypted, nil
+}
+
+func encryptSchema(c *Cipher, s *schema.Schema) (*schema.Schema, error) {
+	encrypted := &schema.Schema{
+		Type:                 s.Type,
+		Required:             s.Required,
+		AdditionalProperties: s.AdditionalProperties,
+	}
+	if len(s.Properties) > 0 {
+		encrypted.Properties = make(map[string]*schema.Schema, len(s.Properties))
+	}
+
+	// the full property schema is kept encrypted in the description
+	for k, p := range s.Properties {
+		j, _ := json.Marshal(p)
+		desc, err := c.Encrypt(string(j))
+		if err != nil {
+			return nil, err
+		}
+		encrypted.Properties[k] = &schema.Schema{Description: desc}
+	}
+	return encrypted, nil
+}
+
+func decryptSchema(c *Cipher, s *schema.Schema) (*schema.Schema, error) {
+	decrypted := *s
+	if len(s.Properties) > 0 {
+		decrypted.Properties = make(map[string]*schema.Schema, len(s.Properties))
+	}
+
+	for k, p := range s.Properties {
+		if p == nil || !IsEncrypted(p.Description) {
+			decrypted.Properties[k] = p
+			continue
+		}
+
+		j, err := c.Decrypt(p.Description)
+		if err != nil {
+			return nil, err
+		}
+		property := new(schema.Schema)
+		if err := json.Unmarshal([]byte(j), property); err != nil {
+			return nil, errs.NewEncryptionError("encrypted schema is corrupted")
+		}
+		decrypted.Properties[k] = property
+	}
+	return &decrypted, nil
+}
+
+// encrypter applies the encryption or decryption function on all content
+// values. It keeps the first error, so the caller checks it only once.
+type encrypter struct {
+	fn  func(string) (string, error)
+	err error
+}
+
+func (e *encrypter) value(v string) string {
+	if e.err != nil || len(v) == 0 {
+		return v
+	}
+	out, err := e.fn(v)
+	if err != nil {
+		e.err = err
+	}
+	return out
+}
+
+func (e *encrypter) data(data map[string]string) map[string]string {
+	if data == nil {
+		return nil
+	}
+	out := make(map[string]string, len(data))
+	for k, v := range data {
+		out[k] = e.value(v)
+	}
+	return out
+}
+
+func (e *encrypter) text(text *secstore.Text) *secstore.Text {
+	if text == nil {
+		return nil
+	}
+	return &secstore.Text{Format: text.Format, Body: e.value(text.Body)}
+}
+
+func (e *encrypter) checklist(checklist []*secstore.ChecklistItem) []*secstore.ChecklistItem {
+	if checklist == nil {
+		return nil
+	}
+	out := make([]*secstore.ChecklistItem, 0, len(checklist))
+	for _, item := range checklist {
+		out = append(out, &secstore.ChecklistItem{Text: e.value(item.Text), Done: item.Done})
+	}
+	return out
+}
+
+func (e *encrypter) table(table *secstore.Table) *secstore.Table {
+	if table == nil {
+		return nil
+	}
+	out := &secstore.Table{Columns: table.Columns}
+	for _, row := range table.Rows {
+		cells := make([]string, 0, len(row))
+		for _, cell := range row {
+			cells = append(cells, e.value(cell))
+		}
+		out.Rows = append(out.Rows, cells)
+	}
+	return out
+}
+
+func (e *encrypter) code(code *secstore.Code) *secstore.Code {
+	if code == nil {
+		return nil
+	}
+	return &secstore.Code{Language: code.Language, Source: e.value(code.Source)}
+}
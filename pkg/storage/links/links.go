@@ -0,0 +1,149 @@
+package links
+
+import (
+	"context"
+
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+// Backlink is the section which links to the note.
+type Backlink struct {
+	NoteID          string `json:"noteId"`
+	NoteName        string `json:"noteName,omitempty"`
+	SectionID       string `json:"sectionId"`
+	SectionName     string `json:"sectionName,omitempty"`
+	TargetSectionID string `json:"targetSectionId,omitempty"`
+}
+
+// DanglingLink is the link whose target note or section doesn't exist.
+type DanglingLink struct {
+	NoteID    string             `json:"noteId"`
+	SectionID string             `json:"sectionId"`
+	Link      *sectionstore.Link `json:"link"`
+}
+
+// Graph resolves the links between notes. The links are stored in
+// sections only, so the graph scans all notes of the user.
+type Graph struct {
+	notestore    notestore.Notestore
+	sectionstore sectionstore.Sectionstore
+}
+
+// Backlinks returns all sections which link to the note or its sections.
+func (g *Graph) Backlinks(ctx context.Context, id string) ([]*Backlink, error) {
+	backlinks := make([]*Backlink, 0)
+	err := g.walk(ctx, func(n *notestore.Note, s *sectionstore.Section) {
+		if s == nil {
+			return
+		}
+		for _, l := range s.Links {
+			if l.NoteID == id {
+				backlinks = append(backlinks, &Backlink{
+					NoteID:          n.ID,
+					NoteName:        n.Name,
+					SectionID:       s.ID,
+					SectionName:     s.Name,
+					TargetSectionID: l.SectionID,
+				})
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return backlinks, nil
+}
+
+// Dangling returns all links whose target note or target section doesn't
+// exist anymore. It is used to find the broken links after deletion.
+func (g *Graph) Dangling(ctx context.Context) ([]*DanglingLink, error) {
+	type source struct {
+		noteID  string
+		section *sectionstore.Section
+	}
+
+	var sources []*source
+	targets := make(map[string]map[string]bool)
+	err := g.walk(ctx, func(n *notestore.Note, s *sectionstore.Section) {
+		if s == nil {
+			targets[n.ID] = make(map[string]bool)
+			return
+		}
+		targets[n.ID][s.ID] = true
+		if len(s.Links) > 0 {
+			sources = append(sources, &source{n.ID, s})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dangling := make([]*DanglingLink, 0)
+	for _, src := range sources {
+		for _, l := range src.section.Links {
+			sections, ok := targets[l.NoteID]
+			if !ok || (len(l.SectionID) > 0 && !sections[l.SectionID]) {
+				dangling = append(dangling, &DanglingLink{
+					NoteID:    src.noteID,
+					SectionID: src.section.ID,
+					Link:      l,
+				})
+			}
+		}
+	}
+	return dangling, nil
+}
+
+// DanglingTo returns all links to the note or its sections. It is used
+// after the note deletion, when all of them are dangling.
+func (g *Graph) DanglingTo(ctx context.Context, id string) ([]*DanglingLink, error) {
+	dangling := make([]*DanglingLink, 0)
+	err := g.walk(ctx, func(n *notestore.Note, s *sectionstore.Section) {
+		if s == nil || n.ID == id {
+			return
+		}
+		for _, l := range s.Links {
+			if l.NoteID == id {
+				dangling = append(dangling, &DanglingLink{
+					NoteID:    n.ID,
+					SectionID: s.ID,
+					Link:      l,
+				})
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dangling, nil
+}
+
+// walk calls the function for each note with a nil section, and then for
+// each section of the note. So the notes without sections are registered.
+func (g *Graph) walk(ctx context.Context, fn func(n *notestore.Note, s *sectionstore.Section)) error {
+	notes, err := g.notestore.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range notes {
+		fn(n, nil)
+		sections, err := g.sectionstore.GetAll(ctx, n.ID, nil)
+		if err != nil {
+			return err
+		}
+		for _, s := range sections {
+			fn(n, s)
+		}
+	}
+	return nil
+}
+
+// New creates a new instance of link graph.
+func New(ns notestore.Notestore, ss sectionstore.Sectionstore) *Graph {
+	return &Graph{
+		notestore:    ns,
+		sectionstore: ss,
+	}
+}
@@ -0,0 +1,121 @@
+package links_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	"github.com/psewda/typing/pkg/storage/links"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+func TestLinks(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "links-suite")
+}
+
+var _ = Describe("link graph", func() {
+	var (
+		mockCtrl         *gomock.Controller
+		mockNotestore    *mocks.MockNotestore
+		mockSectionstore *mocks.MockSectionstore
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockNotestore = mocks.NewMockNotestore(mockCtrl)
+		mockSectionstore = mocks.NewMockSectionstore(mockCtrl)
+
+		mockNotestore.EXPECT().GetAll(gomock.Any()).Return([]*notestore.Note{
+			{ID: "nid1", Name: "note1"},
+			{ID: "nid2", Name: "note2"},
+		}, nil).AnyTimes()
+		mockSectionstore.EXPECT().GetAll(gomock.Any(), "nid1", nil).Return([]*sectionstore.Section{
+			{ID: "sid1", Name: "section1", Links: []*sectionstore.Link{
+				{NoteID: "nid2"},
+				{NoteID: "nid2", SectionID: "sid2"},
+				{NoteID: "nid3"},
+			}},
+		}, nil).AnyTimes()
+		mockSectionstore.EXPECT().GetAll(gomock.Any(), "nid2", nil).Return([]*sectionstore.Section{
+			{ID: "sid2", Name: "section2", Links: []*sectionstore.Link{
+				{NoteID: "nid1", SectionID: "sid9"},
+			}},
+		}, nil).AnyTimes()
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Context("backlinks", func() {
+		It("should return all sections linking to the note", func() {
+			backlinks, err := links.New(mockNotestore, mockSectionstore).Backlinks(context.Background(), "nid2")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(backlinks).Should(HaveLen(2))
+			Expect(backlinks[0].NoteID).Should(Equal("nid1"))
+			Expect(backlinks[0].SectionName).Should(Equal("section1"))
+			Expect(backlinks[1].TargetSectionID).Should(Equal("sid2"))
+		})
+
+		It("should return empty list when no backlinks", func() {
+			backlinks, err := links.New(mockNotestore, mockSectionstore).Backlinks(context.Background(), "nid4")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(backlinks).Should(BeEmpty())
+		})
+
+		It("should return error when sectionstore failure", func() {
+			ss := mocks.NewMockSectionstore(mockCtrl)
+			ss.EXPECT().GetAll(gomock.Any(), gomock.Any(), nil).Return(nil, errors.New("error"))
+			_, err := links.New(mockNotestore, ss).Backlinks(context.Background(), "nid2")
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("dangling links", func() {
+		It("should return links to missing notes and sections", func() {
+			dangling, err := links.New(mockNotestore, mockSectionstore).Dangling(context.Background())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(dangling).Should(HaveLen(2))
+			Expect(dangling[0].SectionID).Should(Equal("sid1"))
+			Expect(dangling[0].Link.NoteID).Should(Equal("nid3"))
+			Expect(dangling[1].SectionID).Should(Equal("sid2"))
+			Expect(dangling[1].Link.SectionID).Should(Equal("sid9"))
+		})
+
+		It("should not return links to existing note without sections", func() {
+			ns := mocks.NewMockNotestore(mockCtrl)
+			ns.EXPECT().GetAll(gomock.Any()).Return([]*notestore.Note{{ID: "nid1"}, {ID: "empty"}}, nil)
+			ss := mocks.NewMockSectionstore(mockCtrl)
+			ss.EXPECT().GetAll(gomock.Any(), "nid1", nil).Return([]*sectionstore.Section{
+				{ID: "sid1", Links: []*sectionstore.Link{{NoteID: "empty"}}},
+			}, nil)
+			ss.EXPECT().GetAll(gomock.Any(), "empty", nil).Return(nil, nil)
+			dangling, err := links.New(ns, ss).Dangling(context.Background())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(dangling).Should(BeEmpty())
+		})
+
+		It("should return error when notestore failure", func() {
+			ns := mocks.NewMockNotestore(mockCtrl)
+			ns.EXPECT().GetAll(gomock.Any()).Return(nil, errors.New("error"))
+			_, err := links.New(ns, mockSectionstore).Dangling(context.Background())
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("dangling links to note", func() {
+		It("should return all links to the note", func() {
+			dangling, err := links.New(mockNotestore, mockSectionstore).DanglingTo(context.Background(), "nid2")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(dangling).Should(HaveLen(2))
+			Expect(dangling[0].NoteID).Should(Equal("nid1"))
+			Expect(dangling[1].Link.SectionID).Should(Equal("sid2"))
+		})
+	})
+})
@@ -0,0 +1,435 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore/drvsectionstore"
+)
+
+const (
+	// BackendDrive is the google drive storage backend.
+	BackendDrive = "drive"
+
+	// BackendFS is the local file system storage backend.
+	BackendFS = "fs"
+)
+
+// Backend is the storage which the notes are migrated from or to. The
+// sections are migrated as the whole note content, so the section ids,
+// dates and the schema are kept as is.
+type Backend struct {
+	Name      string
+	Notestore notestore.Notestore
+	Content   drvsectionstore.Content
+}
+
+// Importer is implemented by the notestore which keeps the id and dates of
+// the migrated note. The notestore without importer assigns new id and
+// dates to the migrated note.
+type Importer interface {
+	// Import saves the note with its id, dates and content.
+	Import(ctx context.Context, n *notestore.Note, content []byte) (*notestore.Note, error)
+}
+
+// Options changes the way of migration run.
+type Options struct {
+	// DryRun reports the notes to be migrated without writing them.
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Verify compares all migrated notes with the source notes after the
+	// migration.
+	Verify bool `json:"verify,omitempty"`
+}
+
+// Report is the outcome of migration run. The dates kept is false when the
+// destination has no importer, its notes are dated by the migration and
+// the source note dates are not migrated.
+type Report struct {
+	From       string      `json:"from"`
+	To         string      `json:"to"`
+	DryRun     bool        `json:"dryRun,omitempty"`
+	Notes      int         `json:"notes"`
+	Copied     int         `json:"copied"`
+	Skipped    int         `json:"skipped"`
+	Sections   int         `json:"sections"`
+	Bytes      int64       `json:"bytes"`
+	DatesKept  bool        `json:"datesKept"`
+	Failures   []*Failure  `json:"failures,omitempty"`
+	Verified   int         `json:"verified,omitempty"`
+	Mismatches []*Mismatch `json:"mismatches,omitempty"`
+}
+
+// Failure is the note failed to migrate, it is migrated again on the
+// next run.
+type Failure struct {
+	NoteID string `json:"noteId"`
+	Name   string `json:"name,omitempty"`
+	Error  string `json:"error"`
+}
+
+// Mismatch is the migrated note field differing from the source note.
+type Mismatch struct {
+	NoteID string `json:"noteId"`
+	Name   string `json:"name,omitempty"`
+	Field  string `json:"field"`
+}
+
+// Migration copies all notes and their content from one backend to other.
+// The progress is saved in the state file after every note, so the
+// interrupted migration resumes from the last note on the next run.
+type Migration struct {
+	from  *Backend
+	to    *Backend
+	state string
+}
+
+// state maps the source note id to the migrated note.
+type state struct {
+	From  string            `json:"from"`
+	To    string            `json:"to"`
+	Notes map[string]*entry `json:"notes"`
+}
+
+type entry struct {
+	ID   string `json:"id"`
+	Done bool   `json:"done,omitempty"`
+}
+
+// Run migrates the notes not migrated yet. The notes are created first, so
+// the links between notes are rewritten with the new note ids while
+// copying the content. The note failure is reported and the run goes on,
+// the authorization and context failures stop the run.
+func (m *Migration) Run(ctx context.Context, o Options) (*Report, error) {
+	if m.from.Name == m.to.Name {
+		return nil, errs.NewValidationError("source and destination backends are the same")
+	}
+	st, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+	notes, err := m.from.Notestore.GetAll(ctx)
+	if err != nil {
+		return nil, utils.Error("source notes retrival error", err)
+	}
+
+	report := &Report{
+		From:   m.from.Name,
+		To:     m.to.Name,
+		DryRun: o.DryRun,
+		Notes:  len(notes),
+	}
+	importer, _ := m.to.Notestore.(Importer)
+	report.DatesKept = importer != nil
+
+	if err := m.create(ctx, notes, st, o, importer, report); err != nil {
+		return nil, err
+	}
+	ids := st.ids()
+	if err := m.copyContent(ctx, notes, st, ids, o, importer, report); err != nil {
+		return nil, err
+	}
+
+	if o.Verify && !o.DryRun {
+		if err := m.verify(ctx, notes, st, ids, importer != nil, report); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// create creates the destination notes not migrated yet. The importer keeps
+// the note id, so only other backends create the notes before the content.
+func (m *Migration) create(ctx context.Context, notes []*notestore.Note, st *state,
+	o Options, importer Importer, report *Report) error {
+	if o.DryRun || importer != nil {
+		return nil
+	}
+	for _, n := range notes {
+		if _, ok := st.Notes[n.ID]; ok {
+			continue
+		}
+		note, err := m.to.Notestore.Create(ctx, n.Writable())
+		if err != nil {
+			if errs.IsFatal(ctx, err) {
+				return err
+			}
+			report.fail(n, err)
+			continue
+		}
+		st.Notes[n.ID] = &entry{ID: note.ID}
+		if err := m.save(st); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyContent copies the content of notes not copied yet, the links between
+// notes are rewritten with the destination note ids.
+func (m *Migration) copyContent(ctx context.Context, notes []*notestore.Note, st *state,
+	ids map[string]string, o Options, importer Importer, report *Report) error {
+	for _, n := range notes {
+		e, ok := st.Notes[n.ID]
+		if ok && e.Done {
+			report.Skipped++
+			continue
+		}
+		if !ok && !o.DryRun && importer == nil {
+			// the note creation failed before
+			continue
+		}
+
+		content, sections, err := m.read(ctx, n.ID, ids)
+		if err != nil {
+			if errs.IsFatal(ctx, err) {
+				return err
+			}
+			report.fail(n, err)
+			continue
+		}
+		if !o.DryRun {
+			if err := m.write(ctx, n, e, content, importer); err != nil {
+				if errs.IsFatal(ctx, err) {
+					return err
+				}
+				report.fail(n, err)
+				continue
+			}
+			st.Notes[n.ID] = &entry{ID: e.idOr(n.ID), Done: true}
+			if err := m.save(st); err != nil {
+				return err
+			}
+		}
+		report.Copied++
+		report.Sections += sections
+		report.Bytes += int64(len(content))
+	}
+	return nil
+}
+
+// New creates a new migration between the backends. The state file keeps
+// the progress of migration.
+func New(from, to *Backend, state string) *Migration {
+	return &Migration{
+		from:  from,
+		to:    to,
+		state: state,
+	}
+}
+
+// StatePath returns the state file of user migration in the directory.
+func StatePath(dir, user, from, to string) string {
+	name := fmt.Sprintf("%s-%s-%s.json", url.PathEscape(user), from, to)
+	return filepath.Join(dir, "migrate", name)
+}
+
+// read returns the source note content with relinked notes, and the count
+// of its sections.
+func (m *Migration) read(ctx context.Context, nid string, ids map[string]string) ([]byte, int, error) {
+	content, err := m.from.Content.Download(ctx, nid)
+	if err != nil {
+		return nil, 0, err
+	}
+	sections, err := drvsectionstore.Sections(content)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(ids) > 0 {
+		if content, err = drvsectionstore.Relink(content, ids); err != nil {
+			return nil, 0, err
+		}
+	}
+	return content, len(sections), nil
+}
+
+func (m *Migration) write(ctx context.Context, n *notestore.Note, e *entry, content []byte, importer Importer) error {
+	if importer != nil {
+		_, err := importer.Import(ctx, n, content)
+		return err
+	}
+	if len(bytes.TrimSpace(content)) == 0 {
+		return nil
+	}
+	_, err := m.to.Content.Upload(ctx, e.ID, content)
+	return err
+}
+
+// verify compares the migrated notes and their sections with the source
+// notes. The dates are compared only when the destination keeps them.
+func (m *Migration) verify(ctx context.Context, notes []*notestore.Note, st *state,
+	ids map[string]string, dates bool, report *Report) error {
+	for _, n := range notes {
+		e, ok := st.Notes[n.ID]
+		if !ok || !e.Done {
+			continue
+		}
+		fields, err := m.compare(ctx, n, e.ID, ids, dates)
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			report.Mismatches = append(report.Mismatches, &Mismatch{NoteID: n.ID, Name: n.Name, Field: f})
+		}
+		if len(fields) == 0 {
+			report.Verified++
+		}
+	}
+	return nil
+}
+
+// compare returns the fields of migrated note differing from the source
+// note. The note or sections failed to read are reported as mismatch, only
+// the authorization and context failures are returned.
+func (m *Migration) compare(ctx context.Context, n *notestore.Note, id string,
+	ids map[string]string, dates bool) ([]string, error) {
+	note, err := m.to.Notestore.Get(ctx, id)
+	if err != nil {
+		if errs.IsFatal(ctx, err) {
+			return nil, err
+		}
+		return []string{"note"}, nil
+	}
+	fields := compareNote(note, n, dates)
+
+	source, _, err := m.read(ctx, n.ID, ids)
+	if err == nil {
+		var migrated []byte
+		migrated, err = m.to.Content.Download(ctx, id)
+		if err == nil && !sameSections(source, migrated) {
+			fields = append(fields, "sections")
+		}
+	}
+	if err != nil {
+		if errs.IsFatal(ctx, err) {
+			return nil, err
+		}
+		fields = append(fields, "sections")
+	}
+	return fields, nil
+}
+
+// compareNote returns the note fields differing from the source note.
+func compareNote(note, n *notestore.Note, dates bool) []string {
+	var fields []string
+	if note.Name != n.Name {
+		fields = append(fields, "name")
+	}
+	if note.Description != n.Description {
+		fields = append(fields, "desc")
+	}
+	if !sameStrings(note.Labels, n.Labels) {
+		fields = append(fields, "labels")
+	}
+	if !sameMap(note.Metadata, n.Metadata) {
+		fields = append(fields, "metadata")
+	}
+	if dates && (!note.DateCreated.Equal(n.DateCreated) || !note.DateUpdated.Equal(n.DateUpdated)) {
+		fields = append(fields, "dates")
+	}
+	return fields
+}
+
+// load reads the state file, the missing file starts a new migration.
+func (m *Migration) load() (*state, error) {
+	st := &state{From: m.from.Name, To: m.to.Name}
+	data, err := ioutil.ReadFile(m.state)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, utils.Error("migration state read error", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, st); err != nil {
+			return nil, utils.Error("migration state read error", err)
+		}
+	}
+	if st.From != m.from.Name || st.To != m.to.Name {
+		msg := fmt.Sprintf("migration state belongs to migration from '%s' to '%s'", st.From, st.To)
+		return nil, errs.NewValidationError(msg)
+	}
+	if st.Notes == nil {
+		st.Notes = make(map[string]*entry)
+	}
+	return st, nil
+}
+
+// save replaces the state file atomically, so the crash never leaves the
+// partially written state.
+func (m *Migration) save(st *state) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return utils.Error("migration state write error", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(m.state), 0700); err != nil {
+		return utils.Error("migration state write error", err)
+	}
+	tmp := m.state + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return utils.Error("migration state write error", err)
+	}
+	if err := os.Rename(tmp, m.state); err != nil {
+		return utils.Error("migration state write error", err)
+	}
+	return nil
+}
+
+// ids returns the source note ids changed by the migration.
+func (st *state) ids() map[string]string {
+	ids := make(map[string]string)
+	for id, e := range st.Notes {
+		if len(e.ID) > 0 && e.ID != id {
+			ids[id] = e.ID
+		}
+	}
+	return ids
+}
+
+func (e *entry) idOr(id string) string {
+	if e != nil && len(e.ID) > 0 {
+		return e.ID
+	}
+	return id
+}
+
+func (r *Report) fail(n *notestore.Note, err error) {
+	r.Failures = append(r.Failures, &Failure{NoteID: n.ID, Name: n.Name, Error: err.Error()})
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func sameMap(a, b map[string]string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// sameSections compares the sections of note contents, the contents may
+// have different format versions.
+func sameSections(a, b []byte) bool {
+	sa, err := drvsectionstore.Sections(a)
+	if err != nil {
+		return false
+	}
+	sb, err := drvsectionstore.Sections(b)
+	if err != nil {
+		return false
+	}
+	ja, _ := json.Marshal(sa)
+	jb, _ := json.Marshal(sb)
+	return bytes.Equal(ja, jb)
+}
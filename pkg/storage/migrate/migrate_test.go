@@ -0,0 +1,328 @@
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/storage/migrate"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/offline"
+	"github.com/psewda/typing/pkg/storage/sectionstore/drvsectionstore"
+)
+
+func TestMigrate(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "migrate-suite")
+}
+
+var _ = Describe("migrate", func() {
+	var (
+		dir   string
+		state string
+		src   *memStore
+	)
+	ctx := context.Background()
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	BeforeEach(func() {
+		dir, _ = ioutil.TempDir("", "typing-migrate")
+		state = filepath.Join(dir, "state.json")
+		src = newMemStore()
+		first := src.add("first", created, `{ "version": 3, "sections": [ { "id": "s1", "name": "section" } ] }`)
+		src.add("second", created, fmt.Sprintf(`[ { "id": "s2", "links": [ { "noteId": "%s" } ] } ]`, first.ID))
+		src.add("empty", created, "")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	backend := func(name string, s *memStore) *migrate.Backend {
+		return &migrate.Backend{Name: name, Notestore: s, Content: s}
+	}
+
+	fsBackend := func() (*migrate.Backend, *offline.Notestore) {
+		store, _ := offline.New(filepath.Join(dir, "fs"), offline.DefaultConfig())
+		ns := offline.NewNotestore(store, nil, nil)
+		return &migrate.Backend{Name: migrate.BackendFS, Notestore: ns, Content: offline.NewContent(store, nil)}, ns
+	}
+
+	Context("migrate notes", func() {
+		It("should copy the notes with ids and dates when importer", func() {
+			to, ns := fsBackend()
+			m := migrate.New(backend(migrate.BackendDrive, src), to, state)
+			report, err := m.Run(ctx, migrate.Options{Verify: true})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Notes).Should(Equal(3))
+			Expect(report.Copied).Should(Equal(3))
+			Expect(report.Sections).Should(Equal(2))
+			Expect(report.Failures).Should(BeEmpty())
+			Expect(report.Verified).Should(Equal(3))
+			Expect(report.Mismatches).Should(BeEmpty())
+			Expect(report.DatesKept).Should(BeTrue())
+
+			notes, _ := ns.GetAll(ctx)
+			Expect(notes).Should(HaveLen(3))
+			for _, n := range notes {
+				Expect(src.notes).Should(HaveKey(n.ID))
+				Expect(n.DateCreated).Should(Equal(created))
+			}
+		})
+
+		It("should relink the notes when new ids", func() {
+			dst := newMemStore()
+			m := migrate.New(backend(migrate.BackendFS, src), backend(migrate.BackendDrive, dst), state)
+			report, err := m.Run(ctx, migrate.Options{Verify: true})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Copied).Should(Equal(3))
+			Expect(report.Mismatches).Should(BeEmpty())
+			Expect(report.DatesKept).Should(BeFalse())
+
+			first, second := dst.byName("first"), dst.byName("second")
+			Expect(src.notes).ShouldNot(HaveKey(first.ID))
+			sections, _ := drvsectionstore.Sections(dst.content[second.ID])
+			Expect(sections[0].Links[0].NoteID).Should(Equal(first.ID))
+		})
+
+		It("should not write anything when dry run", func() {
+			dst := newMemStore()
+			m := migrate.New(backend(migrate.BackendDrive, src), backend(migrate.BackendFS, dst), state)
+			report, err := m.Run(ctx, migrate.Options{DryRun: true})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.DryRun).Should(BeTrue())
+			Expect(report.Copied).Should(Equal(3))
+			Expect(report.Bytes).ShouldNot(BeZero())
+			Expect(dst.notes).Should(BeEmpty())
+			Expect(state).ShouldNot(BeAnExistingFile())
+		})
+
+		It("should resume the migration when interrupted", func() {
+			dst := newMemStore()
+			dst.failUploads = errors.New("network is unreachable")
+			m := migrate.New(backend(migrate.BackendDrive, src), backend(migrate.BackendFS, dst), state)
+			report, err := m.Run(ctx, migrate.Options{})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Copied).Should(Equal(1))
+			Expect(report.Failures).Should(HaveLen(2))
+
+			dst.failUploads = nil
+			report, err = m.Run(ctx, migrate.Options{Verify: true})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Skipped).Should(Equal(1))
+			Expect(report.Copied).Should(Equal(2))
+			Expect(report.Verified).Should(Equal(3))
+			Expect(dst.notes).Should(HaveLen(3))
+		})
+
+		It("should stop the migration when unauthorized", func() {
+			dst := newMemStore()
+			dst.failUploads = errs.NewUnauthorizedError()
+			m := migrate.New(backend(migrate.BackendDrive, src), backend(migrate.BackendFS, dst), state)
+			_, err := m.Run(ctx, migrate.Options{})
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewUnauthorizedError()))
+		})
+
+		It("should report the mismatch when migrated note changed", func() {
+			dst := newMemStore()
+			m := migrate.New(backend(migrate.BackendDrive, src), backend(migrate.BackendFS, dst), state)
+			m.Run(ctx, migrate.Options{})
+
+			first := dst.byName("first")
+			dst.Update(ctx, first.ID, &notestore.WritableNote{Name: "first", Labels: []string{"changed"}})
+			report, err := m.Run(ctx, migrate.Options{Verify: true})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Skipped).Should(Equal(3))
+			Expect(report.Verified).Should(Equal(2))
+			Expect(report.Mismatches).Should(HaveLen(1))
+			Expect(report.Mismatches[0].Field).Should(Equal("labels"))
+		})
+
+		It("should return error when state of other migration", func() {
+			to, _ := fsBackend()
+			migrate.New(backend(migrate.BackendDrive, src), to, state).Run(ctx, migrate.Options{})
+			_, err := migrate.New(to, backend(migrate.BackendDrive, src), state).Run(ctx, migrate.Options{})
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+		})
+	})
+
+	Context("migration runner", func() {
+		It("should run the migration in background", func() {
+			dst := newMemStore()
+			backends := func(name string) (*migrate.Backend, error) {
+				if name == migrate.BackendDrive {
+					return backend(name, src), nil
+				}
+				return backend(name, dst), nil
+			}
+			m := migrate.NewRunner(dir).Migrator(nil, backends)
+			_, err := m.Job(ctx)
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewNotFoundError("msg")))
+
+			job, err := m.Start(ctx, migrate.BackendDrive, migrate.BackendFS, migrate.Options{})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(job.Running).Should(BeTrue())
+
+			Eventually(func() bool {
+				job, _ := m.Job(ctx)
+				return job.Running
+			}).Should(BeFalse())
+			job, _ = m.Job(ctx)
+			Expect(job.Error).Should(BeEmpty())
+			Expect(job.Report.Copied).Should(Equal(3))
+			Expect(migrate.StatePath(dir, "-", migrate.BackendDrive, migrate.BackendFS)).Should(BeAnExistingFile())
+		})
+
+		It("should cancel the running migration when runner stopped", func() {
+			dst := newMemStore()
+			dst.waitUploads = true
+			backends := func(name string) (*migrate.Backend, error) {
+				if name == migrate.BackendDrive {
+					return backend(name, src), nil
+				}
+				return backend(name, dst), nil
+			}
+			r := migrate.NewRunner(dir)
+			m := r.Migrator(nil, backends)
+			_, err := m.Start(ctx, migrate.BackendDrive, migrate.BackendFS, migrate.Options{})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			r.Stop()
+			job, _ := m.Job(ctx)
+			Expect(job.Running).Should(BeFalse())
+			Expect(job.Error).Should(ContainSubstring(context.Canceled.Error()))
+		})
+
+		It("should return error when same backends", func() {
+			backends := func(name string) (*migrate.Backend, error) {
+				return backend(name, src), nil
+			}
+			m := migrate.NewRunner(dir).Migrator(nil, backends)
+			_, err := m.Start(ctx, migrate.BackendFS, migrate.BackendFS, migrate.Options{})
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+		})
+	})
+})
+
+// memStore is the in-memory storage backend.
+type memStore struct {
+	notes       map[string]*notestore.Note
+	content     map[string][]byte
+	seq         int
+	failUploads error
+	waitUploads bool
+	mutex       sync.Mutex
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		notes:   make(map[string]*notestore.Note),
+		content: make(map[string][]byte),
+	}
+}
+
+func (s *memStore) add(name string, date time.Time, content string) *notestore.Note {
+	note, _ := s.Create(context.Background(), &notestore.WritableNote{Name: name})
+	note.DateCreated = date
+	note.DateUpdated = date
+	s.content[note.ID] = []byte(content)
+	return note
+}
+
+func (s *memStore) byName(name string) *notestore.Note {
+	for _, n := range s.notes {
+		if n.Name == name {
+			return n
+		}
+	}
+	return nil
+}
+
+func (s *memStore) Create(ctx context.Context, n *notestore.WritableNote) (*notestore.Note, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.seq++
+	note := &notestore.Note{
+		ID:          fmt.Sprintf("note-%d-%d", s.seq, time.Now().UnixNano()),
+		Name:        n.Name,
+		Labels:      n.Labels,
+		Metadata:    n.Metadata,
+		DateCreated: time.Now().UTC(),
+		DateUpdated: time.Now().UTC(),
+	}
+	s.notes[note.ID] = note
+	return note, nil
+}
+
+func (s *memStore) GetAll(ctx context.Context) ([]*notestore.Note, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var notes []*notestore.Note
+	for _, n := range s.notes {
+		notes = append(notes, n)
+	}
+	return notes, nil
+}
+
+func (s *memStore) Get(ctx context.Context, id string) (*notestore.Note, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if n, ok := s.notes[id]; ok {
+		return n, nil
+	}
+	return nil, errs.NewNotFoundError("note not found")
+}
+
+func (s *memStore) Update(ctx context.Context, id string, n *notestore.WritableNote) (*notestore.Note, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	note := s.notes[id]
+	note.Name = n.Name
+	note.Labels = n.Labels
+	note.Metadata = n.Metadata
+	return note, nil
+}
+
+func (s *memStore) Delete(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.notes, id)
+	delete(s.content, id)
+	return nil
+}
+
+func (s *memStore) Download(ctx context.Context, nid string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.notes[nid]; !ok {
+		return nil, errs.NewNotFoundError("note not found")
+	}
+	return s.content[nid], nil
+}
+
+func (s *memStore) Upload(ctx context.Context, nid string, content []byte) (int64, error) {
+	if s.waitUploads {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.failUploads != nil {
+		return 0, s.failUploads
+	}
+	s.content[nid] = content
+	return 0, nil
+}
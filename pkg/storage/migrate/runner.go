@@ -0,0 +1,194 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/signin/userinfo"
+)
+
+// Migrator runs the migration of user notes in background.
+type Migrator interface {
+	// Start begins the migration between the named backends, and returns
+	// the running job.
+	Start(ctx context.Context, from, to string, o Options) (*Job, error)
+
+	// Job returns the last migration job of the user.
+	Job(ctx context.Context) (*Job, error)
+}
+
+// Backends returns the storage backend of user by name.
+type Backends func(name string) (*Backend, error)
+
+// Job is the migration running in background. The report is set when the
+// migration finishes without error.
+type Job struct {
+	From     string     `json:"from"`
+	To       string     `json:"to"`
+	Options  Options    `json:"options"`
+	Running  bool       `json:"running"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Report   *Report    `json:"report,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// Runner keeps the migration jobs of all users, a user runs a single
+// migration at a time. The state files are kept in the directory.
+type Runner struct {
+	dir     string
+	jobs    map[string]*Job
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+	mutex   sync.Mutex
+}
+
+// UserMigrator is the migrator of single user using the runner.
+type UserMigrator struct {
+	runner   *Runner
+	userinfo userinfo.Userinfo
+	backends Backends
+}
+
+// Start begins the migration of user notes. The migration outlives the
+// request, so it runs with the runner context instead of the request
+// context.
+func (um *UserMigrator) Start(ctx context.Context, from, to string, o Options) (*Job, error) {
+	user, m, err := um.migration(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return um.runner.start(user, m, o)
+}
+
+// Run migrates the user notes and waits for the migration. It shares the
+// state file with the background migration.
+func (um *UserMigrator) Run(ctx context.Context, from, to string, o Options) (*Report, error) {
+	_, m, err := um.migration(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return m.Run(ctx, o)
+}
+
+// Job returns the last migration job of the user.
+func (um *UserMigrator) Job(ctx context.Context) (*Job, error) {
+	user, err := um.user(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return um.runner.job(user)
+}
+
+// NewRunner creates a new runner keeping the state files in the directory.
+func NewRunner(dir string) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{
+		dir:    dir,
+		jobs:   make(map[string]*Job),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Stop cancels the running migrations and waits for them, e.g. on server
+// shutdown. The cancelled migration resumes from its state file on the next
+// start.
+func (r *Runner) Stop() {
+	r.cancel()
+	r.running.Wait()
+}
+
+// Migrator returns the migrator of the user having the backends. The nil
+// userinfo keeps a single user.
+func (r *Runner) Migrator(ui userinfo.Userinfo, b Backends) *UserMigrator {
+	return &UserMigrator{
+		runner:   r,
+		userinfo: ui,
+		backends: b,
+	}
+}
+
+func (um *UserMigrator) migration(ctx context.Context, from, to string) (string, *Migration, error) {
+	user, err := um.user(ctx)
+	if err != nil {
+		return utils.Empty, nil, err
+	}
+	src, err := um.backends(from)
+	if err != nil {
+		return utils.Empty, nil, err
+	}
+	dst, err := um.backends(to)
+	if err != nil {
+		return utils.Empty, nil, err
+	}
+	if src.Name == dst.Name {
+		return utils.Empty, nil, errs.NewValidationError("source and destination backends are the same")
+	}
+	return user, New(src, dst, StatePath(um.runner.dir, user, from, to)), nil
+}
+
+func (um *UserMigrator) user(ctx context.Context) (string, error) {
+	if um.userinfo == nil {
+		return "-", nil
+	}
+	user, err := um.userinfo.Get(ctx)
+	if err != nil {
+		return utils.Empty, err
+	}
+	return user.ID, nil
+}
+
+func (r *Runner) start(user string, m *Migration, o Options) (*Job, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if job, ok := r.jobs[user]; ok && job.Running {
+		msg := fmt.Sprintf("migration from '%s' to '%s' is already running", job.From, job.To)
+		return nil, errs.NewConflictError(msg)
+	}
+	job := &Job{
+		From:    m.from.Name,
+		To:      m.to.Name,
+		Options: o,
+		Running: true,
+		Started: time.Now().UTC(),
+	}
+	r.jobs[user] = job
+
+	r.running.Add(1)
+	go func() {
+		defer r.running.Done()
+		report, err := m.Run(r.ctx, o)
+
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		now := time.Now().UTC()
+		job.Running = false
+		job.Finished = &now
+		job.Report = report
+		if err != nil {
+			job.Error = utils.AppendError("migration error", err)
+		}
+	}()
+
+	j := *job
+	return &j, nil
+}
+
+func (r *Runner) job(user string) (*Job, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	job, ok := r.jobs[user]
+	if !ok {
+		return nil, errs.NewNotFoundError("no migration is started")
+	}
+	j := *job
+	return &j, nil
+}
@@ -0,0 +1,42 @@
+package notelock
+
+import (
+	"context"
+
+	"github.com/psewda/typing/pkg/signin/userinfo"
+	"github.com/psewda/typing/pkg/storage/sectionstore/drvsectionstore"
+)
+
+// Content is the note content decorator which uploads holding the note
+// lock, so the whole content written by restore or migration never races
+// with the mutation of the locked sectionstore. The downloads are not locked.
+type Content struct {
+	content  drvsectionstore.Content
+	locker   *Locker
+	userinfo userinfo.Userinfo
+}
+
+// Download returns the plain note content.
+func (c *Content) Download(ctx context.Context, nid string) ([]byte, error) {
+	return c.content.Download(ctx, nid)
+}
+
+// Upload saves the note content holding the note lock.
+func (c *Content) Upload(ctx context.Context, nid string, content []byte) (int64, error) {
+	unlock, err := lock(ctx, c.locker, c.userinfo, nid)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	return c.content.Upload(ctx, nid, content)
+}
+
+// NewContent creates a new lock decorator of the note content. The lock
+// key is same as the lock key of sectionstore decorator.
+func NewContent(c drvsectionstore.Content, l *Locker, ui userinfo.Userinfo) *Content {
+	return &Content{
+		content:  c,
+		locker:   l,
+		userinfo: ui,
+	}
+}
@@ -0,0 +1,150 @@
+package notelock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/psewda/typing/pkg/errs"
+)
+
+// DefaultTimeout is the default duration to wait for the lock.
+const DefaultTimeout = 30 * time.Second
+
+// Stats has the counters of lock contention.
+type Stats struct {
+	// Acquired is the number of locks taken.
+	Acquired uint64 `json:"acquired"`
+
+	// Contended is the number of locks which had to wait for other holder.
+	Contended uint64 `json:"contended"`
+
+	// Timeouts is the number of locks which gave up waiting.
+	Timeouts uint64 `json:"timeouts"`
+
+	// Waiting is the number of requests waiting for the lock now.
+	Waiting int `json:"waiting"`
+
+	// Held is the number of keys locked now.
+	Held int `json:"held"`
+
+	// WaitTime is the total time spent waiting for the lock in milliseconds.
+	WaitTime int64 `json:"waitTime"`
+}
+
+// Locker is the in-process keyed lock. The requests locking the same key
+// are serialized, and the requests having different keys run in parallel.
+type Locker struct {
+	timeout time.Duration
+	entries map[string]*entry
+	stats   Stats
+	mutex   sync.Mutex
+}
+
+type entry struct {
+	ch   chan struct{}
+	refs int
+}
+
+// Lock takes the lock of the key, waiting at most for the timeout. It
+// returns the unlock function, which must be called to release the lock.
+// If the wait times out, it returns the conflict error. If the context is
+// done while waiting, it returns the context error.
+func (l *Locker) Lock(ctx context.Context, key string) (func(), error) {
+	l.mutex.Lock()
+	e, ok := l.entries[key]
+	if !ok {
+		e = &entry{ch: make(chan struct{}, 1)}
+		l.entries[key] = e
+	}
+	e.refs++
+
+	// take the free lock without waiting
+	select {
+	case e.ch <- struct{}{}:
+		l.stats.Acquired++
+		l.stats.Held++
+		l.mutex.Unlock()
+		return l.unlock(key, e), nil
+	default:
+	}
+	l.stats.Contended++
+	l.stats.Waiting++
+	l.mutex.Unlock()
+
+	start := time.Now()
+	timer := time.NewTimer(l.timeout)
+	defer timer.Stop()
+
+	select {
+	case e.ch <- struct{}{}:
+		l.mutex.Lock()
+		l.stats.Acquired++
+		l.stats.Held++
+		l.stats.Waiting--
+		l.stats.WaitTime += time.Since(start).Milliseconds()
+		l.mutex.Unlock()
+		return l.unlock(key, e), nil
+	case <-timer.C:
+		l.giveUp(key, e, start, true)
+		msg := fmt.Sprintf("note is being modified by other request, lock wait timed out after %s", l.timeout)
+		return nil, errs.NewConflictError(msg)
+	case <-ctx.Done():
+		l.giveUp(key, e, start, false)
+		return nil, ctx.Err()
+	}
+}
+
+// Stats returns the current counters of lock contention.
+func (l *Locker) Stats() Stats {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.stats
+}
+
+// New creates a new keyed lock. The zero timeout uses the default timeout.
+func New(timeout time.Duration) *Locker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &Locker{
+		timeout: timeout,
+		entries: make(map[string]*entry),
+	}
+}
+
+func (l *Locker) unlock(key string, e *entry) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			<-e.ch
+			l.stats.Held--
+			l.release(key, e)
+		})
+	}
+}
+
+// giveUp records the lock which stopped waiting.
+func (l *Locker) giveUp(key string, e *entry, start time.Time, timeout bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if timeout {
+		l.stats.Timeouts++
+	}
+	l.stats.Waiting--
+	l.stats.WaitTime += time.Since(start).Milliseconds()
+	l.release(key, e)
+}
+
+// release drops the reference of entry, and removes the entry when no one
+// holds or waits for it. The caller must hold the mutex.
+func (l *Locker) release(key string, e *entry) {
+	e.refs--
+	if e.refs == 0 {
+		delete(l.entries, key)
+	}
+}
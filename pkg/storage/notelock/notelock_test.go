@@ -0,0 +1,208 @@
+package notelock_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/signin/userinfo"
+	"github.com/psewda/typing/pkg/storage/notelock"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+func TestNotelock(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "notelock-suite")
+}
+
+var _ = Describe("note lock", func() {
+	var mockCtrl *gomock.Controller
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Context("locker", func() {
+		It("should serialize the same key", func() {
+			l := notelock.New(time.Second)
+			unlock, err := l.Lock(context.Background(), "key")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			locked := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				unlock2, err := l.Lock(context.Background(), "key")
+				Expect(err).ShouldNot(HaveOccurred())
+				close(locked)
+				unlock2()
+			}()
+
+			Consistently(locked, 50*time.Millisecond).ShouldNot(BeClosed())
+			unlock()
+			Eventually(locked).Should(BeClosed())
+			Eventually(func() int { return l.Stats().Held }).Should(BeZero())
+
+			stats := l.Stats()
+			Expect(stats.Acquired).Should(BeEquivalentTo(2))
+			Expect(stats.Contended).Should(BeEquivalentTo(1))
+			Expect(stats.Waiting).Should(BeZero())
+			Expect(stats.WaitTime).Should(BeNumerically(">=", 50))
+		})
+
+		It("should not block the different keys", func() {
+			l := notelock.New(time.Second)
+			unlock1, _ := l.Lock(context.Background(), "key1")
+			defer unlock1()
+			unlock2, err := l.Lock(context.Background(), "key2")
+			Expect(err).ShouldNot(HaveOccurred())
+			defer unlock2()
+			Expect(l.Stats().Contended).Should(BeZero())
+			Expect(l.Stats().Held).Should(Equal(2))
+		})
+
+		It("should return conflict error when wait times out", func() {
+			l := notelock.New(20 * time.Millisecond)
+			unlock, _ := l.Lock(context.Background(), "key")
+			defer unlock()
+
+			_, err := l.Lock(context.Background(), "key")
+			Expect(err).Should(HaveOccurred())
+			Expect(err).Should(BeAssignableToTypeOf(&errs.ConflictError{}))
+			Expect(l.Stats().Timeouts).Should(BeEquivalentTo(1))
+		})
+
+		It("should ignore the second unlock call", func() {
+			l := notelock.New(time.Second)
+			unlock, _ := l.Lock(context.Background(), "key")
+			unlock()
+			unlock()
+
+			unlock, err := l.Lock(context.Background(), "key")
+			Expect(err).ShouldNot(HaveOccurred())
+			unlock()
+			Expect(l.Stats().Held).Should(BeZero())
+		})
+	})
+
+	Context("sectionstore", func() {
+		It("should not lose the parallel updates", func() {
+			var (
+				content []string
+				mutex   sync.Mutex
+			)
+			ss := mocks.NewMockSectionstore(mockCtrl)
+			ss.EXPECT().Create(gomock.Any(), gomock.Eq("nid"), gomock.Any()).DoAndReturn(
+				func(_ context.Context, nid string, s *sectionstore.WritableSection) (*sectionstore.Section, error) {
+					// read, wait and write back like the drive sectionstore
+					mutex.Lock()
+					copied := append([]string{}, content...)
+					mutex.Unlock()
+					time.Sleep(5 * time.Millisecond)
+					mutex.Lock()
+					content = append(copied, s.Name)
+					mutex.Unlock()
+					return &sectionstore.Section{Name: s.Name}, nil
+				}).Times(5)
+
+			lss := notelock.NewSectionstore(ss, notelock.New(time.Second), nil)
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					_, err := lss.Create(context.Background(), "nid", &sectionstore.WritableSection{Name: "name"})
+					Expect(err).ShouldNot(HaveOccurred())
+				}()
+			}
+			wg.Wait()
+			Expect(content).Should(HaveLen(5))
+		})
+
+		It("should lock the note per user", func() {
+			l := notelock.New(20 * time.Millisecond)
+			unlock, _ := l.Lock(context.Background(), "uid/nid")
+			defer unlock()
+
+			ui := mocks.NewMockUserinfo(mockCtrl)
+			ui.EXPECT().Get(gomock.Any()).Return(&userinfo.User{ID: "uid"}, nil)
+			ss := mocks.NewMockSectionstore(mockCtrl)
+
+			err := notelock.NewSectionstore(ss, l, ui).Delete(context.Background(), "nid", "sid")
+			Expect(err).Should(BeAssignableToTypeOf(&errs.ConflictError{}))
+
+			ui2 := mocks.NewMockUserinfo(mockCtrl)
+			ui2.EXPECT().Get(gomock.Any()).Return(&userinfo.User{ID: "uid2"}, nil)
+			ss.EXPECT().Delete(gomock.Any(), gomock.Eq("nid"), gomock.Eq("sid")).Return(nil)
+			Expect(notelock.NewSectionstore(ss, l, ui2).Delete(context.Background(), "nid", "sid")).Should(Succeed())
+		})
+
+		It("should not lock the reads", func() {
+			l := notelock.New(20 * time.Millisecond)
+			unlock, _ := l.Lock(context.Background(), "/nid")
+			defer unlock()
+
+			ss := mocks.NewMockSectionstore(mockCtrl)
+			ss.EXPECT().Get(gomock.Any(), gomock.Eq("nid"), gomock.Eq("sid")).Return(&sectionstore.Section{}, nil)
+			_, err := notelock.NewSectionstore(ss, l, nil).Get(context.Background(), "nid", "sid")
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should return error when userinfo fails", func() {
+			ui := mocks.NewMockUserinfo(mockCtrl)
+			ui.EXPECT().Get(gomock.Any()).Return(nil, errors.New("error"))
+			ss := mocks.NewMockSectionstore(mockCtrl)
+
+			_, err := notelock.NewSectionstore(ss, notelock.New(0), ui).Batch(context.Background(), "nid", nil)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("content", func() {
+		It("should upload holding the note lock", func() {
+			l := notelock.New(20 * time.Millisecond)
+			unlock, _ := l.Lock(context.Background(), "/nid")
+
+			c := notelock.NewContent(new(memContent), l, nil)
+			_, err := c.Upload(context.Background(), "nid", []byte("content"))
+			Expect(err).Should(BeAssignableToTypeOf(&errs.ConflictError{}))
+
+			content, err := c.Download(context.Background(), "nid")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(content).Should(BeEmpty())
+
+			unlock()
+			_, err = c.Upload(context.Background(), "nid", []byte("content"))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(c.Download(context.Background(), "nid")).Should(Equal([]byte("content")))
+		})
+	})
+})
+
+// memContent is the note content in memory.
+type memContent struct {
+	data map[string][]byte
+}
+
+func (c *memContent) Download(_ context.Context, nid string) ([]byte, error) {
+	return c.data[nid], nil
+}
+
+func (c *memContent) Upload(_ context.Context, nid string, content []byte) (int64, error) {
+	if c.data == nil {
+		c.data = make(map[string][]byte)
+	}
+	c.data[nid] = content
+	return 0, nil
+}
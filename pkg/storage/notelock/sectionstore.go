@@ -0,0 +1,113 @@
+package notelock
+
+import (
+	"context"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/schema"
+	"github.com/psewda/typing/pkg/signin/userinfo"
+	secstore "github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+// Sectionstore is the sectionstore decorator which serializes the mutations
+// of the same note. The wrapped sectionstore downloads and uploads the whole
+// note content, so two parallel mutations would lose one of the updates.
+// The lock is kept per user and note id, the reads are not locked.
+type Sectionstore struct {
+	sectionstore secstore.Sectionstore
+	locker       *Locker
+	userinfo     userinfo.Userinfo
+}
+
+// Create adds a new section in the note holding the note lock.
+func (ss *Sectionstore) Create(ctx context.Context, nid string, s *secstore.WritableSection) (*secstore.Section, error) {
+	unlock, err := ss.lock(ctx, nid)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return ss.sectionstore.Create(ctx, nid, s)
+}
+
+// GetAll fetches all sections from the note.
+func (ss *Sectionstore) GetAll(ctx context.Context, nid string, q *secstore.Query) ([]*secstore.Section, error) {
+	return ss.sectionstore.GetAll(ctx, nid, q)
+}
+
+// Get returns a single section from the note.
+func (ss *Sectionstore) Get(ctx context.Context, nid, sid string) (*secstore.Section, error) {
+	return ss.sectionstore.Get(ctx, nid, sid)
+}
+
+// Update modifies the section holding the note lock.
+func (ss *Sectionstore) Update(ctx context.Context, nid, sid string, s *secstore.WritableSection) (*secstore.Section, error) {
+	unlock, err := ss.lock(ctx, nid)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return ss.sectionstore.Update(ctx, nid, sid, s)
+}
+
+// Delete removes the section holding the note lock.
+func (ss *Sectionstore) Delete(ctx context.Context, nid, sid string) error {
+	unlock, err := ss.lock(ctx, nid)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return ss.sectionstore.Delete(ctx, nid, sid)
+}
+
+// GetSchema returns the schema of section data declared by the note.
+func (ss *Sectionstore) GetSchema(ctx context.Context, nid string) (*schema.Schema, error) {
+	return ss.sectionstore.GetSchema(ctx, nid)
+}
+
+// Batch applies all operations holding the note lock.
+func (ss *Sectionstore) Batch(ctx context.Context, nid string, ops []*secstore.Operation) ([]*secstore.OperationResult, error) {
+	unlock, err := ss.lock(ctx, nid)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return ss.sectionstore.Batch(ctx, nid, ops)
+}
+
+// SetSchema declares the schema holding the note lock.
+func (ss *Sectionstore) SetSchema(ctx context.Context, nid string, s *schema.Schema) error {
+	unlock, err := ss.lock(ctx, nid)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return ss.sectionstore.SetSchema(ctx, nid, s)
+}
+
+// NewSectionstore creates a new lock decorator of the sectionstore. The
+// userinfo gives the user part of lock key, the nil userinfo locks by
+// the note id only.
+func NewSectionstore(ss secstore.Sectionstore, l *Locker, ui userinfo.Userinfo) *Sectionstore {
+	return &Sectionstore{
+		sectionstore: ss,
+		locker:       l,
+		userinfo:     ui,
+	}
+}
+
+func (ss *Sectionstore) lock(ctx context.Context, nid string) (func(), error) {
+	return lock(ctx, ss.locker, ss.userinfo, nid)
+}
+
+// lock takes the lock of the note, the key has the user id and note id.
+func lock(ctx context.Context, l *Locker, ui userinfo.Userinfo, nid string) (func(), error) {
+	owner := utils.Empty
+	if ui != nil {
+		user, err := ui.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		owner = user.ID
+	}
+	return l.Lock(ctx, owner+"/"+nid)
+}
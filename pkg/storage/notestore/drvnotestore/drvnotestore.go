@@ -1,6 +1,7 @@
 package drvnotestore
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -9,14 +10,20 @@ import (
 
 	"github.com/psewda/typing/internal/utils"
 	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/storage/attachstore/drvattachstore"
 	"github.com/psewda/typing/pkg/storage/notestore"
 	"google.golang.org/api/drive/v3"
 )
 
 const (
 	appdir         = "appDataFolder"
-	fileFields     = "id, name, description, properties, createdTime, modifiedTime"
-	fileListFields = "files(id, name, description, properties, createdTime, modifiedTime)"
+	fileFields     = "id, name, description, properties, createdTime, modifiedTime, version"
+	fileListFields = "nextPageToken, files(id, name, description, properties, createdTime, modifiedTime, version)"
+	changeFields   = "nextPageToken, newStartPageToken, " +
+		"changes(fileId, removed, file(id, name, description, properties, createdTime, modifiedTime, version, trashed))"
+
+	// pageSize is the max number of files listed in a single request.
+	pageSize = 1000
 )
 
 // DrvNotestore is the notestore implementation
@@ -26,7 +33,7 @@ type DrvNotestore struct {
 }
 
 // Create builds a new note and saves it on google drive.
-func (ns *DrvNotestore) Create(n *notestore.WritableNote) (*notestore.Note, error) {
+func (ns *DrvNotestore) Create(ctx context.Context, n *notestore.WritableNote) (*notestore.Note, error) {
 	err := checkNote(n)
 	if err != nil {
 		return nil, utils.Error("note validation failed", err)
@@ -41,7 +48,7 @@ func (ns *DrvNotestore) Create(n *notestore.WritableNote) (*notestore.Note, erro
 		Properties:  fillProps(note),
 	}
 
-	file, err := ns.service.Files.Create(&f).Fields(fileFields).Do()
+	file, err := ns.service.Files.Create(&f).Fields(fileFields).Context(ctx).Do()
 	if err != nil {
 		if utils.GetStatusCode(err) == http.StatusUnauthorized {
 			return nil, errs.NewUnauthorizedError()
@@ -52,30 +59,43 @@ func (ns *DrvNotestore) Create(n *notestore.WritableNote) (*notestore.Note, erro
 	return toNote(file), nil
 }
 
-// GetAll returns a list of all notes from google drive
-func (ns *DrvNotestore) GetAll() ([]*notestore.Note, error) {
-	list, err := ns.service.Files.List().Spaces(appdir).Fields(fileListFields).Do()
-	if err != nil {
-		if utils.GetStatusCode(err) == http.StatusUnauthorized {
-			return nil, errs.NewUnauthorizedError()
+// GetAll returns a list of all notes from google drive. The attachment
+// files in the same app data folder are skipped. The files are listed
+// page by page until the last page.
+func (ns *DrvNotestore) GetAll(ctx context.Context) ([]*notestore.Note, error) {
+	q := drvattachstore.ExcludeQuery
+	var notes []*notestore.Note
+	token := utils.Empty
+	for {
+		call := ns.service.Files.List().Spaces(appdir).Q(q).PageSize(pageSize).Fields(fileListFields)
+		if len(token) > 0 {
+			call = call.PageToken(token)
+		}
+		list, err := call.Context(ctx).Do()
+		if err != nil {
+			if utils.GetStatusCode(err) == http.StatusUnauthorized {
+				return nil, errs.NewUnauthorizedError()
+			}
+			return nil, utils.Error("file listing error", err)
 		}
-		return nil, utils.Error("file listing error", err)
-	}
 
-	var notes []*notestore.Note
-	for _, f := range list.Files {
-		notes = append(notes, toNote(f))
+		for _, f := range list.Files {
+			notes = append(notes, toNote(f))
+		}
+		token = list.NextPageToken
+		if len(token) == 0 {
+			return notes, nil
+		}
 	}
-	return notes, nil
 }
 
 // Get returns the single note from google drive.
-func (ns *DrvNotestore) Get(id string) (*notestore.Note, error) {
+func (ns *DrvNotestore) Get(ctx context.Context, id string) (*notestore.Note, error) {
 	if len(id) == 0 {
 		return nil, errors.New("note id is nil")
 	}
 
-	file, err := getFile(ns.service, id)
+	file, err := getFile(ctx, ns.service, id)
 	if err != nil {
 		return nil, err
 	}
@@ -83,12 +103,12 @@ func (ns *DrvNotestore) Get(id string) (*notestore.Note, error) {
 }
 
 // Update modifies the note and saves back on google drive.
-func (ns *DrvNotestore) Update(id string, n *notestore.WritableNote) (*notestore.Note, error) {
+func (ns *DrvNotestore) Update(ctx context.Context, id string, n *notestore.WritableNote) (*notestore.Note, error) {
 	if len(id) == 0 {
 		return nil, errors.New("note id is nil")
 	}
 
-	file, err := getFile(ns.service, id)
+	file, err := getFile(ctx, ns.service, id)
 	if err != nil {
 		return nil, err
 	}
@@ -109,7 +129,7 @@ func (ns *DrvNotestore) Update(id string, n *notestore.WritableNote) (*notestore
 		ForceSendFields: []string{"Description", "Properties"},
 	}
 
-	updated, err := ns.service.Files.Update(file.Id, &f).Fields(fileFields).Do()
+	updated, err := ns.service.Files.Update(file.Id, &f).Fields(fileFields).Context(ctx).Do()
 	if err != nil {
 		if utils.GetStatusCode(err) == http.StatusUnauthorized {
 			return nil, errs.NewUnauthorizedError()
@@ -120,12 +140,12 @@ func (ns *DrvNotestore) Update(id string, n *notestore.WritableNote) (*notestore
 }
 
 // Delete removes the note from google drive.
-func (ns *DrvNotestore) Delete(id string) error {
+func (ns *DrvNotestore) Delete(ctx context.Context, id string) error {
 	if len(id) == 0 {
 		return errors.New("note id is nil")
 	}
 
-	err := ns.service.Files.Delete(id).Do()
+	err := ns.service.Files.Delete(id).Context(ctx).Do()
 	if err != nil {
 		if utils.GetStatusCode(err) == http.StatusUnauthorized {
 			return errs.NewUnauthorizedError()
@@ -140,16 +160,71 @@ func (ns *DrvNotestore) Delete(id string) error {
 	return nil
 }
 
+// StartToken returns the page token of current google drive changes.
+func (ns *DrvNotestore) StartToken(ctx context.Context) (string, error) {
+	token, err := ns.service.Changes.GetStartPageToken().Context(ctx).Do()
+	if err != nil {
+		if utils.GetStatusCode(err) == http.StatusUnauthorized {
+			return utils.Empty, errs.NewUnauthorizedError()
+		}
+		return utils.Empty, utils.Error("change token retrival error", err)
+	}
+	return token.StartPageToken, nil
+}
+
+// Changes returns the notes changed in google drive since the page token.
+// The trashed notes are returned as removed, and the attachment files are
+// skipped.
+func (ns *DrvNotestore) Changes(ctx context.Context, token string) (*notestore.Changes, error) {
+	if len(token) == 0 {
+		return nil, errors.New("change token is nil")
+	}
+
+	changes := new(notestore.Changes)
+	for len(token) > 0 {
+		list, err := ns.service.Changes.List(token).Spaces(appdir).Fields(changeFields).Context(ctx).Do()
+		if err != nil {
+			if utils.GetStatusCode(err) == http.StatusUnauthorized {
+				return nil, errs.NewUnauthorizedError()
+			}
+			return nil, utils.Error("change listing error", err)
+		}
+
+		for _, c := range list.Changes {
+			if drvattachstore.IsAttachment(c.File) {
+				continue
+			}
+			if c.Removed || c.File == nil || c.File.Trashed {
+				changes.Removed = append(changes.Removed, c.FileId)
+				continue
+			}
+			changes.Notes = append(changes.Notes, toNote(c.File))
+		}
+
+		// the last page has the token of next changes
+		token = list.NextPageToken
+		if len(list.NewStartPageToken) > 0 {
+			changes.Token = list.NewStartPageToken
+		}
+	}
+	return changes, nil
+}
+
 // New creates a new instance of google drive notestore.
 func New(c *http.Client) (*DrvNotestore, error) {
 	service, err := drive.New(c)
 	if err != nil {
 		return nil, utils.Error("drive service creation error", err)
 	}
+	return NewWithService(service), nil
+}
 
+// NewWithService creates a new instance of google drive notestore
+// using the existing drive service.
+func NewWithService(service *drive.Service) *DrvNotestore {
 	return &DrvNotestore{
 		service: service,
-	}, nil
+	}
 }
 
 func parseTime(value string) time.Time {
@@ -202,6 +277,7 @@ func toNote(f *drive.File) *notestore.Note {
 		Description: f.Description,
 		DateCreated: parseTime(f.CreatedTime),
 		DateUpdated: parseTime(f.ModifiedTime),
+		Version:     f.Version,
 	}
 
 	if len(f.Properties["labels"]) > 0 {
@@ -226,8 +302,8 @@ func checkNote(n *notestore.WritableNote) error {
 	return n.Validate()
 }
 
-func getFile(service *drive.Service, id string) (*drive.File, error) {
-	file, err := service.Files.Get(id).Fields(fileFields).Do()
+func getFile(ctx context.Context, service *drive.Service, id string) (*drive.File, error) {
+	file, err := service.Files.Get(id).Fields(fileFields).Context(ctx).Do()
 	if err != nil {
 		if utils.GetStatusCode(err) == http.StatusUnauthorized {
 			return nil, errs.NewUnauthorizedError()
@@ -238,6 +314,10 @@ func getFile(service *drive.Service, id string) (*drive.File, error) {
 		return nil, utils.Error("file retrival error", err)
 	}
 
+	// the attachment is in the same folder, but it is not a note
+	if drvattachstore.IsAttachment(file) {
+		return nil, buildNotFoundError(id)
+	}
 	return file, nil
 }
 
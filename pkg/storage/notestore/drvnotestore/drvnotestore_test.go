@@ -2,6 +2,7 @@ package drvnotestore_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -33,7 +34,7 @@ var _ = Describe("googledrive notestore", func() {
 				}`
 			client := utils.ClientWithJSON(j, http.StatusCreated)
 			drvns, _ := drvnotestore.New(client)
-			note, err := drvns.Create(&notestore.WritableNote{
+			note, err := drvns.Create(context.Background(), &notestore.WritableNote{
 				Name:        "note",
 				Description: "desc",
 				Labels:      []string{"label1", "label2"},
@@ -81,7 +82,7 @@ var _ = Describe("googledrive notestore", func() {
 			})
 
 			drvns, _ := drvnotestore.New(client)
-			note, err := drvns.Create(&notestore.WritableNote{
+			note, err := drvns.Create(context.Background(), &notestore.WritableNote{
 				Name:        "note",
 				Description: " desc  ",
 				Labels:      []string{"label1", " ", "label2  "},
@@ -100,7 +101,7 @@ var _ = Describe("googledrive notestore", func() {
 		It("should return error when wrong input", func() {
 			client := utils.ClientWithJSON("{}", http.StatusCreated)
 			drvns, _ := drvnotestore.New(client)
-			note, err := drvns.Create(&notestore.WritableNote{
+			note, err := drvns.Create(context.Background(), &notestore.WritableNote{
 				Description: "desc",
 			})
 
@@ -112,7 +113,7 @@ var _ = Describe("googledrive notestore", func() {
 			code := http.StatusUnauthorized
 			client := utils.ClientWithJSON("{}", code)
 			drvns, _ := drvnotestore.New(client)
-			_, err := drvns.Create(&notestore.WritableNote{
+			_, err := drvns.Create(context.Background(), &notestore.WritableNote{
 				Name: "note",
 			})
 
@@ -124,7 +125,7 @@ var _ = Describe("googledrive notestore", func() {
 			code := http.StatusInternalServerError
 			client := utils.ClientWithJSON("error", code)
 			drvns, _ := drvnotestore.New(client)
-			note, err := drvns.Create(&notestore.WritableNote{
+			note, err := drvns.Create(context.Background(), &notestore.WritableNote{
 				Name: "note",
 			})
 
@@ -159,7 +160,7 @@ var _ = Describe("googledrive notestore", func() {
 			  }`
 			client := utils.ClientWithJSON(j, http.StatusOK)
 			drvns, _ := drvnotestore.New(client)
-			notes, err := drvns.GetAll()
+			notes, err := drvns.GetAll(context.Background())
 
 			Expect(err).ShouldNot(HaveOccurred())
 			Expect(notes).ShouldNot(BeNil())
@@ -174,11 +175,37 @@ var _ = Describe("googledrive notestore", func() {
 			Expect(len(notes[1].Metadata)).Should(Equal(1))
 		})
 
+		It("should return the notes of all pages", func() {
+			client := http.DefaultClient
+			client.Transport = utils.TransportFunc(func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.Query().Get("pageSize")).Should(Equal("1000"))
+				j := `{
+						"nextPageToken": "p2",
+						"files": [ { "id": "nid1", "name": "note1.json" } ]
+					}`
+				if req.URL.Query().Get("pageToken") == "p2" {
+					j = `{ "files": [ { "id": "nid2", "name": "note2.json" } ] }`
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewBufferString(j)),
+					Header:     map[string][]string{"Content-Type": {"application/json"}},
+				}, nil
+			})
+
+			drvns, _ := drvnotestore.New(client)
+			notes, err := drvns.GetAll(context.Background())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(notes).Should(HaveLen(2))
+			Expect(notes[0].ID).Should(Equal("nid1"))
+			Expect(notes[1].ID).Should(Equal("nid2"))
+		})
+
 		It("should return error when authorization failure", func() {
 			code := http.StatusUnauthorized
 			client := utils.ClientWithJSON("{}", code)
 			drvns, _ := drvnotestore.New(client)
-			_, err := drvns.GetAll()
+			_, err := drvns.GetAll(context.Background())
 			Expect(err).Should(HaveOccurred())
 			Expect(err).Should(BeAssignableToTypeOf(errs.NewUnauthorizedError()))
 		})
@@ -187,7 +214,7 @@ var _ = Describe("googledrive notestore", func() {
 			code := http.StatusInternalServerError
 			client := utils.ClientWithJSON("error", code)
 			drvns, _ := drvnotestore.New(client)
-			notes, err := drvns.GetAll()
+			notes, err := drvns.GetAll(context.Background())
 			Expect(err).Should(HaveOccurred())
 			Expect(notes).Should(BeNil())
 		})
@@ -202,11 +229,12 @@ var _ = Describe("googledrive notestore", func() {
 					  "labels": "label1,label2",
 					  "meta!key1": "value1"
 					},
-					"createdTime": "2021-02-12T07:20:50.52Z"
+					"createdTime": "2021-02-12T07:20:50.52Z",
+					"version": "7"
 				  }`
 			client := utils.ClientWithJSON(j, http.StatusOK)
 			drvns, _ := drvnotestore.New(client)
-			note, err := drvns.Get("id")
+			note, err := drvns.Get(context.Background(), "id")
 
 			Expect(err).ShouldNot(HaveOccurred())
 			Expect(note).ShouldNot(BeNil())
@@ -214,20 +242,28 @@ var _ = Describe("googledrive notestore", func() {
 			Expect(note.Name).Should(Equal("note"))
 			Expect(len(note.Labels)).Should(Equal(2))
 			Expect(len(note.Metadata)).Should(Equal(1))
+			Expect(note.Version).Should(Equal(int64(7)))
 		})
 
 		It("should return error when wrong note id", func() {
 			client := utils.ClientWithJSON("{}", http.StatusNotFound)
 			drvns, _ := drvnotestore.New(client)
-			_, err := drvns.Get("id")
+			_, err := drvns.Get(context.Background(), "id")
 			Expect(err).Should(HaveOccurred())
 			Expect(err).Should(BeAssignableToTypeOf(errs.NewNotFoundError("msg")))
 		})
 
+		It("should return error when attachment id", func() {
+			client := utils.ClientWithJSON(`{ "id": "aid", "name": "image.png", "properties": { "type": "attachment", "note": "nid" } }`, http.StatusOK)
+			drvns, _ := drvnotestore.New(client)
+			_, err := drvns.Get(context.Background(), "aid")
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewNotFoundError("msg")))
+		})
+
 		It("should return error when authorization failure", func() {
 			client := utils.ClientWithJSON("{}", http.StatusUnauthorized)
 			drvns, _ := drvnotestore.New(client)
-			_, err := drvns.Get("id")
+			_, err := drvns.Get(context.Background(), "id")
 			Expect(err).Should(HaveOccurred())
 			Expect(err).Should(BeAssignableToTypeOf(errs.NewUnauthorizedError()))
 		})
@@ -236,7 +272,7 @@ var _ = Describe("googledrive notestore", func() {
 			code := http.StatusInternalServerError
 			client := utils.ClientWithJSON("error", code)
 			drvns, _ := drvnotestore.New(client)
-			note, err := drvns.Get("id")
+			note, err := drvns.Get(context.Background(), "id")
 			Expect(err).Should(HaveOccurred())
 			Expect(note).Should(BeNil())
 		})
@@ -251,7 +287,7 @@ var _ = Describe("googledrive notestore", func() {
 				}`
 			client := utils.ClientWithJSON(j, http.StatusOK)
 			drvns, _ := drvnotestore.New(client)
-			note, err := drvns.Update("id", &notestore.WritableNote{
+			note, err := drvns.Update(context.Background(), "id", &notestore.WritableNote{
 				Name:        "note",
 				Description: "desc",
 			})
@@ -263,17 +299,26 @@ var _ = Describe("googledrive notestore", func() {
 		It("should return error when wrong note id", func() {
 			client := utils.ClientWithJSON("{}", http.StatusNotFound)
 			drvns, _ := drvnotestore.New(client)
-			_, err := drvns.Update("id", &notestore.WritableNote{
+			_, err := drvns.Update(context.Background(), "id", &notestore.WritableNote{
 				Name: "note",
 			})
 			Expect(err).Should(HaveOccurred())
 			Expect(err).Should(BeAssignableToTypeOf(errs.NewNotFoundError("msg")))
 		})
 
+		It("should return error when attachment id", func() {
+			client := utils.ClientWithJSON(`{ "id": "aid", "name": "image.png", "properties": { "type": "attachment", "note": "nid" } }`, http.StatusOK)
+			drvns, _ := drvnotestore.New(client)
+			_, err := drvns.Update(context.Background(), "aid", &notestore.WritableNote{
+				Name: "note",
+			})
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewNotFoundError("msg")))
+		})
+
 		It("should return error when authorization failure", func() {
 			client := utils.ClientWithJSON("{}", http.StatusUnauthorized)
 			drvns, _ := drvnotestore.New(client)
-			_, err := drvns.Update("id", &notestore.WritableNote{
+			_, err := drvns.Update(context.Background(), "id", &notestore.WritableNote{
 				Name: "note",
 			})
 			Expect(err).Should(HaveOccurred())
@@ -284,7 +329,7 @@ var _ = Describe("googledrive notestore", func() {
 			code := http.StatusInternalServerError
 			client := utils.ClientWithJSON("error", code)
 			drvns, _ := drvnotestore.New(client)
-			note, err := drvns.Update("id", &notestore.WritableNote{
+			note, err := drvns.Update(context.Background(), "id", &notestore.WritableNote{
 				Name: "note",
 			})
 			Expect(err).Should(HaveOccurred())
@@ -296,14 +341,14 @@ var _ = Describe("googledrive notestore", func() {
 		It("should succeed when correct input", func() {
 			client := utils.ClientWithJSON("{}", http.StatusOK)
 			drvns, _ := drvnotestore.New(client)
-			err := drvns.Delete("id")
+			err := drvns.Delete(context.Background(), "id")
 			Expect(err).ShouldNot(HaveOccurred())
 		})
 
 		It("should return error when wrong note id", func() {
 			client := utils.ClientWithJSON("{}", http.StatusNotFound)
 			drvns, _ := drvnotestore.New(client)
-			err := drvns.Delete("id")
+			err := drvns.Delete(context.Background(), "id")
 			Expect(err).Should(HaveOccurred())
 			Expect(err).Should(BeAssignableToTypeOf(errs.NewNotFoundError("msg")))
 		})
@@ -311,7 +356,7 @@ var _ = Describe("googledrive notestore", func() {
 		It("should return error when authorization failure", func() {
 			client := utils.ClientWithJSON("{}", http.StatusUnauthorized)
 			drvns, _ := drvnotestore.New(client)
-			err := drvns.Delete("id")
+			err := drvns.Delete(context.Background(), "id")
 			Expect(err).Should(HaveOccurred())
 			Expect(err).Should(BeAssignableToTypeOf(errs.NewUnauthorizedError()))
 		})
@@ -320,10 +365,63 @@ var _ = Describe("googledrive notestore", func() {
 			code := http.StatusInternalServerError
 			client := utils.ClientWithJSON("error", code)
 			drvns, _ := drvnotestore.New(client)
-			err := drvns.Delete("id")
+			err := drvns.Delete(context.Background(), "id")
 			Expect(err).Should(HaveOccurred())
 		})
 	})
+
+	Context("note changes", func() {
+		It("should return the start token when correct setup", func() {
+			client := utils.ClientWithJSON(`{ "startPageToken": "10" }`, http.StatusOK)
+			drvns, _ := drvnotestore.New(client)
+			token, err := drvns.StartToken(context.Background())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(token).Should(Equal("10"))
+		})
+
+		It("should return the changes of all pages", func() {
+			client := http.DefaultClient
+			client.Transport = utils.TransportFunc(func(req *http.Request) (*http.Response, error) {
+				j := `{
+						"nextPageToken": "11",
+						"changes": [
+							{ "fileId": "nid1", "file": { "id": "nid1", "name": "note1.json", "version": "3" } },
+							{ "fileId": "aid", "file": { "id": "aid", "properties": { "type": "attachment" } } }
+						]
+					}`
+				if req.URL.Query().Get("pageToken") == "11" {
+					j = `{
+							"newStartPageToken": "12",
+							"changes": [
+								{ "fileId": "nid2", "removed": true },
+								{ "fileId": "nid3", "file": { "id": "nid3", "trashed": true } }
+							]
+						}`
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewBufferString(j)),
+					Header:     map[string][]string{"Content-Type": {"application/json"}},
+				}, nil
+			})
+
+			drvns, _ := drvnotestore.New(client)
+			changes, err := drvns.Changes(context.Background(), "10")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(changes.Token).Should(Equal("12"))
+			Expect(changes.Notes).Should(HaveLen(1))
+			Expect(changes.Notes[0].Name).Should(Equal("note1"))
+			Expect(changes.Notes[0].Version).Should(Equal(int64(3)))
+			Expect(changes.Removed).Should(ConsistOf("nid2", "nid3"))
+		})
+
+		It("should return error when authorization failure", func() {
+			client := utils.ClientWithJSON("{}", http.StatusUnauthorized)
+			drvns, _ := drvnotestore.New(client)
+			_, err := drvns.Changes(context.Background(), "10")
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewUnauthorizedError()))
+		})
+	})
 })
 
 func count(m map[string]string, h func(k, v string) bool) int {
@@ -1,6 +1,7 @@
 package notestore
 
 import (
+	"context"
 	"time"
 
 	"github.com/psewda/typing/internal/utils"
@@ -9,19 +10,38 @@ import (
 // Notestore is the base interface having all operations on note.
 type Notestore interface {
 	// Create builds a new note and saves it on cloud storage.
-	Create(n *WritableNote) (*Note, error)
+	Create(ctx context.Context, n *WritableNote) (*Note, error)
 
 	// GetAll fetches all notes from cloud storage.
-	GetAll() ([]*Note, error)
+	GetAll(ctx context.Context) ([]*Note, error)
 
 	// Get returns the single note from cloud storage.
-	Get(id string) (*Note, error)
+	Get(ctx context.Context, id string) (*Note, error)
 
 	// Update modifies the note and saves it on from cloud storage.
-	Update(id string, n *WritableNote) (*Note, error)
+	Update(ctx context.Context, id string, n *WritableNote) (*Note, error)
 
 	// Delete removes the note from cloud storage.
-	Delete(id string) error
+	Delete(ctx context.Context, id string) error
+}
+
+// Tracker is implemented by the notestore tracking its changes. The sync
+// reads the changes since its last token instead of listing all notes.
+type Tracker interface {
+	// StartToken returns the token of the current state of notes.
+	StartToken(ctx context.Context) (string, error)
+
+	// Changes returns the notes changed since the token, and the token
+	// of next changes.
+	Changes(ctx context.Context, token string) (*Changes, error)
+}
+
+// Changes is the list of notes changed since the token. The removed notes
+// have only the ids.
+type Changes struct {
+	Notes   []*Note  `json:"notes,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Token   string   `json:"token"`
 }
 
 // WritableNote is used for creating and updating note.
@@ -38,7 +58,8 @@ func (n *WritableNote) Validate() error {
 	return utils.ValidateStruct(n, messages)
 }
 
-// Note represent full detail about note.
+// Note represent full detail about note. The version is increased by the
+// cloud storage on every change of the note or its content.
 type Note struct {
 	ID          string            `json:"id,omitempty"`
 	Name        string            `json:"name,omitempty"`
@@ -47,6 +68,17 @@ type Note struct {
 	Metadata    map[string]string `json:"metadata,omitempty"`
 	DateCreated time.Time         `json:"dateCreated,omitempty"`
 	DateUpdated time.Time         `json:"dateUpdated,omitempty"`
+	Version     int64             `json:"version,omitempty"`
+}
+
+// Writable returns the writable fields of the note.
+func (n *Note) Writable() *WritableNote {
+	return &WritableNote{
+		Name:        n.Name,
+		Description: n.Description,
+		Labels:      n.Labels,
+		Metadata:    n.Metadata,
+	}
 }
 
 var messages map[string]string
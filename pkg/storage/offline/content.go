@@ -0,0 +1,85 @@
+package offline
+
+import (
+	"context"
+	"time"
+
+	"github.com/psewda/typing/pkg/signin/userinfo"
+)
+
+// Content is the note content in the local store. It is used by the
+// sectionstore in place of google drive content, and the content upload
+// is queued in the outbox like other changes of the note.
+type Content struct {
+	store    *Store
+	userinfo userinfo.Userinfo
+}
+
+// Download returns the local note content. The note without content
+// returns the empty content.
+func (c *Content) Download(ctx context.Context, nid string) ([]byte, error) {
+	owner, err := c.store.owner(ctx, c.userinfo, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	err = c.store.view(owner, func(idx *index) error {
+		if _, err := idx.get(nid); err != nil {
+			return err
+		}
+		content, err = c.store.readContent(owner, nid)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// Upload saves the local note content and queues it in the outbox. It
+// returns the note version synced last time.
+func (c *Content) Upload(ctx context.Context, nid string, content []byte) (int64, error) {
+	owner, err := c.store.owner(ctx, c.userinfo, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	c.store.mutex.Lock()
+	defer c.store.mutex.Unlock()
+
+	idx, err := c.store.load(owner)
+	if err != nil {
+		return 0, err
+	}
+	rec, err := idx.get(nid)
+	if err != nil {
+		return 0, err
+	}
+	rec.Note.DateUpdated = time.Now().UTC()
+	idx.enqueue(opContent, rec)
+
+	// the outbox is saved first, so the crash in between pushes the old
+	// content instead of losing the new one
+	if err := c.store.save(owner, idx); err != nil {
+		return 0, err
+	}
+	mtime, err := c.store.writeContent(owner, nid, content)
+	if err != nil {
+		return 0, err
+	}
+	rec.Mtime = mtime
+	if err := c.store.save(owner, idx); err != nil {
+		return 0, err
+	}
+	return rec.Note.Version, nil
+}
+
+// NewContent creates a new local note content. The userinfo keys the
+// content per user, the nil userinfo shares it for all users.
+func NewContent(s *Store, ui userinfo.Userinfo) *Content {
+	return &Content{
+		store:    s,
+		userinfo: ui,
+	}
+}
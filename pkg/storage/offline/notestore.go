@@ -0,0 +1,242 @@
+package offline
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/signin/userinfo"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/rs/xid"
+)
+
+// Notestore is the notestore implementation using the local store. The
+// changes are queued in the outbox and pushed to the cloud storage by the
+// sync. The note created locally keeps its local id after the sync.
+type Notestore struct {
+	store    *Store
+	userinfo userinfo.Userinfo
+	remote   Remote
+}
+
+// Create builds a new note in the local store.
+func (ns *Notestore) Create(ctx context.Context, n *notestore.WritableNote) (*notestore.Note, error) {
+	if err := checkNote(n); err != nil {
+		return nil, utils.Error("note validation failed", err)
+	}
+	owner, err := ns.store.owner(ctx, ns.userinfo, ns.remote)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	note := toNote(xid.New().String(), sanitize(n))
+	note.DateCreated = now
+	note.DateUpdated = now
+
+	err = ns.store.update(owner, func(idx *index) error {
+		rec := &record{Note: note}
+		idx.Notes[note.ID] = rec
+		idx.enqueue(opCreate, rec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// GetAll returns all notes from the local store, the oldest note first.
+func (ns *Notestore) GetAll(ctx context.Context) ([]*notestore.Note, error) {
+	owner, err := ns.store.owner(ctx, ns.userinfo, ns.remote)
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []*notestore.Note
+	err = ns.store.view(owner, func(idx *index) error {
+		for _, rec := range idx.Notes {
+			notes = append(notes, rec.Note)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(notes, func(i, j int) bool {
+		return notes[i].DateCreated.Before(notes[j].DateCreated)
+	})
+	return notes, nil
+}
+
+// Get returns the single note from the local store.
+func (ns *Notestore) Get(ctx context.Context, id string) (*notestore.Note, error) {
+	if len(id) == 0 {
+		return nil, errors.New("note id is nil")
+	}
+	owner, err := ns.store.owner(ctx, ns.userinfo, ns.remote)
+	if err != nil {
+		return nil, err
+	}
+
+	var note *notestore.Note
+	err = ns.store.view(owner, func(idx *index) error {
+		rec, err := idx.get(id)
+		if err != nil {
+			return err
+		}
+		note = rec.Note
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// Update modifies the note in the local store.
+func (ns *Notestore) Update(ctx context.Context, id string, n *notestore.WritableNote) (*notestore.Note, error) {
+	if len(id) == 0 {
+		return nil, errors.New("note id is nil")
+	}
+	if err := checkNote(n); err != nil {
+		return nil, utils.Error("note validation failed", err)
+	}
+	owner, err := ns.store.owner(ctx, ns.userinfo, ns.remote)
+	if err != nil {
+		return nil, err
+	}
+
+	var note *notestore.Note
+	err = ns.store.update(owner, func(idx *index) error {
+		rec, err := idx.get(id)
+		if err != nil {
+			return err
+		}
+
+		note = toNote(id, sanitize(n))
+		note.DateCreated = rec.Note.DateCreated
+		note.DateUpdated = time.Now().UTC()
+		note.Version = rec.Note.Version
+		rec.Note = note
+		idx.enqueue(opUpdate, rec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// Delete removes the note and its content from the local store.
+func (ns *Notestore) Delete(ctx context.Context, id string) error {
+	if len(id) == 0 {
+		return errors.New("note id is nil")
+	}
+	owner, err := ns.store.owner(ctx, ns.userinfo, ns.remote)
+	if err != nil {
+		return err
+	}
+
+	err = ns.store.update(owner, func(idx *index) error {
+		rec, err := idx.get(id)
+		if err != nil {
+			return err
+		}
+
+		delete(idx.Notes, id)
+		idx.enqueue(opDelete, rec)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	ns.store.removeContent(owner, id)
+	return nil
+}
+
+// Import saves the note with its id, dates and content in the local store,
+// it replaces the local note having the same id. The imported note is not
+// queued in the outbox, the sync links it to the cloud storage note having
+// the same id.
+func (ns *Notestore) Import(ctx context.Context, n *notestore.Note, content []byte) (*notestore.Note, error) {
+	if n == nil || len(n.ID) == 0 {
+		return nil, errors.New("note id is nil")
+	}
+	if err := checkNote(n.Writable()); err != nil {
+		return nil, utils.Error("note validation failed", err)
+	}
+	owner, err := ns.store.owner(ctx, ns.userinfo, ns.remote)
+	if err != nil {
+		return nil, err
+	}
+
+	note := toNote(n.ID, sanitize(n.Writable()))
+	note.DateCreated = n.DateCreated
+	note.DateUpdated = n.DateUpdated
+
+	// the content is written first, so the crash in between leaves the
+	// content file without note instead of the note without content
+	mtime, err := ns.store.writeContent(owner, note.ID, content)
+	if err != nil {
+		return nil, err
+	}
+	err = ns.store.update(owner, func(idx *index) error {
+		idx.drop(note.ID)
+		idx.Notes[note.ID] = &record{Note: note, Mtime: mtime}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// NewNotestore creates a new notestore using the local store. The userinfo
+// keys the notes per user, the nil userinfo shares them for all users. The
+// remote is used by the background sync of the user, the nil remote leaves
+// the user out of background sync.
+func NewNotestore(s *Store, ui userinfo.Userinfo, r Remote) *Notestore {
+	return &Notestore{
+		store:    s,
+		userinfo: ui,
+		remote:   r,
+	}
+}
+
+func checkNote(n *notestore.WritableNote) error {
+	if n == nil {
+		return errors.New("note is nil")
+	}
+	return n.Validate()
+}
+
+func sanitize(n *notestore.WritableNote) *notestore.WritableNote {
+	note := notestore.WritableNote{
+		Name:        strings.TrimSpace(n.Name),
+		Description: strings.TrimSpace(n.Description),
+	}
+
+	for _, l := range n.Labels {
+		cleanLabel := strings.TrimSpace(l)
+		if len(cleanLabel) > 0 {
+			note.Labels = append(note.Labels, cleanLabel)
+		}
+	}
+	note.Metadata = utils.Sanitize(n.Metadata)
+	return &note
+}
+
+func toNote(id string, n *notestore.WritableNote) *notestore.Note {
+	return &notestore.Note{
+		ID:          id,
+		Name:        n.Name,
+		Description: n.Description,
+		Labels:      n.Labels,
+		Metadata:    n.Metadata,
+	}
+}
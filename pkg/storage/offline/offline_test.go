@@ -0,0 +1,686 @@
+package offline_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/psewda/typing/mocks"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/signin/userinfo"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/offline"
+	"github.com/psewda/typing/pkg/storage/sectionstore"
+	"github.com/psewda/typing/pkg/storage/sectionstore/drvsectionstore"
+)
+
+func TestOffline(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "offline-suite")
+}
+
+var _ = Describe("offline store", func() {
+	var (
+		dir    string
+		store  *offline.Store
+		remote *fakeRemote
+		ns     *offline.Notestore
+		ss     *drvsectionstore.DrvSectionstore
+		syncer *offline.LocalSyncer
+	)
+	ctx := context.Background()
+	config := offline.Config{Interval: time.Minute, MaxBackoff: time.Hour}
+
+	BeforeEach(func() {
+		dir, _ = ioutil.TempDir("", "typing-offline")
+		store, _ = offline.New(dir, config)
+		remote = newFakeRemote()
+		ns = offline.NewNotestore(store, nil, remote)
+		ss = drvsectionstore.NewWithContent(offline.NewContent(store, nil), nil)
+		syncer = offline.NewSyncer(store, nil, remote)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	newNote := func(name string) *notestore.Note {
+		note, err := ns.Create(ctx, &notestore.WritableNote{Name: name})
+		Expect(err).ShouldNot(HaveOccurred())
+		return note
+	}
+
+	newSection := func(nid, name string) *sectionstore.Section {
+		section, err := ss.Create(ctx, nid, &sectionstore.WritableSection{Name: name})
+		Expect(err).ShouldNot(HaveOccurred())
+		return section
+	}
+
+	Context("local store", func() {
+		It("should serve the notes and sections without cloud storage", func() {
+			remote.fail(errors.New("network is unreachable"))
+			note := newNote("  note  ")
+			newSection(note.ID, "section")
+
+			notes, err := ns.GetAll(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(notes).Should(HaveLen(1))
+			Expect(notes[0].Name).Should(Equal("note"))
+
+			sections, err := ss.GetAll(ctx, note.ID, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sections).Should(HaveLen(1))
+			Expect(sections[0].Name).Should(Equal("section"))
+
+			status, _ := syncer.Status(ctx)
+			Expect(status.Pending).Should(Equal(2))
+		})
+
+		It("should throw not found error when wrong note id", func() {
+			_, err := ns.Get(ctx, "wrong")
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewNotFoundError("msg")))
+			_, err = ss.GetAll(ctx, "wrong", nil)
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewNotFoundError("msg")))
+		})
+
+		It("should keep the outbox across restarts", func() {
+			newNote("note")
+			restarted, _ := offline.New(dir, config)
+			status, err := offline.NewSyncer(restarted, nil, remote).Status(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(status.Pending).Should(Equal(1))
+		})
+
+		It("should merge the changes of the same note", func() {
+			note := newNote("note")
+			newSection(note.ID, "s1")
+			newSection(note.ID, "s2")
+			ns.Update(ctx, note.ID, &notestore.WritableNote{Name: "renamed"})
+
+			status, _ := syncer.Status(ctx)
+			Expect(status.Pending).Should(Equal(2))
+		})
+
+		It("should drop the changes when note deleted before sync", func() {
+			note := newNote("note")
+			newSection(note.ID, "section")
+			Expect(ns.Delete(ctx, note.ID)).Should(Succeed())
+
+			status, _ := syncer.Status(ctx)
+			Expect(status.Pending).Should(BeZero())
+		})
+
+		It("should import the note with its id and dates", func() {
+			rn := remote.add("note", `{ "version": 3, "sections": [ { "id": "sid" } ] }`)
+			created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+			imported, err := ns.Import(ctx, &notestore.Note{
+				ID:          rn.ID,
+				Name:        "note",
+				DateCreated: created,
+				DateUpdated: created,
+			}, remote.content[rn.ID])
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(imported.ID).Should(Equal(rn.ID))
+
+			local, _ := ns.Get(ctx, rn.ID)
+			Expect(local.DateCreated).Should(Equal(created))
+			sections, _ := ss.GetAll(ctx, rn.ID, nil)
+			Expect(sections).Should(HaveLen(1))
+
+			status, _ := syncer.Sync(ctx)
+			Expect(status.Pending).Should(BeZero())
+			notes, _ := ns.GetAll(ctx)
+			Expect(notes).Should(HaveLen(1))
+			Expect(remote.notes).Should(HaveLen(1))
+		})
+	})
+
+	Context("push local changes", func() {
+		It("should create the note and its content in cloud storage", func() {
+			note := newNote("note")
+			newSection(note.ID, "section")
+
+			status, err := syncer.Sync(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(status.Pending).Should(BeZero())
+			Expect(status.LastSync).ShouldNot(BeNil())
+
+			Expect(remote.notes).Should(HaveLen(1))
+			rn := remote.only()
+			Expect(rn.Name).Should(Equal("note"))
+			Expect(string(remote.content[rn.ID])).Should(ContainSubstring("section"))
+
+			local, _ := ns.Get(ctx, note.ID)
+			Expect(local.Version).Should(Equal(rn.Version))
+		})
+
+		It("should update and delete the synced note", func() {
+			note := newNote("note")
+			syncer.Sync(ctx)
+			rid := remote.only().ID
+
+			ns.Update(ctx, note.ID, &notestore.WritableNote{Name: "renamed"})
+			syncer.Sync(ctx)
+			Expect(remote.notes[rid].Name).Should(Equal("renamed"))
+
+			ns.Delete(ctx, note.ID)
+			status, err := syncer.Sync(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(status.Pending).Should(BeZero())
+			Expect(remote.notes).Should(BeEmpty())
+		})
+
+		It("should retry the failed change after backoff", func() {
+			newNote("note")
+			remote.fail(errors.New("network is unreachable"))
+
+			status, err := syncer.Sync(ctx)
+			Expect(err).Should(HaveOccurred())
+			status, _ = syncer.Status(ctx)
+			Expect(status.Pending).Should(Equal(1))
+			Expect(status.Failures).Should(HaveLen(1))
+			Expect(status.Failures[0].Attempts).Should(Equal(1))
+			Expect(status.Failures[0].Retry).Should(BeTemporally(">", time.Now()))
+			Expect(status.LastError).ShouldNot(BeEmpty())
+
+			// the change waits for the retry time
+			remote.fail(nil)
+			status, err = syncer.Sync(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(status.Pending).Should(Equal(1))
+			Expect(remote.notes).Should(BeEmpty())
+		})
+
+		It("should stop the push when unauthorized", func() {
+			newNote("note")
+			remote.fail(errs.NewUnauthorizedError())
+			_, err := syncer.Sync(ctx)
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewUnauthorizedError()))
+
+			status, _ := syncer.Status(ctx)
+			Expect(status.Failures).Should(BeEmpty())
+			Expect(status.AuthExpired).Should(BeTrue())
+		})
+
+		It("should stop the background sync until next request when token expired", func() {
+			store, _ = offline.New(dir, offline.Config{Interval: 10 * time.Millisecond, MaxBackoff: time.Hour})
+			ns = offline.NewNotestore(store, nil, remote)
+			syncer = offline.NewSyncer(store, nil, remote)
+			newNote("note")
+			remote.fail(errs.NewUnauthorizedError())
+			stop := store.Start()
+			defer stop()
+
+			// the status without remote doesn't register it again
+			watcher := offline.NewSyncer(store, nil, nil)
+			authExpired := func() bool {
+				status, _ := watcher.Status(ctx)
+				return status.AuthExpired
+			}
+			Eventually(authExpired).Should(BeTrue())
+
+			remote.fail(nil)
+			Consistently(remote.count, 100*time.Millisecond).Should(BeZero())
+
+			syncer.Status(ctx)
+			Eventually(remote.count).Should(Equal(1))
+			Eventually(authExpired).Should(BeFalse())
+		})
+	})
+
+	Context("pull cloud storage changes", func() {
+		It("should add the new note with its content", func() {
+			rn := remote.add("remote-note", `{"version":3,"sections":[{"id":"s1","name":"remote","kind":"data"}]}`)
+
+			_, err := syncer.Sync(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			note, err := ns.Get(ctx, rn.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(note.Name).Should(Equal("remote-note"))
+			section, err := ss.Get(ctx, rn.ID, "s1")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(section.Name).Should(Equal("remote"))
+		})
+
+		It("should update the changed note and remove the deleted note", func() {
+			rn1 := remote.add("note1", "")
+			rn2 := remote.add("note2", "")
+			syncer.Sync(ctx)
+
+			remote.Update(ctx, rn1.ID, &notestore.WritableNote{Name: "renamed"})
+			remote.Delete(ctx, rn2.ID)
+			syncer.Sync(ctx)
+
+			notes, _ := ns.GetAll(ctx)
+			Expect(notes).Should(HaveLen(1))
+			Expect(notes[0].Name).Should(Equal("renamed"))
+		})
+	})
+
+	Context("conflict detection", func() {
+		var note *notestore.Note
+		var rid string
+
+		BeforeEach(func() {
+			note = newNote("note")
+			newSection(note.ID, "local")
+			syncer.Sync(ctx)
+			rid = remote.only().ID
+
+			// the note is changed on both sides
+			newSection(note.ID, "local-2")
+			remote.Upload(ctx, rid, []byte(`{"version":3,"sections":[{"id":"r1","name":"remote","kind":"data"}]}`))
+		})
+
+		It("should report the conflict without overwriting cloud storage", func() {
+			status, err := syncer.Sync(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(status.Pending).Should(Equal(1))
+			Expect(status.Conflicts).Should(HaveLen(1))
+			Expect(status.Conflicts[0].NoteID).Should(Equal(note.ID))
+			Expect(status.Conflicts[0].Name).Should(Equal("note"))
+			Expect(string(remote.content[rid])).ShouldNot(ContainSubstring("local-2"))
+
+			// the conflicted note is not pulled either
+			sections, _ := ss.GetAll(ctx, note.ID, nil)
+			Expect(sections).Should(HaveLen(2))
+		})
+
+		It("should push the local note when keeping local", func() {
+			syncer.Sync(ctx)
+			Expect(syncer.Resolve(ctx, note.ID, offline.KeepLocal)).Should(Succeed())
+
+			status, err := syncer.Sync(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(status.Pending).Should(BeZero())
+			Expect(string(remote.content[rid])).Should(ContainSubstring("local-2"))
+		})
+
+		It("should pull the cloud storage note when keeping remote", func() {
+			syncer.Sync(ctx)
+			Expect(syncer.Resolve(ctx, note.ID, offline.KeepRemote)).Should(Succeed())
+
+			status, _ := syncer.Status(ctx)
+			Expect(status.Pending).Should(BeZero())
+			sections, _ := ss.GetAll(ctx, note.ID, nil)
+			Expect(sections).Should(HaveLen(1))
+			Expect(sections[0].Name).Should(Equal("remote"))
+		})
+
+		It("should throw error when no conflict or wrong keep", func() {
+			err := syncer.Resolve(ctx, note.ID, offline.KeepLocal)
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewNotFoundError("msg")))
+			err = syncer.Resolve(ctx, note.ID, "none")
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+		})
+
+		It("should keep the local note as copy when keeping both", func() {
+			syncer.Sync(ctx)
+			Expect(syncer.Resolve(ctx, note.ID, offline.KeepBoth)).Should(Succeed())
+
+			status, err := syncer.Sync(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(status.Pending).Should(BeZero())
+			Expect(remote.notes).Should(HaveLen(2))
+
+			notes, _ := ns.GetAll(ctx)
+			Expect(notes).Should(HaveLen(2))
+			sections, _ := ss.GetAll(ctx, note.ID, nil)
+			Expect(sections[0].Name).Should(Equal("remote"))
+			copied := notes[0]
+			if copied.ID == note.ID {
+				copied = notes[1]
+			}
+			Expect(copied.Name).Should(Equal("note (conflict copy)"))
+			sections, _ = ss.GetAll(ctx, copied.ID, nil)
+			Expect(sections).Should(HaveLen(2))
+		})
+	})
+
+	Context("conflict policy", func() {
+		withPolicy := func(policy string) {
+			c := config
+			c.Policy = policy
+			store, _ = offline.New(dir, c)
+			ns = offline.NewNotestore(store, nil, remote)
+			ss = drvsectionstore.NewWithContent(offline.NewContent(store, nil), nil)
+			syncer = offline.NewSyncer(store, nil, remote)
+		}
+
+		conflict := func(localLast bool) (*notestore.Note, string) {
+			note := newNote("note")
+			newSection(note.ID, "local")
+			syncer.Sync(ctx)
+			rid := remote.only().ID
+
+			j := []byte(`{"version":3,"sections":[{"id":"r1","name":"remote","kind":"data"}]}`)
+			if localLast {
+				remote.Upload(ctx, rid, j)
+				time.Sleep(5 * time.Millisecond)
+				newSection(note.ID, "local-2")
+			} else {
+				newSection(note.ID, "local-2")
+				time.Sleep(5 * time.Millisecond)
+				remote.Upload(ctx, rid, j)
+			}
+			return note, rid
+		}
+
+		It("should keep the remote note when changed last", func() {
+			withPolicy(offline.PolicyLastWriterWins)
+			note, _ := conflict(false)
+
+			status, err := syncer.Sync(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(status.Policy).Should(Equal(offline.PolicyLastWriterWins))
+			Expect(status.Conflicts).Should(BeEmpty())
+			sections, _ := ss.GetAll(ctx, note.ID, nil)
+			Expect(sections).Should(HaveLen(1))
+			Expect(sections[0].Name).Should(Equal("remote"))
+		})
+
+		It("should keep the local note when changed last", func() {
+			withPolicy(offline.PolicyLastWriterWins)
+			_, rid := conflict(true)
+
+			syncer.Sync(ctx)
+			status, err := syncer.Sync(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(status.Pending).Should(BeZero())
+			Expect(string(remote.content[rid])).Should(ContainSubstring("local-2"))
+		})
+
+		It("should keep both notes when keep both policy", func() {
+			withPolicy(offline.PolicyKeepBoth)
+			conflict(true)
+
+			syncer.Sync(ctx)
+			status, err := syncer.Sync(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(status.Pending).Should(BeZero())
+			Expect(remote.notes).Should(HaveLen(2))
+		})
+
+		It("should throw error when unknown policy", func() {
+			Expect(offline.CheckPolicy("newest")).ShouldNot(Succeed())
+			_, err := offline.New(dir, offline.Config{Policy: "newest"})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("change tracking", func() {
+		It("should pull only the changes when remote tracks them", func() {
+			tracked := &trackedRemote{newFakeRemote()}
+			remote = tracked.fakeRemote
+			ns = offline.NewNotestore(store, nil, tracked)
+			syncer = offline.NewSyncer(store, nil, tracked)
+			rn1 := remote.add("note1", "")
+			rn2 := remote.add("note2", "")
+
+			_, err := syncer.Sync(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(remote.listed).Should(Equal(1))
+
+			remote.Delete(ctx, rn1.ID)
+			remote.Update(ctx, rn2.ID, &notestore.WritableNote{Name: "renamed"})
+			rn3 := remote.add("note3", "")
+			_, err = syncer.Sync(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(remote.listed).Should(Equal(1))
+
+			notes, _ := ns.GetAll(ctx)
+			Expect(notes).Should(HaveLen(2))
+			note, _ := ns.Get(ctx, rn2.ID)
+			Expect(note.Name).Should(Equal("renamed"))
+			_, err = ns.Get(ctx, rn3.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should push the content file changed by other program", func() {
+			note := newNote("note")
+			newSection(note.ID, "section")
+			syncer.Sync(ctx)
+			rid := remote.only().ID
+
+			path := filepath.Join(dir, "-", "content", note.ID+".json")
+			j := []byte(`{"version":3,"sections":[{"id":"s1","name":"edited","kind":"data"}]}`)
+			Expect(ioutil.WriteFile(path, j, 0600)).Should(Succeed())
+			later := time.Now().Add(time.Minute)
+			os.Chtimes(path, later, later)
+
+			status, err := syncer.Sync(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(status.Pending).Should(BeZero())
+			Expect(string(remote.content[rid])).Should(ContainSubstring("edited"))
+
+			// the unchanged file is not pushed again
+			version := remote.notes[rid].Version
+			syncer.Sync(ctx)
+			Expect(remote.notes[rid].Version).Should(Equal(version))
+		})
+	})
+
+	Context("remembered userinfo", func() {
+		It("should return the remembered user when userinfo fails", func() {
+			mockCtrl := gomock.NewController(GinkgoT())
+			defer mockCtrl.Finish()
+			mockUserinfo := mocks.NewMockUserinfo(mockCtrl)
+			mockUserinfo.EXPECT().Get(gomock.Any()).Return(&userinfo.User{ID: "u1"}, nil).Times(1)
+
+			user, err := store.Userinfo("token", mockUserinfo).Get(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(user.ID).Should(Equal("u1"))
+
+			restarted, _ := offline.New(dir, config)
+			user, err = restarted.Userinfo("token", mockUserinfo).Get(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(user.ID).Should(Equal("u1"))
+		})
+
+		It("should keep the notes per user", func() {
+			mockCtrl := gomock.NewController(GinkgoT())
+			defer mockCtrl.Finish()
+			ui1 := mocks.NewMockUserinfo(mockCtrl)
+			ui1.EXPECT().Get(gomock.Any()).Return(&userinfo.User{ID: "u1"}, nil).AnyTimes()
+			ui2 := mocks.NewMockUserinfo(mockCtrl)
+			ui2.EXPECT().Get(gomock.Any()).Return(&userinfo.User{ID: "u2"}, nil).AnyTimes()
+
+			offline.NewNotestore(store, ui1, nil).Create(ctx, &notestore.WritableNote{Name: "note"})
+			notes, _ := offline.NewNotestore(store, ui2, nil).GetAll(ctx)
+			Expect(notes).Should(BeEmpty())
+		})
+	})
+})
+
+// fakeRemote is the in-memory cloud storage increasing the note version
+// on every change.
+type fakeRemote struct {
+	notes   map[string]*notestore.Note
+	content map[string][]byte
+	changes []string
+	listed  int
+	seq     int
+	err     error
+	mutex   sync.Mutex
+}
+
+func newFakeRemote() *fakeRemote {
+	return &fakeRemote{
+		notes:   make(map[string]*notestore.Note),
+		content: make(map[string][]byte),
+	}
+}
+
+func (r *fakeRemote) fail(err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.err = err
+}
+
+func (r *fakeRemote) count() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.notes)
+}
+
+func (r *fakeRemote) only() *notestore.Note {
+	for _, n := range r.notes {
+		return n
+	}
+	return nil
+}
+
+func (r *fakeRemote) add(name, content string) *notestore.Note {
+	note, _ := r.Create(context.Background(), &notestore.WritableNote{Name: name})
+	if len(content) > 0 {
+		r.Upload(context.Background(), note.ID, []byte(content))
+	}
+	return r.notes[note.ID]
+}
+
+func (r *fakeRemote) Create(ctx context.Context, n *notestore.WritableNote) (*notestore.Note, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	r.seq++
+	note := &notestore.Note{ID: fmt.Sprintf("remote-%d", r.seq), Name: n.Name, Version: 1, DateUpdated: time.Now().UTC()}
+	r.notes[note.ID] = note
+	r.changes = append(r.changes, note.ID)
+	copied := *note
+	return &copied, nil
+}
+
+func (r *fakeRemote) GetAll(ctx context.Context) ([]*notestore.Note, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	r.listed++
+	var notes []*notestore.Note
+	for _, n := range r.notes {
+		copied := *n
+		notes = append(notes, &copied)
+	}
+	return notes, nil
+}
+
+func (r *fakeRemote) Get(ctx context.Context, id string) (*notestore.Note, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	n, ok := r.notes[id]
+	if !ok {
+		return nil, errs.NewNotFoundError("note not found")
+	}
+	copied := *n
+	return &copied, nil
+}
+
+func (r *fakeRemote) Update(ctx context.Context, id string, n *notestore.WritableNote) (*notestore.Note, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	note, ok := r.notes[id]
+	if !ok {
+		return nil, errs.NewNotFoundError("note not found")
+	}
+	note.Name = n.Name
+	note.Version++
+	note.DateUpdated = time.Now().UTC()
+	r.changes = append(r.changes, id)
+	copied := *note
+	return &copied, nil
+}
+
+func (r *fakeRemote) Delete(ctx context.Context, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	delete(r.notes, id)
+	delete(r.content, id)
+	r.changes = append(r.changes, id)
+	return nil
+}
+
+func (r *fakeRemote) Download(ctx context.Context, nid string) ([]byte, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.content[nid], nil
+}
+
+func (r *fakeRemote) Upload(ctx context.Context, nid string, content []byte) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err != nil {
+		return 0, r.err
+	}
+	note, ok := r.notes[nid]
+	if !ok {
+		return 0, errs.NewNotFoundError("note not found")
+	}
+	note.Version++
+	note.DateUpdated = time.Now().UTC()
+	r.content[nid] = content
+	r.changes = append(r.changes, nid)
+	return note.Version, nil
+}
+
+// trackedRemote is the fake cloud storage tracking its changes, the token
+// is the count of changes.
+type trackedRemote struct {
+	*fakeRemote
+}
+
+func (r *trackedRemote) StartToken(ctx context.Context) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return strconv.Itoa(len(r.changes)), nil
+}
+
+func (r *trackedRemote) Changes(ctx context.Context, token string) (*notestore.Changes, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	from, err := strconv.Atoi(token)
+	if err != nil || from > len(r.changes) {
+		return nil, errors.New("invalid token")
+	}
+
+	changes := &notestore.Changes{Token: strconv.Itoa(len(r.changes))}
+	seen := make(map[string]bool)
+	for _, id := range r.changes[from:] {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if n, ok := r.notes[id]; ok {
+			copied := *n
+			changes.Notes = append(changes.Notes, &copied)
+		} else {
+			changes.Removed = append(changes.Removed, id)
+		}
+	}
+	return changes, nil
+}
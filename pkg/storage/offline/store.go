@@ -0,0 +1,406 @@
+package offline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/signin/userinfo"
+	"github.com/psewda/typing/pkg/storage/notestore"
+)
+
+const (
+	opCreate  = "create"
+	opUpdate  = "update"
+	opContent = "content"
+	opDelete  = "delete"
+
+	indexFile = "index.json"
+	usersFile = "users.json"
+
+	// PolicyManual leaves the conflicts for the user to resolve.
+	PolicyManual = "manual"
+
+	// PolicyLastWriterWins keeps the note changed last, the local change
+	// time is compared with the cloud storage note update time.
+	PolicyLastWriterWins = "last-writer-wins"
+
+	// PolicyKeepBoth keeps the cloud storage note, and the local note is
+	// kept as a new copy.
+	PolicyKeepBoth = "keep-both"
+)
+
+// Config has the durations of background sync.
+type Config struct {
+	// Interval is the duration between background syncs. It is also the
+	// first delay before retrying the failed change.
+	Interval time.Duration
+
+	// MaxBackoff is the max delay before retrying the failed change, the
+	// delay is doubled on every failure.
+	MaxBackoff time.Duration
+
+	// Policy resolves the conflicts found by the sync, the empty policy
+	// is the manual policy.
+	Policy string
+}
+
+// DefaultConfig returns the config used by the local store.
+func DefaultConfig() Config {
+	return Config{
+		Interval:   time.Minute,
+		MaxBackoff: 30 * time.Minute,
+		Policy:     PolicyManual,
+	}
+}
+
+// CheckPolicy validates the conflict policy, the empty policy is valid.
+func CheckPolicy(policy string) error {
+	switch policy {
+	case utils.Empty, PolicyManual, PolicyLastWriterWins, PolicyKeepBoth:
+		return nil
+	default:
+		return fmt.Errorf("conflict policy '%s' is not supported", policy)
+	}
+}
+
+// Store is the local copy of the notes on disk. All writes go to the local
+// files and are recorded in the persistent outbox, the sync pushes them to
+// the cloud storage later. The notes of each user are kept in a separate
+// directory having the index of notes and outbox, and the note contents.
+type Store struct {
+	dir       string
+	config    Config
+	remotes   map[string]Remote
+	mutex     sync.Mutex
+	syncMutex sync.Mutex
+}
+
+// record is the local copy of the note. The note version is the version of
+// cloud storage note when it was synced last time. The mtime is the time of
+// content file written last time by the store, the content file changed by
+// other programs is pushed by the sync.
+type record struct {
+	Note     *notestore.Note `json:"note"`
+	RemoteID string          `json:"remoteId,omitempty"`
+	Mtime    time.Time       `json:"mtime,omitempty"`
+}
+
+// change is the local change waiting in the outbox. The change is pushed
+// with the current local note, so the same change is not queued again and
+// its revision is increased instead. The deleted note has no record, so
+// the delete change keeps the cloud storage id and version itself. The date
+// is the time of latest local change.
+type change struct {
+	Seq      uint64    `json:"seq"`
+	Op       string    `json:"op"`
+	NoteID   string    `json:"noteId"`
+	RemoteID string    `json:"remoteId,omitempty"`
+	Version  int64     `json:"version,omitempty"`
+	Rev      int       `json:"rev"`
+	Date     time.Time `json:"date"`
+	Attempts int       `json:"attempts,omitempty"`
+	Retry    time.Time `json:"retry,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Conflict string    `json:"conflict,omitempty"`
+}
+
+// index has the notes and outbox of the user. The token is the change
+// token of the cloud storage tracking its changes.
+type index struct {
+	Notes       map[string]*record `json:"notes"`
+	Outbox      []*change          `json:"outbox"`
+	Seq         uint64             `json:"seq"`
+	Token       string             `json:"token,omitempty"`
+	LastSync    *time.Time         `json:"lastSync,omitempty"`
+	LastError   string             `json:"lastError,omitempty"`
+	AuthExpired bool               `json:"authExpired,omitempty"`
+}
+
+// Start runs the sync of all users in background. The users are known from
+// the requests served by the local store, so the user is synced using the
+// cloud storage of its latest request. The user whose access token is
+// expired is not synced until its next request. It returns the func stopping the sync.
+func (s *Store) Start() func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.syncAll(ctx)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// New creates a new local store in the directory. The zero config values
+// use the default config.
+func New(dir string, c Config) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, utils.Error("local store creation error", err)
+	}
+	if c.Interval <= 0 {
+		c.Interval = DefaultConfig().Interval
+	}
+	if c.MaxBackoff < c.Interval {
+		c.MaxBackoff = c.Interval
+	}
+	if err := CheckPolicy(c.Policy); err != nil {
+		return nil, err
+	}
+	if len(c.Policy) == 0 {
+		c.Policy = PolicyManual
+	}
+
+	return &Store{
+		dir:     dir,
+		config:  c,
+		remotes: make(map[string]Remote),
+	}, nil
+}
+
+// owner returns the user id keying the local files. The remote is kept for
+// the background sync of the user. The nil userinfo keeps a single user.
+func (s *Store) owner(ctx context.Context, ui userinfo.Userinfo, r Remote) (string, error) {
+	owner := "-"
+	if ui != nil {
+		user, err := ui.Get(ctx)
+		if err != nil {
+			return utils.Empty, err
+		}
+		owner = user.ID
+	}
+
+	if r != nil {
+		s.mutex.Lock()
+		s.remotes[owner] = r
+		s.mutex.Unlock()
+	}
+	return owner, nil
+}
+
+func (s *Store) userDir(owner string) string {
+	return filepath.Join(s.dir, url.PathEscape(owner))
+}
+
+func (s *Store) contentPath(owner, nid string) string {
+	return filepath.Join(s.userDir(owner), "content", url.PathEscape(nid)+".json")
+}
+
+// load reads the index of user. The caller must hold the mutex.
+func (s *Store) load(owner string) (*index, error) {
+	idx := &index{Notes: make(map[string]*record)}
+	if err := readJSON(filepath.Join(s.userDir(owner), indexFile), idx); err != nil {
+		return nil, err
+	}
+	if idx.Notes == nil {
+		idx.Notes = make(map[string]*record)
+	}
+	return idx, nil
+}
+
+// save writes the index of user. The caller must hold the mutex.
+func (s *Store) save(owner string, idx *index) error {
+	return writeJSON(filepath.Join(s.userDir(owner), indexFile), idx)
+}
+
+// update applies the func on the index of user and saves it, the index is
+// not saved when the func fails.
+func (s *Store) update(owner string, fn func(idx *index) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	idx, err := s.load(owner)
+	if err != nil {
+		return err
+	}
+	if err := fn(idx); err != nil {
+		return err
+	}
+	return s.save(owner, idx)
+}
+
+// view applies the func on the index of user without saving it.
+func (s *Store) view(owner string, fn func(idx *index) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	idx, err := s.load(owner)
+	if err != nil {
+		return err
+	}
+	return fn(idx)
+}
+
+func (s *Store) readContent(owner, nid string) ([]byte, error) {
+	content, err := ioutil.ReadFile(s.contentPath(owner, nid))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, utils.Error("local note content read error", err)
+	}
+	return content, nil
+}
+
+// writeContent saves the note content, and returns the mtime of content
+// file.
+func (s *Store) writeContent(owner, nid string, content []byte) (time.Time, error) {
+	path := s.contentPath(owner, nid)
+	if err := writeFile(path, content); err != nil {
+		return time.Time{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, utils.Error("local store write error", err)
+	}
+	return info.ModTime().UTC(), nil
+}
+
+func (s *Store) removeContent(owner, nid string) {
+	os.Remove(s.contentPath(owner, nid))
+}
+
+// enqueue adds the change of note in the outbox. The pending change of the
+// same kind is pushed with the latest note, so its revision is increased
+// instead. The note never pushed is removed from the outbox on deletion.
+func (idx *index) enqueue(op string, rec *record) {
+	nid := rec.Note.ID
+	if op == opDelete {
+		idx.drop(nid)
+		if len(rec.RemoteID) > 0 {
+			idx.append(&change{
+				Op:       opDelete,
+				NoteID:   nid,
+				RemoteID: rec.RemoteID,
+				Version:  rec.Note.Version,
+				Date:     time.Now().UTC(),
+			})
+		}
+		return
+	}
+
+	for _, c := range idx.Outbox {
+		if c.NoteID != nid {
+			continue
+		}
+		// the create change pushes the latest metadata too
+		if c.Op == op || (op == opUpdate && c.Op == opCreate) {
+			c.Rev++
+			c.Date = time.Now().UTC()
+			return
+		}
+	}
+	idx.append(&change{Op: op, NoteID: nid, Date: time.Now().UTC()})
+}
+
+func (idx *index) append(c *change) {
+	idx.Seq++
+	c.Seq = idx.Seq
+	idx.Outbox = append(idx.Outbox, c)
+}
+
+func (idx *index) find(seq uint64) *change {
+	for _, c := range idx.Outbox {
+		if c.Seq == seq {
+			return c
+		}
+	}
+	return nil
+}
+
+func (idx *index) remove(seq uint64) {
+	for i, c := range idx.Outbox {
+		if c.Seq == seq {
+			idx.Outbox = append(idx.Outbox[:i], idx.Outbox[i+1:]...)
+			return
+		}
+	}
+}
+
+// pending returns the ids of notes and cloud storage notes having changes
+// in the outbox.
+func (idx *index) pending() (map[string]bool, map[string]bool) {
+	notes := make(map[string]bool)
+	remotes := make(map[string]bool)
+	for _, c := range idx.Outbox {
+		notes[c.NoteID] = true
+		if len(c.RemoteID) > 0 {
+			remotes[c.RemoteID] = true
+		}
+	}
+	return notes, remotes
+}
+
+func (idx *index) byRemote(rid string) *record {
+	for _, rec := range idx.Notes {
+		if rec.RemoteID == rid {
+			return rec
+		}
+	}
+	return nil
+}
+
+func (idx *index) get(nid string) (*record, error) {
+	rec, ok := idx.Notes[nid]
+	if !ok {
+		msg := fmt.Sprintf("note with id '%s' not found", nid)
+		return nil, errs.NewNotFoundError(msg)
+	}
+	return rec, nil
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return utils.Error("local store read error", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return utils.Error("local store read error", err)
+	}
+	return nil
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return utils.Error("local store write error", err)
+	}
+	return writeFile(path, data)
+}
+
+// writeFile replaces the file atomically, so the crash never leaves the
+// partially written file.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return utils.Error("local store write error", err)
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return utils.Error("local store write error", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return utils.Error("local store write error", err)
+	}
+	return nil
+}
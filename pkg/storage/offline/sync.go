@@ -0,0 +1,951 @@
+package offline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/signin/userinfo"
+	"github.com/psewda/typing/pkg/storage/notestore"
+	"github.com/psewda/typing/pkg/storage/sectionstore/drvsectionstore"
+	"github.com/rs/xid"
+)
+
+const (
+	// KeepLocal resolves the conflict by pushing the local note.
+	KeepLocal = "local"
+
+	// KeepRemote resolves the conflict by pulling the cloud storage note.
+	KeepRemote = "remote"
+
+	// KeepBoth resolves the conflict by pulling the cloud storage note, and
+	// pushing the local note as a new note.
+	KeepBoth = "both"
+
+	// copySuffix is added to the name of the local note kept as a copy.
+	copySuffix = " (conflict copy)"
+
+	// syncTimeout is the max duration of background sync of a single user.
+	syncTimeout = 10 * time.Minute
+)
+
+// Remote is the cloud storage which the local store is synced with. The
+// note version must increase on every change of the note or its content,
+// it is used to detect the notes changed on both sides.
+type Remote interface {
+	notestore.Notestore
+	drvsectionstore.Content
+}
+
+// NewRemote joins the notestore and note content of the cloud storage. The
+// remote tracks the changes when the notestore is a tracker.
+func NewRemote(ns notestore.Notestore, c drvsectionstore.Content) Remote {
+	r := &remote{
+		Notestore: ns,
+		Content:   c,
+	}
+	if t, ok := ns.(notestore.Tracker); ok {
+		return &trackedRemote{remote: r, Tracker: t}
+	}
+	return r
+}
+
+type remote struct {
+	notestore.Notestore
+	drvsectionstore.Content
+}
+
+type trackedRemote struct {
+	*remote
+	notestore.Tracker
+}
+
+// Syncer is the interface having the sync operations of the local store.
+type Syncer interface {
+	// Status returns the pending changes and conflicts of the local store.
+	Status(ctx context.Context) (*Status, error)
+
+	// Sync pushes the local changes to the cloud storage, and then pulls
+	// the cloud storage changes in the local store.
+	Sync(ctx context.Context) (*Status, error)
+
+	// Resolve settles the conflict of the note by keeping the local note,
+	// the cloud storage note or both.
+	Resolve(ctx context.Context, nid, keep string) error
+}
+
+// Status is the sync state of the local store. The auth expired state tells
+// the access token of background sync is expired, the background sync is
+// stopped until the next request of user brings a new token.
+type Status struct {
+	Policy      string      `json:"policy"`
+	Pending     int         `json:"pending"`
+	Changes     []*Change   `json:"changes,omitempty"`
+	Conflicts   []*Conflict `json:"conflicts,omitempty"`
+	Failures    []*Failure  `json:"failures,omitempty"`
+	LastSync    *time.Time  `json:"lastSync,omitempty"`
+	LastError   string      `json:"lastError,omitempty"`
+	AuthExpired bool        `json:"authExpired,omitempty"`
+}
+
+// Change is the local change waiting for the push.
+type Change struct {
+	NoteID string    `json:"noteId"`
+	Name   string    `json:"name,omitempty"`
+	Op     string    `json:"op"`
+	Date   time.Time `json:"date"`
+}
+
+// Conflict is the note changed both locally and in the cloud storage. The
+// local changes of the note are not pushed until it is resolved.
+type Conflict struct {
+	NoteID string `json:"noteId"`
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// Failure is the local change which failed to push, it is retried after
+// the retry time.
+type Failure struct {
+	NoteID   string    `json:"noteId"`
+	Op       string    `json:"op"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	Retry    time.Time `json:"retry"`
+}
+
+// LocalSyncer is the syncer of the user's local store.
+type LocalSyncer struct {
+	store    *Store
+	userinfo userinfo.Userinfo
+	remote   Remote
+}
+
+// Status returns the sync state of the user's local store.
+func (ls *LocalSyncer) Status(ctx context.Context) (*Status, error) {
+	owner, err := ls.store.owner(ctx, ls.userinfo, ls.remote)
+	if err != nil {
+		return nil, err
+	}
+	return ls.store.status(owner)
+}
+
+// Sync runs the sync of the user's local store now.
+func (ls *LocalSyncer) Sync(ctx context.Context) (*Status, error) {
+	owner, err := ls.store.owner(ctx, ls.userinfo, ls.remote)
+	if err != nil {
+		return nil, err
+	}
+	if err := ls.store.sync(ctx, owner, ls.remote); err != nil {
+		return nil, err
+	}
+	return ls.store.status(owner)
+}
+
+// Resolve settles the conflict of the note. The local note is pushed on
+// next sync, and the cloud storage note is pulled right away.
+func (ls *LocalSyncer) Resolve(ctx context.Context, nid, keep string) error {
+	if keep != KeepLocal && keep != KeepRemote && keep != KeepBoth {
+		msg := fmt.Sprintf("keep must be one of '%s', '%s' or '%s'", KeepLocal, KeepRemote, KeepBoth)
+		return errs.NewValidationError(msg)
+	}
+	owner, err := ls.store.owner(ctx, ls.userinfo, ls.remote)
+	if err != nil {
+		return err
+	}
+	return ls.store.resolve(ctx, owner, ls.remote, nid, keep)
+}
+
+// NewSyncer creates a new syncer of the user's local store.
+func NewSyncer(s *Store, ui userinfo.Userinfo, r Remote) *LocalSyncer {
+	return &LocalSyncer{
+		store:    s,
+		userinfo: ui,
+		remote:   r,
+	}
+}
+
+func (s *Store) status(owner string) (*Status, error) {
+	var st *Status
+	err := s.view(owner, func(idx *index) error {
+		st = &Status{
+			Policy:      s.config.Policy,
+			Pending:     len(idx.Outbox),
+			LastSync:    idx.LastSync,
+			LastError:   idx.LastError,
+			AuthExpired: idx.AuthExpired,
+		}
+		seen := make(map[string]bool)
+		for _, c := range idx.Outbox {
+			name := utils.Empty
+			if rec, ok := idx.Notes[c.NoteID]; ok {
+				name = rec.Note.Name
+			}
+			st.Changes = append(st.Changes, &Change{NoteID: c.NoteID, Name: name, Op: c.Op, Date: c.Date})
+
+			if len(c.Conflict) > 0 && !seen[c.NoteID] {
+				seen[c.NoteID] = true
+				st.Conflicts = append(st.Conflicts, &Conflict{NoteID: c.NoteID, Name: name, Reason: c.Conflict})
+			} else if len(c.Error) > 0 {
+				st.Failures = append(st.Failures, &Failure{
+					NoteID:   c.NoteID,
+					Op:       c.Op,
+					Error:    c.Error,
+					Attempts: c.Attempts,
+					Retry:    c.Retry,
+				})
+			}
+		}
+		return nil
+	})
+	return st, err
+}
+
+func (s *Store) syncAll(ctx context.Context) {
+	s.mutex.Lock()
+	remotes := make(map[string]Remote, len(s.remotes))
+	for owner, r := range s.remotes {
+		remotes[owner] = r
+	}
+	s.mutex.Unlock()
+
+	// the failure is kept in the status of user
+	for owner, r := range remotes {
+		if ctx.Err() != nil {
+			return
+		}
+		c, cancel := context.WithTimeout(ctx, syncTimeout)
+		err := s.sync(c, owner, r)
+		cancel()
+
+		// the expired access token fails all next syncs, so the remote
+		// is dropped until the next request of user registers it again
+		if _, ok := err.(*errs.UnauthorizedError); ok {
+			s.mutex.Lock()
+			if s.remotes[owner] == r {
+				delete(s.remotes, owner)
+			}
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// sync queues the content files changed by other programs, pushes the
+// outbox, settles the conflicts by the policy and pulls the cloud storage
+// notes. The syncs are serialized, so the same change is never pushed twice.
+func (s *Store) sync(ctx context.Context, owner string, r Remote) error {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
+	err := s.scan(owner)
+	if err == nil {
+		err = s.push(ctx, owner, r)
+	}
+	if err == nil {
+		err = s.settle(ctx, owner, r)
+	}
+	if err == nil {
+		err = s.pull(ctx, owner, r)
+	}
+
+	serr := s.update(owner, func(idx *index) error {
+		if err != nil {
+			_, idx.AuthExpired = err.(*errs.UnauthorizedError)
+			idx.LastError = err.Error()
+			return nil
+		}
+		now := time.Now().UTC()
+		idx.LastSync = &now
+		idx.LastError = ""
+		idx.AuthExpired = false
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+// scan queues the content files changed by other programs. The content file
+// is changed when its mtime differs from the mtime written by the store.
+func (s *Store) scan(owner string) error {
+	return s.update(owner, func(idx *index) error {
+		pending, _ := idx.pending()
+		for id, rec := range idx.Notes {
+			info, err := os.Stat(s.contentPath(owner, id))
+			if err != nil {
+				continue
+			}
+			mtime := info.ModTime().UTC()
+			if mtime.Equal(rec.Mtime) {
+				continue
+			}
+
+			// the mtime unknown before is taken as is, and the note never
+			// synced has no cloud storage note to update
+			known := !rec.Mtime.IsZero()
+			rec.Mtime = mtime
+			if known && (len(rec.RemoteID) > 0 || pending[id]) {
+				rec.Note.DateUpdated = mtime
+				idx.enqueue(opContent, rec)
+			}
+		}
+		return nil
+	})
+}
+
+// push sends the outbox changes in order. The failed change blocks the later
+// changes of the same note until its retry, the changes of other notes are
+// pushed anyway. The unauthorized error stops the push, because no other
+// change can succeed.
+func (s *Store) push(ctx context.Context, owner string, r Remote) error {
+	var outbox []change
+	err := s.view(owner, func(idx *index) error {
+		for _, c := range idx.Outbox {
+			outbox = append(outbox, *c)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	blocked := make(map[string]bool)
+	for _, c := range outbox {
+		if blocked[c.NoteID] {
+			continue
+		}
+		if len(c.Conflict) > 0 || now.Before(c.Retry) {
+			blocked[c.NoteID] = true
+			continue
+		}
+
+		cur, rec, content, err := s.reread(owner, c.Seq)
+		if err != nil {
+			return err
+		}
+		if cur == nil {
+			continue
+		}
+
+		rid, version, err := send(ctx, r, cur, rec, content)
+		if err != nil {
+			if errs.IsFatal(ctx, err) {
+				return err
+			}
+			blocked[cur.NoteID] = true
+		}
+		retry := now.Add(s.backoff(cur.Attempts + 1))
+		if err := s.finish(owner, cur, rid, version, retry, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reread reads the change again, it might be merged or dropped meanwhile.
+// It returns the nil change when the change is not in the outbox, and the
+// local content to push with the content change.
+func (s *Store) reread(owner string, seq uint64) (*change, *record, []byte, error) {
+	var c *change
+	var rec *record
+	var content []byte
+	err := s.view(owner, func(idx *index) error {
+		cur := idx.find(seq)
+		if cur == nil {
+			return nil
+		}
+		cp := *cur
+		c = &cp
+		rec = idx.Notes[c.NoteID]
+		if c.Op == opContent && rec != nil {
+			var err error
+			content, err = s.readContent(owner, c.NoteID)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return c, rec, content, nil
+}
+
+// send pushes the change to the cloud storage. It returns the cloud storage
+// id and version of the note after the change.
+func send(ctx context.Context, r Remote, c *change, rec *record, content []byte) (string, int64, error) {
+	if c.Op == opDelete {
+		return sendDelete(ctx, r, c)
+	}
+	if rec == nil {
+		return utils.Empty, 0, nil
+	}
+	if c.Op == opCreate {
+		note, err := r.Create(ctx, rec.Note.Writable())
+		if err != nil {
+			return utils.Empty, 0, err
+		}
+		return note.ID, note.Version, nil
+	}
+
+	if err := checkRemote(ctx, r, rec); err != nil {
+		return utils.Empty, 0, err
+	}
+	if c.Op == opUpdate {
+		updated, err := r.Update(ctx, rec.RemoteID, rec.Note.Writable())
+		if err != nil {
+			return utils.Empty, 0, err
+		}
+		return rec.RemoteID, updated.Version, nil
+	}
+	version, err := r.Upload(ctx, rec.RemoteID, content)
+	if err != nil {
+		return utils.Empty, 0, err
+	}
+	return rec.RemoteID, version, nil
+}
+
+// sendDelete deletes the cloud storage note, unless it is changed after
+// the last sync. The note already deleted in cloud storage is not an error.
+func sendDelete(ctx context.Context, r Remote, c *change) (string, int64, error) {
+	note, err := r.Get(ctx, c.RemoteID)
+	if isNotFound(err) {
+		return c.RemoteID, 0, nil
+	}
+	if err != nil {
+		return utils.Empty, 0, err
+	}
+	if note.Version != c.Version {
+		return utils.Empty, 0, errs.NewConflictError("note is deleted locally but changed in cloud storage")
+	}
+	if err := r.Delete(ctx, c.RemoteID); err != nil && !isNotFound(err) {
+		return utils.Empty, 0, err
+	}
+	return c.RemoteID, 0, nil
+}
+
+// checkRemote returns the conflict error when the cloud storage note is
+// changed or deleted after the last sync. The changed note is not
+// overwritten, because the drive api has no conditional update.
+func checkRemote(ctx context.Context, r Remote, rec *record) error {
+	if len(rec.RemoteID) == 0 {
+		return fmt.Errorf("note with id '%s' is not created in cloud storage", rec.Note.ID)
+	}
+	note, err := r.Get(ctx, rec.RemoteID)
+	if isNotFound(err) {
+		return errs.NewConflictError("note is changed locally but deleted in cloud storage")
+	}
+	if err != nil {
+		return err
+	}
+	if note.Version != rec.Note.Version {
+		return errs.NewConflictError("note is changed both locally and in cloud storage")
+	}
+	return nil
+}
+
+// finish records the outcome of the pushed change. The successful change
+// is removed from the outbox, unless the note is changed again meanwhile.
+func (s *Store) finish(owner string, c *change, rid string, version int64, retry time.Time, err error) error {
+	return s.update(owner, func(idx *index) error {
+		cur := idx.find(c.Seq)
+		switch {
+		case err != nil:
+			idx.fail(cur, retry, err)
+		case c.Op == opDelete || len(rid) == 0:
+			idx.remove(c.Seq)
+		default:
+			idx.pushed(c, cur, rid, version)
+		}
+		return nil
+	})
+}
+
+// fail records the error of the change. The conflict blocks the change
+// until it is resolved, other errors are retried after the retry time.
+func (idx *index) fail(cur *change, retry time.Time, err error) {
+	if cur == nil {
+		return
+	}
+	if _, ok := err.(*errs.ConflictError); ok {
+		cur.Conflict = err.Error()
+		return
+	}
+	cur.Attempts++
+	cur.Error = err.Error()
+	cur.Retry = retry
+}
+
+// pushed records the cloud storage id and version of the pushed note. The
+// current change is nil when it is dropped while pushing.
+func (idx *index) pushed(c, cur *change, rid string, version int64) {
+	// the cloud storage note is at new version now, the delete change
+	// of the same note must not see it as conflict
+	deleting := false
+	for _, d := range idx.Outbox {
+		if d.Op == opDelete && d.RemoteID == rid {
+			d.Version = version
+			deleting = true
+		}
+	}
+	rec, ok := idx.Notes[c.NoteID]
+	if ok {
+		rec.RemoteID = rid
+		rec.Note.Version = version
+	} else if c.Op == opCreate && !deleting {
+		// the note is deleted locally while creating it
+		idx.append(&change{Op: opDelete, NoteID: c.NoteID, RemoteID: rid, Version: version})
+	}
+
+	if cur == nil {
+		return
+	}
+	if cur.Rev != c.Rev {
+		// the note is changed again while pushing, so the change is
+		// pushed again with the latest note
+		if cur.Op == opCreate {
+			cur.Op = opUpdate
+		}
+		cur.Attempts = 0
+		cur.Error = utils.Empty
+		return
+	}
+	idx.remove(c.Seq)
+}
+
+// pull applies the cloud storage changes on the notes having no local
+// change. The notes changed on both sides are left for the push, which
+// reports them as conflicts.
+func (s *Store) pull(ctx context.Context, owner string, r Remote) error {
+	notes, gone, token, err := s.changes(ctx, owner, r)
+	if err != nil {
+		return err
+	}
+
+	var fetch []*notestore.Note
+	var removed []string
+	err = s.update(owner, func(idx *index) error {
+		fetch, removed = idx.merge(notes, gone)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range removed {
+		s.removeContent(owner, id)
+	}
+
+	for _, n := range fetch {
+		if err := s.fetch(ctx, owner, r, n); err != nil {
+			return err
+		}
+	}
+
+	// the token is saved last, so the failed pull reads the same changes
+	// again next time
+	if len(token) == 0 {
+		return nil
+	}
+	return s.update(owner, func(idx *index) error {
+		idx.Token = token
+		return nil
+	})
+}
+
+// merge removes the notes deleted in cloud storage, and returns the cloud
+// storage notes to download and the ids of removed notes. The notes having
+// local changes are not touched.
+func (idx *index) merge(notes []*notestore.Note, gone func(string) bool) ([]*notestore.Note, []string) {
+	var fetch []*notestore.Note
+	var removed []string
+	pending, deleting := idx.pending()
+	for _, n := range notes {
+		rec := idx.byRemote(n.ID)
+		if rec == nil && !deleting[n.ID] {
+			fetch = append(fetch, n)
+		} else if rec != nil && !pending[rec.Note.ID] && rec.Note.Version != n.Version {
+			fetch = append(fetch, n)
+		}
+	}
+	for id, rec := range idx.Notes {
+		if len(rec.RemoteID) > 0 && !pending[id] && gone(rec.RemoteID) {
+			delete(idx.Notes, id)
+			removed = append(removed, id)
+		}
+	}
+	return fetch, removed
+}
+
+// changes returns the cloud storage notes compared with the local notes, the
+// func telling the removed cloud storage notes, and the next change token.
+// The remote tracking its changes returns only the notes changed since the
+// last pull, other remotes list all notes.
+func (s *Store) changes(ctx context.Context, owner string, r Remote) ([]*notestore.Note, func(string) bool, string, error) {
+	t, ok := r.(notestore.Tracker)
+	if !ok {
+		return list(ctx, r, utils.Empty)
+	}
+
+	token := utils.Empty
+	err := s.view(owner, func(idx *index) error {
+		token = idx.Token
+		return nil
+	})
+	if err != nil {
+		return nil, nil, utils.Empty, err
+	}
+	if len(token) == 0 {
+		// the token is taken before listing, so the changes made while
+		// listing are read again on the next pull
+		token, err := t.StartToken(ctx)
+		if err != nil {
+			return nil, nil, utils.Empty, err
+		}
+		return list(ctx, r, token)
+	}
+
+	changes, err := t.Changes(ctx, token)
+	if err != nil {
+		if _, ok := err.(*errs.UnauthorizedError); !ok && ctx.Err() == nil {
+			// the token might be expired, so the next pull lists all notes
+			_ = s.update(owner, func(idx *index) error {
+				idx.Token = utils.Empty
+				return nil
+			})
+		}
+		return nil, nil, utils.Empty, err
+	}
+	removed := make(map[string]bool, len(changes.Removed))
+	for _, id := range changes.Removed {
+		removed[id] = true
+	}
+	return changes.Notes, func(rid string) bool { return removed[rid] }, changes.Token, nil
+}
+
+// list returns all cloud storage notes, and the func telling the removed
+// cloud storage notes.
+func list(ctx context.Context, r Remote, token string) ([]*notestore.Note, func(string) bool, string, error) {
+	notes, err := r.GetAll(ctx)
+	if err != nil {
+		return nil, nil, utils.Empty, err
+	}
+	remotes := make(map[string]bool, len(notes))
+	for _, n := range notes {
+		remotes[n.ID] = true
+	}
+	return notes, func(rid string) bool { return !remotes[rid] }, token, nil
+}
+
+// fetch downloads the cloud storage note in the local store, unless the note
+// is changed locally meanwhile.
+func (s *Store) fetch(ctx context.Context, owner string, r Remote, n *notestore.Note) error {
+	content, err := r.Download(ctx, n.ID)
+	if err != nil {
+		return err
+	}
+	return s.update(owner, func(idx *index) error {
+		pending, deleting := idx.pending()
+		rec := idx.byRemote(n.ID)
+		if rec == nil {
+			if deleting[n.ID] {
+				return nil
+			}
+			rec = &record{RemoteID: n.ID}
+			idx.Notes[n.ID] = rec
+		} else if pending[rec.Note.ID] {
+			// the note is changed locally while downloading
+			return nil
+		}
+
+		note := *n
+		if rec.Note != nil {
+			note.ID = rec.Note.ID
+		}
+		rec.Note = &note
+		mtime, err := s.writeContent(owner, note.ID, content)
+		if err != nil {
+			return err
+		}
+		rec.Mtime = mtime
+		return nil
+	})
+}
+
+// settle resolves the conflicts by the conflict policy, the manual policy
+// leaves them for the user. The note deleted in cloud storage has no update
+// time, so the local note is always kept.
+func (s *Store) settle(ctx context.Context, owner string, r Remote) error {
+	if s.config.Policy == PolicyManual {
+		return nil
+	}
+	conflicts, err := s.conflicts(owner)
+	if err != nil {
+		return err
+	}
+
+	for _, cf := range conflicts {
+		note, err := r.Get(ctx, cf.rid)
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+
+		keep := KeepLocal
+		if note != nil {
+			keep = s.keep(cf, note)
+		}
+		if err := s.settleNote(ctx, owner, r, cf.nid, keep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pendingConflict is the conflict of the note settled by the policy. The
+// date is the latest local change time of the note.
+type pendingConflict struct {
+	nid     string
+	rid     string
+	date    time.Time
+	deleted bool
+}
+
+// conflicts returns the notes having conflict in the outbox.
+func (s *Store) conflicts(owner string) ([]*pendingConflict, error) {
+	var conflicts []*pendingConflict
+	err := s.view(owner, func(idx *index) error {
+		found := make(map[string]*pendingConflict)
+		for _, c := range idx.Outbox {
+			if len(c.Conflict) == 0 || found[c.NoteID] != nil {
+				continue
+			}
+			cf := &pendingConflict{nid: c.NoteID, rid: c.RemoteID, deleted: true}
+			if rec, ok := idx.Notes[c.NoteID]; ok {
+				cf.rid = rec.RemoteID
+				cf.deleted = false
+			}
+			found[c.NoteID] = cf
+			conflicts = append(conflicts, cf)
+		}
+		// the latest change of the note is its local change time
+		for _, c := range idx.Outbox {
+			if cf := found[c.NoteID]; cf != nil && c.Date.After(cf.date) {
+				cf.date = c.Date
+			}
+		}
+		return nil
+	})
+	return conflicts, err
+}
+
+// keep returns the note kept by the conflict policy, when the note is
+// still in cloud storage.
+func (s *Store) keep(cf *pendingConflict, note *notestore.Note) string {
+	switch {
+	case s.config.Policy == PolicyLastWriterWins && !cf.date.After(note.DateUpdated):
+		return KeepRemote
+	case s.config.Policy == PolicyKeepBoth && cf.deleted:
+		return KeepRemote
+	case s.config.Policy == PolicyKeepBoth:
+		return KeepBoth
+	default:
+		return KeepLocal
+	}
+}
+
+// resolve settles the conflict of the note chosen by the user.
+func (s *Store) resolve(ctx context.Context, owner string, r Remote, nid, keep string) error {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+	return s.settleNote(ctx, owner, r, nid, keep)
+}
+
+// settleNote settles the conflict of the note by keeping the local note,
+// the cloud storage note or both. The caller must hold the sync mutex.
+func (s *Store) settleNote(ctx context.Context, owner string, r Remote, nid, keep string) error {
+	rid, err := s.conflictRemote(owner, nid)
+	if err != nil {
+		return err
+	}
+	switch keep {
+	case KeepBoth:
+		return s.keepBoth(ctx, owner, r, nid, rid)
+	case KeepRemote:
+		return s.keepRemote(ctx, owner, r, nid, rid)
+	default:
+		return s.keepLocal(ctx, owner, r, nid, rid)
+	}
+}
+
+// conflictRemote returns the cloud storage id of the note having conflict.
+func (s *Store) conflictRemote(owner, nid string) (string, error) {
+	rid := utils.Empty
+	err := s.view(owner, func(idx *index) error {
+		for _, c := range idx.Outbox {
+			if c.NoteID == nid && len(c.Conflict) > 0 {
+				rid = c.RemoteID
+				if rec, ok := idx.Notes[nid]; ok {
+					rid = rec.RemoteID
+				}
+				return nil
+			}
+		}
+		msg := fmt.Sprintf("note with id '%s' has no sync conflict", nid)
+		return errs.NewNotFoundError(msg)
+	})
+	return rid, err
+}
+
+// keepBoth copies the local note first, and then keeps the cloud storage
+// note.
+func (s *Store) keepBoth(ctx context.Context, owner string, r Remote, nid, rid string) error {
+	if err := s.copyNote(owner, nid); err != nil {
+		return err
+	}
+	return s.keepRemote(ctx, owner, r, nid, rid)
+}
+
+// keepRemote drops the local changes and downloads the cloud storage note
+// again. The note deleted in cloud storage is deleted locally too.
+func (s *Store) keepRemote(ctx context.Context, owner string, r Remote, nid, rid string) error {
+	note, err := r.Get(ctx, rid)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	err = s.update(owner, func(idx *index) error {
+		idx.drop(nid)
+		if note == nil {
+			delete(idx.Notes, nid)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if note == nil {
+		s.removeContent(owner, nid)
+		return nil
+	}
+	return s.fetch(ctx, owner, r, note)
+}
+
+// keepLocal moves the local note to the current cloud storage version, so
+// the local changes are pushed on next sync. The note deleted in cloud
+// storage is created again.
+func (s *Store) keepLocal(ctx context.Context, owner string, r Remote, nid, rid string) error {
+	note, err := r.Get(ctx, rid)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	return s.update(owner, func(idx *index) error {
+		rec, ok := idx.Notes[nid]
+		if note == nil {
+			idx.drop(nid)
+			if ok {
+				rec.RemoteID = utils.Empty
+				idx.enqueue(opCreate, rec)
+				idx.enqueue(opContent, rec)
+			}
+			return nil
+		}
+
+		if ok {
+			rec.Note.Version = note.Version
+		}
+		for _, c := range idx.Outbox {
+			if c.NoteID == nid {
+				c.Conflict = utils.Empty
+				c.Error = utils.Empty
+				c.Attempts = 0
+				c.Retry = time.Time{}
+				if c.Op == opDelete {
+					c.Version = note.Version
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// copyNote keeps the local note as a new note, the new note is pushed as
+// a new cloud storage note on the next sync.
+func (s *Store) copyNote(owner, nid string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	idx, err := s.load(owner)
+	if err != nil {
+		return err
+	}
+	rec, ok := idx.Notes[nid]
+	if !ok {
+		return nil
+	}
+	content, err := s.readContent(owner, nid)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	note := *rec.Note
+	note.ID = xid.New().String()
+	note.Name = copyName(note.Name)
+	note.DateCreated = now
+	note.DateUpdated = now
+	note.Version = 0
+	mtime, err := s.writeContent(owner, note.ID, content)
+	if err != nil {
+		return err
+	}
+
+	cp := &record{Note: &note, Mtime: mtime}
+	idx.Notes[note.ID] = cp
+	idx.enqueue(opCreate, cp)
+	if len(content) > 0 {
+		idx.enqueue(opContent, cp)
+	}
+	return s.save(owner, idx)
+}
+
+// backoff returns the delay before retrying the change failed for the
+// number of attempts.
+func (s *Store) backoff(attempts int) time.Duration {
+	delay := s.config.Interval
+	for i := 1; i < attempts && delay < s.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > s.config.MaxBackoff {
+		delay = s.config.MaxBackoff
+	}
+	return delay
+}
+
+// drop removes all changes of the note from the outbox.
+func (idx *index) drop(nid string) {
+	outbox := make([]*change, 0, len(idx.Outbox))
+	for _, c := range idx.Outbox {
+		if c.NoteID != nid {
+			outbox = append(outbox, c)
+		}
+	}
+	idx.Outbox = outbox
+}
+
+// copyName adds the copy suffix to the note name, the name is cut to keep
+// it in the max length of note name.
+func copyName(name string) string {
+	runes := []rune(name)
+	if max := 100 - len([]rune(copySuffix)); len(runes) > max {
+		runes = runes[:max]
+	}
+	return strings.TrimSpace(string(runes)) + copySuffix
+}
+
+func isNotFound(err error) bool {
+	_, ok := err.(*errs.NotFoundError)
+	return ok
+}
@@ -0,0 +1,61 @@
+package offline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+
+	"github.com/psewda/typing/pkg/signin/userinfo"
+)
+
+// Userinfo returns the userinfo remembering the user of access token on
+// disk, so the local store serves the requests when google is not
+// reachable. The user of token never changes, so the remembered user is
+// returned without asking google. Only the hash of token is stored.
+func (s *Store) Userinfo(accessToken string, ui userinfo.Userinfo) userinfo.Userinfo {
+	sum := sha256.Sum256([]byte(accessToken))
+	return &remembered{
+		store:    s,
+		key:      hex.EncodeToString(sum[:]),
+		userinfo: ui,
+	}
+}
+
+type remembered struct {
+	store    *Store
+	key      string
+	userinfo userinfo.Userinfo
+}
+
+func (r *remembered) Get(ctx context.Context) (*userinfo.User, error) {
+	path := filepath.Join(r.store.dir, usersFile)
+	r.store.mutex.Lock()
+	users := make(map[string]*userinfo.User)
+	err := readJSON(path, &users)
+	r.store.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if user, ok := users[r.key]; ok {
+		return user, nil
+	}
+
+	user, err := r.userinfo.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the users are read again, other tokens might be added meanwhile
+	r.store.mutex.Lock()
+	defer r.store.mutex.Unlock()
+	users = make(map[string]*userinfo.User)
+	if err := readJSON(path, &users); err != nil {
+		return nil, err
+	}
+	users[r.key] = user
+	if err := writeJSON(path, users); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,82 @@
+package sectionstore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/psewda/typing/pkg/errs"
+)
+
+const (
+	// OperationCreate adds a new section in the note.
+	OperationCreate = "create"
+
+	// OperationUpdate modifies the existing section.
+	OperationUpdate = "update"
+
+	// OperationDelete removes the existing section.
+	OperationDelete = "delete"
+
+	// MaxOperations is the max number of operations in a single batch.
+	MaxOperations = 100
+)
+
+// Operation is a single create, update or delete operation in the batch.
+// The section id is required for update and delete operations.
+type Operation struct {
+	Type    string           `json:"op"`
+	ID      string           `json:"id,omitempty"`
+	Section *WritableSection `json:"section,omitempty"`
+}
+
+// OperationResult is the outcome of a single operation in the batch. The
+// section is not returned for delete operation.
+type OperationResult struct {
+	Type    string   `json:"op"`
+	ID      string   `json:"id"`
+	Section *Section `json:"section,omitempty"`
+}
+
+// CheckOperations validates all operations of the batch. It returns
+// validation error having the position of the first wrong operation.
+func CheckOperations(ops []*Operation) error {
+	if len(ops) == 0 {
+		return errs.NewValidationError("batch must have at least one operation")
+	}
+	if len(ops) > MaxOperations {
+		msg := fmt.Sprintf("batch can't have more than %d operations", MaxOperations)
+		return errs.NewValidationError(msg)
+	}
+
+	for i, op := range ops {
+		if err := checkOperation(op); err != nil {
+			msg := fmt.Sprintf("operation %d: %s", i, err.Error())
+			return errs.NewValidationError(msg)
+		}
+	}
+	return nil
+}
+
+func checkOperation(op *Operation) error {
+	if op == nil {
+		return errors.New("operation can't be null")
+	}
+
+	switch op.Type {
+	case OperationCreate, OperationUpdate:
+		if op.Type == OperationUpdate && len(op.ID) == 0 {
+			return errors.New("section id is required")
+		}
+		if op.Section == nil {
+			return errors.New("section is required")
+		}
+		return op.Section.Validate()
+	case OperationDelete:
+		if len(op.ID) == 0 {
+			return errors.New("section id is required")
+		}
+		return nil
+	default:
+		return errors.New("op must be one of 'create', 'update' or 'delete'")
+	}
+}
@@ -0,0 +1,118 @@
+package drvsectionstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/storage/compress"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// Content reads and writes the whole note content. The sectionstore
+// downloads the content, applies the change and uploads it back.
+type Content interface {
+	// Download returns the plain note content. The note without sections
+	// may return the empty content.
+	Download(ctx context.Context, nid string) ([]byte, error)
+
+	// Upload saves the note content and returns the note version after
+	// saving. The storage without versions returns zero version.
+	Upload(ctx context.Context, nid string, content []byte) (int64, error)
+}
+
+// DriveContent is the note content stored in google drive file.
+type DriveContent struct {
+	service  *drive.Service
+	encoding string
+}
+
+// Download returns the note content from google drive file. The content
+// is decoded by the encoding recorded in file properties.
+func (c *DriveContent) Download(ctx context.Context, nid string) ([]byte, error) {
+	file, err := c.service.Files.Get(nid).Fields("properties").Context(ctx).Do()
+	if err != nil {
+		return nil, downloadError(err, nid)
+	}
+	res, err := c.service.Files.Get(nid).Context(ctx).Download()
+	if err != nil {
+		return nil, downloadError(err, nid)
+	}
+
+	defer res.Body.Close()
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, utils.Error("note download error", err)
+	}
+
+	// the encoding is detected from content only when not recorded, so the
+	// notes written before compression are still readable
+	encoding, ok := file.Properties[compress.PropertyKey]
+	if !ok {
+		encoding = compress.Detect(content)
+	}
+	decoded, err := compress.Decode(content, encoding)
+	if err != nil {
+		return nil, utils.Error("note decompression error", err)
+	}
+	return decoded, nil
+}
+
+// Upload saves the note content in google drive file. It returns the
+// drive version of the file after upload.
+func (c *DriveContent) Upload(ctx context.Context, nid string, content []byte) (int64, error) {
+	encoded, err := compress.Encode(content, c.encoding)
+	if err != nil {
+		return 0, utils.Error("note compression error", err)
+	}
+
+	// the encoding is recorded in file properties for other readers
+	f := drive.File{
+		Properties: map[string]string{compress.PropertyKey: c.encoding},
+	}
+	if c.encoding == compress.EncodingNone {
+		f.Properties = nil
+		f.NullFields = []string{"Properties." + compress.PropertyKey}
+	}
+
+	reader := bytes.NewReader(encoded)
+	call := c.service.Files.Update(nid, &f).Media(reader, googleapi.ContentType("application/json"))
+	file, err := call.Fields("id, version").Context(ctx).Do()
+	if err != nil {
+		if utils.GetStatusCode(err) == http.StatusUnauthorized {
+			return 0, errs.NewUnauthorizedError()
+		}
+		return 0, utils.Error("note upload error", err)
+	}
+	return file.Version, nil
+}
+
+// NewDriveContent creates a new google drive content using the existing
+// drive service. The content is compressed using the encoding, the empty
+// encoding stores the plain json.
+func NewDriveContent(service *drive.Service, encoding string) (*DriveContent, error) {
+	if err := compress.Check(encoding); err != nil {
+		return nil, err
+	}
+
+	return &DriveContent{
+		service:  service,
+		encoding: encoding,
+	}, nil
+}
+
+func downloadError(err error, nid string) error {
+	if utils.GetStatusCode(err) == http.StatusUnauthorized {
+		return errs.NewUnauthorizedError()
+	}
+	if utils.GetStatusCode(err) == http.StatusNotFound {
+		msg := fmt.Sprintf("note with id '%s' not found", nid)
+		return errs.NewNotFoundError(msg)
+	}
+	return utils.Error("note download error", err)
+}
@@ -2,199 +2,392 @@ package drvsectionstore
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/psewda/typing/internal/utils"
 	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/schema"
+	"github.com/psewda/typing/pkg/signin/userinfo"
 	secstore "github.com/psewda/typing/pkg/storage/sectionstore"
 	"github.com/rs/xid"
 	"google.golang.org/api/drive/v3"
-	"google.golang.org/api/googleapi"
 )
 
 // DrvSectionstore is the sectionstore implementation
 // using google drive api.
 type DrvSectionstore struct {
-	service *drive.Service
+	content  Content
+	userinfo userinfo.Userinfo
 }
 
 // Create adds a new section in the note and stores the data on google drive.
-func (ss *DrvSectionstore) Create(nid string, s *secstore.WritableSection) (*secstore.Section, error) {
+func (ss *DrvSectionstore) Create(ctx context.Context, nid string, s *secstore.WritableSection) (*secstore.Section, error) {
 	err := checkSection(s)
 	if err != nil {
 		return nil, utils.Error("section validation failed", err)
 	}
 
 	// download existing note content
-	content, err := download(ss.service, nid)
+	doc, err := load(ctx, ss.content, nid)
 	if err != nil {
 		return nil, err
 	}
 
-	// build new section instance
-	sanitized := sanitize(s)
-	section := &secstore.Section{
-		ID:       xid.New().String(),
-		Name:     sanitized.Name,
-		Labels:   sanitized.Labels,
-		Metadata: sanitized.Metadata,
-		Data:     sanitized.Data,
+	author, err := ss.getAuthor(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	// append the new section
-	var sections []*secstore.Section
-	if len(content) > 0 {
-		sections, err = unmarshal(content)
-		if err != nil {
-			return nil, err
-		}
+	section, err := doc.create(s, author)
+	if err != nil {
+		return nil, err
 	}
-	sections = append(sections, section)
 
 	// upload the note content
-	j, _ := json.Marshal(sections)
-	if err := upload(ss.service, nid, j); err != nil {
+	if err := ss.save(ctx, nid, doc); err != nil {
 		return nil, err
 	}
 	return section, nil
 }
 
-// GetAll fetches all sections from the note.
-func (ss *DrvSectionstore) GetAll(nid string) ([]*secstore.Section, error) {
-	content, err := download(ss.service, nid)
+// GetAll fetches all sections from the note. The query is applied
+// on the sections after downloading the note content.
+func (ss *DrvSectionstore) GetAll(ctx context.Context, nid string, q *secstore.Query) ([]*secstore.Section, error) {
+	doc, err := load(ctx, ss.content, nid)
 	if err != nil {
 		return nil, err
 	}
-
-	var sections []*secstore.Section
-	if len(content) > 0 {
-		sections, err = unmarshal(content)
-		if err != nil {
-			return nil, err
-		}
-	}
-	return sections, nil
+	return q.Apply(doc.Sections), nil
 }
 
 // Get returns a single section from the note.
-func (ss *DrvSectionstore) Get(nid, sid string) (*secstore.Section, error) {
-	content, err := download(ss.service, nid)
+func (ss *DrvSectionstore) Get(ctx context.Context, nid, sid string) (*secstore.Section, error) {
+	doc, err := load(ctx, ss.content, nid)
 	if err != nil {
 		return nil, err
 	}
 
-	var sections []*secstore.Section
-	if len(content) > 0 {
-		sections, err = unmarshal(content)
-		if err != nil {
-			return nil, err
-		}
-	}
-
 	// find the section in the array
-	idx := indexOf(sections, sid)
+	idx := indexOf(doc.Sections, sid)
 	if idx == -1 {
-		msg := fmt.Sprintf("section with id '%s' not found", sid)
-		return nil, errs.NewNotFoundError(msg)
+		return nil, buildNotFoundError(sid)
 	}
 
 	// section found, so return the section
-	return sections[idx], nil
+	return doc.Sections[idx], nil
 }
 
 // Update modifies the section and saves it back in the note.
-func (ss *DrvSectionstore) Update(nid, sid string, s *secstore.WritableSection) (*secstore.Section, error) {
+func (ss *DrvSectionstore) Update(ctx context.Context, nid, sid string, s *secstore.WritableSection) (*secstore.Section, error) {
 	err := checkSection(s)
 	if err != nil {
 		return nil, utils.Error("section validation failed", err)
 	}
 
 	// download existing note content
-	content, err := download(ss.service, nid)
+	doc, err := load(ctx, ss.content, nid)
 	if err != nil {
 		return nil, err
 	}
 
-	var sections []*secstore.Section
-	if len(content) > 0 {
-		sections, err = unmarshal(content)
-		if err != nil {
-			return nil, err
-		}
+	// fail fast before looking up the author
+	if indexOf(doc.Sections, sid) == -1 {
+		return nil, buildNotFoundError(sid)
 	}
 
-	// find the section in the array
-	idx := indexOf(sections, sid)
-	if idx == -1 {
-		msg := fmt.Sprintf("section with id '%s' not found", sid)
-		return nil, errs.NewNotFoundError(msg)
+	author, err := ss.getAuthor(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	// update section fields
-	sanitized := sanitize(s)
-	sections[idx].Name = sanitized.Name
-	sections[idx].Labels = sanitized.Labels
-	sections[idx].Metadata = sanitized.Metadata
-	sections[idx].Data = sanitized.Data
+	section, err := doc.update(sid, s, author)
+	if err != nil {
+		return nil, err
+	}
 
 	// upload the note content
-	j, _ := json.Marshal(sections)
-	if err := upload(ss.service, nid, j); err != nil {
+	if err := ss.save(ctx, nid, doc); err != nil {
 		return nil, err
 	}
-	return sections[idx], nil
+	return section, nil
 }
 
 // Delete removes the section from note.
-func (ss *DrvSectionstore) Delete(nid, sid string) error {
-	content, err := download(ss.service, nid)
+func (ss *DrvSectionstore) Delete(ctx context.Context, nid, sid string) error {
+	doc, err := load(ctx, ss.content, nid)
 	if err != nil {
 		return err
 	}
 
-	var sections []*secstore.Section
-	if len(content) > 0 {
-		sections, err = unmarshal(content)
+	// delete the section
+	if err := doc.delete(sid); err != nil {
+		return err
+	}
+
+	// upload the note content
+	if err := ss.save(ctx, nid, doc); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Batch applies all operations on the note content in a single download
+// and upload cycle. If any operation fails, the note content is not
+// uploaded, so either all or none of the operations are saved.
+func (ss *DrvSectionstore) Batch(ctx context.Context, nid string, ops []*secstore.Operation) ([]*secstore.OperationResult, error) {
+	if err := secstore.CheckOperations(ops); err != nil {
+		return nil, err
+	}
+
+	// download existing note content
+	doc, err := load(ctx, ss.content, nid)
+	if err != nil {
+		return nil, err
+	}
+
+	author, err := ss.getAuthor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// apply all operations in order
+	results := make([]*secstore.OperationResult, 0, len(ops))
+	for i, op := range ops {
+		result := &secstore.OperationResult{
+			Type: op.Type,
+			ID:   op.ID,
+		}
+
+		var err error
+		switch op.Type {
+		case secstore.OperationCreate:
+			result.Section, err = doc.create(op.Section, author)
+		case secstore.OperationUpdate:
+			result.Section, err = doc.update(op.ID, op.Section, author)
+		case secstore.OperationDelete:
+			err = doc.delete(op.ID)
+		}
 		if err != nil {
-			return err
+			return nil, buildOperationError(i, err)
 		}
+
+		if result.Section != nil {
+			result.ID = result.Section.ID
+		}
+		results = append(results, result)
 	}
 
-	// find the section in the array
-	idx := indexOf(sections, sid)
-	if idx == -1 {
-		msg := fmt.Sprintf("section with id '%s' not found", sid)
-		return errs.NewNotFoundError(msg)
+	// upload the note content
+	if err := ss.save(ctx, nid, doc); err != nil {
+		return nil, err
 	}
+	return results, nil
+}
 
-	// delete the section
-	sections[idx] = sections[len(sections)-1]
-	sections[len(sections)-1] = nil
-	sections = sections[:len(sections)-1]
+// GetSchema returns the schema of section data declared by the note.
+func (ss *DrvSectionstore) GetSchema(ctx context.Context, nid string) (*schema.Schema, error) {
+	doc, err := load(ctx, ss.content, nid)
+	if err != nil {
+		return nil, err
+	}
 
-	// upload the note content
-	j, _ := json.Marshal(sections)
-	if err := upload(ss.service, nid, j); err != nil {
+	if doc.Schema == nil {
+		msg := fmt.Sprintf("schema of note with id '%s' not found", nid)
+		return nil, errs.NewNotFoundError(msg)
+	}
+	return doc.Schema, nil
+}
+
+// SetSchema declares the schema of section data in the note. All existing
+// sections are checked against the new schema before saving it.
+func (ss *DrvSectionstore) SetSchema(ctx context.Context, nid string, s *schema.Schema) error {
+	if s != nil {
+		if err := s.Check(); err != nil {
+			return errs.NewValidationError(err.Error())
+		}
+	}
+
+	doc, err := load(ctx, ss.content, nid)
+	if err != nil {
 		return err
 	}
-	return nil
+
+	// existing sections must satisfy the new schema
+	if s != nil {
+		for _, section := range doc.Sections {
+			if err := s.Validate(section.Data); err != nil {
+				msg := fmt.Sprintf("section with id '%s' violates schema: %s", section.ID, err.Error())
+				return errs.NewValidationError(msg)
+			}
+		}
+	}
+
+	// upload the note content
+	doc.Schema = s
+	return ss.save(ctx, nid, doc)
+}
+
+// Migrate rewrites the note content in the current format version. The
+// content is migrated on read anyway, so it is only needed to upgrade
+// the stored file. It returns false when the content is already current.
+func (ss *DrvSectionstore) Migrate(ctx context.Context, nid string) (bool, error) {
+	content, err := ss.content.Download(ctx, nid)
+	if err != nil {
+		return false, err
+	}
+
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 {
+		return false, nil
+	}
+
+	migrated, version, err := migrate(content)
+	if err != nil {
+		return false, err
+	}
+	if version == FormatVersion {
+		return false, nil
+	}
+
+	// upload the migrated note content
+	if _, err := ss.content.Upload(ctx, nid, migrated); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
-// New creates a new instance of google drive sectionstore.
-func New(c *http.Client) (*DrvSectionstore, error) {
+// New creates a new instance of google drive sectionstore. The userinfo
+// is used to set the author of sections, the nil userinfo skips it. The
+// note content is compressed using the encoding, the empty encoding
+// stores the plain json.
+func New(c *http.Client, ui userinfo.Userinfo, encoding string) (*DrvSectionstore, error) {
 	service, err := drive.New(c)
 	if err != nil {
 		return nil, utils.Error("drive service creation error", err)
 	}
+	return NewWithService(service, ui, encoding)
+}
+
+// NewWithService creates a new instance of google drive sectionstore
+// using the existing drive service.
+func NewWithService(service *drive.Service, ui userinfo.Userinfo, encoding string) (*DrvSectionstore, error) {
+	c, err := NewDriveContent(service, encoding)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithContent(c, ui), nil
+}
 
+// NewWithContent creates a new instance of sectionstore which reads and
+// writes the note content through the content, e.g. the local copy of
+// google drive files.
+func NewWithContent(c Content, ui userinfo.Userinfo) *DrvSectionstore {
 	return &DrvSectionstore{
-		service: service,
-	}, nil
+		content:  c,
+		userinfo: ui,
+	}
+}
+
+func (ss *DrvSectionstore) getAuthor(ctx context.Context) (string, error) {
+	if ss.userinfo == nil {
+		return utils.Empty, nil
+	}
+
+	user, err := ss.userinfo.Get(ctx)
+	if err != nil {
+		return utils.Empty, err
+	}
+	return user.ID, nil
+}
+
+func (doc *document) create(s *secstore.WritableSection, author string) (*secstore.Section, error) {
+	// section data must satisfy the note schema
+	sanitized := sanitize(s)
+	if err := checkSchema(doc.Schema, sanitized); err != nil {
+		return nil, err
+	}
+
+	// build new section instance
+	now := time.Now().UTC()
+	section := &secstore.Section{
+		ID:       xid.New().String(),
+		Name:     sanitized.Name,
+		Labels:   sanitized.Labels,
+		Metadata: sanitized.Metadata,
+		Data:     sanitized.Data,
+
+		Kind:      sanitized.Kind,
+		Text:      sanitized.Text,
+		Checklist: sanitized.Checklist,
+		Table:     sanitized.Table,
+		Code:      sanitized.Code,
+
+		Links: sanitized.Links,
+
+		DateCreated: &now,
+		DateUpdated: &now,
+		CreatedBy:   author,
+		UpdatedBy:   author,
+	}
+
+	doc.Sections = append(doc.Sections, section)
+	return section, nil
+}
+
+func (doc *document) update(sid string, s *secstore.WritableSection, author string) (*secstore.Section, error) {
+	sections := doc.Sections
+
+	// find the section in the array
+	idx := indexOf(sections, sid)
+	if idx == -1 {
+		return nil, buildNotFoundError(sid)
+	}
+
+	// section data must satisfy the note schema
+	sanitized := sanitize(s)
+	if err := checkSchema(doc.Schema, sanitized); err != nil {
+		return nil, err
+	}
+
+	// update section fields
+	now := time.Now().UTC()
+	sections[idx].Name = sanitized.Name
+	sections[idx].Labels = sanitized.Labels
+	sections[idx].Metadata = sanitized.Metadata
+	sections[idx].Data = sanitized.Data
+	sections[idx].Kind = sanitized.Kind
+	sections[idx].Text = sanitized.Text
+	sections[idx].Checklist = sanitized.Checklist
+	sections[idx].Table = sanitized.Table
+	sections[idx].Code = sanitized.Code
+	sections[idx].Links = sanitized.Links
+	sections[idx].DateUpdated = &now
+	sections[idx].UpdatedBy = author
+
+	return sections[idx], nil
+}
+
+func (doc *document) delete(sid string) error {
+	sections := doc.Sections
+
+	// find the section in the array
+	idx := indexOf(sections, sid)
+	if idx == -1 {
+		return buildNotFoundError(sid)
+	}
+
+	// delete the section
+	sections[idx] = sections[len(sections)-1]
+	sections[len(sections)-1] = nil
+	doc.Sections = sections[:len(sections)-1]
+	return nil
 }
 
 func checkSection(s *secstore.WritableSection) error {
@@ -204,9 +397,19 @@ func checkSection(s *secstore.WritableSection) error {
 	return s.Validate()
 }
 
+func checkSchema(sch *schema.Schema, s *secstore.WritableSection) error {
+	if sch != nil {
+		if err := sch.Validate(s.Data); err != nil {
+			return errs.NewValidationError(err.Error())
+		}
+	}
+	return nil
+}
+
 func sanitize(s *secstore.WritableSection) *secstore.WritableSection {
 	section := secstore.WritableSection{
 		Name: strings.TrimSpace(s.Name),
+		Kind: secstore.GetKind(s.Kind),
 	}
 
 	for _, l := range s.Labels {
@@ -218,46 +421,77 @@ func sanitize(s *secstore.WritableSection) *secstore.WritableSection {
 	section.Metadata = utils.Sanitize(s.Metadata)
 	section.Data = utils.Sanitize(s.Data)
 
-	return &section
-}
+	// text and code bodies are kept as it is, because
+	// whitespaces are meaningful in markdown and code
+	if s.Text != nil {
+		section.Text = &secstore.Text{
+			Format: secstore.TextFormatPlain,
+			Body:   s.Text.Body,
+		}
+		if len(s.Text.Format) > 0 {
+			section.Text.Format = s.Text.Format
+		}
+	}
+	if s.Code != nil {
+		section.Code = &secstore.Code{
+			Language: strings.ToLower(strings.TrimSpace(s.Code.Language)),
+			Source:   s.Code.Source,
+		}
+	}
 
-func download(ds *drive.Service, nid string) ([]byte, error) {
-	res, err := ds.Files.Get(nid).Download()
-	if err != nil {
-		if utils.GetStatusCode(err) == http.StatusUnauthorized {
-			return nil, errs.NewUnauthorizedError()
+	if s.Checklist != nil {
+		section.Checklist = make([]*secstore.ChecklistItem, 0, len(s.Checklist))
+		for _, item := range s.Checklist {
+			section.Checklist = append(section.Checklist, &secstore.ChecklistItem{
+				Text: strings.TrimSpace(item.Text),
+				Done: item.Done,
+			})
+		}
+	}
+	if s.Table != nil {
+		section.Table = &secstore.Table{
+			Columns: trimAll(s.Table.Columns),
 		}
-		if utils.GetStatusCode(err) == http.StatusNotFound {
-			msg := fmt.Sprintf("note with id '%s' not found", nid)
-			return nil, errs.NewNotFoundError(msg)
+		for _, row := range s.Table.Rows {
+			section.Table.Rows = append(section.Table.Rows, trimAll(row))
 		}
-		return nil, utils.Error("note download error", err)
 	}
 
-	defer res.Body.Close()
-	return ioutil.ReadAll(res.Body)
-}
+	// same link is kept only once
+	seen := make(map[secstore.Link]bool)
+	for _, l := range s.Links {
+		link := secstore.Link{
+			NoteID:    strings.TrimSpace(l.NoteID),
+			SectionID: strings.TrimSpace(l.SectionID),
+		}
+		if !seen[link] {
+			seen[link] = true
+			section.Links = append(section.Links, &link)
+		}
+	}
 
-func upload(ds *drive.Service, nid string, content []byte) error {
-	reader := bytes.NewReader(content)
-	_, err := ds.Files.Update(nid, nil).Media(reader, googleapi.ContentType("application/json")).Do()
+	return &section
+}
 
-	if err != nil {
-		if utils.GetStatusCode(err) == http.StatusUnauthorized {
-			return errs.NewUnauthorizedError()
-		}
-		return utils.Error("note upload error", err)
+func trimAll(values []string) []string {
+	trimmed := make([]string, 0, len(values))
+	for _, v := range values {
+		trimmed = append(trimmed, strings.TrimSpace(v))
 	}
-	return nil
+	return trimmed
 }
 
-func unmarshal(content []byte) ([]*secstore.Section, error) {
-	var sections []*secstore.Section
-	err := json.Unmarshal(content, &sections)
+func load(ctx context.Context, c Content, nid string) (*document, error) {
+	content, err := c.Download(ctx, nid)
 	if err != nil {
-		return nil, utils.Error("error on unmarshalling sections", err)
+		return nil, err
 	}
-	return sections, nil
+	return unmarshal(content)
+}
+
+func (ss *DrvSectionstore) save(ctx context.Context, nid string, doc *document) error {
+	_, err := ss.content.Upload(ctx, nid, marshal(doc))
+	return err
 }
 
 func indexOf(sections []*secstore.Section, sid string) int {
@@ -268,3 +502,20 @@ func indexOf(sections []*secstore.Section, sid string) int {
 	}
 	return -1
 }
+
+func buildNotFoundError(sid string) *errs.NotFoundError {
+	msg := fmt.Sprintf("section with id '%s' not found", sid)
+	return errs.NewNotFoundError(msg)
+}
+
+func buildOperationError(idx int, err error) error {
+	msg := fmt.Sprintf("operation %d: %s", idx, err.Error())
+	switch err.(type) {
+	case *errs.NotFoundError:
+		return errs.NewNotFoundError(msg)
+	case *errs.ValidationError:
+		return errs.NewValidationError(msg)
+	default:
+		return errors.New(msg)
+	}
+}
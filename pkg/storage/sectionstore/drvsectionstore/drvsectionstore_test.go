@@ -2,6 +2,7 @@ package drvsectionstore_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"math/rand"
@@ -11,10 +12,16 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/mocks"
 	"github.com/psewda/typing/pkg/errs"
+	"github.com/psewda/typing/pkg/schema"
+	"github.com/psewda/typing/pkg/signin/userinfo"
+	"github.com/psewda/typing/pkg/storage/compress"
 	"github.com/psewda/typing/pkg/storage/sectionstore"
 	"github.com/psewda/typing/pkg/storage/sectionstore/drvsectionstore"
 )
@@ -28,9 +35,7 @@ var _ = Describe("googledrive sectionstore", func() {
 	Context("create new section", func() {
 		It("should succeed when correct input - w/ existing content", func() {
 			verifyReq := func(req *http.Request) {
-				var sections []*sectionstore.Section
-				content := readContent(req)
-				json.Unmarshal(content, &sections)
+				sections := readSections(req)
 
 				Expect(sections).Should(HaveLen(2))
 				Expect(sections[1].ID).ShouldNot(BeEmpty())
@@ -44,7 +49,7 @@ var _ = Describe("googledrive sectionstore", func() {
 			}
 
 			client := http.DefaultClient
-			client.Transport = utils.TransportFunc(func(req *http.Request) (*http.Response, error) {
+			client.Transport = withProperties(nil, func(req *http.Request) (*http.Response, error) {
 				j := `[
 						{
 							"id": "gdtg45w9mjh10ds",
@@ -61,8 +66,8 @@ var _ = Describe("googledrive sectionstore", func() {
 				return buildResponse(http.StatusOK, j), nil
 			})
 
-			dss, _ := drvsectionstore.New(client)
-			section, err := dss.Create("nid", &sectionstore.WritableSection{
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			section, err := dss.Create(context.Background(), "nid", &sectionstore.WritableSection{
 				Name:   "new-section",
 				Labels: []string{"label1", "label2"},
 				Metadata: map[string]string{
@@ -81,9 +86,7 @@ var _ = Describe("googledrive sectionstore", func() {
 
 		It("should succeed when correct input - w/o existing content", func() {
 			verifyReq := func(req *http.Request) {
-				var sections []*sectionstore.Section
-				content := readContent(req)
-				json.Unmarshal(content, &sections)
+				sections := readSections(req)
 
 				Expect(sections).Should(HaveLen(1))
 				Expect(sections[0].ID).ShouldNot(BeEmpty())
@@ -93,7 +96,7 @@ var _ = Describe("googledrive sectionstore", func() {
 			}
 
 			client := http.DefaultClient
-			client.Transport = utils.TransportFunc(func(req *http.Request) (*http.Response, error) {
+			client.Transport = withProperties(nil, func(req *http.Request) (*http.Response, error) {
 				j := ``
 				if req.Method == "PATCH" {
 					verifyReq(req)
@@ -102,8 +105,8 @@ var _ = Describe("googledrive sectionstore", func() {
 				return buildResponse(http.StatusOK, j), nil
 			})
 
-			dss, _ := drvsectionstore.New(client)
-			section, err := dss.Create("nid", &sectionstore.WritableSection{
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			section, err := dss.Create(context.Background(), "nid", &sectionstore.WritableSection{
 				Name: "new-section",
 				Data: map[string]string{
 					"item1": "value1",
@@ -118,9 +121,7 @@ var _ = Describe("googledrive sectionstore", func() {
 
 		It("should succeed when unsanitized input", func() {
 			verifyReq := func(req *http.Request) {
-				var sections []*sectionstore.Section
-				content := readContent(req)
-				json.Unmarshal(content, &sections)
+				sections := readSections(req)
 
 				Expect(sections).Should(HaveLen(1))
 				Expect(sections[0].ID).ShouldNot(BeEmpty())
@@ -134,7 +135,7 @@ var _ = Describe("googledrive sectionstore", func() {
 			}
 
 			client := http.DefaultClient
-			client.Transport = utils.TransportFunc(func(req *http.Request) (*http.Response, error) {
+			client.Transport = withProperties(nil, func(req *http.Request) (*http.Response, error) {
 				j := ``
 				if req.Method == "PATCH" {
 					verifyReq(req)
@@ -143,8 +144,8 @@ var _ = Describe("googledrive sectionstore", func() {
 				return buildResponse(http.StatusOK, j), nil
 			})
 
-			dss, _ := drvsectionstore.New(client)
-			section, err := dss.Create("nid", &sectionstore.WritableSection{
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			section, err := dss.Create(context.Background(), "nid", &sectionstore.WritableSection{
 				Name:   " new-section ",
 				Labels: []string{"label1", " ", "label2  "},
 				Metadata: map[string]string{
@@ -162,9 +163,147 @@ var _ = Describe("googledrive sectionstore", func() {
 			Expect(section.Name).Should(Equal("new-section"))
 		})
 
+		It("should succeed when section kind with payload", func() {
+			verifyReq := func(req *http.Request) {
+				sections := readSections(req)
+
+				Expect(sections).Should(HaveLen(1))
+				Expect(sections[0].Kind).Should(Equal(sectionstore.KindChecklist))
+				Expect(sections[0].Checklist).Should(HaveLen(2))
+				Expect(sections[0].Checklist[0].Text).Should(Equal("item1"))
+				Expect(sections[0].Checklist[0].Done).Should(BeTrue())
+				Expect(sections[0].Checklist[1].Text).Should(Equal("item2"))
+				Expect(sections[0].Checklist[1].Done).Should(BeFalse())
+			}
+
+			client := http.DefaultClient
+			client.Transport = withProperties(nil, func(req *http.Request) (*http.Response, error) {
+				j := ``
+				if req.Method == "PATCH" {
+					verifyReq(req)
+					j = `{ "id": "nid" }`
+				}
+				return buildResponse(http.StatusOK, j), nil
+			})
+
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			section, err := dss.Create(context.Background(), "nid", &sectionstore.WritableSection{
+				Name: "section",
+				Kind: sectionstore.KindChecklist,
+				Checklist: []*sectionstore.ChecklistItem{
+					{Text: " item1 ", Done: true},
+					{Text: "item2"},
+				},
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(section.Kind).Should(Equal(sectionstore.KindChecklist))
+		})
+
+		It("should set timestamps and author on new section", func() {
+			mockCtrl := gomock.NewController(GinkgoT())
+			defer mockCtrl.Finish()
+			mockUserinfo := mocks.NewMockUserinfo(mockCtrl)
+			mockUserinfo.EXPECT().Get(gomock.Any()).Return(&userinfo.User{ID: "uid"}, nil)
+
+			client := http.DefaultClient
+			client.Transport = withProperties(nil, func(req *http.Request) (*http.Response, error) {
+				j := ``
+				if req.Method == "PATCH" {
+					j = `{ "id": "nid" }`
+				}
+				return buildResponse(http.StatusOK, j), nil
+			})
+
+			dss, _ := drvsectionstore.New(client, mockUserinfo, compress.EncodingNone)
+			section, err := dss.Create(context.Background(), "nid", &sectionstore.WritableSection{
+				Name: "section",
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(section.DateCreated).ShouldNot(BeNil())
+			Expect(section.DateUpdated).Should(Equal(section.DateCreated))
+			Expect(section.CreatedBy).Should(Equal("uid"))
+			Expect(section.UpdatedBy).Should(Equal("uid"))
+		})
+
+		It("should return error when author lookup failure", func() {
+			mockCtrl := gomock.NewController(GinkgoT())
+			defer mockCtrl.Finish()
+			mockUserinfo := mocks.NewMockUserinfo(mockCtrl)
+			mockUserinfo.EXPECT().Get(gomock.Any()).Return(nil, errs.NewUnauthorizedError())
+
+			client := clientWithJSON(`[]`, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, mockUserinfo, compress.EncodingNone)
+			_, err := dss.Create(context.Background(), "nid", &sectionstore.WritableSection{
+				Name: "section",
+			})
+
+			Expect(err).Should(HaveOccurred())
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewUnauthorizedError()))
+		})
+
+		It("should set data kind when kind is not specified", func() {
+			client := http.DefaultClient
+			client.Transport = withProperties(nil, func(req *http.Request) (*http.Response, error) {
+				j := ``
+				if req.Method == "PATCH" {
+					j = `{ "id": "nid" }`
+				}
+				return buildResponse(http.StatusOK, j), nil
+			})
+
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			section, err := dss.Create(context.Background(), "nid", &sectionstore.WritableSection{
+				Name: "section",
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(section.Kind).Should(Equal(sectionstore.KindData))
+		})
+
+		It("should return error when payload doesn't match kind", func() {
+			dss, _ := drvsectionstore.New(http.DefaultClient, nil, compress.EncodingNone)
+			section, err := dss.Create(context.Background(), "nid", &sectionstore.WritableSection{
+				Name: "section",
+				Kind: sectionstore.KindText,
+				Code: &sectionstore.Code{Source: "fmt.Println()"},
+			})
+
+			Expect(err).Should(HaveOccurred())
+			Expect(section).Should(BeNil())
+		})
+
+		It("should return error when kind has whitespaces", func() {
+			dss, _ := drvsectionstore.New(http.DefaultClient, nil, compress.EncodingNone)
+			section, err := dss.Create(context.Background(), "nid", &sectionstore.WritableSection{
+				Name: "section",
+				Kind: " text",
+				Text: &sectionstore.Text{Body: "body"},
+			})
+
+			Expect(err).Should(HaveOccurred())
+			Expect(section).Should(BeNil())
+		})
+
+		It("should return error when table row width is wrong", func() {
+			dss, _ := drvsectionstore.New(http.DefaultClient, nil, compress.EncodingNone)
+			section, err := dss.Create(context.Background(), "nid", &sectionstore.WritableSection{
+				Name: "section",
+				Kind: sectionstore.KindTable,
+				Table: &sectionstore.Table{
+					Columns: []string{"col1", "col2"},
+					Rows:    [][]string{{"value1"}},
+				},
+			})
+
+			Expect(err).Should(HaveOccurred())
+			Expect(section).Should(BeNil())
+		})
+
 		It("should return error when wrong input", func() {
-			dss, _ := drvsectionstore.New(http.DefaultClient)
-			section, err := dss.Create("nid", &sectionstore.WritableSection{
+			dss, _ := drvsectionstore.New(http.DefaultClient, nil, compress.EncodingNone)
+			section, err := dss.Create(context.Background(), "nid", &sectionstore.WritableSection{
 				Labels: []string{"label1", "label2"},
 			})
 
@@ -172,11 +311,31 @@ var _ = Describe("googledrive sectionstore", func() {
 			Expect(section).Should(BeNil())
 		})
 
+		It("should return error when data violates note schema", func() {
+			j := `{
+					"schema": {
+						"required": ["email"],
+						"properties": { "email": { "format": "email" } }
+					},
+					"sections": []
+				}`
+			client := clientWithJSON(j, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			section, err := dss.Create(context.Background(), "nid", &sectionstore.WritableSection{
+				Name: "section",
+				Data: map[string]string{"name": "john"},
+			})
+
+			Expect(err).Should(HaveOccurred())
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+			Expect(section).Should(BeNil())
+		})
+
 		It("should return error when wrong note id", func() {
 			code := http.StatusNotFound
-			client := utils.ClientWithJSON("{}", code)
-			dss, _ := drvsectionstore.New(client)
-			_, err := dss.Create("nid", &sectionstore.WritableSection{
+			client := clientWithJSON("{}", code)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			_, err := dss.Create(context.Background(), "nid", &sectionstore.WritableSection{
 				Name: "section",
 			})
 
@@ -186,9 +345,9 @@ var _ = Describe("googledrive sectionstore", func() {
 
 		It("should return error when authorization failure", func() {
 			code := http.StatusUnauthorized
-			client := utils.ClientWithJSON("{}", code)
-			dss, _ := drvsectionstore.New(client)
-			_, err := dss.Create("nid", &sectionstore.WritableSection{
+			client := clientWithJSON("{}", code)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			_, err := dss.Create(context.Background(), "nid", &sectionstore.WritableSection{
 				Name: "section",
 			})
 
@@ -215,9 +374,9 @@ var _ = Describe("googledrive sectionstore", func() {
 							}
 						}
 					]`
-			client := utils.ClientWithJSON(j, http.StatusOK)
-			dss, _ := drvsectionstore.New(client)
-			sections, err := dss.GetAll("nid")
+			client := clientWithJSON(j, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			sections, err := dss.GetAll(context.Background(), "nid", nil)
 
 			Expect(err).ShouldNot(HaveOccurred())
 			Expect(sections).ShouldNot(BeNil())
@@ -232,10 +391,110 @@ var _ = Describe("googledrive sectionstore", func() {
 			Expect(sections[1].Data).Should(HaveKeyWithValue("item1", "value1"))
 		})
 
+		It("should return filtered and sorted sections when query", func() {
+			j := `[
+						{
+							"id": "secid1",
+							"name": "section1",
+							"dateCreated": "2021-02-10T10:00:00Z",
+							"createdBy": "uid1"
+						},
+						{
+							"id": "secid2",
+							"name": "section2",
+							"dateCreated": "2021-02-12T10:00:00Z",
+							"createdBy": "uid1"
+						},
+						{
+							"id": "secid3",
+							"name": "section3",
+							"dateCreated": "2021-02-11T10:00:00Z",
+							"createdBy": "uid2"
+						},
+						{
+							"id": "secid4",
+							"name": "section4"
+						}
+					]`
+			client := clientWithJSON(j, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			sections, err := dss.GetAll(context.Background(), "nid", &sectionstore.Query{
+				CreatedBy: "uid1",
+				Sort:      "-dateCreated",
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sections).Should(HaveLen(2))
+			Expect(sections[0].ID).Should(Equal("secid2"))
+			Expect(sections[1].ID).Should(Equal("secid1"))
+
+			after, _ := time.Parse(time.RFC3339, "2021-02-10T12:00:00Z")
+			sections, err = dss.GetAll(context.Background(), "nid", &sectionstore.Query{
+				CreatedAfter: after,
+				Sort:         "dateCreated",
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sections).Should(HaveLen(2))
+			Expect(sections[0].ID).Should(Equal("secid3"))
+			Expect(sections[1].ID).Should(Equal("secid2"))
+		})
+
+		It("should return matching sections with projected fields", func() {
+			j := `[
+						{
+							"id": "secid1",
+							"name": "Contact John",
+							"labels": ["contact", "vip"],
+							"metadata": { "region": "eu" },
+							"data": { "email": "john@example.com" }
+						},
+						{
+							"id": "secid2",
+							"name": "Contact Jane",
+							"labels": ["contact"],
+							"metadata": { "region": "eu" },
+							"data": { "email": "jane@example.com" }
+						},
+						{
+							"id": "secid3",
+							"name": "Address",
+							"labels": ["contact", "vip"],
+							"metadata": { "region": "us" }
+						}
+					]`
+			client := clientWithJSON(j, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			sections, err := dss.GetAll(context.Background(), "nid", &sectionstore.Query{
+				Name:     "contact",
+				Labels:   []string{"vip"},
+				Metadata: map[string]string{"region": "eu"},
+				Data:     map[string]string{"email": ""},
+				Fields:   []string{"id", "name"},
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sections).Should(HaveLen(1))
+			Expect(sections[0].ID).Should(Equal("secid1"))
+			Expect(sections[0].Name).Should(Equal("Contact John"))
+			Expect(sections[0].Labels).Should(BeNil())
+			Expect(sections[0].Data).Should(BeNil())
+		})
+
+		It("should return empty sections when no section matches", func() {
+			client := clientWithJSON(`[ { "id": "secid1", "name": "section" } ]`, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			sections, err := dss.GetAll(context.Background(), "nid", &sectionstore.Query{Name: "other"})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sections).ShouldNot(BeNil())
+			Expect(sections).Should(BeEmpty())
+		})
+
 		It("should return nil when no note content", func() {
-			client := utils.ClientWithJSON(``, http.StatusOK)
-			dss, _ := drvsectionstore.New(client)
-			sections, err := dss.GetAll("nid")
+			client := clientWithJSON(``, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			sections, err := dss.GetAll(context.Background(), "nid", nil)
 
 			Expect(err).ShouldNot(HaveOccurred())
 			Expect(sections).Should(BeNil())
@@ -243,9 +502,9 @@ var _ = Describe("googledrive sectionstore", func() {
 
 		It("should return error when download failure", func() {
 			code := getRndHTTPErrorCode()
-			client := utils.ClientWithJSON("{}", code)
-			dss, _ := drvsectionstore.New(client)
-			_, err := dss.GetAll("nid")
+			client := clientWithJSON("{}", code)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			_, err := dss.GetAll(context.Background(), "nid", nil)
 
 			assertDownloadError(err, code)
 		})
@@ -269,9 +528,9 @@ var _ = Describe("googledrive sectionstore", func() {
 						}
 					}
 				]`
-			client := utils.ClientWithJSON(j, http.StatusOK)
-			dss, _ := drvsectionstore.New(client)
-			section, err := dss.Get("nid", "secid1")
+			client := clientWithJSON(j, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			section, err := dss.Get(context.Background(), "nid", "secid1")
 
 			Expect(err).ShouldNot(HaveOccurred())
 			Expect(section).ShouldNot(BeNil())
@@ -292,9 +551,9 @@ var _ = Describe("googledrive sectionstore", func() {
 						}
 					}
 				]`
-			client := utils.ClientWithJSON(j, http.StatusOK)
-			dss, _ := drvsectionstore.New(client)
-			_, err := dss.Get("nid", "wrong")
+			client := clientWithJSON(j, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			_, err := dss.Get(context.Background(), "nid", "wrong")
 
 			Expect(err).Should(HaveOccurred())
 			Expect(err).Should(BeAssignableToTypeOf(errs.NewNotFoundError("msg")))
@@ -302,9 +561,9 @@ var _ = Describe("googledrive sectionstore", func() {
 
 		It("should return error when download failure", func() {
 			code := getRndHTTPErrorCode()
-			client := utils.ClientWithJSON("{}", code)
-			dss, _ := drvsectionstore.New(client)
-			_, err := dss.Get("nid", "secid")
+			client := clientWithJSON("{}", code)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			_, err := dss.Get(context.Background(), "nid", "secid")
 
 			assertDownloadError(err, code)
 		})
@@ -313,9 +572,7 @@ var _ = Describe("googledrive sectionstore", func() {
 	Context("update section", func() {
 		It("should succeed when correct input", func() {
 			verifyReq := func(req *http.Request) {
-				var sections []*sectionstore.Section
-				content := readContent(req)
-				json.Unmarshal(content, &sections)
+				sections := readSections(req)
 
 				Expect(sections).Should(HaveLen(1))
 				Expect(sections[0].ID).ShouldNot(BeEmpty())
@@ -329,7 +586,7 @@ var _ = Describe("googledrive sectionstore", func() {
 			}
 
 			client := http.DefaultClient
-			client.Transport = utils.TransportFunc(func(req *http.Request) (*http.Response, error) {
+			client.Transport = withProperties(nil, func(req *http.Request) (*http.Response, error) {
 				j := `[
 						{
 							"id": "secid",
@@ -346,8 +603,8 @@ var _ = Describe("googledrive sectionstore", func() {
 				return buildResponse(http.StatusOK, j), nil
 			})
 
-			dss, _ := drvsectionstore.New(client)
-			section, err := dss.Update("nid", "secid", &sectionstore.WritableSection{
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			section, err := dss.Update(context.Background(), "nid", "secid", &sectionstore.WritableSection{
 				Name:   "section-updated",
 				Labels: []string{"label1", "label2"},
 				Metadata: map[string]string{
@@ -364,11 +621,45 @@ var _ = Describe("googledrive sectionstore", func() {
 			Expect(section.Name).Should(Equal("section-updated"))
 		})
 
+		It("should keep creation time and set update time", func() {
+			mockCtrl := gomock.NewController(GinkgoT())
+			defer mockCtrl.Finish()
+			mockUserinfo := mocks.NewMockUserinfo(mockCtrl)
+			mockUserinfo.EXPECT().Get(gomock.Any()).Return(&userinfo.User{ID: "uid2"}, nil)
+
+			client := http.DefaultClient
+			client.Transport = withProperties(nil, func(req *http.Request) (*http.Response, error) {
+				j := `[
+						{
+							"id": "secid",
+							"name": "section",
+							"dateCreated": "2021-02-10T10:00:00Z",
+							"dateUpdated": "2021-02-10T10:00:00Z",
+							"createdBy": "uid1",
+							"updatedBy": "uid1"
+						}
+					]`
+				if req.Method == "PATCH" {
+					j = `{ "id": "nid" }`
+				}
+				return buildResponse(http.StatusOK, j), nil
+			})
+
+			dss, _ := drvsectionstore.New(client, mockUserinfo, compress.EncodingNone)
+			section, err := dss.Update(context.Background(), "nid", "secid", &sectionstore.WritableSection{
+				Name: "section-updated",
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(section.DateCreated.Format(time.RFC3339)).Should(Equal("2021-02-10T10:00:00Z"))
+			Expect(section.DateUpdated.After(*section.DateCreated)).Should(BeTrue())
+			Expect(section.CreatedBy).Should(Equal("uid1"))
+			Expect(section.UpdatedBy).Should(Equal("uid2"))
+		})
+
 		It("should succeed when unsanitized input", func() {
 			verifyReq := func(req *http.Request) {
-				var sections []*sectionstore.Section
-				content := readContent(req)
-				json.Unmarshal(content, &sections)
+				sections := readSections(req)
 
 				Expect(sections).Should(HaveLen(1))
 				Expect(sections[0].ID).ShouldNot(BeEmpty())
@@ -382,7 +673,7 @@ var _ = Describe("googledrive sectionstore", func() {
 			}
 
 			client := http.DefaultClient
-			client.Transport = utils.TransportFunc(func(req *http.Request) (*http.Response, error) {
+			client.Transport = withProperties(nil, func(req *http.Request) (*http.Response, error) {
 				j := `[
 						{
 							"id": "secid",
@@ -399,8 +690,8 @@ var _ = Describe("googledrive sectionstore", func() {
 				return buildResponse(http.StatusOK, j), nil
 			})
 
-			dss, _ := drvsectionstore.New(client)
-			section, err := dss.Update("nid", "secid", &sectionstore.WritableSection{
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			section, err := dss.Update(context.Background(), "nid", "secid", &sectionstore.WritableSection{
 				Name:   " section-updated ",
 				Labels: []string{"label1", " ", "label2  "},
 				Metadata: map[string]string{
@@ -418,9 +709,37 @@ var _ = Describe("googledrive sectionstore", func() {
 			Expect(section.Name).Should(Equal("section-updated"))
 		})
 
+		It("should save trimmed and unique links", func() {
+			client := http.DefaultClient
+			client.Transport = withProperties(nil, func(req *http.Request) (*http.Response, error) {
+				j := `[ { "id": "secid", "name": "section" } ]`
+				if req.Method == "PATCH" {
+					sections := readSections(req)
+					Expect(sections[0].Links).Should(HaveLen(2))
+					Expect(sections[0].Links[0].NoteID).Should(Equal("nid2"))
+					Expect(sections[0].Links[1].SectionID).Should(Equal("sid2"))
+					j = `{ "id": "nid" }`
+				}
+				return buildResponse(http.StatusOK, j), nil
+			})
+
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			section, err := dss.Update(context.Background(), "nid", "secid", &sectionstore.WritableSection{
+				Name: "section",
+				Links: []*sectionstore.Link{
+					{NoteID: " nid2 "},
+					{NoteID: "nid2"},
+					{NoteID: "nid2", SectionID: "sid2 "},
+				},
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(section.Links).Should(HaveLen(2))
+		})
+
 		It("should return error when wrong input", func() {
-			dss, _ := drvsectionstore.New(http.DefaultClient)
-			section, err := dss.Update("nid", "sid", &sectionstore.WritableSection{
+			dss, _ := drvsectionstore.New(http.DefaultClient, nil, compress.EncodingNone)
+			section, err := dss.Update(context.Background(), "nid", "sid", &sectionstore.WritableSection{
 				Labels: []string{"label1", "label2"},
 			})
 
@@ -438,9 +757,9 @@ var _ = Describe("googledrive sectionstore", func() {
 						}
 					}
 				]`
-			client := utils.ClientWithJSON(j, http.StatusOK)
-			dss, _ := drvsectionstore.New(client)
-			_, err := dss.Update("nid", "wrong", &sectionstore.WritableSection{
+			client := clientWithJSON(j, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			_, err := dss.Update(context.Background(), "nid", "wrong", &sectionstore.WritableSection{
 				Name: "section-updated",
 				Data: map[string]string{
 					"item1": "value1-updated",
@@ -453,9 +772,9 @@ var _ = Describe("googledrive sectionstore", func() {
 
 		It("should return error when download failure", func() {
 			code := getRndHTTPErrorCode()
-			client := utils.ClientWithJSON("{}", code)
-			dss, _ := drvsectionstore.New(client)
-			_, err := dss.Update("nid", "sid", &sectionstore.WritableSection{
+			client := clientWithJSON("{}", code)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			_, err := dss.Update(context.Background(), "nid", "sid", &sectionstore.WritableSection{
 				Name: "section-updated",
 			})
 
@@ -466,9 +785,7 @@ var _ = Describe("googledrive sectionstore", func() {
 	Context("delete section", func() {
 		It("should succeed when correct section id", func() {
 			verifyReq := func(req *http.Request) {
-				var sections []*sectionstore.Section
-				content := readContent(req)
-				json.Unmarshal(content, &sections)
+				sections := readSections(req)
 
 				Expect(sections).Should(HaveLen(1))
 				Expect(sections[0].ID).ShouldNot(Equal("secid1"))
@@ -476,7 +793,7 @@ var _ = Describe("googledrive sectionstore", func() {
 			}
 
 			client := http.DefaultClient
-			client.Transport = utils.TransportFunc(func(req *http.Request) (*http.Response, error) {
+			client.Transport = withProperties(nil, func(req *http.Request) (*http.Response, error) {
 				j := `[
 						{
 							"id": "secid1",
@@ -500,8 +817,8 @@ var _ = Describe("googledrive sectionstore", func() {
 				return buildResponse(http.StatusOK, j), nil
 			})
 
-			dss, _ := drvsectionstore.New(client)
-			err := dss.Delete("nid", "secid1")
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			err := dss.Delete(context.Background(), "nid", "secid1")
 
 			Expect(err).ShouldNot(HaveOccurred())
 		})
@@ -516,9 +833,9 @@ var _ = Describe("googledrive sectionstore", func() {
 						}
 					}
 				]`
-			client := utils.ClientWithJSON(j, http.StatusOK)
-			dss, _ := drvsectionstore.New(client)
-			err := dss.Delete("nid", "wrong")
+			client := clientWithJSON(j, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			err := dss.Delete(context.Background(), "nid", "wrong")
 
 			Expect(err).Should(HaveOccurred())
 			Expect(err).Should(BeAssignableToTypeOf(errs.NewNotFoundError("msg")))
@@ -526,13 +843,354 @@ var _ = Describe("googledrive sectionstore", func() {
 
 		It("should return error when download failure", func() {
 			code := getRndHTTPErrorCode()
-			client := utils.ClientWithJSON("{}", code)
-			dss, _ := drvsectionstore.New(client)
-			err := dss.Delete("nid", "sid")
+			client := clientWithJSON("{}", code)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			err := dss.Delete(context.Background(), "nid", "sid")
 
 			assertDownloadError(err, code)
 		})
 	})
+
+	Context("batch sections", func() {
+		j := `[
+				{
+					"id": "secid1",
+					"name": "section1"
+				},
+				{
+					"id": "secid2",
+					"name": "section2"
+				}
+			]`
+
+		It("should apply all operations in single upload", func() {
+			uploads := 0
+			verifyReq := func(req *http.Request) {
+				sections := readSections(req)
+
+				Expect(sections).Should(HaveLen(2))
+				Expect(sections[0].Name).Should(Equal("section1-updated"))
+				Expect(sections[1].Name).Should(Equal("section3"))
+			}
+
+			client := http.DefaultClient
+			client.Transport = withProperties(nil, func(req *http.Request) (*http.Response, error) {
+				content := j
+				if req.Method == "PATCH" {
+					uploads++
+					verifyReq(req)
+					content = `{ "id": "nid" }`
+				}
+				return buildResponse(http.StatusOK, content), nil
+			})
+
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			results, err := dss.Batch(context.Background(), "nid", []*sectionstore.Operation{
+				{Type: sectionstore.OperationUpdate, ID: "secid1", Section: &sectionstore.WritableSection{Name: "section1-updated"}},
+				{Type: sectionstore.OperationDelete, ID: "secid2"},
+				{Type: sectionstore.OperationCreate, Section: &sectionstore.WritableSection{Name: "section3"}},
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(uploads).Should(Equal(1))
+			Expect(results).Should(HaveLen(3))
+			Expect(results[0].Section.Name).Should(Equal("section1-updated"))
+			Expect(results[1].ID).Should(Equal("secid2"))
+			Expect(results[1].Section).Should(BeNil())
+			Expect(results[2].ID).ShouldNot(BeEmpty())
+		})
+
+		It("should not upload when any operation fails", func() {
+			client := http.DefaultClient
+			client.Transport = withProperties(nil, func(req *http.Request) (*http.Response, error) {
+				Expect(req.Method).ShouldNot(Equal("PATCH"))
+				return buildResponse(http.StatusOK, j), nil
+			})
+
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			_, err := dss.Batch(context.Background(), "nid", []*sectionstore.Operation{
+				{Type: sectionstore.OperationCreate, Section: &sectionstore.WritableSection{Name: "section3"}},
+				{Type: sectionstore.OperationDelete, ID: "wrong"},
+			})
+
+			Expect(err).Should(HaveOccurred())
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewNotFoundError("msg")))
+			Expect(err.Error()).Should(HavePrefix("operation 1:"))
+		})
+
+		It("should return error when wrong operation", func() {
+			dss, _ := drvsectionstore.New(http.DefaultClient, nil, compress.EncodingNone)
+			_, err := dss.Batch(context.Background(), "nid", []*sectionstore.Operation{
+				{Type: sectionstore.OperationDelete},
+			})
+
+			Expect(err).Should(HaveOccurred())
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+		})
+	})
+
+	Context("note schema", func() {
+		It("should return the schema when declared", func() {
+			j := `{
+					"schema": { "required": ["email"], "properties": { "email": {} } },
+					"sections": []
+				}`
+			client := clientWithJSON(j, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			sch, err := dss.GetSchema(context.Background(), "nid")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sch.Required).Should(ContainElement("email"))
+		})
+
+		It("should return error when schema is not declared", func() {
+			client := clientWithJSON(`[]`, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			_, err := dss.GetSchema(context.Background(), "nid")
+
+			Expect(err).Should(HaveOccurred())
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewNotFoundError("msg")))
+		})
+
+		It("should save the schema along with sections", func() {
+			verifyReq := func(req *http.Request) {
+				var doc struct {
+					Schema   *schema.Schema
+					Sections []*sectionstore.Section
+				}
+				content := readContent(req)
+				json.Unmarshal(content, &doc)
+
+				Expect(doc.Schema).ShouldNot(BeNil())
+				Expect(doc.Schema.Required).Should(ContainElement("item1"))
+				Expect(doc.Sections).Should(HaveLen(1))
+			}
+
+			client := http.DefaultClient
+			client.Transport = withProperties(nil, func(req *http.Request) (*http.Response, error) {
+				j := `[
+						{
+							"id": "secid",
+							"name": "section",
+							"data": {
+								"item1": "value1"
+							}
+						}
+					]`
+				if req.Method == "PATCH" {
+					verifyReq(req)
+					j = `{ "id": "nid" }`
+				}
+				return buildResponse(http.StatusOK, j), nil
+			})
+
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			err := dss.SetSchema(context.Background(), "nid", &schema.Schema{
+				Required:   []string{"item1"},
+				Properties: map[string]*schema.Schema{"item1": {}},
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should return error when existing section violates schema", func() {
+			j := `[
+					{
+						"id": "secid",
+						"name": "section",
+						"data": {
+							"item1": "value1"
+						}
+					}
+				]`
+			client := clientWithJSON(j, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			err := dss.SetSchema(context.Background(), "nid", &schema.Schema{
+				Required:   []string{"item2"},
+				Properties: map[string]*schema.Schema{"item2": {}},
+			})
+
+			Expect(err).Should(HaveOccurred())
+			Expect(err).Should(BeAssignableToTypeOf(errs.NewValidationError("msg")))
+		})
+	})
+
+	Context("note format migration", func() {
+		It("should read version 1 content when bare section array", func() {
+			j := `[ { "id": "sid", "name": "section", "data": { "item1": "value1" } } ]`
+			client := clientWithJSON(j, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			sections, err := dss.GetAll(context.Background(), "nid", nil)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sections).Should(HaveLen(1))
+			Expect(sections[0].Kind).Should(Equal(sectionstore.KindData))
+			Expect(sections[0].Data).Should(HaveKeyWithValue("item1", "value1"))
+		})
+
+		It("should read version 2 content when document without version", func() {
+			j := `{
+					"schema": { "properties": { "item1": {} } },
+					"sections": [ { "id": "sid", "name": "section" } ]
+				}`
+			client := clientWithJSON(j, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			sch, err := dss.GetSchema(context.Background(), "nid")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sch.Properties).Should(HaveKey("item1"))
+
+			sections, err := dss.GetAll(context.Background(), "nid", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sections).Should(HaveLen(1))
+			Expect(sections[0].Kind).Should(Equal(sectionstore.KindData))
+		})
+
+		It("should keep the kind when version 2 content has it", func() {
+			j := `{ "sections": [ { "id": "sid", "kind": "text", "text": { "body": "text" } } ] }`
+			client := clientWithJSON(j, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			sections, err := dss.GetAll(context.Background(), "nid", nil)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sections[0].Kind).Should(Equal(sectionstore.KindText))
+		})
+
+		It("should return error when newer version", func() {
+			j := `{ "version": 99, "sections": [] }`
+			client := clientWithJSON(j, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			_, err := dss.GetAll(context.Background(), "nid", nil)
+
+			Expect(err).Should(HaveOccurred())
+		})
+
+		DescribeTable("should return error when version below 1",
+			func(j string) {
+				_, err := drvsectionstore.Sections([]byte(j))
+				Expect(err).Should(HaveOccurred())
+
+				_, err = drvsectionstore.Relink([]byte(j), map[string]string{"old": "new"})
+				Expect(err).Should(HaveOccurred())
+			},
+			Entry("negative version", `{ "version": -1, "sections": [] }`),
+			Entry("zero version", `{ "version": 0, "sections": [] }`),
+		)
+
+		It("should rewrite the content when older version", func() {
+			uploads := 0
+			client := http.DefaultClient
+			client.Transport = withProperties(nil, func(req *http.Request) (*http.Response, error) {
+				j := `[ { "id": "sid", "name": "section" } ]`
+				if req.Method == "PATCH" {
+					uploads++
+					sections := readSections(req)
+					Expect(sections).Should(HaveLen(1))
+					Expect(sections[0].Kind).Should(Equal(sectionstore.KindData))
+					j = `{ "id": "nid" }`
+				}
+				return buildResponse(http.StatusOK, j), nil
+			})
+
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			migrated, err := dss.Migrate(context.Background(), "nid")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(migrated).Should(BeTrue())
+			Expect(uploads).Should(Equal(1))
+		})
+
+		It("should not rewrite the content when current version", func() {
+			j := `{ "version": 3, "sections": [] }`
+			client := clientWithJSON(j, http.StatusOK)
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			migrated, err := dss.Migrate(context.Background(), "nid")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(migrated).Should(BeFalse())
+		})
+	})
+
+	Context("note links relink", func() {
+		It("should rewrite the link note ids when mapped", func() {
+			j := `[ { "id": "sid", "links": [ { "noteId": "old" }, { "noteId": "other" } ] } ]`
+			content, err := drvsectionstore.Relink([]byte(j), map[string]string{"old": "new"})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			sections, err := drvsectionstore.Sections(content)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sections).Should(HaveLen(1))
+			Expect(sections[0].Links[0].NoteID).Should(Equal("new"))
+			Expect(sections[0].Links[1].NoteID).Should(Equal("other"))
+		})
+
+		It("should keep the empty content when no sections", func() {
+			content, err := drvsectionstore.Relink(nil, map[string]string{"old": "new"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(content).Should(BeEmpty())
+		})
+	})
+
+	Context("note content compression", func() {
+		It("should upload compressed content when encoding", func() {
+			for _, encoding := range []string{compress.EncodingGzip, compress.EncodingZstd} {
+				client := http.DefaultClient
+				client.Transport = withProperties(nil, func(req *http.Request) (*http.Response, error) {
+					j := ``
+					if req.Method == "PATCH" {
+						content := readContent(req)
+						Expect(compress.Detect(content)).Should(Equal(encoding))
+						decoded, err := compress.Decode(content, encoding)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(decoded).Should(ContainSubstring(`"name":"section"`))
+						j = `{ "id": "nid" }`
+					}
+					return buildResponse(http.StatusOK, j), nil
+				})
+
+				dss, _ := drvsectionstore.New(client, nil, encoding)
+				_, err := dss.Create(context.Background(), "nid", &sectionstore.WritableSection{Name: "section"})
+				Expect(err).ShouldNot(HaveOccurred())
+			}
+		})
+
+		It("should read the content when compressed", func() {
+			j := []byte(`{ "version": 3, "sections": [ { "id": "sid", "name": "section" } ] }`)
+			for _, encoding := range []string{compress.EncodingGzip, compress.EncodingZstd} {
+				content, _ := compress.Encode(j, encoding)
+				properties := map[string]string{compress.PropertyKey: encoding}
+				client := http.DefaultClient
+				client.Transport = withProperties(properties, func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(bytes.NewReader(content)),
+					}, nil
+				})
+
+				dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+				sections, err := dss.GetAll(context.Background(), "nid", nil)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(sections).Should(HaveLen(1))
+			}
+		})
+
+		It("should decode the content by recorded encoding", func() {
+			properties := map[string]string{compress.PropertyKey: compress.EncodingGzip}
+			client := http.DefaultClient
+			client.Transport = withProperties(properties, func(req *http.Request) (*http.Response, error) {
+				return buildResponse(http.StatusOK, `[]`), nil
+			})
+
+			dss, _ := drvsectionstore.New(client, nil, compress.EncodingNone)
+			_, err := dss.GetAll(context.Background(), "nid", nil)
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).Should(ContainSubstring("decompression"))
+		})
+
+		It("should return error when wrong encoding", func() {
+			_, err := drvsectionstore.New(http.DefaultClient, nil, "lz4")
+			Expect(err).Should(HaveOccurred())
+		})
+	})
 })
 
 func readContent(req *http.Request) []byte {
@@ -547,6 +1205,43 @@ func readContent(req *http.Request) []byte {
 	return content
 }
 
+func readSections(req *http.Request) []*sectionstore.Section {
+	var doc struct {
+		Version  int
+		Sections []*sectionstore.Section
+	}
+	content := readContent(req)
+	content, _ = compress.Decode(content, compress.Detect(content))
+	json.Unmarshal(content, &doc)
+	Expect(doc.Version).Should(Equal(drvsectionstore.FormatVersion))
+	return doc.Sections
+}
+
+// withProperties answers the request of file properties, and forwards the
+// other requests to the transport function.
+func withProperties(properties map[string]string, fn utils.TransportFunc) utils.TransportFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodGet && req.URL.Query().Get("alt") != "media" {
+			j, _ := json.Marshal(map[string]interface{}{"properties": properties})
+			return buildResponse(http.StatusOK, string(j)), nil
+		}
+		return fn(req)
+	}
+}
+
+// clientWithJSON is same as utils.ClientWithJSON, but it answers the request
+// of file properties with the same status code.
+func clientWithJSON(j string, code int) *http.Client {
+	return &http.Client{
+		Transport: utils.TransportFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodGet && req.URL.Query().Get("alt") != "media" {
+				return buildResponse(code, "{}"), nil
+			}
+			return buildResponse(code, j), nil
+		}),
+	}
+}
+
 func buildResponse(code int, j string) *http.Response {
 	return &http.Response{
 		StatusCode: code,
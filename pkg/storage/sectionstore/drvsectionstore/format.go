@@ -0,0 +1,149 @@
+package drvsectionstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/schema"
+	secstore "github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+// FormatVersion is the version of note content written by the sectionstore.
+// The version 1 is the bare section array, version 2 is the document having
+// the schema and sections, and version 3 adds the version itself and the
+// kind on all sections. The older note content is upgraded on read.
+const FormatVersion = 3
+
+// migrations upgrade the note content by one version. The migration
+// at index i upgrades the content from version i+1 to i+2.
+var migrations = []func(content []byte) ([]byte, error){
+	migrateV1,
+	migrateV2,
+}
+
+// document is the note content stored in google drive file.
+type document struct {
+	Version  int                 `json:"version"`
+	Schema   *schema.Schema      `json:"schema,omitempty"`
+	Sections []*secstore.Section `json:"sections"`
+}
+
+func marshal(doc *document) []byte {
+	doc.Version = FormatVersion
+	j, _ := json.Marshal(doc)
+	return j
+}
+
+// Empty returns the note content having no sections in the current format
+// version.
+func Empty() []byte {
+	return marshal(&document{Sections: []*secstore.Section{}})
+}
+
+func unmarshal(content []byte) (*document, error) {
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 {
+		return &document{Version: FormatVersion}, nil
+	}
+
+	content, _, err := migrate(content)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := new(document)
+	if err := json.Unmarshal(content, doc); err != nil {
+		return nil, utils.Error("error on unmarshalling sections", err)
+	}
+	return doc, nil
+}
+
+// migrate upgrades the note content to the current format version. It
+// returns the version of the content before migration.
+func migrate(content []byte) ([]byte, int, error) {
+	version, err := versionOf(content)
+	if err != nil {
+		return nil, 0, utils.Error("error on reading format version", err)
+	}
+	if version < 1 {
+		return nil, 0, fmt.Errorf("note format version %d is not valid", version)
+	}
+	if version > FormatVersion {
+		return nil, 0, fmt.Errorf("note format version %d is newer than supported version %d",
+			version, FormatVersion)
+	}
+
+	migrated := content
+	for v := version; v < FormatVersion; v++ {
+		migrated, err = migrations[v-1](migrated)
+		if err != nil {
+			msg := fmt.Sprintf("error on migrating note format from version %d", v)
+			return nil, 0, utils.Error(msg, err)
+		}
+	}
+	return migrated, version, nil
+}
+
+// versionOf returns the format version of note content. The content
+// without version is version 2, the explicit version below 1 is invalid.
+func versionOf(content []byte) (int, error) {
+	if content[0] == '[' {
+		return 1, nil
+	}
+
+	var v struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(content, &v); err != nil {
+		return 0, err
+	}
+	if v.Version == nil {
+		return 2, nil
+	}
+	if *v.Version < 1 {
+		return 0, fmt.Errorf("version %d is below 1", *v.Version)
+	}
+	return *v.Version, nil
+}
+
+// migrateV1 wraps the bare section array in the document.
+func migrateV1(content []byte) ([]byte, error) {
+	doc := map[string]json.RawMessage{
+		"sections": content,
+	}
+	return json.Marshal(doc)
+}
+
+// migrateV2 adds the format version and sets the default kind on the
+// sections written before section kinds.
+func migrateV2(content []byte) ([]byte, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+
+	// unknown section fields must be kept, so generic maps are used
+	var sections []map[string]interface{}
+	if raw, ok := doc["sections"]; ok && !bytes.Equal(raw, []byte("null")) {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		if err := decoder.Decode(&sections); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range sections {
+		if kind, _ := s["kind"].(string); len(kind) == 0 {
+			s["kind"] = secstore.KindData
+		}
+	}
+
+	j, err := json.Marshal(sections)
+	if err != nil {
+		return nil, err
+	}
+	doc["sections"] = j
+	doc["version"] = json.RawMessage("3")
+	return json.Marshal(doc)
+}
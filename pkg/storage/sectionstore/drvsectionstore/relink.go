@@ -0,0 +1,38 @@
+package drvsectionstore
+
+import (
+	"bytes"
+
+	secstore "github.com/psewda/typing/pkg/storage/sectionstore"
+)
+
+// Sections returns the sections of note content in any format version.
+func Sections(content []byte) ([]*secstore.Section, error) {
+	doc, err := unmarshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Sections, nil
+}
+
+// Relink rewrites the note ids of section links in the note content. The
+// ids map the old note id to the new one, and the links to other notes are
+// kept. The content is upgraded to the current format version.
+func Relink(content []byte, ids map[string]string) ([]byte, error) {
+	if len(bytes.TrimSpace(content)) == 0 {
+		return content, nil
+	}
+
+	doc, err := unmarshal(content)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range doc.Sections {
+		for _, l := range s.Links {
+			if id, ok := ids[l.NoteID]; ok {
+				l.NoteID = id
+			}
+		}
+	}
+	return marshal(doc), nil
+}
@@ -0,0 +1,94 @@
+package sectionstore
+
+import (
+	"errors"
+)
+
+const (
+	// KindData is the generic section kind having key/value pairs in data field.
+	KindData = "data"
+
+	// KindText is the section kind having a plain or markdown text body.
+	KindText = "text"
+
+	// KindChecklist is the section kind having a list of items with done state.
+	KindChecklist = "checklist"
+
+	// KindTable is the section kind having columns and rows of values.
+	KindTable = "table"
+
+	// KindCode is the section kind having a code block with its language.
+	KindCode = "code"
+)
+
+const (
+	// TextFormatPlain is the plain text format of text section.
+	TextFormatPlain = "plain"
+
+	// TextFormatMarkdown is the markdown format of text section.
+	TextFormatMarkdown = "markdown"
+)
+
+// Text is the payload of 'text' section kind.
+type Text struct {
+	Format string `json:"format,omitempty" validate:"omitempty,oneof=plain markdown"`
+	Body   string `json:"body,omitempty" validate:"max=20000"`
+}
+
+// ChecklistItem is the single item of 'checklist' section kind.
+type ChecklistItem struct {
+	Text string `json:"text,omitempty" validate:"required,notblank,max=500"`
+	Done bool   `json:"done"`
+}
+
+// Table is the payload of 'table' section kind. Each row must have
+// the same number of cells as the columns.
+type Table struct {
+	Columns []string   `json:"columns,omitempty" validate:"min=1,max=20,dive,max=50"`
+	Rows    [][]string `json:"rows,omitempty" validate:"max=500,dive,max=20,dive,max=2000"`
+}
+
+// Code is the payload of 'code' section kind.
+type Code struct {
+	Language string `json:"language,omitempty" validate:"max=30"`
+	Source   string `json:"source,omitempty" validate:"max=20000"`
+}
+
+// GetKind returns the section kind. If kind is not set, it
+// returns the generic 'data' kind.
+func GetKind(kind string) string {
+	if len(kind) == 0 {
+		return KindData
+	}
+	return kind
+}
+
+func checkKind(s *WritableSection) error {
+	kind := GetKind(s.Kind)
+	payloads := map[string]bool{
+		KindText:      s.Text != nil,
+		KindChecklist: s.Checklist != nil,
+		KindTable:     s.Table != nil,
+		KindCode:      s.Code != nil,
+	}
+
+	// only the payload of same kind is allowed
+	for k, found := range payloads {
+		if found && k != kind {
+			return errors.New(messages["kind.mismatch"])
+		}
+	}
+	if kind != KindData && !payloads[kind] {
+		return errors.New(messages["kind.payload"])
+	}
+
+	// all table rows must match the column count
+	if s.Table != nil {
+		for _, row := range s.Table.Rows {
+			if len(row) != len(s.Table.Columns) {
+				return errors.New(messages["rows.width"])
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package sectionstore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// SortFieldName sorts the sections by name.
+	SortFieldName = "name"
+
+	// SortFieldDateCreated sorts the sections by creation time.
+	SortFieldDateCreated = "dateCreated"
+
+	// SortFieldDateUpdated sorts the sections by last update time.
+	SortFieldDateUpdated = "dateUpdated"
+)
+
+// Query is used for filtering, sorting and projecting the sections of a
+// note. The zero value query matches all sections in their stored order.
+type Query struct {
+	// Name matches the sections having the name substring, case insensitive.
+	Name string
+
+	// Labels matches the sections having all the labels.
+	Labels []string
+
+	// Metadata and Data match the sections having all key/value pairs. The
+	// empty value only checks the key is present.
+	Metadata map[string]string
+	Data     map[string]string
+
+	CreatedBy     string
+	UpdatedBy     string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+
+	// Sort is the sort field name. The '-' prefix sorts in descending order.
+	Sort string
+
+	// Fields are the json field names to be returned in sections. The
+	// empty fields return all fields.
+	Fields []string
+}
+
+// Validate checks the query values. It returns error when the sort
+// field or any projection field is not supported.
+func (q *Query) Validate() error {
+	switch strings.TrimPrefix(q.Sort, "-") {
+	case "", SortFieldName, SortFieldDateCreated, SortFieldDateUpdated:
+	default:
+		return errors.New("sort must be one of 'name', 'dateCreated' or 'dateUpdated'")
+	}
+
+	for _, f := range q.Fields {
+		if _, ok := projections[f]; !ok {
+			return fmt.Errorf("field '%s' is not a section field", f)
+		}
+	}
+	return nil
+}
+
+// Apply filters, sorts and projects the sections. It is used by the
+// sectionstores which can't run the query on the storage itself.
+func (q *Query) Apply(sections []*Section) []*Section {
+	if q == nil {
+		return sections
+	}
+
+	// the empty result is sent as empty json array
+	filtered := make([]*Section, 0)
+	for _, s := range sections {
+		if q.match(s) {
+			filtered = append(filtered, s)
+		}
+	}
+
+	if len(q.Sort) > 0 {
+		desc := strings.HasPrefix(q.Sort, "-")
+		field := strings.TrimPrefix(q.Sort, "-")
+		sort.SliceStable(filtered, func(i, j int) bool {
+			if desc {
+				return less(filtered[j], filtered[i], field)
+			}
+			return less(filtered[i], filtered[j], field)
+		})
+	}
+
+	if len(q.Fields) > 0 {
+		for i, s := range filtered {
+			filtered[i] = project(s, q.Fields)
+		}
+	}
+	return filtered
+}
+
+func (q *Query) match(s *Section) bool {
+	if len(q.Name) > 0 && !strings.Contains(strings.ToLower(s.Name), strings.ToLower(q.Name)) {
+		return false
+	}
+	for _, l := range q.Labels {
+		if !hasLabel(s.Labels, l) {
+			return false
+		}
+	}
+	if !hasPairs(s.Metadata, q.Metadata) || !hasPairs(s.Data, q.Data) {
+		return false
+	}
+	if len(q.CreatedBy) > 0 && q.CreatedBy != s.CreatedBy {
+		return false
+	}
+	if len(q.UpdatedBy) > 0 && q.UpdatedBy != s.UpdatedBy {
+		return false
+	}
+	return inRange(s.DateCreated, q.CreatedAfter, q.CreatedBefore) &&
+		inRange(s.DateUpdated, q.UpdatedAfter, q.UpdatedBefore)
+}
+
+func hasLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if strings.EqualFold(l, label) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPairs(m, pairs map[string]string) bool {
+	for k, v := range pairs {
+		value, ok := m[k]
+		if !ok || (len(v) > 0 && v != value) {
+			return false
+		}
+	}
+	return true
+}
+
+func inRange(t *time.Time, after, before time.Time) bool {
+	if after.IsZero() && before.IsZero() {
+		return true
+	}
+	if t == nil {
+		return false
+	}
+	if !after.IsZero() && !t.After(after) {
+		return false
+	}
+	if !before.IsZero() && !t.Before(before) {
+		return false
+	}
+	return true
+}
+
+func less(a, b *Section, field string) bool {
+	switch field {
+	case SortFieldDateCreated:
+		return timeOf(a.DateCreated).Before(timeOf(b.DateCreated))
+	case SortFieldDateUpdated:
+		return timeOf(a.DateUpdated).Before(timeOf(b.DateUpdated))
+	default:
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	}
+}
+
+func timeOf(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+var projections = map[string]func(dst, src *Section){
+	"id":          func(dst, src *Section) { dst.ID = src.ID },
+	"name":        func(dst, src *Section) { dst.Name = src.Name },
+	"labels":      func(dst, src *Section) { dst.Labels = src.Labels },
+	"metadata":    func(dst, src *Section) { dst.Metadata = src.Metadata },
+	"data":        func(dst, src *Section) { dst.Data = src.Data },
+	"kind":        func(dst, src *Section) { dst.Kind = src.Kind },
+	"text":        func(dst, src *Section) { dst.Text = src.Text },
+	"checklist":   func(dst, src *Section) { dst.Checklist = src.Checklist },
+	"table":       func(dst, src *Section) { dst.Table = src.Table },
+	"code":        func(dst, src *Section) { dst.Code = src.Code },
+	"links":       func(dst, src *Section) { dst.Links = src.Links },
+	"dateCreated": func(dst, src *Section) { dst.DateCreated = src.DateCreated },
+	"dateUpdated": func(dst, src *Section) { dst.DateUpdated = src.DateUpdated },
+	"createdBy":   func(dst, src *Section) { dst.CreatedBy = src.CreatedBy },
+	"updatedBy":   func(dst, src *Section) { dst.UpdatedBy = src.UpdatedBy },
+}
+
+func project(s *Section, fields []string) *Section {
+	projected := new(Section)
+	for _, f := range fields {
+		if fn, ok := projections[f]; ok {
+			fn(projected, s)
+		}
+	}
+	return projected
+}
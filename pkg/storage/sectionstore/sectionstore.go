@@ -1,25 +1,41 @@
 package sectionstore
 
 import (
+	"context"
+	"time"
+
 	"github.com/psewda/typing/internal/utils"
+	"github.com/psewda/typing/pkg/schema"
 )
 
 // Sectionstore is the base interface having all operations on section.
 type Sectionstore interface {
 	// Create adds a new section in the note.
-	Create(nid string, s *WritableSection) (*Section, error)
+	Create(ctx context.Context, nid string, s *WritableSection) (*Section, error)
 
-	// GetAll fetches all sections from the note.
-	GetAll(nid string) ([]*Section, error)
+	// GetAll fetches all sections from the note which match the
+	// query. The nil query returns all sections.
+	GetAll(ctx context.Context, nid string, q *Query) ([]*Section, error)
 
 	// Get returns a single section from the note.
-	Get(nid, sid string) (*Section, error)
+	Get(ctx context.Context, nid, sid string) (*Section, error)
 
 	// Update modifies the section and saves it back in the note.
-	Update(nid, sid string, s *WritableSection) (*Section, error)
+	Update(ctx context.Context, nid, sid string, s *WritableSection) (*Section, error)
 
 	// Delete removes the section from note.
-	Delete(nid, sid string) error
+	Delete(ctx context.Context, nid, sid string) error
+
+	// GetSchema returns the schema of section data declared by the note.
+	GetSchema(ctx context.Context, nid string) (*schema.Schema, error)
+
+	// Batch applies all create, update and delete operations on the note
+	// atomically. If any operation fails, none of them is saved.
+	Batch(ctx context.Context, nid string, ops []*Operation) ([]*OperationResult, error)
+
+	// SetSchema declares the schema of section data in the note. All existing
+	// sections must satisfy the schema. The nil schema removes the declaration.
+	SetSchema(ctx context.Context, nid string, s *schema.Schema) error
 }
 
 // WritableSection is used for creating and updating section.
@@ -28,21 +44,69 @@ type WritableSection struct {
 	Labels   []string          `json:"labels,omitempty" validate:"max=5,dive,max=20"`
 	Metadata map[string]string `json:"metadata,omitempty" validate:"max=20,dive,keys,max=20,endkeys,max=100"`
 	Data     map[string]string `json:"data,omitempty" validate:"max=50,dive,keys,max=50,endkeys,max=2000"`
+
+	Kind      string           `json:"kind,omitempty" validate:"omitempty,oneof=data text checklist table code"`
+	Text      *Text            `json:"text,omitempty"`
+	Checklist []*ChecklistItem `json:"checklist,omitempty" validate:"max=100,dive,required"`
+	Table     *Table           `json:"table,omitempty"`
+	Code      *Code            `json:"code,omitempty"`
+
+	Links []*Link `json:"links,omitempty" validate:"max=50,dive,required"`
+}
+
+// Link is the reference from section to another note or its section.
+type Link struct {
+	NoteID    string `json:"noteId,omitempty" validate:"required,notblank,max=50"`
+	SectionID string `json:"sectionId,omitempty" validate:"max=50"`
 }
 
 // Validate checks all validation rules on writable section fields. It returns
-// error on any validation failure.
+// error on any validation failure. The kind specific payload must match
+// with the section kind.
 func (s *WritableSection) Validate() error {
-	return utils.ValidateStruct(s, messages)
+	if err := utils.ValidateStruct(s, messages); err != nil {
+		return err
+	}
+	return checkKind(s)
 }
 
-// Section represents full detail about section.
+// Section represents full detail about section. The sections created before
+// timestamps support have no dates and authors.
 type Section struct {
 	ID       string            `json:"id,omitempty"`
 	Name     string            `json:"name,omitempty"`
 	Labels   []string          `json:"labels,omitempty"`
 	Metadata map[string]string `json:"metadata,omitempty"`
 	Data     map[string]string `json:"data,omitempty"`
+
+	Kind      string           `json:"kind,omitempty"`
+	Text      *Text            `json:"text,omitempty"`
+	Checklist []*ChecklistItem `json:"checklist,omitempty"`
+	Table     *Table           `json:"table,omitempty"`
+	Code      *Code            `json:"code,omitempty"`
+
+	Links []*Link `json:"links,omitempty"`
+
+	DateCreated *time.Time `json:"dateCreated,omitempty"`
+	DateUpdated *time.Time `json:"dateUpdated,omitempty"`
+	CreatedBy   string     `json:"createdBy,omitempty"`
+	UpdatedBy   string     `json:"updatedBy,omitempty"`
+}
+
+// Writable returns the writable fields of the section.
+func (s *Section) Writable() *WritableSection {
+	return &WritableSection{
+		Name:      s.Name,
+		Labels:    s.Labels,
+		Metadata:  s.Metadata,
+		Data:      s.Data,
+		Kind:      s.Kind,
+		Text:      s.Text,
+		Checklist: s.Checklist,
+		Table:     s.Table,
+		Code:      s.Code,
+		Links:     s.Links,
+	}
 }
 
 var messages map[string]string
@@ -58,4 +122,28 @@ func init() {
 	messages["metadata.item.max"] = "metadata key and value must be less than 20 and 100 chars respectively"
 	messages["data.max"] = "data count can't be more than 50"
 	messages["data.item.max"] = "data key and value must be less than 50 and 2000 chars respectively"
+	messages["kind.oneof"] = "kind must be one of 'data', 'text', 'checklist', 'table' or 'code'"
+	messages["kind.mismatch"] = "section payload doesn't match with the section kind"
+	messages["kind.payload"] = "section payload is required for the section kind"
+	messages["format.oneof"] = "text format must be either 'plain' or 'markdown'"
+	messages["body.max"] = "text body must be less than 20000 chars"
+	messages["checklist.max"] = "checklist item count can't be more than 100"
+	messages["checklist.item.required"] = "checklist item can't be null"
+	messages["text.required"] = "checklist item text is required field"
+	messages["text.notblank"] = "checklist item text can't be empty value"
+	messages["text.max"] = "checklist item text must be less than 500 chars"
+	messages["columns.min"] = "table must have at least one column"
+	messages["columns.max"] = "table column count can't be more than 20"
+	messages["columns.item.max"] = "table column must be less than 50 chars"
+	messages["rows.max"] = "table row count can't be more than 500"
+	messages["rows.item.max"] = "table row cell count can't be more than 20 and cell must be less than 2000 chars"
+	messages["rows.width"] = "table row cell count must be same as column count"
+	messages["language.max"] = "code language must be less than 30 chars"
+	messages["source.max"] = "code source must be less than 20000 chars"
+	messages["links.max"] = "link count can't be more than 50"
+	messages["links.item.required"] = "link can't be null"
+	messages["noteid.required"] = "link note id is required field"
+	messages["noteid.notblank"] = "link note id can't be empty value"
+	messages["noteid.max"] = "link note id must be less than 50 chars"
+	messages["sectionid.max"] = "link section id must be less than 50 chars"
 }
@@ -0,0 +1,13 @@
+package types
+
+import (
+	"github.com/psewda/typing/pkg/breaker"
+	"github.com/psewda/typing/pkg/storage/notelock"
+)
+
+// HealthValue represents health value.
+type HealthValue struct {
+	Status   string           `json:"status"`
+	Backends []breaker.Status `json:"backends"`
+	Locks    *notelock.Stats  `json:"locks,omitempty"`
+}